@@ -33,9 +33,24 @@
 package flattrack
 
 import (
+	"fmt"
+	"os"
+
 	"gitlab.com/flattrack/flattrack/internal/flattrack"
 )
 
 func Run() {
-	flattrack.NewManager().Init().Run()
+	if len(os.Args) > 1 {
+		if err := flattrack.RunCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	manager, err := flattrack.NewManager().Init()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	manager.Run()
 }
@@ -1,7 +1,7 @@
 #!/bin/bash
 
 if [ -z "$FT_TOKEN" ]; then
-    echo "error: \$FT_TOKEN must be set to authenticate" > /dev/stderr
+    echo "error: \$FT_TOKEN must be set to authenticate, to either a personal access token or the token from logging in" > /dev/stderr
     exit 1
 fi
 
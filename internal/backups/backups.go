@@ -0,0 +1,316 @@
+/*
+  backups
+    export and import all the data of an instance as a portable archive
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package backups
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/migrations"
+	"gitlab.com/flattrack/flattrack/internal/system"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// BackupKind is the kind in the first line of every backup archive
+	BackupKind = "FlatTrackBackup"
+	// BackupFormatVersion is the version of the layout of backup archives,
+	// which changes only when the archive itself changes and not the tables inside it
+	BackupFormatVersion = 1
+	// BackupContentType is the media type of backup archives, being a JSON object per line
+	BackupContentType = "application/x-ndjson"
+)
+
+var (
+	ErrBackupInvalid                  = fmt.Errorf("Unable to read backup, as it is not a FlatTrack backup")
+	ErrBackupFormatVersionUnsupported = fmt.Errorf("Unable to restore backup, as it was made in a format which this version of FlatTrack doesn't support")
+	ErrBackupMigrationVersionMismatch = fmt.Errorf("Unable to restore backup, as it was made with a different database schema version")
+	ErrBackupDatabaseDirty            = fmt.Errorf("Unable to use backups, as the database schema failed to migrate")
+	ErrBackupUnknownTable             = fmt.Errorf("Unable to restore backup, as it contains a table which is not able to be restored")
+)
+
+// backupTables ...
+// the tables in a backup archive, in an order where each table only references the ones before it
+var backupTables = []string{
+	"flats",
+	"users",
+	"groups",
+	"user_to_groups",
+	"user_creation_secret",
+	"user_totp",
+	"user_recovery_code",
+	"user_access_token",
+	"settings",
+	"shopping_list",
+	"shopping_list_tag",
+	"shopping_item",
+	"shopping_attachment",
+	"task",
+	"task_occurrence",
+	"expense",
+	"expense_share",
+}
+
+// transientTables ...
+// the tables which are not backed up, as they only hold short-lived state.
+// They are cleared on restore, since they reference the tables which are replaced
+var transientTables = []string{
+	"user_session",
+	"user_auth_challenge",
+	"user_auth_attempt",
+	"user_password_reset_secret",
+	"oidc_auth_state",
+}
+
+type Manager struct {
+	db         *sql.DB
+	migrations *migrations.Manager
+	system     *system.Manager
+}
+
+func NewManager(db *sql.DB, migrations *migrations.Manager, system *system.Manager) *Manager {
+	return &Manager{
+		db:         db,
+		migrations: migrations,
+		system:     system,
+	}
+}
+
+// migrationVersion ...
+// returns the schema version of the database, which backups must match
+func (m *Manager) migrationVersion() (version uint, err error) {
+	version, dirty, err := m.migrations.Version()
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, ErrBackupDatabaseDirty
+	}
+	return version, nil
+}
+
+// tableColumns ...
+// returns the lowercase names and types of the columns of a table.
+// Names are lowercase like the names of the columns of Postgres tables, so that archives are the same for both
+func (m *Manager) tableColumns(table string) (columns map[string]string, err error) {
+	rows, err := m.db.Query(database.DialectOf(m.db).TableColumnTypes(), table)
+	if err != nil {
+		return map[string]string{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	columns = map[string]string{}
+	for rows.Next() {
+		var name, columnType string
+		if err := rows.Scan(&name, &columnType); err != nil {
+			return map[string]string{}, err
+		}
+		columns[name] = columnType
+	}
+	return columns, rows.Err()
+}
+
+// selectRowsStatement ...
+// returns the statement which selects every row of a table as a JSON object
+func (m *Manager) selectRowsStatement(table string) (sqlStatement string, err error) {
+	columns, err := m.tableColumns(table)
+	if err != nil {
+		return "", err
+	}
+	return database.DialectOf(m.db).SelectRowsAsJSON(table, columns), nil
+}
+
+// insertRowStatement ...
+// returns the statement which inserts a row of a table from a JSON object
+func (m *Manager) insertRowStatement(table string) (sqlStatement string, err error) {
+	columns, err := m.tableColumns(table)
+	if err != nil {
+		return "", err
+	}
+	return database.DialectOf(m.db).InsertRowFromJSON(table, columns), nil
+}
+
+// writeTable ...
+// writes every row of a table to an archive, as read by a transaction
+func (m *Manager) writeTable(tx *sql.Tx, encoder *json.Encoder, table string) (count int, err error) {
+	sqlStatement, err := m.selectRowsStatement(table)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query(sqlStatement)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		var row []byte
+		if err := rows.Scan(&row); err != nil {
+			return 0, err
+		}
+		if err := encoder.Encode(types.BackupRowSpec{Table: table, Row: json.RawMessage(row)}); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
+
+// Write ...
+// writes a backup archive of all the flats of the instance.
+// The first line describes the archive and each following line is a row of a table
+func (m *Manager) Write(w io.Writer) (backup types.BackupSpec, err error) {
+	return m.write(w, backupTables)
+}
+
+// write ...
+// writes an archive of the rows of tables
+func (m *Manager) write(w io.Writer, tables []string) (backup types.BackupSpec, err error) {
+	version, err := m.migrationVersion()
+	if err != nil {
+		return types.BackupSpec{}, err
+	}
+	backup = types.BackupSpec{
+		Kind:              BackupKind,
+		FormatVersion:     BackupFormatVersion,
+		MigrationVersion:  version,
+		BuildVersion:      common.GetAppBuildVersion(),
+		CreationTimestamp: time.Now().Unix(),
+	}
+	// every table is read in one transaction, so that the archive holds the rows as they were at a single point in time
+	tx, err := m.db.BeginTx(context.Background(), database.DialectOf(m.db).SnapshotTxOptions())
+	if err != nil {
+		return types.BackupSpec{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to end backup transaction", "error", err)
+		}
+	}()
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(backup); err != nil {
+		return types.BackupSpec{}, err
+	}
+	for _, table := range tables {
+		count, err := m.writeTable(tx, encoder, table)
+		if err != nil {
+			return types.BackupSpec{}, fmt.Errorf("failed to back up table '%v': %w", table, err)
+		}
+		slog.Debug("Backed up table", "table", table, "rows", count)
+	}
+	return backup, nil
+}
+
+// readHeader ...
+// reads the first line of a backup archive, checking that it's able to be restored
+func (m *Manager) readHeader(decoder *json.Decoder) (backup types.BackupSpec, err error) {
+	if err := decoder.Decode(&backup); err != nil {
+		return types.BackupSpec{}, ErrBackupInvalid
+	}
+	if backup.Kind != BackupKind {
+		return types.BackupSpec{}, ErrBackupInvalid
+	}
+	if backup.FormatVersion != BackupFormatVersion {
+		return types.BackupSpec{}, ErrBackupFormatVersionUnsupported
+	}
+	version, err := m.migrationVersion()
+	if err != nil {
+		return types.BackupSpec{}, err
+	}
+	if backup.MigrationVersion != version {
+		return types.BackupSpec{}, fmt.Errorf("%w (backup %v, database %v)", ErrBackupMigrationVersionMismatch, backup.MigrationVersion, version)
+	}
+	return backup, nil
+}
+
+// Restore ...
+// replaces all the data of the instance with a backup archive.
+// Nothing is changed unless the whole archive is restored, and all user accounts are signed out
+func (m *Manager) Restore(r io.Reader) (backup types.BackupSpec, err error) {
+	return m.restore(r, backupTables)
+}
+
+// restore ...
+// replaces the rows of tables with those of an archive
+func (m *Manager) restore(r io.Reader, tables []string) (backup types.BackupSpec, err error) {
+	decoder := json.NewDecoder(r)
+	backup, err = m.readHeader(decoder)
+	if err != nil {
+		return types.BackupSpec{}, err
+	}
+	insertStatements := map[string]string{}
+	for _, table := range tables {
+		if insertStatements[table], err = m.insertRowStatement(table); err != nil {
+			return types.BackupSpec{}, err
+		}
+	}
+	tx, err := m.db.Begin()
+	if err != nil {
+		return types.BackupSpec{}, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback restore", "error", err)
+		}
+	}()
+	// tables are cleared in reverse, so that nothing still references the rows being removed
+	clearTables := slices.Clone(tables)
+	slices.Reverse(clearTables)
+	for _, table := range append(slices.Clone(transientTables), clearTables...) {
+		if _, err := tx.Exec(fmt.Sprintf(`delete from %v`, table)); err != nil {
+			return types.BackupSpec{}, fmt.Errorf("failed to clear table '%v': %w", table, err)
+		}
+	}
+	counts := map[string]int{}
+	for {
+		var row types.BackupRowSpec
+		if err := decoder.Decode(&row); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return types.BackupSpec{}, ErrBackupInvalid
+		}
+		if !slices.Contains(tables, row.Table) {
+			return types.BackupSpec{}, fmt.Errorf("%w: '%v'", ErrBackupUnknownTable, row.Table)
+		}
+		if _, err := tx.Exec(insertStatements[row.Table], string(row.Row)); err != nil {
+			return types.BackupSpec{}, fmt.Errorf("failed to restore row of table '%v': %w", row.Table, err)
+		}
+		counts[row.Table]++
+	}
+	if err := tx.Commit(); err != nil {
+		return types.BackupSpec{}, err
+	}
+	slog.Info("Restored backup", "creationTimestamp", backup.CreationTimestamp, "rows", counts)
+	return backup, nil
+}
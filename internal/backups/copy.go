@@ -0,0 +1,118 @@
+/*
+  backups
+    copy
+      move all the data of an instance from one database into another
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package backups
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+var (
+	ErrCopyMigrationVersionMismatch = fmt.Errorf("Unable to copy database, as the source and destination have different database schema versions")
+	ErrCopyDestinationNotEmpty      = fmt.Errorf("Unable to copy database, as the destination already has user accounts")
+	ErrCopyRowCountMismatch         = fmt.Errorf("Unable to verify copy, as a table has a different number of rows in the destination")
+)
+
+// copyTables ...
+// the tables which are copied between databases, in an order where each table only references the ones before it.
+// Along with the tables which are backed up, the details of the instance itself are copied, so that it keeps it's id
+var copyTables = append([]string{"system"}, backupTables...)
+
+// countRows ...
+// returns the number of rows in a table
+func (m *Manager) countRows(table string) (count int, err error) {
+	sqlStatement := fmt.Sprintf(`select count(*) from %v`, table)
+	rows, err := m.db.Query(sqlStatement)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	if err := rows.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, rows.Err()
+}
+
+// CopyTo ...
+// replaces all the data in the destination database with the data of this one,
+// such as when moving between Postgres and SQLite.
+// Rows are streamed as a backup archive, and the number of rows of each table are compared once copied
+func (m *Manager) CopyTo(destination *Manager) (counts map[string]int, err error) {
+	sourceVersion, err := m.migrationVersion()
+	if err != nil {
+		return map[string]int{}, fmt.Errorf("failed to get source database version: %w", err)
+	}
+	destinationVersion, err := destination.migrationVersion()
+	if err != nil {
+		return map[string]int{}, fmt.Errorf("failed to get destination database version: %w", err)
+	}
+	if sourceVersion != destinationVersion {
+		return map[string]int{}, fmt.Errorf("%w (source %v, destination %v)", ErrCopyMigrationVersionMismatch, sourceVersion, destinationVersion)
+	}
+	users, err := destination.countRows("users")
+	if err != nil {
+		return map[string]int{}, err
+	}
+	if users > 0 {
+		return map[string]int{}, ErrCopyDestinationNotEmpty
+	}
+
+	reader, writer := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		_, err := m.write(writer, copyTables)
+		written <- err
+		writer.CloseWithError(err)
+	}()
+	_, err = destination.restore(reader, copyTables)
+	// the source stops writing once nothing is reading
+	reader.CloseWithError(err)
+	// failures to write are reported first, as the destination only sees an incomplete archive
+	if writeErr := <-written; writeErr != nil && (err == nil || !errors.Is(writeErr, err)) {
+		return map[string]int{}, fmt.Errorf("failed to read source database: %w", writeErr)
+	}
+	if err != nil {
+		return map[string]int{}, fmt.Errorf("failed to write destination database: %w", err)
+	}
+
+	counts = map[string]int{}
+	for _, table := range copyTables {
+		sourceCount, err := m.countRows(table)
+		if err != nil {
+			return map[string]int{}, err
+		}
+		destinationCount, err := destination.countRows(table)
+		if err != nil {
+			return map[string]int{}, err
+		}
+		if sourceCount != destinationCount {
+			return map[string]int{}, fmt.Errorf("%w: '%v' (source %v, destination %v)", ErrCopyRowCountMismatch, table, sourceCount, destinationCount)
+		}
+		counts[table] = sourceCount
+	}
+	return counts, nil
+}
@@ -0,0 +1,156 @@
+/*
+  backups
+    scheduled
+      nightly backups into file storage, keeping the latest days and weeks
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package backups
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/files"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// backupFilePrefix begins the names of backups in file storage
+	backupFilePrefix = "backup"
+	// backupFileTimeFormat is the time in the names of backups in file storage, which sorts by age
+	backupFileTimeFormat = "20060102T150405Z"
+	// backupFileExtension ends the names of backups in file storage
+	backupFileExtension = ".ndjson"
+)
+
+// backupFileName ...
+// returns the name of a backup in file storage which is made at a time
+func backupFileName(t time.Time) string {
+	return t.UTC().Format(backupFileTimeFormat) + backupFileExtension
+}
+
+// backupFileTime ...
+// returns the time which a backup in file storage was made at, from it's name
+func backupFileTime(name string) (time.Time, bool) {
+	if !strings.HasSuffix(name, backupFileExtension) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(backupFileTimeFormat, strings.TrimSuffix(name, backupFileExtension))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// backupFilesToDelete ...
+// given the names of backups in file storage, returns the ones which aren't the latest of one of
+// the latest keepDaily days or keepWeekly weeks.
+// Files which aren't named like backups are never deleted
+func backupFilesToDelete(names []string, keepDaily int, keepWeekly int) (toDelete []string) {
+	backupTimes := map[string]time.Time{}
+	backupNames := []string{}
+	for _, name := range names {
+		t, ok := backupFileTime(name)
+		if !ok {
+			continue
+		}
+		backupTimes[name] = t
+		backupNames = append(backupNames, name)
+	}
+	// newest first, so that the first backup seen of a day or week is the one kept
+	slices.SortFunc(backupNames, func(a, b string) int {
+		return backupTimes[b].Compare(backupTimes[a])
+	})
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for _, name := range backupNames {
+		t := backupTimes[name]
+		keep := false
+		day := t.Format(time.DateOnly)
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep = true
+		}
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%v-%v", year, week)
+		if !weeks[weekKey] && len(weeks) < keepWeekly {
+			weeks[weekKey] = true
+			keep = true
+		}
+		if !keep {
+			toDelete = append(toDelete, name)
+		}
+	}
+	return toDelete
+}
+
+// writeToFileStore ...
+// writes a backup into file storage and deletes the backups which are no longer kept
+func (m *Manager) writeToFileStore(fileAccess files.FileAccess, keepDaily int, keepWeekly int) (lastRun types.BackupLastRun, err error) {
+	now := time.Now()
+	lastRun = types.BackupLastRun{
+		Time:  now.Unix(),
+		State: types.SchedulerRunStateFailure,
+		Name:  fmt.Sprintf("%v-%v", backupFilePrefix, backupFileName(now)),
+	}
+	var buf bytes.Buffer
+	if _, err := m.Write(&buf); err != nil {
+		return lastRun, err
+	}
+	lastRun.Size = int64(buf.Len())
+	if err := fileAccess.Put(backupFileName(now), buf.Bytes()); err != nil {
+		return lastRun, err
+	}
+	lastRun.State = types.SchedulerRunStateComplete
+	names, err := fileAccess.List()
+	if err != nil {
+		return lastRun, err
+	}
+	for _, name := range backupFilesToDelete(names, keepDaily, keepWeekly) {
+		if err := fileAccess.Delete(name); err != nil {
+			return lastRun, err
+		}
+	}
+	return lastRun, nil
+}
+
+// Scheduled ...
+// returns scheduled work which writes a backup into file storage, keeping the latest backup
+// of each of the latest keepDaily days and keepWeekly weeks, and records how it went
+func (m *Manager) Scheduled(fileAccess files.FileAccess, keepDaily int, keepWeekly int) func() error {
+	fileAccess = fileAccess.WithPrefix(backupFilePrefix)
+	return func() error {
+		if err := m.system.SetBackupLastRun(types.BackupLastRun{
+			Time:  time.Now().Unix(),
+			State: types.SchedulerRunStateRunning,
+		}); err != nil {
+			return err
+		}
+		lastRun, err := m.writeToFileStore(fileAccess, keepDaily, keepWeekly)
+		if errLastRun := m.system.SetBackupLastRun(lastRun); errLastRun != nil {
+			slog.Error("failed to record backup", "error", errLastRun)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write scheduled backup: %w", err)
+		}
+		slog.Info("Wrote scheduled backup", "name", lastRun.Name, "size", lastRun.Size)
+		return nil
+	}
+}
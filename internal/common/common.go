@@ -19,7 +19,15 @@
 package common
 
 import (
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	// #nosec G505 -- authenticator apps use SHA-1 for time-based one-time passwords
+	"crypto/sha1"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
@@ -30,8 +38,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/argon2"
 )
 
 // AppVars ...
@@ -231,6 +242,18 @@ func GetAppEmbeddedHTML() (output string) {
 	return GetEnvOrDefault("APP_EMBEDDED_HTML", "")
 }
 
+// GetAppFileStorage ...
+// return where files are stored, either minio or local
+func GetAppFileStorage() (output string) {
+	return GetEnvOrDefault("APP_FILE_STORAGE", "minio")
+}
+
+// GetAppFileStoragePath ...
+// return the directory for local file storage
+func GetAppFileStoragePath() (output string) {
+	return GetEnvOrDefault("APP_FILE_STORAGE_PATH", "")
+}
+
 // GetAppMinioAccessKey ...
 // return the accessKey for file storage
 func GetAppMinioAccessKey() (output string) {
@@ -272,11 +295,122 @@ func GetSchedulerEndpointSecret() string {
 	return GetEnvOrDefault("APP_SCHEDULER_ENDPOINT_SECRET", "")
 }
 
+// GetInstanceAdminSecret the shared secret to require for managing the flats of an instance
+func GetInstanceAdminSecret() string {
+	return GetEnvOrDefault("APP_INSTANCE_ADMIN_SECRET", "")
+}
+
 // GetRegistrationSecret the shared secret to require for setting up an instance
 func GetRegistrationSecret() string {
 	return GetEnvOrDefault("APP_REGISTRATION_SECRET", "")
 }
 
+// GetOIDCIssuer ...
+// return the issuer URL of the OpenID Connect identity provider to log in with
+func GetOIDCIssuer() string {
+	return GetEnvOrDefault("APP_OIDC_ISSUER", "")
+}
+
+// GetOIDCClientID ...
+// return the client id registered with the OpenID Connect identity provider
+func GetOIDCClientID() string {
+	return GetEnvOrDefault("APP_OIDC_CLIENT_ID", "")
+}
+
+// GetOIDCClientSecret ...
+// return the client secret registered with the OpenID Connect identity provider
+func GetOIDCClientSecret() string {
+	return GetEnvOrDefault("APP_OIDC_CLIENT_SECRET", "")
+}
+
+// GetOIDCAutoProvision ...
+// return whether to create accounts for unknown users of the OpenID Connect identity provider
+func GetOIDCAutoProvision() bool {
+	return GetEnvOrDefault("APP_OIDC_AUTO_PROVISION", "false") == "true"
+}
+
+// GetOIDCGroupsClaim ...
+// return the claim of the ID token which lists the groups of a user
+func GetOIDCGroupsClaim() string {
+	return GetEnvOrDefault("APP_OIDC_GROUPS_CLAIM", "groups")
+}
+
+// GetOIDCAdminGroup ...
+// return the identity provider group whose members are made admins
+func GetOIDCAdminGroup() string {
+	return GetEnvOrDefault("APP_OIDC_ADMIN_GROUP", "")
+}
+
+// GetAuthMaxFailuresPerEmail ...
+// return how many failed logins to an email are allowed before it's locked out, or 0 to never lock out
+func GetAuthMaxFailuresPerEmail() int {
+	return parseCountOrDefault(GetEnvOrDefault("APP_AUTH_MAX_FAILURES_EMAIL", "5"), 5)
+}
+
+// GetAuthMaxFailuresPerIP ...
+// return how many failed logins from an IP address are allowed before it's locked out, or 0 to never lock out
+func GetAuthMaxFailuresPerIP() int {
+	return parseCountOrDefault(GetEnvOrDefault("APP_AUTH_MAX_FAILURES_IP", "20"), 20)
+}
+
+// GetAuthFailureWindow ...
+// return how long failed logins are counted for
+func GetAuthFailureWindow() time.Duration {
+	return parseDurationOrDefault(GetEnvOrDefault("APP_AUTH_FAILURE_WINDOW", "15m"), 15*time.Minute)
+}
+
+// GetAuthLockoutDuration ...
+// return how long an email or IP address is locked out for after too many failed logins
+func GetAuthLockoutDuration() time.Duration {
+	return parseDurationOrDefault(GetEnvOrDefault("APP_AUTH_LOCKOUT_DURATION", "15m"), 15*time.Minute)
+}
+
+// GetAttachmentMaxBytes ...
+// return the largest photo or receipt which is able to be uploaded, in bytes
+func GetAttachmentMaxBytes() int {
+	return parseCountOrDefault(GetEnvOrDefault("APP_ATTACHMENT_MAX_BYTES", "10485760"), 10485760)
+}
+
+// GetBackupKeepDaily ...
+// return how many daily backups are kept in file storage, or 0 to keep none
+func GetBackupKeepDaily() int {
+	return parseCountOrDefault(GetEnvOrDefault("APP_BACKUP_KEEP_DAILY", "7"), 7)
+}
+
+// GetBackupKeepWeekly ...
+// return how many weekly backups are kept in file storage, or 0 to keep none
+func GetBackupKeepWeekly() int {
+	return parseCountOrDefault(GetEnvOrDefault("APP_BACKUP_KEEP_WEEKLY", "4"), 4)
+}
+
+// GetDBRequestTimeout ...
+// return how long the database queries of a request may take altogether, before they are cancelled
+func GetDBRequestTimeout() time.Duration {
+	return parseDurationOrDefault(GetEnvOrDefault("APP_DB_REQUEST_TIMEOUT", "10s"), 10*time.Second)
+}
+
+// parseCountOrDefault ...
+// return a value as a count, falling back to the default when it isn't one
+func parseCountOrDefault(value string, defaultValue int) int {
+	output, err := strconv.Atoi(value)
+	if err != nil || output < 0 {
+		slog.Error("Failed to parse count", "value", value)
+		return defaultValue
+	}
+	return output
+}
+
+// parseDurationOrDefault ...
+// return a value as a duration (e.g. 15m), falling back to the default when it isn't one
+func parseDurationOrDefault(value string, defaultValue time.Duration) time.Duration {
+	output, err := time.ParseDuration(value)
+	if err != nil || output <= 0 {
+		slog.Error("Failed to parse duration", "value", value)
+		return defaultValue
+	}
+	return output
+}
+
 // GetMaintenanceMode whether to make the instance unavailable
 func GetMaintenanceMode() bool {
 	return GetEnvOrDefault("APP_MAINTENANCE_MODE", "") == "true"
@@ -403,6 +537,155 @@ func HashSHA512(input string) (output string) {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
+// password hash parameters for argon2id.
+// Changing these causes hashes to be upgraded on the next login
+const (
+	passwordHashAlgorithmArgon2id = "argon2id"
+	passwordHashAlgorithmSHA512   = "sha512"
+	passwordHashArgon2Memory      = 19 * 1024
+	passwordHashArgon2Time        = 2
+	passwordHashArgon2Threads     = 1
+	passwordHashArgon2KeyLength   = 32
+	passwordHashArgon2SaltLength  = 16
+)
+
+// HashPassword ...
+// given a password, return an argon2id hash of it encoded with it's algorithm and parameters
+func HashPassword(password string) (output string, err error) {
+	salt := make([]byte, passwordHashArgon2SaltLength)
+	if _, err := cryptorand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey([]byte(password), salt, passwordHashArgon2Time, passwordHashArgon2Memory, passwordHashArgon2Threads, passwordHashArgon2KeyLength)
+	return fmt.Sprintf("$%v$v=%v$m=%v,t=%v,p=%v$%v$%v",
+		passwordHashAlgorithmArgon2id, argon2.Version,
+		passwordHashArgon2Memory, passwordHashArgon2Time, passwordHashArgon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// CheckPasswordHash ...
+// given a password and an encoded hash, return if they match and if the hash should be replaced
+// with one from HashPassword. Legacy unsalted SHA-512 hashes, with or without the sha512 prefix, are still accepted
+func CheckPasswordHash(password string, encoded string) (matches bool, needsRehash bool, err error) {
+	if encoded == "" {
+		return false, false, nil
+	}
+	parts := strings.Split(encoded, "$")
+	switch {
+	case len(parts) == 1:
+		return subtle.ConstantTimeCompare([]byte(encoded), []byte(HashSHA512(password))) == 1, true, nil
+	case len(parts) == 3 && parts[1] == passwordHashAlgorithmSHA512:
+		return subtle.ConstantTimeCompare([]byte(parts[2]), []byte(HashSHA512(password))) == 1, true, nil
+	case len(parts) == 6 && parts[1] == passwordHashAlgorithmArgon2id:
+		var version int
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+			return false, false, err
+		}
+		var memory, iterations uint32
+		var threads uint8
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+			return false, false, err
+		}
+		salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+		if err != nil {
+			return false, false, err
+		}
+		hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+		if err != nil {
+			return false, false, err
+		}
+		passwordHash := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(hash)))
+		needsRehash = version != argon2.Version ||
+			memory != passwordHashArgon2Memory ||
+			iterations != passwordHashArgon2Time ||
+			threads != passwordHashArgon2Threads ||
+			len(hash) != passwordHashArgon2KeyLength
+		return subtle.ConstantTimeCompare(hash, passwordHash) == 1, needsRehash, nil
+	}
+	return false, false, fmt.Errorf("unknown password hash format")
+}
+
+// time-based one-time password parameters, as supported by most authenticator apps
+const (
+	totpIssuer       = "FlatTrack"
+	totpPeriod       = 30 * time.Second
+	totpDigits       = 6
+	totpSkew         = 1
+	totpSecretLength = 20
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret ...
+// returns a new random secret, encoded for authenticator apps
+func GenerateTOTPSecret() (secret string, err error) {
+	secretBytes := make([]byte, totpSecretLength)
+	if _, err := cryptorand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secretBytes), nil
+}
+
+// GenerateTOTPCode ...
+// returns the time-based one-time password of a secret for a period, as described in RFC 6238
+func GenerateTOTPCode(secret string, counter int64) (code string, err error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	hasher := hmac.New(sha1.New, key)
+	if _, err := hasher.Write(message); err != nil {
+		return "", err
+	}
+	sum := hasher.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for range totpDigits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%modulo), nil
+}
+
+// ValidateTOTPCode ...
+// returns the period which a code is valid for at a time, allowing for clock drift
+func ValidateTOTPCode(secret string, code string, at time.Time) (counter int64, valid bool, err error) {
+	if len(code) != totpDigits {
+		return 0, false, nil
+	}
+	now := at.Unix() / int64(totpPeriod.Seconds())
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		expected, err := GenerateTOTPCode(secret, now+int64(skew))
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return now + int64(skew), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// TOTPURI ...
+// returns an otpauth URI for adding a secret to an authenticator app, such as from a QR code
+func TOTPURI(secret string, accountName string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprintf("%v", totpDigits))
+	query.Set("period", fmt.Sprintf("%v", int(totpPeriod.Seconds())))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + accountName,
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
+
 // StringInStringSlice ...
 // given a list of string and an input string, return if the input string is in the list of strings
 func StringInStringSlice(input string, list []string) bool {
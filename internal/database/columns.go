@@ -0,0 +1,113 @@
+/*
+  database
+    columns
+      select and scan the columns of a table by name
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Column ...
+// a column of a table, along with the field of T which it's scanned into
+type Column[T any] struct {
+	Name  string
+	Field func(t *T) any
+}
+
+// Columns ...
+// the columns of a table which are selected and scanned, in order.
+// Statements name their columns with them instead of using *, so that columns added to a table don't change what is scanned
+type Columns[T any] []Column[T]
+
+// Names ...
+// returns the names of the columns
+func (c Columns[T]) Names() []string {
+	names := []string{}
+	for _, column := range c {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// String ...
+// returns the columns as a list, for select and returning clauses
+func (c Columns[T]) String() string {
+	return strings.Join(c.Names(), ", ")
+}
+
+// Prefixed ...
+// returns the columns as a list, where each is prefixed with the alias of their table
+func (c Columns[T]) Prefixed(alias string) string {
+	names := []string{}
+	for _, name := range c.Names() {
+		names = append(names, alias+"."+name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Without ...
+// returns the columns except those named
+func (c Columns[T]) Without(names ...string) Columns[T] {
+	return slices.DeleteFunc(slices.Clone(c), func(column Column[T]) bool {
+		return slices.Contains(names, column.Name)
+	})
+}
+
+// Scan ...
+// returns the current row as a T, given that the columns were selected in order
+func (c Columns[T]) Scan(rows *sql.Rows) (value T, err error) {
+	var empty T
+	fields := make([]any, len(c))
+	for i, column := range c {
+		fields[i] = column.Field(&value)
+	}
+	if err := rows.Scan(fields...); err != nil {
+		return empty, err
+	}
+	if err := rows.Err(); err != nil {
+		return empty, err
+	}
+	return value, nil
+}
+
+// TableColumns ...
+// returns the lowercase names of the columns of a table, as they are in the database
+func TableColumns(db *sql.DB, table string) (names []string, err error) {
+	rows, err := db.Query(DialectOf(db).TableColumnTypes(), table)
+	if err != nil {
+		return []string{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("Failed to close rows", "error", err)
+		}
+	}()
+	names = []string{}
+	for rows.Next() {
+		var name, columnType string
+		if err := rows.Scan(&name, &columnType); err != nil {
+			return []string{}, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
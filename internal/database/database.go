@@ -79,7 +79,17 @@ func GetConnectionString() (output string) {
 // given database credentials, return a database connection
 func Open() (*sql.DB, error) {
 	connectionString = GetConnectionString()
-	db, err := sql.Open("postgres", connectionString)
+	return OpenConnectionString(connectionString)
+}
+
+// OpenConnectionString ...
+// returns a connection to the database of a connection string,
+// being a SQLite database for sqlite:// and file: connection strings and otherwise Postgres
+func OpenConnectionString(connectionString string) (*sql.DB, error) {
+	if dataSourceName, ok := sqliteDataSourceName(connectionString); ok {
+		return sql.Open(DriverSQLite, dataSourceName)
+	}
+	db, err := sql.Open(DriverPostgres, connectionString)
 	if err != nil {
 		return nil, err
 	}
@@ -123,9 +133,10 @@ func Ping(db *sql.DB) (err error) {
 }
 
 // Version ...
-// Return Postgres version
+// Return Postgres or SQLite version
 func GetVersion(db *sql.DB) (version string, err error) {
-	rows, err := db.Query(`SELECT current_setting('server_version')`)
+	sqlStatement := DialectOf(db).version()
+	rows, err := db.Query(sqlStatement)
 	if err != nil {
 		slog.Error("Failed to query database", "error", err)
 		return "", err
@@ -0,0 +1,198 @@
+/*
+  database
+    dialect
+      write the statements which differ between Postgres and SQLite
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Dialect is the kind of database which statements are run against.
+// Statements are written for both, with the parts which differ between them written by it's methods
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// DialectOf ...
+// returns the kind of database of a connection
+func DialectOf(db *sql.DB) Dialect {
+	if db == nil {
+		return DialectPostgres
+	}
+	if _, ok := db.Driver().(*sqliteDriver); ok {
+		return DialectSQLite
+	}
+	return DialectPostgres
+}
+
+// DialectOfConnectionString ...
+// returns the kind of database which a connection string connects to
+func DialectOfConnectionString(connectionString string) Dialect {
+	if _, ok := sqliteDataSourceName(connectionString); ok {
+		return DialectSQLite
+	}
+	return DialectPostgres
+}
+
+// ArrayContains ...
+// returns a condition which is true when an array contains a value
+func (d Dialect) ArrayContains(array string, value string) string {
+	if d == DialectSQLite {
+		return fmt.Sprintf(`array_contains(%v, %v)`, array, value)
+	}
+	return fmt.Sprintf(`%v = any(%v)`, value, array)
+}
+
+// ArrayElements ...
+// returns a table of the elements of an array, named alias with a value column
+func (d Dialect) ArrayElements(array string, alias string) string {
+	if d == DialectSQLite {
+		return fmt.Sprintf(`json_each(array_to_json(%v)) as %v`, array, alias)
+	}
+	return fmt.Sprintf(`unnest(%v) as %v(value)`, array, alias)
+}
+
+// Now ...
+// returns the current time in seconds since the epoch
+func (d Dialect) Now() string {
+	if d == DialectSQLite {
+		return `cast(strftime('%s', 'now') as int)`
+	}
+	return `date_part('epoch',CURRENT_TIMESTAMP)::int`
+}
+
+// NewID ...
+// returns a random ID in the layout of a UUID
+func (d Dialect) NewID() string {
+	if d == DialectSQLite {
+		return `lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))`
+	}
+	return `md5(random()::text || clock_timestamp()::text)::uuid`
+}
+
+// Cast ...
+// returns a value as a type, such as text[] for a parameter of an array.
+// SQLite columns are loosely typed, so values are left as they are
+func (d Dialect) Cast(value string, valueType string) string {
+	if d == DialectSQLite {
+		return value
+	}
+	return fmt.Sprintf(`%v::%v`, value, valueType)
+}
+
+// JSONElements ...
+// returns a table of the elements of a JSON array, named alias with value and position columns
+func (d Dialect) JSONElements(array string, alias string) string {
+	if d == DialectSQLite {
+		return fmt.Sprintf(`(select value, key as position from json_each(%v)) as %v`, array, alias)
+	}
+	return fmt.Sprintf(`json_array_elements(%v::json) with ordinality as %v(value, position)`, array, alias)
+}
+
+// JSONField ...
+// returns a field of a JSON object as a type
+func (d Dialect) JSONField(object string, field string, fieldType string) string {
+	if d == DialectSQLite {
+		return fmt.Sprintf(`%v ->> '%v'`, object, field)
+	}
+	return fmt.Sprintf(`(%v ->> '%v')::%v`, object, field, fieldType)
+}
+
+// Notifies ...
+// returns whether the database notifies every instance using it of changes, like pg_notify in Postgres.
+// SQLite databases are only used by a single instance, which sends changes within itself instead
+func (d Dialect) Notifies() bool {
+	return d != DialectSQLite
+}
+
+// SnapshotTxOptions ...
+// returns the options of a read only transaction, which reads every table as they were at the same point in time.
+// SQLite transactions are always serializable, and only take the write lock when they write
+func (d Dialect) SnapshotTxOptions() *sql.TxOptions {
+	if d == DialectSQLite {
+		return &sql.TxOptions{ReadOnly: true}
+	}
+	return &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+}
+
+// TableColumnTypes ...
+// returns the statement which selects the lowercase names and types of the columns of a table, in order.
+// Types are written like they are in migrations, such as text[] for arrays
+func (d Dialect) TableColumnTypes() string {
+	if d == DialectSQLite {
+		return `select lower(name), lower(type) from pragma_table_info($1) order by cid`
+	}
+	return `select lower(column_name), lower(case when data_type = 'ARRAY' then substr(udt_name, 2) || '[]' else udt_name end)
+                from information_schema.columns where table_schema = current_schema() and table_name = $1 order by ordinal_position`
+}
+
+// SelectRowsAsJSON ...
+// returns the statement which selects every row of a table as a JSON object, from the names and types of it's columns.
+// Booleans and arrays are written as JSON, like Postgres writes them
+func (d Dialect) SelectRowsAsJSON(table string, columns map[string]string) string {
+	if d != DialectSQLite {
+		return fmt.Sprintf(`select row_to_json(t) from %v t`, table)
+	}
+	fields := []string{}
+	for _, name := range slices.Sorted(maps.Keys(columns)) {
+		value := "t." + name
+		switch {
+		case columns[name] == "bool":
+			value = fmt.Sprintf(`case when %[1]v then json('true') when not %[1]v then json('false') end`, value)
+		case strings.HasSuffix(columns[name], "[]"):
+			value = fmt.Sprintf(`json(array_to_json(%v))`, value)
+		}
+		fields = append(fields, fmt.Sprintf(`'%v', %v`, name, value))
+	}
+	return fmt.Sprintf(`select json_object(%v) from %v t`, strings.Join(fields, ", "), table)
+}
+
+// InsertRowFromJSON ...
+// returns the statement which inserts a row of a table from a JSON object, from the names and types of it's columns
+func (d Dialect) InsertRowFromJSON(table string, columns map[string]string) string {
+	if d != DialectSQLite {
+		return fmt.Sprintf(`insert into %[1]v select * from json_populate_record(null::%[1]v, $1)`, table)
+	}
+	names := slices.Sorted(maps.Keys(columns))
+	values := []string{}
+	for _, name := range names {
+		value := fmt.Sprintf(`json_extract($1, '$."%v"')`, name)
+		if strings.HasSuffix(columns[name], "[]") {
+			value = fmt.Sprintf(`array_from_json(%v)`, value)
+		}
+		values = append(values, value)
+	}
+	return fmt.Sprintf(`insert into %v (%v) values (%v)`, table, strings.Join(names, ", "), strings.Join(values, ", "))
+}
+
+// version ...
+// returns the statement which selects the version of the database
+func (d Dialect) version() string {
+	if d == DialectSQLite {
+		return `SELECT sqlite_version()`
+	}
+	return `SELECT current_setting('server_version')`
+}
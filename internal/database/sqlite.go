@@ -0,0 +1,285 @@
+/*
+  database
+    sqlite
+      open SQLite databases, with functions standing in for the Postgres array operators
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+)
+
+const (
+	// DriverPostgres is the database/sql driver for Postgres connection strings
+	DriverPostgres = "postgres"
+	// DriverSQLite is the database/sql driver for SQLite connection strings
+	DriverSQLite = "flattrack-sqlite"
+)
+
+// sqliteConnectionStringPrefixes are how connection strings name a SQLite database file,
+// such as sqlite:///var/lib/flattrack/flattrack.db
+var sqliteConnectionStringPrefixes = []string{"sqlite://", "sqlite3://", "file:"}
+
+// sqliteConnectionParameters ...
+// the parameters of every SQLite connection, unless set in the connection string.
+// Foreign keys are enforced like in Postgres, readers don't wait for writers,
+// and transactions take the write lock as they begin instead of failing to upgrade to it
+var sqliteConnectionParameters = map[string]string{
+	"_foreign_keys": "1",
+	"_journal_mode": "WAL",
+	"_busy_timeout": "5000",
+	"_txlock":       "immediate",
+}
+
+// sqliteDataSourceName ...
+// returns the SQLite data source name of a connection string, if it names a SQLite database
+func sqliteDataSourceName(connectionString string) (dataSourceName string, ok bool) {
+	for _, prefix := range sqliteConnectionStringPrefixes {
+		path, found := strings.CutPrefix(connectionString, prefix)
+		if !found {
+			continue
+		}
+		path, rawQuery, _ := strings.Cut(path, "?")
+		query, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			query = url.Values{}
+		}
+		for name, value := range sqliteConnectionParameters {
+			if !query.Has(name) {
+				query.Set(name, value)
+			}
+		}
+		return "file:" + path + "?" + query.Encode(), true
+	}
+	return "", false
+}
+
+// sqliteText ...
+// returns the text of a value passed to a SQLite function, which is nil for NULL
+func sqliteText(value any) (text string, ok bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), v != nil
+	}
+	return "", false
+}
+
+// arrayContains ...
+// returns whether a Postgres array literal contains a value, like value = any(array) in Postgres
+func arrayContains(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	arrayText, ok := sqliteText(args[0])
+	if !ok {
+		return false, nil
+	}
+	valueText, ok := sqliteText(args[1])
+	if !ok {
+		return false, nil
+	}
+	var elements pq.StringArray
+	if err := elements.Scan(arrayText); err != nil {
+		return false, err
+	}
+	for _, e := range elements {
+		if e == valueText {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// arrayToJSON ...
+// returns a Postgres array literal as a JSON array, like array_to_json in Postgres
+func arrayToJSON(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	arrayText, ok := sqliteText(args[0])
+	if !ok {
+		return nil, nil
+	}
+	elements := pq.StringArray{}
+	if err := elements.Scan(arrayText); err != nil {
+		return nil, err
+	}
+	output, err := json.Marshal([]string(elements))
+	if err != nil {
+		return nil, err
+	}
+	return string(output), nil
+}
+
+// arrayFromJSON ...
+// returns a JSON array as a Postgres array literal.
+// Values which are already array literals are returned as they are
+func arrayFromJSON(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	arrayText, ok := sqliteText(args[0])
+	if !ok {
+		return nil, nil
+	}
+	if !strings.HasPrefix(strings.TrimSpace(arrayText), "[") {
+		return arrayText, nil
+	}
+	elements := []string{}
+	if err := json.Unmarshal([]byte(arrayText), &elements); err != nil {
+		return nil, err
+	}
+	return pq.StringArray(elements).Value()
+}
+
+// registerSQLiteFunctions ...
+// adds the functions which stand in for Postgres array operators to the connections of a SQLite driver.
+// Arrays are kept as Postgres array literals in text columns, so that pq.Array reads and writes them the same
+func registerSQLiteFunctions(d *sqlite.Driver) error {
+	for name, impl := range map[string]struct {
+		arguments int32
+		function  func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error)
+	}{
+		"array_contains":  {arguments: 2, function: arrayContains},
+		"array_to_json":   {arguments: 1, function: arrayToJSON},
+		"array_from_json": {arguments: 1, function: arrayFromJSON},
+	} {
+		if err := d.RegisterDeterministicScalarFunction(name, impl.arguments, impl.function); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteDriver ...
+// opens SQLite connections which run statements like Postgres does.
+// Parameters such as $1 are bound by their number, the same as in Postgres
+type sqliteDriver struct {
+	driver *sqlite.Driver
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{Conn: conn}, nil
+}
+
+// sqliteConn ...
+// a SQLite connection which reads the rows of each statement as it is run
+type sqliteConn struct {
+	driver.Conn
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if conn, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return conn.PrepareContext(ctx, query)
+	}
+	return c.Prepare(query)
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if conn, ok := c.Conn.(driver.ExecerContext); ok {
+		return conn.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := conn.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite holds the lock of a statement until its rows are closed, where Postgres commits it straight away.
+	// Rows are read to the end, so that a statement which writes doesn't block the other connections while its rows are used
+	return readSQLiteRows(rows)
+}
+
+// sqliteRows ...
+// rows which have already been read from a statement
+type sqliteRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// readSQLiteRows ...
+// reads and closes all the rows of a statement
+func readSQLiteRows(rows driver.Rows) (driver.Rows, error) {
+	read := &sqliteRows{columns: rows.Columns()}
+	for {
+		values := make([]driver.Value, len(read.columns))
+		if err := rows.Next(values); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		read.values = append(read.values, values)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	return read, nil
+}
+
+func (r *sqliteRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sqliteRows) Close() error {
+	r.values = nil
+	return nil
+}
+
+func (r *sqliteRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if conn, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return conn.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	if conn, ok := c.Conn.(driver.Pinger); ok {
+		return conn.Ping(ctx)
+	}
+	return nil
+}
+
+func init() {
+	d := &sqlite.Driver{}
+	if err := registerSQLiteFunctions(d); err != nil {
+		panic(err)
+	}
+	sql.Register(DriverSQLite, &sqliteDriver{driver: d})
+}
@@ -65,3 +65,40 @@ func (m *Manager) SendTestEmail(recipient string) (err error) {
 	err = m.smtpManager.SendEmail(templateEmailRendered, context.Subject, recipient)
 	return err
 }
+
+// passwordResetTemplate ...
+// the email sent to reset a forgotten password
+var passwordResetTemplate = template.Must(template.New("passwordReset").Parse(`<html>
+  <body>
+    <p>Hi {{ .Names }},</p>
+    <p>A password reset was requested for your FlatTrack account.</p>
+    <p><a href="{{ .Link }}">Reset your password</a></p>
+    <p>The link can only be used once and expires in an hour. If you didn't request this, you can ignore this email.</p>
+  </body>
+</html>`))
+
+// PasswordResetTemplateData ...
+// fields for the password reset email
+type PasswordResetTemplateData struct {
+	SMTPTemplateData
+	Names string
+	Link  string
+}
+
+// SendPasswordResetEmail ...
+// sends a link to reset the password of an account
+func (m *Manager) SendPasswordResetEmail(recipient string, names string, link string) (err error) {
+	context := &PasswordResetTemplateData{
+		SMTPTemplateData: SMTPTemplateData{
+			Subject: "FlatTrack password reset",
+		},
+		Names: names,
+		Link:  link,
+	}
+	templatedEmailBuffer := new(bytes.Buffer)
+	if err := passwordResetTemplate.Execute(templatedEmailBuffer, context); err != nil {
+		slog.Error("Failed to template email", "error", err)
+		return err
+	}
+	return m.smtpManager.SendEmail(templatedEmailBuffer.String(), context.Subject, recipient)
+}
@@ -0,0 +1,132 @@
+/*
+  expenses
+    balance
+      calculate what flatmates owe each other
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sort"
+
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+type BalanceManager struct {
+	manager *Manager
+	db      *sql.DB
+	ctx     context.Context
+}
+
+func (m *Manager) Balance() *BalanceManager {
+	return &BalanceManager{
+		manager: m,
+		db:      m.db,
+		ctx:     m.ctx,
+	}
+}
+
+// List ...
+// returns how much each flatmate has paid and owes across all expenses.
+// A positive balance is owed to the flatmate, a negative balance is owed by them
+func (m *BalanceManager) List() (balances []types.ExpenseBalance, err error) {
+	sqlStatement := `select userId, sum(paid), sum(owed) from (
+                           select payer as userId, amount as paid, 0 as owed from expense where flatId = $1 and deletionTimestamp = 0
+                           union all
+                           select s.userId, 0 as paid, s.amount as owed from expense_share s
+                             join expense e on e.id = s.expenseId
+                             where e.flatId = $1 and e.deletionTimestamp = 0 and s.deletionTimestamp = 0
+                         ) as ledger
+                         group by userId
+                         order by userId`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
+	if err != nil {
+		return []types.ExpenseBalance{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		var balance types.ExpenseBalance
+		if err := rows.Scan(&balance.UserID, &balance.Paid, &balance.Owed); err != nil {
+			return []types.ExpenseBalance{}, err
+		}
+		if err := rows.Err(); err != nil {
+			return []types.ExpenseBalance{}, err
+		}
+		balance.Paid = fromCents(toCents(balance.Paid))
+		balance.Owed = fromCents(toCents(balance.Owed))
+		balance.Balance = fromCents(toCents(balance.Paid) - toCents(balance.Owed))
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+// SettleUp ...
+// returns the transfers to settle all balances
+func (m *BalanceManager) SettleUp() (transfers []types.ExpenseTransfer, err error) {
+	balances, err := m.List()
+	if err != nil {
+		return []types.ExpenseTransfer{}, err
+	}
+	return SettleUp(balances), nil
+}
+
+// SettleUp ...
+// returns the transfers to settle the given balances.
+// The largest debtor repeatedly pays the largest creditor, which needs
+// at most one fewer transfer than there are flatmates with a balance
+func SettleUp(balances []types.ExpenseBalance) (transfers []types.ExpenseTransfer) {
+	type party struct {
+		userID string
+		cents  int64
+	}
+	creditors := []party{}
+	debtors := []party{}
+	for _, balance := range balances {
+		cents := toCents(balance.Balance)
+		switch {
+		case cents > 0:
+			creditors = append(creditors, party{userID: balance.UserID, cents: cents})
+		case cents < 0:
+			debtors = append(debtors, party{userID: balance.UserID, cents: -cents})
+		}
+	}
+	for len(creditors) > 0 && len(debtors) > 0 {
+		sort.SliceStable(creditors, func(i, j int) bool { return creditors[i].cents > creditors[j].cents })
+		sort.SliceStable(debtors, func(i, j int) bool { return debtors[i].cents > debtors[j].cents })
+		amount := min(creditors[0].cents, debtors[0].cents)
+		transfers = append(transfers, types.ExpenseTransfer{
+			From:   debtors[0].userID,
+			To:     creditors[0].userID,
+			Amount: fromCents(amount),
+		})
+		creditors[0].cents -= amount
+		debtors[0].cents -= amount
+		if creditors[0].cents == 0 {
+			creditors = creditors[1:]
+		}
+		if debtors[0].cents == 0 {
+			debtors = debtors[1:]
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,523 @@
+/*
+  expenses
+    manage shared household expenses
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
+	"gitlab.com/flattrack/flattrack/internal/users"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+var (
+	ErrFailedToAddShareToExpense          = fmt.Errorf("Failed to add share to expense")
+	ErrFailedToCreateExpense              = fmt.Errorf("Failed to create expense")
+	ErrFailedToRemoveAllSharesFromExpense = fmt.Errorf("Failed to remove all shares from expense")
+	ErrFailedToUpdateExpense              = fmt.Errorf("Failed to update expense")
+	ErrInvalidExpenseAmount               = fmt.Errorf("Unable to use the provided amount, as it must be greater than zero")
+	ErrInvalidExpenseName                 = fmt.Errorf("Unable to use the provided name, as it is either empty or too long or too short")
+	ErrInvalidExpenseNotes                = fmt.Errorf("Unable to save expense notes, as they are too long")
+	ErrInvalidExpensePayer                = fmt.Errorf("Unable to use the provided payer, as the user account does not exist")
+	ErrInvalidExpenseShares               = fmt.Errorf("Unable to use the provided shares, as there must be at least one and each flatmate only once")
+	ErrInvalidExpenseShareUser            = fmt.Errorf("Unable to use the provided share, as the user account does not exist")
+	ErrInvalidExpenseSharesExact          = fmt.Errorf("Unable to use the provided shares, as the exact amounts must add up to the expense amount")
+	ErrInvalidExpenseSharesPercentage     = fmt.Errorf("Unable to use the provided shares, as the percentages must add up to 100")
+	ErrInvalidExpenseSplitType            = fmt.Errorf("Unable to use the provided split type, as it is not a known split type")
+	ErrShoppingListNotCompleted           = fmt.Errorf("Unable to create an expense from a shopping list which is not completed")
+)
+
+type Manager struct {
+	db           *sql.DB
+	tx           *sql.Tx
+	ctx          context.Context
+	users        *users.Manager
+	shoppinglist *shoppinglist.Manager
+	flatID       string
+}
+
+func NewManager(db *sql.DB, users *users.Manager, shoppinglist *shoppinglist.Manager) *Manager {
+	return &Manager{
+		db:           db,
+		ctx:          context.Background(),
+		users:        users,
+		shoppinglist: shoppinglist,
+	}
+}
+
+// ForFlat ...
+// returns a manager for the expenses of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		db:           m.db,
+		ctx:          m.ctx,
+		users:        m.users.ForFlat(flatID),
+		shoppinglist: m.shoppinglist.ForFlat(flatID),
+		flatID:       flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:           m.db,
+		ctx:          ctx,
+		users:        m.users.WithContext(ctx),
+		shoppinglist: m.shoppinglist.WithContext(ctx),
+		flatID:       m.flatID,
+	}
+}
+
+// queryer ...
+// the queries of a database or a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryer ...
+// returns the transaction of the manager, otherwise the database
+func (m *Manager) queryer() queryer {
+	if m.tx != nil {
+		return m.tx
+	}
+	return m.db
+}
+
+// InTransaction ...
+// runs fn with a manager which uses one transaction, committing it when fn succeeds
+func (m *Manager) InTransaction(fn func(m *Manager) error) (err error) {
+	if m.tx != nil {
+		return fn(m)
+	}
+	tx, err := m.db.BeginTx(m.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback expense transaction", "error", err)
+		}
+	}()
+	txManager := *m
+	txManager.tx = tx
+	if err := fn(&txManager); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type ExpenseManager struct {
+	manager *Manager
+	db      queryer
+	ctx     context.Context
+}
+
+func (m *Manager) Expense() *ExpenseManager {
+	return &ExpenseManager{
+		manager: m,
+		db:      m.queryer(),
+		ctx:     m.ctx,
+	}
+}
+
+// Validate ...
+// given an expense, return it's validity
+func (m *ExpenseManager) Validate(expense types.ExpenseSpec) (valid bool, err error) {
+	if len(expense.Name) == 0 || len(expense.Name) >= 30 || expense.Name == "" {
+		return false, ErrInvalidExpenseName
+	}
+	if expense.Notes != "" && len(expense.Notes) > 100 {
+		return false, ErrInvalidExpenseNotes
+	}
+	if expense.Amount <= 0 {
+		return false, ErrInvalidExpenseAmount
+	}
+	exists, err := m.manager.users.UserAccountExists(expense.Payer)
+	if err != nil || !exists {
+		return false, ErrInvalidExpensePayer
+	}
+	if len(expense.Shares) == 0 {
+		return false, ErrInvalidExpenseShares
+	}
+	seen := map[string]bool{}
+	var valueTotal float64
+	for _, share := range expense.Shares {
+		if seen[share.UserID] {
+			return false, ErrInvalidExpenseShares
+		}
+		seen[share.UserID] = true
+		exists, err := m.manager.users.UserAccountExists(share.UserID)
+		if err != nil || !exists {
+			return false, ErrInvalidExpenseShareUser
+		}
+		valueTotal += share.Value
+	}
+	switch expense.SplitType {
+	case types.ExpenseSplitTypeEqual:
+	case types.ExpenseSplitTypePercentage:
+		if toCents(valueTotal) != 100*100 {
+			return false, ErrInvalidExpenseSharesPercentage
+		}
+	case types.ExpenseSplitTypeExact:
+		if toCents(valueTotal) != toCents(expense.Amount) {
+			return false, ErrInvalidExpenseSharesExact
+		}
+	default:
+		return false, ErrInvalidExpenseSplitType
+	}
+	return true, nil
+}
+
+// List ...
+// returns a list of all expenses
+func (m *ExpenseManager) List() (expenses []types.ExpenseSpec, err error) {
+	sqlStatement := `select * from expense where flatId = $1 and deletionTimestamp = 0 order by creationTimestamp desc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
+	if err != nil {
+		return []types.ExpenseSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		expense, err := getExpenseObjectFromRows(rows)
+		if err != nil {
+			return []types.ExpenseSpec{}, err
+		}
+		expense.Shares, err = m.ListShares(expense.ID)
+		if err != nil {
+			return []types.ExpenseSpec{}, err
+		}
+		expenses = append(expenses, expense)
+	}
+	return expenses, nil
+}
+
+// Get ...
+// returns a given expense, by it's ID
+func (m *ExpenseManager) Get(id string) (expense types.ExpenseSpec, err error) {
+	sqlStatement := `select * from expense where id = $1 and flatId = $2 and deletionTimestamp = 0`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		expense, err = getExpenseObjectFromRows(rows)
+		if err != nil {
+			return types.ExpenseSpec{}, err
+		}
+	}
+	if expense.ID == "" {
+		return types.ExpenseSpec{}, nil
+	}
+	expense.Shares, err = m.ListShares(expense.ID)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expense, nil
+}
+
+// Create ...
+// creates an expense and the shares of it
+func (m *ExpenseManager) Create(expense types.ExpenseSpec) (expenseInserted types.ExpenseSpec, err error) {
+	if expense.SplitType == "" {
+		expense.SplitType = types.ExpenseSplitTypeEqual
+	}
+	valid, err := m.Validate(expense)
+	if !valid || err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	expense.AuthorLast = expense.Author
+
+	if err := m.manager.InTransaction(func(tm *Manager) error {
+		expenseInserted, err = tm.Expense().insert(expense)
+		return err
+	}); err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expenseInserted, nil
+}
+
+// insert ...
+// inserts an expense and the shares of it
+func (m *ExpenseManager) insert(expense types.ExpenseSpec) (expenseInserted types.ExpenseSpec, err error) {
+	sqlStatement := `insert into expense (name, notes, amount, payer, splitType, shoppingListId, author, authorLast, flatId)
+                         values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, expense.Name, expense.Notes, expense.Amount, expense.Payer, expense.SplitType, expense.ShoppingListID, expense.Author, expense.AuthorLast, m.manager.flatID)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	expenseInserted, err = getExpenseObjectFromRows(rows)
+	if err != nil || expenseInserted.ID == "" {
+		slog.Error("Failed to get expense object from rows", "error", err)
+		return types.ExpenseSpec{}, ErrFailedToCreateExpense
+	}
+	if err := rows.Close(); err != nil {
+		return types.ExpenseSpec{}, err
+	}
+
+	expenseInserted.Shares, err = m.addShares(expenseInserted.ID, expense)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expenseInserted, nil
+}
+
+// CreateFromShoppingList ...
+// creates an expense for the total of a completed shopping list
+func (m *ExpenseManager) CreateFromShoppingList(listID string, expense types.ExpenseSpec) (expenseInserted types.ExpenseSpec, err error) {
+	list, err := m.manager.shoppinglist.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		return types.ExpenseSpec{}, shoppinglist.ErrFailedToGetExistingShoppingList
+	}
+	if !list.Completed {
+		return types.ExpenseSpec{}, ErrShoppingListNotCompleted
+	}
+	total, err := m.manager.shoppinglist.ShoppingList().GetTotal(list.ID)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	if expense.Name == "" {
+		expense.Name = list.Name
+	}
+	expense.Amount = total
+	expense.ShoppingListID = list.ID
+	return m.Create(expense)
+}
+
+// Update ...
+// updates an expense and replaces the shares of it
+func (m *ExpenseManager) Update(id string, expense types.ExpenseSpec) (expenseUpdated types.ExpenseSpec, err error) {
+	if expense.SplitType == "" {
+		expense.SplitType = types.ExpenseSplitTypeEqual
+	}
+	valid, err := m.Validate(expense)
+	if !valid || err != nil {
+		return types.ExpenseSpec{}, err
+	}
+
+	if err := m.manager.InTransaction(func(tm *Manager) error {
+		expenseUpdated, err = tm.Expense().update(id, expense)
+		return err
+	}); err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expenseUpdated, nil
+}
+
+// update ...
+// updates an expense and replaces the shares of it
+func (m *ExpenseManager) update(id string, expense types.ExpenseSpec) (expenseUpdated types.ExpenseSpec, err error) {
+	sqlStatement := fmt.Sprintf(`update expense set name = $1, notes = $2, amount = $3, payer = $4, splitType = $5, authorLast = $6, modificationTimestamp = %v where id = $7 and flatId = $8
+                         returning *`, database.DialectOf(m.manager.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, expense.Name, expense.Notes, expense.Amount, expense.Payer, expense.SplitType, expense.AuthorLast, id, m.manager.flatID)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	expenseUpdated, err = getExpenseObjectFromRows(rows)
+	if err != nil || expenseUpdated.ID == "" {
+		slog.Error("Failed to get expense from rows", "error", err)
+		return types.ExpenseSpec{}, ErrFailedToUpdateExpense
+	}
+	if err := rows.Close(); err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	if err := m.DeleteAllShares(id); err != nil {
+		return types.ExpenseSpec{}, ErrFailedToRemoveAllSharesFromExpense
+	}
+	expenseUpdated.Shares, err = m.addShares(id, expense)
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expenseUpdated, nil
+}
+
+// Delete ...
+// deletes an expense and it's shares
+func (m *ExpenseManager) Delete(id string) (err error) {
+	return m.manager.InTransaction(func(tm *Manager) error {
+		if err := tm.Expense().DeleteAllShares(id); err != nil {
+			return ErrFailedToRemoveAllSharesFromExpense
+		}
+		sqlStatement := `delete from expense where id = $1 and flatId = $2`
+		rows, err := tm.queryer().QueryContext(tm.ctx, sqlStatement, id, tm.flatID)
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+}
+
+// ListShares ...
+// returns the shares of an expense
+func (m *ExpenseManager) ListShares(expenseID string) (shares []types.ExpenseShareSpec, err error) {
+	sqlStatement := `select * from expense_share where expenseId = $1 and expenseId in (select id from expense where flatId = $2) and deletionTimestamp = 0 order by creationTimestamp asc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, expenseID, m.manager.flatID)
+	if err != nil {
+		return []types.ExpenseShareSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		share, err := getShareObjectFromRows(rows)
+		if err != nil {
+			return []types.ExpenseShareSpec{}, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, nil
+}
+
+// DeleteAllShares ...
+// deletes all shares of an expense
+func (m *ExpenseManager) DeleteAllShares(expenseID string) (err error) {
+	sqlStatement := `delete from expense_share where expenseId = $1 and expenseId in (select id from expense where flatId = $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, expenseID, m.manager.flatID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// addShares ...
+// splits an expense into the shares and stores them
+func (m *ExpenseManager) addShares(expenseID string, expense types.ExpenseSpec) (shares []types.ExpenseShareSpec, err error) {
+	amounts := SplitShares(expense)
+	for i, share := range expense.Shares {
+		sqlStatement := `insert into expense_share (expenseId, userId, value, amount)
+                                 values ($1, $2, $3, $4)
+                                 returning *`
+		rows, err := m.db.QueryContext(m.ctx, sqlStatement, expenseID, share.UserID, share.Value, amounts[i])
+		if err != nil {
+			return []types.ExpenseShareSpec{}, err
+		}
+		rows.Next()
+		shareInserted, err := getShareObjectFromRows(rows)
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+		if err != nil || shareInserted.ID == "" {
+			slog.Error("Failed to get expense share object from rows", "error", err)
+			return []types.ExpenseShareSpec{}, ErrFailedToAddShareToExpense
+		}
+		shares = append(shares, shareInserted)
+	}
+	return shares, nil
+}
+
+// SplitShares ...
+// returns the amount owed for each share of an expense, in the order of the shares.
+// Any cents left over from rounding are given to the first shares
+func SplitShares(expense types.ExpenseSpec) (amounts []float64) {
+	total := toCents(expense.Amount)
+	cents := make([]int64, len(expense.Shares))
+	var allocated int64
+	for i, share := range expense.Shares {
+		switch expense.SplitType {
+		case types.ExpenseSplitTypePercentage:
+			cents[i] = int64(math.Floor(float64(total) * share.Value / 100))
+		case types.ExpenseSplitTypeExact:
+			cents[i] = toCents(share.Value)
+		default:
+			cents[i] = total / int64(len(expense.Shares))
+		}
+		allocated += cents[i]
+	}
+	for i := 0; allocated < total && len(cents) > 0; i = (i + 1) % len(cents) {
+		cents[i]++
+		allocated++
+	}
+	for _, c := range cents {
+		amounts = append(amounts, fromCents(c))
+	}
+	return amounts
+}
+
+// toCents ...
+// returns an amount as a whole number of cents
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// fromCents ...
+// returns a whole number of cents as an amount
+func fromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// getExpenseObjectFromRows ...
+// returns an expense object from rows
+func getExpenseObjectFromRows(rows *sql.Rows) (expense types.ExpenseSpec, err error) {
+	if err := rows.Scan(&expense.ID, &expense.Name, &expense.Notes, &expense.Amount, &expense.Payer, &expense.SplitType, &expense.ShoppingListID, &expense.Author, &expense.AuthorLast, &expense.CreationTimestamp, &expense.ModificationTimestamp, &expense.DeletionTimestamp, &expense.FlatID); err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	err = rows.Err()
+	if err != nil {
+		return types.ExpenseSpec{}, err
+	}
+	return expense, nil
+}
+
+// getShareObjectFromRows ...
+// returns an expense share object from rows
+func getShareObjectFromRows(rows *sql.Rows) (share types.ExpenseShareSpec, err error) {
+	if err := rows.Scan(&share.ID, &share.ExpenseID, &share.UserID, &share.Value, &share.Amount, &share.CreationTimestamp, &share.ModificationTimestamp, &share.DeletionTimestamp); err != nil {
+		return types.ExpenseShareSpec{}, err
+	}
+	err = rows.Err()
+	if err != nil {
+		return types.ExpenseShareSpec{}, err
+	}
+	return share, nil
+}
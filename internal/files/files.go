@@ -1,111 +1,85 @@
 package files
 
 import (
-	"bytes"
-	"context"
 	"fmt"
-	"io"
-	"log/slog"
-
-	minio "github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"net/http"
+	"time"
 
 	"gitlab.com/flattrack/flattrack/internal/common"
 )
 
-// FileAccess to access an S3 compatible backend
-type FileAccess struct {
-	Client     *minio.Client
-	BucketName string
-	Prefix     string
-}
+const (
+	// FileStorageMinio keeps files in a bucket of an S3 compatible server
+	FileStorageMinio = "minio"
+	// FileStorageLocal keeps files in a directory, such as a mounted volume
+	FileStorageLocal = "local"
+)
 
-// Open ...
-// open a Minio client
-func Open(endpoint string, accessKey string, secretKey string, bucketName string, useSSL bool) (FileAccess, error) {
-	mc, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		return FileAccess{}, err
-	}
-	return FileAccess{Client: mc, BucketName: bucketName}, err
+var (
+	ErrFileStorageUnknown      = fmt.Errorf("Unable to open file storage, as it must be either 'minio' or 'local'")
+	ErrFileStoragePathRequired = fmt.Errorf("Unable to open local file storage, as no directory was provided")
+	ErrFileNameInvalid         = fmt.Errorf("Unable to access file, as it's name is not valid")
+)
+
+// FileAccess to store and retrieve files
+type FileAccess interface {
+	// Init prepares the storage to be used
+	Init() error
+	// Get retrieves a given file
+	Get(name string) (data []byte, info ObjectInfo, err error)
+	// Put stores a file, replacing any file of the same name
+	Put(name string, data []byte) error
+	// List returns the names of the files
+	List() (names []string, err error)
+	// Delete removes a file
+	Delete(name string) error
+	// WithPrefix returns access to the files named with a prefix
+	WithPrefix(prefix string) FileAccess
 }
 
-// Init to initialise a bucket
-func (f FileAccess) Init() error {
-	if f.BucketName == "" {
-		return fmt.Errorf("Error: cannot initialise a bucket, because no bucket name was provided")
-	}
-	buckets, err := f.Client.ListBuckets(context.TODO())
-	if err != nil {
-		return err
-	}
-	foundBucket := false
-	for _, b := range buckets {
-		if b.Name == f.BucketName {
-			foundBucket = true
-		}
-	}
-	if foundBucket {
-		return nil
-	}
-	err = f.Client.MakeBucket(context.TODO(), f.BucketName, minio.MakeBucketOptions{})
-	if err != nil {
-		return err
-	}
-	return nil
+// ObjectInfo describes a stored file
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
 }
 
-// Get ...
-// retrieves a given object
-func (f FileAccess) Get(name string) (objectBytes []byte, objectInfo minio.ObjectInfo, err error) {
-	fileName := fmt.Sprintf("%v-%v", f.Prefix, name)
-	object, err := f.Client.GetObject(context.TODO(), common.GetAppMinioBucket(), fileName, minio.GetObjectOptions{})
-	if err != nil {
-		slog.Error("Failed to get object", "error", err)
-		return []byte{}, minio.ObjectInfo{}, err
-	}
-	defer func() {
-		if err := object.Close(); err != nil {
-			slog.Error("Failed to close object", "file", fileName, "error", err)
-		}
-	}()
-	objectInfo, err = object.Stat()
-	if err != nil {
-		slog.Error("Failed to stat object", "error", err)
-		return []byte{}, minio.ObjectInfo{}, err
-	}
-	objectBytes, err = io.ReadAll(object)
-	if err != nil {
-		slog.Error("Failed to read object", "error", err)
-		return []byte{}, minio.ObjectInfo{}, err
-	}
-	return objectBytes, objectInfo, err
+// objectName ...
+// returns the name of a file as it's stored, with the prefix of the file access
+func objectName(prefix string, name string) string {
+	return fmt.Sprintf("%v-%v", prefix, name)
 }
 
-// Put ...
-// uploads a file
-func (f FileAccess) Put(name string, data []byte) error {
-	fileName := fmt.Sprintf("%v-%v", f.Prefix, name)
-	reader := bytes.NewReader(data)
-	info, err := f.Client.PutObject(context.TODO(), f.BucketName, fileName, reader, int64(reader.Len()), minio.PutObjectOptions{})
-	if err != nil {
-		return err
-	}
-	slog.Info("Successfully uploaded file into bucket", "file", info.Key)
-	return nil
+// detectContentType ...
+// returns the content type of a file from it's first bytes
+func detectContentType(data []byte) string {
+	return http.DetectContentType(data)
 }
 
-// Delete ...
-// deletes a file
-func (f FileAccess) Delete(name string) error {
-	fileName := fmt.Sprintf("%v-%v", f.Prefix, name)
-	err := f.Client.RemoveObject(context.TODO(), f.BucketName, fileName, minio.RemoveObjectOptions{})
-	if err != nil {
-		return err
+// OpenFromEnv ...
+// opens and initialises the file storage configured by the environment,
+// returning nil when there isn't one
+func OpenFromEnv() (FileAccess, error) {
+	var f FileAccess
+	switch common.GetAppFileStorage() {
+	case FileStorageMinio:
+		bucketName := common.GetAppMinioBucket()
+		if bucketName == "" {
+			return nil, nil
+		}
+		mf, err := OpenMinio(common.GetAppMinioHost(), common.GetAppMinioAccessKey(), common.GetAppMinioSecretKey(), bucketName, common.GetAppMinioUseSSL() == "true")
+		if err != nil {
+			return nil, err
+		}
+		f = mf
+	case FileStorageLocal:
+		f = OpenLocal(common.GetAppFileStoragePath())
+	default:
+		return nil, ErrFileStorageUnknown
+	}
+	if err := f.Init(); err != nil {
+		return nil, err
 	}
-	slog.Info("Successfully deleted file into bucket", "file", fileName)
-	return nil
+	return f, nil
 }
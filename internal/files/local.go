@@ -0,0 +1,201 @@
+package files
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localTempFilePrefix begins the names of files which are still being written,
+// so that they are never listed or read
+const localTempFilePrefix = ".upload-"
+
+// LocalFileAccess to access files in a directory.
+// Every file is kept directly inside the directory, which is never able to be escaped
+type LocalFileAccess struct {
+	Directory string
+	Prefix    string
+}
+
+// OpenLocal ...
+// returns access to the files in a directory
+func OpenLocal(directory string) LocalFileAccess {
+	return LocalFileAccess{Directory: directory}
+}
+
+// Init to create the directory
+func (f LocalFileAccess) Init() error {
+	if f.Directory == "" {
+		return ErrFileStoragePathRequired
+	}
+	return os.MkdirAll(f.Directory, 0700)
+}
+
+// WithPrefix ...
+// returns access to the files named with a prefix
+func (f LocalFileAccess) WithPrefix(prefix string) FileAccess {
+	f.Prefix = prefix
+	return f
+}
+
+// fileName ...
+// returns the name of a file in the directory,
+// refusing names which are able to refer to another directory or a file being written
+func (f LocalFileAccess) fileName(name string) (string, error) {
+	fileName := objectName(f.Prefix, name)
+	if name == "" ||
+		strings.ContainsAny(fileName, `/\`) ||
+		!filepath.IsLocal(fileName) ||
+		strings.HasPrefix(fileName, ".") {
+		return "", ErrFileNameInvalid
+	}
+	return fileName, nil
+}
+
+// openRoot ...
+// opens the directory, so that files are only accessed inside of it
+func (f LocalFileAccess) openRoot() (*os.Root, error) {
+	if f.Directory == "" {
+		return nil, ErrFileStoragePathRequired
+	}
+	return os.OpenRoot(f.Directory)
+}
+
+// closeRoot ...
+// closes the directory, logging when it fails to
+func closeRoot(root *os.Root) {
+	if err := root.Close(); err != nil {
+		slog.Error("Failed to close directory", "directory", root.Name(), "error", err)
+	}
+}
+
+// Get ...
+// retrieves a given file
+func (f LocalFileAccess) Get(name string) (data []byte, info ObjectInfo, err error) {
+	fileName, err := f.fileName(name)
+	if err != nil {
+		return []byte{}, ObjectInfo{}, err
+	}
+	root, err := f.openRoot()
+	if err != nil {
+		return []byte{}, ObjectInfo{}, err
+	}
+	defer closeRoot(root)
+	file, err := root.Open(fileName)
+	if err != nil {
+		return []byte{}, ObjectInfo{}, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Error("Failed to close file", "file", fileName, "error", err)
+		}
+	}()
+	stat, err := file.Stat()
+	if err != nil {
+		return []byte{}, ObjectInfo{}, err
+	}
+	if !stat.Mode().IsRegular() {
+		return []byte{}, ObjectInfo{}, ErrFileNameInvalid
+	}
+	data = make([]byte, stat.Size())
+	if _, err := file.ReadAt(data, 0); err != nil {
+		return []byte{}, ObjectInfo{}, err
+	}
+	return data, ObjectInfo{
+		Name:         name,
+		Size:         stat.Size(),
+		ContentType:  detectContentType(data),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+// Put ...
+// writes a file into a temporary file first, then moves it into place,
+// so that a file is never seen partly written
+func (f LocalFileAccess) Put(name string, data []byte) (err error) {
+	fileName, err := f.fileName(name)
+	if err != nil {
+		return err
+	}
+	root, err := f.openRoot()
+	if err != nil {
+		return err
+	}
+	defer closeRoot(root)
+	tempName := localTempFilePrefix + rand.Text()
+	file, err := root.OpenFile(tempName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if err := root.Remove(tempName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("Failed to remove temporary file", "file", tempName, "error", err)
+		}
+	}()
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := root.Rename(tempName, fileName); err != nil {
+		return err
+	}
+	slog.Info("Successfully wrote file into directory", "file", fileName)
+	return nil
+}
+
+// List ...
+// returns the names of the files
+func (f LocalFileAccess) List() (names []string, err error) {
+	root, err := f.openRoot()
+	if err != nil {
+		return []string{}, err
+	}
+	defer closeRoot(root)
+	entries, err := fs.ReadDir(root.FS(), ".")
+	if err != nil {
+		return []string{}, err
+	}
+	prefix := objectName(f.Prefix, "")
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), localTempFilePrefix) {
+			continue
+		}
+		if name, ok := strings.CutPrefix(entry.Name(), prefix); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Delete ...
+// deletes a file, doing nothing when there isn't one
+func (f LocalFileAccess) Delete(name string) error {
+	fileName, err := f.fileName(name)
+	if err != nil {
+		return err
+	}
+	root, err := f.openRoot()
+	if err != nil {
+		return err
+	}
+	defer closeRoot(root)
+	if err := root.Remove(fileName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	slog.Info("Successfully deleted file from directory", "file", fileName)
+	return nil
+}
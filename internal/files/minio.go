@@ -0,0 +1,137 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// MinioFileAccess to access an S3 compatible backend
+type MinioFileAccess struct {
+	Client     *minio.Client
+	BucketName string
+	Prefix     string
+}
+
+// OpenMinio ...
+// open a Minio client
+func OpenMinio(endpoint string, accessKey string, secretKey string, bucketName string, useSSL bool) (MinioFileAccess, error) {
+	mc, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+	})
+	if err != nil {
+		return MinioFileAccess{}, err
+	}
+	return MinioFileAccess{Client: mc, BucketName: bucketName}, err
+}
+
+// Init to initialise a bucket
+func (f MinioFileAccess) Init() error {
+	if f.BucketName == "" {
+		return fmt.Errorf("Error: cannot initialise a bucket, because no bucket name was provided")
+	}
+	buckets, err := f.Client.ListBuckets(context.TODO())
+	if err != nil {
+		return err
+	}
+	foundBucket := false
+	for _, b := range buckets {
+		if b.Name == f.BucketName {
+			foundBucket = true
+		}
+	}
+	if foundBucket {
+		return nil
+	}
+	err = f.Client.MakeBucket(context.TODO(), f.BucketName, minio.MakeBucketOptions{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// WithPrefix ...
+// returns access to the objects named with a prefix
+func (f MinioFileAccess) WithPrefix(prefix string) FileAccess {
+	f.Prefix = prefix
+	return f
+}
+
+// Get ...
+// retrieves a given object
+func (f MinioFileAccess) Get(name string) (objectBytes []byte, info ObjectInfo, err error) {
+	fileName := objectName(f.Prefix, name)
+	object, err := f.Client.GetObject(context.TODO(), f.BucketName, fileName, minio.GetObjectOptions{})
+	if err != nil {
+		slog.Error("Failed to get object", "error", err)
+		return []byte{}, ObjectInfo{}, err
+	}
+	defer func() {
+		if err := object.Close(); err != nil {
+			slog.Error("Failed to close object", "file", fileName, "error", err)
+		}
+	}()
+	objectInfo, err := object.Stat()
+	if err != nil {
+		slog.Error("Failed to stat object", "error", err)
+		return []byte{}, ObjectInfo{}, err
+	}
+	objectBytes, err = io.ReadAll(object)
+	if err != nil {
+		slog.Error("Failed to read object", "error", err)
+		return []byte{}, ObjectInfo{}, err
+	}
+	return objectBytes, ObjectInfo{
+		Name:         name,
+		Size:         objectInfo.Size,
+		ContentType:  objectInfo.ContentType,
+		LastModified: objectInfo.LastModified,
+	}, err
+}
+
+// Put ...
+// uploads a file
+func (f MinioFileAccess) Put(name string, data []byte) error {
+	fileName := objectName(f.Prefix, name)
+	reader := bytes.NewReader(data)
+	info, err := f.Client.PutObject(context.TODO(), f.BucketName, fileName, reader, int64(reader.Len()), minio.PutObjectOptions{
+		ContentType: detectContentType(data),
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Successfully uploaded file into bucket", "file", info.Key)
+	return nil
+}
+
+// List ...
+// returns the names of the files
+func (f MinioFileAccess) List() (names []string, err error) {
+	prefix := objectName(f.Prefix, "")
+	for object := range f.Client.ListObjects(context.TODO(), f.BucketName, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return []string{}, object.Err
+		}
+		names = append(names, strings.TrimPrefix(object.Key, prefix))
+	}
+	return names, nil
+}
+
+// Delete ...
+// deletes a file
+func (f MinioFileAccess) Delete(name string) error {
+	fileName := objectName(f.Prefix, name)
+	err := f.Client.RemoveObject(context.TODO(), f.BucketName, fileName, minio.RemoveObjectOptions{})
+	if err != nil {
+		return err
+	}
+	slog.Info("Successfully deleted file into bucket", "file", fileName)
+	return nil
+}
@@ -0,0 +1,79 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	// allow decoding gif images
+	_ "image/gif"
+	"image/jpeg"
+	// allow decoding png images
+	_ "image/png"
+)
+
+// thumbnailMaxSourcePixels is the largest image which thumbnails are made of,
+// so that small files which decode into huge images aren't able to use up memory
+const thumbnailMaxSourcePixels = 50_000_000
+
+var ErrThumbnailSourceTooLarge = fmt.Errorf("Unable to make a thumbnail, as the image is too large")
+
+// Thumbnail ...
+// returns a JPEG of a JPEG, PNG or GIF image scaled to fit within maxDimension pixels,
+// averaging the pixels which are scaled into each pixel of the thumbnail
+func Thumbnail(data []byte, maxDimension int) (thumbnail []byte, err error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return []byte{}, err
+	}
+	if config.Width*config.Height > thumbnailMaxSourcePixels {
+		return []byte{}, ErrThumbnailSourceTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return []byte{}, err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return []byte{}, image.ErrFormat
+	}
+	thumbWidth, thumbHeight := width, height
+	if width > maxDimension || height > maxDimension {
+		if width >= height {
+			thumbWidth, thumbHeight = maxDimension, max(1, height*maxDimension/width)
+		} else {
+			thumbWidth, thumbHeight = max(1, width*maxDimension/height), maxDimension
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := range thumbHeight {
+		y0 := bounds.Min.Y + y*height/thumbHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/thumbHeight)
+		for x := range thumbWidth {
+			x0 := bounds.Min.X + x*width/thumbWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/thumbWidth)
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sr, sg, sb, sa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(sr), g+uint64(sg), b+uint64(sb), a+uint64(sa)
+					count++
+				}
+			}
+			// transparent pixels are shown over white, as JPEGs have no transparency
+			white := 0xffff*count - a
+			dst.Set(x, y, color.RGBA64{
+				R: uint16((r + white) / count),
+				G: uint16((g + white) / count),
+				B: uint16((b + white) / count),
+				A: 0xffff,
+			})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return []byte{}, err
+	}
+	return buf.Bytes(), nil
+}
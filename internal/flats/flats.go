@@ -0,0 +1,362 @@
+/*
+  flats
+    manage the flats which an instance hosts
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package flats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/groups"
+	"gitlab.com/flattrack/flattrack/internal/settings"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+var (
+	// FlatDefault is the flat which existing data belongs to and
+	// which is used when a request doesn't select another flat
+	FlatDefault = "default"
+	// PathPrefix begins the paths which select a flat by it's name
+	PathPrefix = "/flats/"
+)
+
+var (
+	ErrFlatNotFound        = fmt.Errorf("Unable to find flat")
+	ErrFlatInvalidName     = fmt.Errorf("Unable to use the provided flat name, as it must be between 1 and 30 lower case letters, numbers or dashes")
+	ErrFlatInvalidHostname = fmt.Errorf("Unable to use the provided hostname, as it is not a valid hostname")
+	ErrFlatNameTaken       = fmt.Errorf("Unable to use the provided flat name, as another flat already has it")
+	ErrFlatHostnameTaken   = fmt.Errorf("Unable to use the provided hostname, as another flat already has it")
+	ErrFlatDefault         = fmt.Errorf("Unable to rename or delete the default flat")
+	ErrFlatHasUsers        = fmt.Errorf("Unable to delete the flat, as it still has user accounts")
+)
+
+// flatNameRegex matches the names which flats are able to have
+var flatNameRegex = regexp.MustCompile(`^[a-z0-9-]{1,30}$`)
+
+// flatHostnameRegex matches the hostnames, with an optional port, which flats are able to be selected by
+var flatHostnameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*(:[0-9]{1,5})?$`)
+
+type Manager struct {
+	db       *sql.DB
+	ctx      context.Context
+	groups   *groups.Manager
+	settings *settings.Manager
+}
+
+func NewManager(db *sql.DB, groups *groups.Manager, settings *settings.Manager) *Manager {
+	return &Manager{
+		db:       db,
+		ctx:      context.Background(),
+		groups:   groups,
+		settings: settings,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:       m.db,
+		ctx:      ctx,
+		groups:   m.groups.WithContext(ctx),
+		settings: m.settings.WithContext(ctx),
+	}
+}
+
+// BasePath ...
+// returns the path which the requests of a flat are prefixed with, which is empty for the default flat
+func BasePath(flat types.FlatSpec) string {
+	if flat.Name == "" || flat.Name == FlatDefault {
+		return ""
+	}
+	return PathPrefix + flat.Name
+}
+
+// flatObjectFromRows ...
+// constructs a flat object from database rows
+func flatObjectFromRows(rows *sql.Rows) (flat types.FlatSpec, err error) {
+	if err := rows.Scan(&flat.ID, &flat.Name, &flat.Hostname, &flat.Initialized, &flat.CreationTimestamp, &flat.ModificationTimestamp, &flat.DeletionTimestamp); err != nil {
+		return types.FlatSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.FlatSpec{}, err
+	}
+	return flat, nil
+}
+
+// Validate ...
+// checks the name and hostname of a flat, returning it normalised
+func (m *Manager) Validate(flat types.FlatSpec) (types.FlatSpec, error) {
+	flat.Name = strings.TrimSpace(flat.Name)
+	if !flatNameRegex.MatchString(flat.Name) {
+		return types.FlatSpec{}, ErrFlatInvalidName
+	}
+	flat.Hostname = strings.ToLower(strings.TrimSpace(flat.Hostname))
+	if flat.Hostname != "" && !flatHostnameRegex.MatchString(flat.Hostname) {
+		return types.FlatSpec{}, ErrFlatInvalidHostname
+	}
+	return flat, nil
+}
+
+// List ...
+// returns all flats
+func (m *Manager) List() (flats []types.FlatSpec, err error) {
+	sqlStatement := `select * from flats where deletionTimestamp = 0 order by name`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement)
+	if err != nil {
+		return []types.FlatSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		flat, err := flatObjectFromRows(rows)
+		if err != nil {
+			return []types.FlatSpec{}, err
+		}
+		flats = append(flats, flat)
+	}
+	return flats, nil
+}
+
+// get ...
+// returns the flat which a column has a value
+func (m *Manager) get(column string, value string) (flat types.FlatSpec, err error) {
+	sqlStatement := fmt.Sprintf(`select * from flats where %v = $1 and deletionTimestamp = 0`, column)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, value)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		flat, err = flatObjectFromRows(rows)
+		if err != nil {
+			return types.FlatSpec{}, err
+		}
+	}
+	if flat.ID == "" {
+		return types.FlatSpec{}, ErrFlatNotFound
+	}
+	return flat, nil
+}
+
+// GetByID ...
+// given an id, return the flat
+func (m *Manager) GetByID(id string) (flat types.FlatSpec, err error) {
+	return m.get("id", id)
+}
+
+// GetByName ...
+// given a name, return the flat
+func (m *Manager) GetByName(name string) (flat types.FlatSpec, err error) {
+	return m.get("name", name)
+}
+
+// GetByHostname ...
+// given a hostname, return the flat which is selected by it
+func (m *Manager) GetByHostname(hostname string) (flat types.FlatSpec, err error) {
+	if hostname == "" {
+		return types.FlatSpec{}, ErrFlatNotFound
+	}
+	return m.get("hostname", strings.ToLower(hostname))
+}
+
+// GetDefault ...
+// returns the flat which is used when a request doesn't select another flat
+func (m *Manager) GetDefault() (flat types.FlatSpec, err error) {
+	return m.GetByName(FlatDefault)
+}
+
+// checkTaken ...
+// returns an error if a flat other than the given id already has the name or hostname
+func (m *Manager) checkTaken(flat types.FlatSpec, id string) (err error) {
+	if existing, err := m.GetByName(flat.Name); err == nil && existing.ID != id {
+		return ErrFlatNameTaken
+	}
+	if existing, err := m.GetByHostname(flat.Hostname); err == nil && existing.ID != id {
+		return ErrFlatHostnameTaken
+	}
+	return nil
+}
+
+// Create ...
+// creates a flat, along with it's built-in groups and default settings
+func (m *Manager) Create(flat types.FlatSpec) (flatInserted types.FlatSpec, err error) {
+	flat, err = m.Validate(flat)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	if err := m.checkTaken(flat, ""); err != nil {
+		return types.FlatSpec{}, err
+	}
+	sqlStatement := `insert into flats (name, hostname)
+                         values ($1, $2)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, flat.Name, flat.Hostname)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		flatInserted, err = flatObjectFromRows(rows)
+		if err != nil {
+			return types.FlatSpec{}, err
+		}
+	}
+	if err := m.groups.ForFlat(flatInserted.ID).CreateBuiltIn(); err != nil {
+		return types.FlatSpec{}, err
+	}
+	if err := m.settings.ForFlat(flatInserted.ID).CreateDefaults(); err != nil {
+		return types.FlatSpec{}, err
+	}
+	return flatInserted, nil
+}
+
+// Update ...
+// updates the name and hostname of a flat.
+// The default flat keeps it's name
+func (m *Manager) Update(id string, flat types.FlatSpec) (flatUpdated types.FlatSpec, err error) {
+	existing, err := m.GetByID(id)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	flat, err = m.Validate(flat)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	if existing.Name == FlatDefault && flat.Name != existing.Name {
+		return types.FlatSpec{}, ErrFlatDefault
+	}
+	if err := m.checkTaken(flat, id); err != nil {
+		return types.FlatSpec{}, err
+	}
+	sqlStatement := fmt.Sprintf(`update flats set name = $2, hostname = $3, modificationTimestamp = %v
+                         where id = $1
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, flat.Name, flat.Hostname)
+	if err != nil {
+		return types.FlatSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		flatUpdated, err = flatObjectFromRows(rows)
+		if err != nil {
+			return types.FlatSpec{}, err
+		}
+	}
+	return flatUpdated, nil
+}
+
+// EachFlat ...
+// returns scheduled work which performs work for every flat.
+// A flat failing doesn't stop the work for the others
+func (m *Manager) EachFlat(work func(flatID string) error) func() error {
+	return func() error {
+		flats, err := m.List()
+		if err != nil {
+			return err
+		}
+		errs := []error{}
+		for _, flat := range flats {
+			if err := work(flat.ID); err != nil {
+				errs = append(errs, fmt.Errorf("flat '%v': %w", flat.Name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// SetInitialized ...
+// marks a flat as having completed setup
+func (m *Manager) SetInitialized(id string) (err error) {
+	sqlStatement := fmt.Sprintf(`update flats set initialized = true, modificationTimestamp = %v where id = $1`, database.DialectOf(m.db).Now())
+	_, err = m.db.ExecContext(m.ctx, sqlStatement, id)
+	return err
+}
+
+// hasUsers ...
+// returns whether a flat has any user accounts
+func (m *Manager) hasUsers(id string) (hasUsers bool, err error) {
+	sqlStatement := `select count(*) > 0 from users where flatId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		if err := rows.Scan(&hasUsers); err != nil {
+			return false, err
+		}
+	}
+	return hasUsers, rows.Err()
+}
+
+// Delete ...
+// deletes a flat which has no user accounts, along with it's groups and settings.
+// The default flat is unable to be deleted
+func (m *Manager) Delete(id string) (err error) {
+	flat, err := m.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if flat.Name == FlatDefault {
+		return ErrFlatDefault
+	}
+	hasUsers, err := m.hasUsers(id)
+	if err != nil {
+		return err
+	}
+	if hasUsers {
+		return ErrFlatHasUsers
+	}
+	for _, sqlStatement := range []string{
+		`delete from settings where flatId = $1`,
+		`delete from groups where flatId = $1`,
+		`delete from user_auth_attempt where flatId = $1`,
+		`delete from flats where id = $1`,
+	} {
+		if _, err := m.db.ExecContext(m.ctx, sqlStatement, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package flattrack
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+
+	"gitlab.com/flattrack/flattrack/internal/backups"
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/migrations"
+	"gitlab.com/flattrack/flattrack/internal/system"
+)
+
+var (
+	ErrCommandUsage             = fmt.Errorf("Unable to run command, usage: flattrack [backup FILE | restore FILE | copy-database SOURCE DESTINATION | migrate [status | pending | up | to VERSION | rollback | force VERSION]]")
+	ErrCopyDatabaseSameAsSource = fmt.Errorf("Unable to copy database, as the source and destination are the same")
+	ErrMigrateVersionInvalid    = fmt.Errorf("Unable to migrate database, as the version must be a number")
+)
+
+// command ...
+// a task which is run from the command line instead of serving
+type command struct {
+	name string
+	run  func(m *commandManagers, args []string) error
+	// runWithoutDatabase is used instead of run by commands which connect to the databases in their arguments,
+	// rather than the one which is configured
+	runWithoutDatabase func(args []string) error
+	// managesMigrations is set by commands which migrate the database themselves,
+	// so that it isn't migrated before they run
+	managesMigrations bool
+}
+
+// commandManagers ...
+// the managers which commands use
+type commandManagers struct {
+	migrations *migrations.Manager
+	backups    *backups.Manager
+}
+
+var commands = []command{
+	{
+		name: "backup",
+		run:  runBackup,
+	},
+	{
+		name: "restore",
+		run:  runRestore,
+	},
+	{
+		name:               "copy-database",
+		runWithoutDatabase: runCopyDatabase,
+	},
+	{
+		name:              "migrate",
+		run:               runMigrate,
+		managesMigrations: true,
+	},
+}
+
+// RunCommand ...
+// runs the command given by args, such as `backup FILE`.
+// Logs are written to stderr, so that they are kept apart from any output
+func RunCommand(args []string) error {
+	setupLogging(os.Stderr)
+	_ = godotenv.Load(common.GetAppEnvFile())
+	if len(args) == 0 {
+		return ErrCommandUsage
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if c.runWithoutDatabase != nil {
+			return c.runWithoutDatabase(args[1:])
+		}
+		db, err := database.Open()
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				slog.Error("failed to close database", "error", err)
+			}
+		}()
+		migrations := migrations.NewManager(db)
+		if !c.managesMigrations {
+			if err := migrations.Migrate(); err != nil {
+				return fmt.Errorf("failed to migrate database: %w", err)
+			}
+		}
+		return c.run(&commandManagers{
+			migrations: migrations,
+			backups:    backups.NewManager(db, migrations, system.NewManager(db)),
+		}, args[1:])
+	}
+	return fmt.Errorf("%w: unknown command '%v'", ErrCommandUsage, args[0])
+}
+
+// runBackup ...
+// writes a backup archive of the instance to a file
+func runBackup(m *commandManagers, args []string) error {
+	if len(args) != 1 {
+		return ErrCommandUsage
+	}
+	file, err := os.OpenFile(args[0], os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	backup, err := m.backups.Write(file)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(args[0])
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	slog.Info("Wrote backup", "file", args[0], "migrationVersion", backup.MigrationVersion)
+	return nil
+}
+
+// runRestore ...
+// replaces all the data of the instance with a backup archive from a file
+func runRestore(m *commandManagers, args []string) error {
+	if len(args) != 1 {
+		return ErrCommandUsage
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Error("failed to close file", "error", err)
+		}
+	}()
+	backup, err := m.backups.Restore(file)
+	if err != nil {
+		return err
+	}
+	slog.Info("Restored backup", "file", args[0], "creationTimestamp", backup.CreationTimestamp)
+	return nil
+}
+
+// openBackups ...
+// connects to the database of a connection string, returning it's migrations and backups managers.
+// The database isn't migrated, so that copying never changes the schema of either database
+func openBackups(connectionString string) (db *sql.DB, migrationsManager *migrations.Manager, backupsManager *backups.Manager, err error) {
+	db, err = database.OpenConnectionString(connectionString)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := database.Ping(db); err != nil {
+		_ = db.Close()
+		return nil, nil, nil, err
+	}
+	migrationsManager = migrations.NewManager(db)
+	return db, migrationsManager, backups.NewManager(db, migrationsManager, system.NewManager(db)), nil
+}
+
+// runCopyDatabase ...
+// replaces all the data in a database with the data of another, given their connection strings,
+// such as for moving an instance from Postgres to SQLite
+func runCopyDatabase(args []string) error {
+	if len(args) != 2 {
+		return ErrCommandUsage
+	}
+	if args[0] == args[1] {
+		return ErrCopyDatabaseSameAsSource
+	}
+	sourceDB, _, source, err := openBackups(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to connect to source database: %w", err)
+	}
+	defer func() {
+		if err := sourceDB.Close(); err != nil {
+			slog.Error("failed to close source database", "error", err)
+		}
+	}()
+	destinationDB, destinationMigrations, destination, err := openBackups(args[1])
+	if err != nil {
+		return fmt.Errorf("failed to connect to destination database: %w", err)
+	}
+	defer func() {
+		if err := destinationDB.Close(); err != nil {
+			slog.Error("failed to close destination database", "error", err)
+		}
+	}()
+	// a new destination has no schema yet, so it's migrated like FlatTrack does when it starts.
+	// Otherwise both must already be at the same version, which is checked when copying
+	destinationVersion, _, err := destinationMigrations.Version()
+	if err != nil {
+		return fmt.Errorf("failed to get destination database version: %w", err)
+	}
+	if destinationVersion == 0 {
+		if err := destinationMigrations.Migrate(); err != nil {
+			return fmt.Errorf("failed to migrate destination database: %w", err)
+		}
+	}
+	counts, err := source.CopyTo(destination)
+	if err != nil {
+		return err
+	}
+	slog.Info("Copied database", "source", database.DialectOfConnectionString(args[0]), "destination", database.DialectOfConnectionString(args[1]), "rows", counts)
+	return nil
+}
+
+// parseMigrationVersion ...
+// returns the version of a migration given as an argument
+func parseMigrationVersion(args []string) (version uint, err error) {
+	if len(args) != 1 {
+		return 0, ErrCommandUsage
+	}
+	parsed, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%v'", ErrMigrateVersionInvalid, args[0])
+	}
+	return uint(parsed), nil
+}
+
+// runMigrate ...
+// shows or changes the database schema version, such as rolling back the latest migration.
+// The version and migrations are written to stdout
+func runMigrate(m *commandManagers, args []string) error {
+	if len(args) == 0 {
+		return ErrCommandUsage
+	}
+	switch args[0] {
+	case "status":
+		if len(args) != 1 {
+			return ErrCommandUsage
+		}
+		version, dirty, err := m.migrations.Version()
+		if err != nil {
+			return err
+		}
+		pending, err := m.migrations.Pending()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version: %v\ndirty: %v\npending: %v\n", version, dirty, len(pending))
+		if dirty {
+			slog.Warn("A migration failed part way through. Repair the database by hand, then run 'flattrack migrate force VERSION' with the version it is at", "version", version)
+		}
+		return nil
+	case "pending":
+		if len(args) != 1 {
+			return ErrCommandUsage
+		}
+		pending, err := m.migrations.Pending()
+		if err != nil {
+			return err
+		}
+		for _, migration := range pending {
+			fmt.Printf("%v %v\n", migration.Version, migration.Name)
+		}
+		return nil
+	case "up":
+		if len(args) != 1 {
+			return ErrCommandUsage
+		}
+		return m.migrations.Migrate()
+	case "to":
+		version, err := parseMigrationVersion(args[1:])
+		if err != nil {
+			return err
+		}
+		return m.migrations.MigrateTo(version)
+	case "rollback":
+		if len(args) != 1 {
+			return ErrCommandUsage
+		}
+		return m.migrations.Rollback()
+	case "force":
+		version, err := parseMigrationVersion(args[1:])
+		if err != nil {
+			return err
+		}
+		return m.migrations.Force(version)
+	}
+	return fmt.Errorf("%w: unknown migrate command '%v'", ErrCommandUsage, args[0])
+}
@@ -1,27 +1,39 @@
 package flattrack
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
 	"github.com/joho/godotenv"
 
+	"gitlab.com/flattrack/flattrack/internal/backups"
 	"gitlab.com/flattrack/flattrack/internal/common"
 	"gitlab.com/flattrack/flattrack/internal/database"
 	"gitlab.com/flattrack/flattrack/internal/emails"
+	"gitlab.com/flattrack/flattrack/internal/expenses"
+	"gitlab.com/flattrack/flattrack/internal/files"
+	"gitlab.com/flattrack/flattrack/internal/flats"
 	"gitlab.com/flattrack/flattrack/internal/groups"
 	"gitlab.com/flattrack/flattrack/internal/health"
 	"gitlab.com/flattrack/flattrack/internal/httpserver"
 	"gitlab.com/flattrack/flattrack/internal/metrics"
 	"gitlab.com/flattrack/flattrack/internal/migrations"
+	"gitlab.com/flattrack/flattrack/internal/oidc"
 	"gitlab.com/flattrack/flattrack/internal/registration"
 	"gitlab.com/flattrack/flattrack/internal/scheduling"
 	"gitlab.com/flattrack/flattrack/internal/settings"
 	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
 	"gitlab.com/flattrack/flattrack/internal/system"
+	"gitlab.com/flattrack/flattrack/internal/tasks"
 	"gitlab.com/flattrack/flattrack/internal/users"
+	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
+var ErrDatabaseSchemaDirty = fmt.Errorf("Unable to start, as a database migration failed part way through and left the schema dirty. Repair the database by hand, then run 'flattrack migrate force VERSION' with the version it is at")
+
 type manager struct {
 	httpserver   *httpserver.HTTPServer
 	metrics      *metrics.Manager
@@ -33,18 +45,25 @@ type manager struct {
 	settings     *settings.Manager
 	system       *system.Manager
 	scheduling   *scheduling.Manager
+	shoppinglist *shoppinglist.Manager
 
 	maintenanceMode bool
 }
 
-func NewManager() *manager {
+// setupLogging ...
+// sets the default logger to write to w
+func setupLogging(w io.Writer) {
 	slog.SetDefault(
 		slog.New(slog.NewTextHandler(
-			os.Stdout,
+			w,
 			&slog.HandlerOptions{AddSource: true, ReplaceAttr: common.SLogReplaceAttr()},
 		)),
 	)
 	slog.SetLogLoggerLevel(common.GetLogLevel())
+}
+
+func NewManager() *manager {
+	setupLogging(os.Stdout)
 	slog.Info("launching FlatTrack",
 		slog.String("buildVersion", common.GetAppBuildVersion()),
 		slog.String("buildHash", common.GetAppBuildHash()),
@@ -61,20 +80,48 @@ func NewManager() *manager {
 	}
 	users := users.NewManager(db)
 	settings := settings.NewManager(db)
-	shoppinglist := shoppinglist.NewManager(db, settings)
+	fileAccess, err := files.OpenFromEnv()
+	if err != nil {
+		slog.Error("failed to open file storage", "error", err)
+	}
+	shoppinglist := shoppinglist.NewManager(db, settings, fileAccess)
+	tasks := tasks.NewManager(db, users)
+	expenses := expenses.NewManager(db, users, shoppinglist)
 	emails := emails.NewManager()
 	groups := groups.NewManager(db)
 	health := health.NewManager(db)
 	migrations := migrations.NewManager(db)
 	system := system.NewManager(db)
-	registration := registration.NewManager(users, system, settings)
+	oidc := oidc.NewManager(db, users, groups)
+	flats := flats.NewManager(db, groups, settings)
+	registration := registration.NewManager(users, flats, settings)
+	backups := backups.NewManager(db, migrations, system)
 	metrics := metrics.NewManager()
 	scheduling := scheduling.NewManager(db, system).
-		RegisterCronFunc(shoppinglist.ShoppingList().DeleteCleanup()).
+		RegisterCronFunc(types.CronTabScheduleShoppingListCleanup, flats.EachFlat(func(flatID string) error {
+			return shoppinglist.ForFlat(flatID).ShoppingList().DeleteCleanup()
+		})).
+		RegisterCronFunc(types.CronTabScheduleTaskOccurrences, flats.EachFlat(func(flatID string) error {
+			return tasks.ForFlat(flatID).Task().GenerateOccurrences()
+		})).
 		RegisterFunc(shoppinglist.ShoppingList().UntemplateListsFromDeletedLists).
 		RegisterFunc(shoppinglist.ShoppingItem().UntemplateItemsFromDeletedLists).
-		RegisterFunc(users.RemoveUnreferencedDeletedUsers)
-	httpserver := httpserver.NewHTTPServer(db, users, shoppinglist, emails, groups, health, migrations, registration, settings, system, scheduling, maintenanceMode)
+		RegisterFunc(users.UserPasswordResetSecrets().DeleteExpired).
+		RegisterFunc(users.UserSessions().DeleteExpired).
+		RegisterFunc(users.UserAuthChallenges().DeleteExpired).
+		RegisterFunc(users.UserAuthAttempts().DeleteExpired).
+		RegisterFunc(users.UserAccessTokens().DeleteExpired).
+		RegisterFunc(flats.EachFlat(func(flatID string) error {
+			return users.ForFlat(flatID).DisableExpired()
+		})).
+		RegisterFunc(oidc.AuthStates().DeleteExpired).
+		RegisterFunc(flats.EachFlat(func(flatID string) error {
+			return users.ForFlat(flatID).RemoveUnreferencedDeletedUsers()
+		}))
+	if keepDaily, keepWeekly := common.GetBackupKeepDaily(), common.GetBackupKeepWeekly(); fileAccess != nil && (keepDaily > 0 || keepWeekly > 0) {
+		scheduling.RegisterCronFunc(types.CronTabScheduleBackup, backups.Scheduled(fileAccess, keepDaily, keepWeekly))
+	}
+	httpserver := httpserver.NewHTTPServer(db, users, shoppinglist, emails, groups, health, migrations, registration, settings, system, scheduling, tasks, expenses, oidc, flats, backups, maintenanceMode)
 	return &manager{
 		httpserver:      httpserver,
 		metrics:         metrics,
@@ -86,6 +133,7 @@ func NewManager() *manager {
 		settings:        settings,
 		system:          system,
 		scheduling:      scheduling,
+		shoppinglist:    shoppinglist,
 		maintenanceMode: maintenanceMode,
 	}
 }
@@ -96,13 +144,19 @@ type managerInit struct {
 	health       *health.Manager
 	registration *registration.Manager
 	scheduling   *scheduling.Manager
+	shoppinglist *shoppinglist.Manager
 
 	maintenanceMode bool
 }
 
-func (m *manager) Init() *managerInit {
+// Init ...
+// migrates the database, refusing to serve when it is unable to be migrated
+func (m *manager) Init() (*managerInit, error) {
 	if err := m.migrations.Migrate(); err != nil && !m.maintenanceMode {
-		slog.Error("failed to migrate database", "error", err)
+		if errors.Is(err, migrations.ErrMigrationDirty) {
+			return nil, fmt.Errorf("%w: %w", ErrDatabaseSchemaDirty, err)
+		}
+		return nil, err
 	}
 	return &managerInit{
 		httpserver:      m.httpserver,
@@ -110,8 +164,9 @@ func (m *manager) Init() *managerInit {
 		health:          m.health,
 		registration:    m.registration,
 		scheduling:      m.scheduling,
+		shoppinglist:    m.shoppinglist,
 		maintenanceMode: m.maintenanceMode,
-	}
+	}, nil
 }
 
 func (mi *managerInit) Run() {
@@ -119,6 +174,7 @@ func (mi *managerInit) Run() {
 	go mi.health.Listen()
 	if !mi.maintenanceMode {
 		go mi.scheduling.Run()
+		go mi.shoppinglist.ShoppingItemEvent().Listen(database.GetConnectionString())
 	} else {
 		slog.Info("Instance in maintenance mode. Will only serve message stating as such.")
 	}
@@ -19,25 +19,99 @@
 package groups
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
 
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 var (
 	GroupFlatmember = "flatmember"
 	GroupAdmin      = "admin"
+	GroupGuest      = "guest"
+)
+
+var (
+	ErrGroupNotFound           = fmt.Errorf("Unable to find group")
+	ErrGroupInvalidName        = fmt.Errorf("Unable to use the provided group name, as it must be between 1 and 30 lower case letters, numbers or dashes")
+	ErrGroupNameTaken          = fmt.Errorf("Unable to use the provided group name, as another group already has it")
+	ErrGroupInvalidDescription = fmt.Errorf("Unable to use the provided group description, as it is too long")
+	ErrGroupInvalidPermission  = fmt.Errorf("Unable to use the provided permissions, as some of them are unknown")
+	ErrGroupBuiltIn            = fmt.Errorf("Unable to rename or delete the flatmember, admin or guest groups, or change the permissions of the admin group")
+	ErrGroupHasMembers         = fmt.Errorf("Unable to delete the group, as user accounts are still in it")
 )
 
+// groupNameRegex matches the names which groups are able to have
+var groupNameRegex = regexp.MustCompile(`^[a-z0-9-]{1,30}$`)
+
+// groupDescriptionMaxLength is the longest description of a group
+const groupDescriptionMaxLength = 200
+
+// builtInGroups ...
+// the groups which every flat is created with
+var builtInGroups = []types.GroupSpec{
+	{
+		Name:         GroupFlatmember,
+		DefaultGroup: true,
+		Description:  "Standard user account",
+		Permissions: []string{
+			PermissionShoppingListRead, PermissionShoppingListWrite,
+			PermissionTasksRead, PermissionTasksWrite,
+			PermissionExpensesRead, PermissionExpensesWrite,
+			PermissionFlatmatesRead,
+		},
+	},
+	{
+		Name:        GroupAdmin,
+		Description: "Administrative user account, allows for access to Admin panel and API",
+		Permissions: adminPermissions(),
+	},
+	{
+		Name:        GroupGuest,
+		Description: "Temporary user account, such as for someone subletting a room",
+		Permissions: []string{PermissionShoppingListRead, PermissionShoppingListWrite},
+	},
+}
+
 type Manager struct {
-	db *sql.DB
+	db     *sql.DB
+	ctx    context.Context
+	flatID string
 }
 
 func NewManager(db *sql.DB) *Manager {
 	return &Manager{
-		db: db,
+		db:  db,
+		ctx: context.Background(),
+	}
+}
+
+// ForFlat ...
+// returns a manager for the groups of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    m.ctx,
+		flatID: flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    ctx,
+		flatID: m.flatID,
 	}
 }
 
@@ -45,7 +119,7 @@ func NewManager(db *sql.DB) *Manager {
 // given a userID and a groupID, adds a user to a group
 func (m *Manager) AddUserToGroup(userID string, groupID string) (err error) {
 	sqlStatement := `insert into user_to_groups (userid, groupid) values ($1, $2)`
-	rows, err := m.db.Query(sqlStatement, userID, groupID)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, groupID)
 	if err != nil {
 		return err
 	}
@@ -61,7 +135,7 @@ func (m *Manager) AddUserToGroup(userID string, groupID string) (err error) {
 // given a userID and a groupID, removes a user from a group
 func (m *Manager) RemoveUserFromGroup(userID string, groupID string) (err error) {
 	sqlStatement := `delete from user_to_groups where userid = $1 and groupid = $2`
-	rows, err := m.db.Query(sqlStatement, userID, groupID)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, groupID)
 	if err != nil {
 		return err
 	}
@@ -73,25 +147,25 @@ func (m *Manager) RemoveUserFromGroup(userID string, groupID string) (err error)
 	return nil
 }
 
-// groupObjectFromRows ...
-// constructs a group object from database rows
-func groupObjectFromRows(rows *sql.Rows) (group types.GroupSpec, err error) {
-	err = rows.Scan(&group.ID, &group.Name, &group.DefaultGroup, &group.Description, &group.CreationTimestamp, &group.ModificationTimestamp, &group.DeletionTimestamp)
-	if err != nil {
-		return types.GroupSpec{}, err
-	}
-	err = rows.Err()
-	if err != nil {
-		return types.GroupSpec{}, err
-	}
-	return group, nil
+// GroupColumns ...
+// the columns of groups, along with the fields of a GroupSpec which they are scanned into
+var GroupColumns = database.Columns[types.GroupSpec]{
+	{Name: "id", Field: func(group *types.GroupSpec) any { return &group.ID }},
+	{Name: "name", Field: func(group *types.GroupSpec) any { return &group.Name }},
+	{Name: "defaultGroup", Field: func(group *types.GroupSpec) any { return &group.DefaultGroup }},
+	{Name: "description", Field: func(group *types.GroupSpec) any { return &group.Description }},
+	{Name: "creationTimestamp", Field: func(group *types.GroupSpec) any { return &group.CreationTimestamp }},
+	{Name: "modificationTimestamp", Field: func(group *types.GroupSpec) any { return &group.ModificationTimestamp }},
+	{Name: "deletionTimestamp", Field: func(group *types.GroupSpec) any { return &group.DeletionTimestamp }},
+	{Name: "permissions", Field: func(group *types.GroupSpec) any { return pq.Array(&group.Permissions) }},
+	{Name: "flatId", Field: func(group *types.GroupSpec) any { return &group.FlatID }},
 }
 
 // List ...
 // returns a list of all groups
 func (m *Manager) List() (groups []types.GroupSpec, err error) {
-	sqlStatement := `select * from groups where deletionTimestamp = 0`
-	rows, err := m.db.Query(sqlStatement)
+	sqlStatement := fmt.Sprintf(`select %v from groups where flatId = $1 and deletionTimestamp = 0`, GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID)
 	if err != nil {
 		return []types.GroupSpec{}, err
 	}
@@ -105,7 +179,7 @@ func (m *Manager) List() (groups []types.GroupSpec, err error) {
 	}()
 	for rows.Next() {
 		var group types.GroupSpec
-		group, err = groupObjectFromRows(rows)
+		group, err = GroupColumns.Scan(rows)
 		if err != nil {
 			return []types.GroupSpec{}, err
 		}
@@ -117,8 +191,8 @@ func (m *Manager) List() (groups []types.GroupSpec, err error) {
 // GetByName ...
 // given a group name, return the group
 func (m *Manager) GetByName(name string) (group types.GroupSpec, err error) {
-	sqlStatement := `select * from groups where name = $1`
-	rows, err := m.db.Query(sqlStatement, name)
+	sqlStatement := fmt.Sprintf(`select %v from groups where flatId = $1 and name = $2`, GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, name)
 	if err != nil {
 		return types.GroupSpec{}, err
 	}
@@ -128,7 +202,7 @@ func (m *Manager) GetByName(name string) (group types.GroupSpec, err error) {
 		}
 	}()
 	rows.Next()
-	group, err = groupObjectFromRows(rows)
+	group, err = GroupColumns.Scan(rows)
 	if err != nil {
 		return types.GroupSpec{}, err
 	}
@@ -138,8 +212,8 @@ func (m *Manager) GetByName(name string) (group types.GroupSpec, err error) {
 // GetByID ...
 // given a group id, return the group
 func (m *Manager) GetByID(id string) (group types.GroupSpec, err error) {
-	sqlStatement := `select * from groups where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := fmt.Sprintf(`select %v from groups where flatId = $1 and id = $2`, GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return types.GroupSpec{}, err
 	}
@@ -149,7 +223,7 @@ func (m *Manager) GetByID(id string) (group types.GroupSpec, err error) {
 		}
 	}()
 	rows.Next()
-	group, err = groupObjectFromRows(rows)
+	group, err = GroupColumns.Scan(rows)
 	if err != nil {
 		return types.GroupSpec{}, err
 	}
@@ -161,7 +235,7 @@ func (m *Manager) GetByID(id string) (group types.GroupSpec, err error) {
 func (m *Manager) GetGroupsOfUserByID(userID string) (groups []types.GroupSpec, err error) {
 	var groupIDs []string
 	sqlStatement := `select groupid from user_to_groups where userid = $1`
-	rows, err := m.db.Query(sqlStatement, userID)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
 	if err != nil {
 		return []types.GroupSpec{}, err
 	}
@@ -218,8 +292,8 @@ func (m *Manager) CheckUserInGroup(userID string, group string) (found bool, err
 // GetDefault ...
 // return a list of default groups
 func (m *Manager) GetDefault() (groups []types.GroupSpec, err error) {
-	sqlStatement := `select * from groups where defaultGroup = true`
-	rows, err := m.db.Query(sqlStatement)
+	sqlStatement := fmt.Sprintf(`select %v from groups where flatId = $1 and defaultGroup = true`, GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID)
 	if err != nil {
 		return []types.GroupSpec{}, err
 	}
@@ -230,7 +304,7 @@ func (m *Manager) GetDefault() (groups []types.GroupSpec, err error) {
 	}()
 	for rows.Next() {
 		var group types.GroupSpec
-		group, err = groupObjectFromRows(rows)
+		group, err = GroupColumns.Scan(rows)
 		if err != nil {
 			return []types.GroupSpec{}, err
 		}
@@ -270,3 +344,180 @@ func (m *Manager) UpdateUserGroups(userID string, groups []string) (err error) {
 	}
 	return nil
 }
+
+// isBuiltIn ...
+// returns whether a group is one which FlatTrack relies on existing
+func isBuiltIn(name string) bool {
+	return name == GroupFlatmember || name == GroupAdmin || name == GroupGuest
+}
+
+// Validate ...
+// checks the name, description and permissions of a group, returning it normalised
+func (m *Manager) Validate(group types.GroupSpec) (types.GroupSpec, error) {
+	group.Name = strings.TrimSpace(group.Name)
+	if !groupNameRegex.MatchString(group.Name) {
+		return types.GroupSpec{}, ErrGroupInvalidName
+	}
+	group.Description = strings.TrimSpace(group.Description)
+	if len(group.Description) > groupDescriptionMaxLength {
+		return types.GroupSpec{}, ErrGroupInvalidDescription
+	}
+	permissions := []string{}
+	for _, permission := range group.Permissions {
+		if !PermissionExists(permission) {
+			return types.GroupSpec{}, ErrGroupInvalidPermission
+		}
+		if !slices.Contains(permissions, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	group.Permissions = permissions
+	return group, nil
+}
+
+// nameTaken ...
+// returns whether a group other than the given id already has a name
+func (m *Manager) nameTaken(name string, id string) (taken bool, err error) {
+	sqlStatement := `select count(*) > 0 from groups where flatId = $1 and name = $2 and id != $3`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, name, id)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		if err := rows.Scan(&taken); err != nil {
+			return false, err
+		}
+	}
+	return taken, rows.Err()
+}
+
+// Create ...
+// creates a group with a set of permissions
+func (m *Manager) Create(group types.GroupSpec) (groupInserted types.GroupSpec, err error) {
+	group, err = m.Validate(group)
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	taken, err := m.nameTaken(group.Name, "")
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	if taken {
+		return types.GroupSpec{}, ErrGroupNameTaken
+	}
+	sqlStatement := fmt.Sprintf(`insert into groups (name, defaultGroup, description, permissions, flatId)
+                         values ($1, $2, $3, $4, $5)
+                         returning %v`, GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, group.Name, group.DefaultGroup, group.Description, pq.Array(group.Permissions), m.flatID)
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		groupInserted, err = GroupColumns.Scan(rows)
+		if err != nil {
+			return types.GroupSpec{}, err
+		}
+	}
+	return groupInserted, nil
+}
+
+// Update ...
+// updates the name, description, default and permissions of a group.
+// The flatmember and admin groups keep their names, and the admin group keeps every admin permission
+func (m *Manager) Update(id string, group types.GroupSpec) (groupUpdated types.GroupSpec, err error) {
+	existing, err := m.GetByID(id)
+	if err != nil || existing.ID == "" {
+		return types.GroupSpec{}, ErrGroupNotFound
+	}
+	group, err = m.Validate(group)
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	if isBuiltIn(existing.Name) && group.Name != existing.Name {
+		return types.GroupSpec{}, ErrGroupBuiltIn
+	}
+	if existing.Name == GroupAdmin {
+		for _, permission := range adminPermissions() {
+			if !slices.Contains(group.Permissions, permission) {
+				return types.GroupSpec{}, ErrGroupBuiltIn
+			}
+		}
+	}
+	taken, err := m.nameTaken(group.Name, id)
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	if taken {
+		return types.GroupSpec{}, ErrGroupNameTaken
+	}
+	sqlStatement := fmt.Sprintf(`update groups set name = $1, defaultGroup = $2, description = $3, permissions = $4, modificationTimestamp = %v
+                         where flatId = $5 and id = $6
+                         returning %v`, database.DialectOf(m.db).Now(), GroupColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, group.Name, group.DefaultGroup, group.Description, pq.Array(group.Permissions), m.flatID, id)
+	if err != nil {
+		return types.GroupSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		groupUpdated, err = GroupColumns.Scan(rows)
+		if err != nil {
+			return types.GroupSpec{}, err
+		}
+	}
+	return groupUpdated, nil
+}
+
+// Delete ...
+// deletes a group which no user accounts are in.
+// The flatmember and admin groups are unable to be deleted
+func (m *Manager) Delete(id string) (err error) {
+	group, err := m.GetByID(id)
+	if err != nil || group.ID == "" {
+		return ErrGroupNotFound
+	}
+	if isBuiltIn(group.Name) {
+		return ErrGroupBuiltIn
+	}
+	sqlStatement := `delete from groups
+                         where flatId = $1 and id = $2 and not exists (select 1 from user_to_groups where groupId = $2)`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, m.flatID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrGroupHasMembers
+	}
+	return nil
+}
+
+// CreateBuiltIn ...
+// creates the groups which FlatTrack relies on existing, for a new flat
+func (m *Manager) CreateBuiltIn() (err error) {
+	sqlStatement := `insert into groups (name, defaultGroup, description, permissions, flatId)
+                         values ($1, $2, $3, $4, $5)
+                         on conflict (flatId, name) do nothing`
+	for _, group := range builtInGroups {
+		if _, err := m.db.ExecContext(m.ctx, sqlStatement, group.Name, group.DefaultGroup, group.Description, pq.Array(group.Permissions), m.flatID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+/*
+  groups
+    permissions
+      what the groups of user accounts allow them to do
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package groups
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	PermissionShoppingListRead  = "shoppinglist:read"
+	PermissionShoppingListWrite = "shoppinglist:write"
+	PermissionTasksRead         = "tasks:read"
+	PermissionTasksWrite        = "tasks:write"
+	PermissionExpensesRead      = "expenses:read"
+	PermissionExpensesWrite     = "expenses:write"
+	PermissionFlatmatesRead     = "flatmates:read"
+	PermissionAdminSettings     = "admin:settings"
+	PermissionAdminUsers        = "admin:users"
+	PermissionAdminGroups       = "admin:groups"
+
+	// permissionAdminPrefix begins the permissions which use admin features
+	permissionAdminPrefix = "admin:"
+)
+
+// Permissions ...
+// the permissions which groups are able to grant
+var Permissions = []types.GroupPermissionSpec{
+	{Name: PermissionShoppingListRead, Description: "View shopping lists, items and tags"},
+	{Name: PermissionShoppingListWrite, Description: "Create and change shopping lists, items and tags"},
+	{Name: PermissionTasksRead, Description: "View tasks and who they are assigned to"},
+	{Name: PermissionTasksWrite, Description: "Create, change and complete tasks"},
+	{Name: PermissionExpensesRead, Description: "View expenses and balances"},
+	{Name: PermissionExpensesWrite, Description: "Record and change expenses"},
+	{Name: PermissionFlatmatesRead, Description: "View flatmates, groups and flat information"},
+	{Name: PermissionAdminSettings, Description: "Manage the settings of the flat"},
+	{Name: PermissionAdminUsers, Description: "Manage the accounts of flatmates"},
+	{Name: PermissionAdminGroups, Description: "Manage groups and their permissions"},
+}
+
+// PermissionExists ...
+// returns whether a permission is able to be granted
+func PermissionExists(permission string) bool {
+	for _, p := range Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdminPermission ...
+// returns whether a permission uses admin features
+func IsAdminPermission(permission string) bool {
+	return strings.HasPrefix(permission, permissionAdminPrefix)
+}
+
+// adminPermissions ...
+// returns every permission which uses admin features, which the admin group always has
+func adminPermissions() (permissions []string) {
+	for _, p := range Permissions {
+		if IsAdminPermission(p.Name) {
+			permissions = append(permissions, p.Name)
+		}
+	}
+	return permissions
+}
+
+// GetPermissionsOfUserByID ...
+// given a userID, return the permissions granted by all of the groups which the user account belongs to
+func (m *Manager) GetPermissionsOfUserByID(userID string) (permissions []string, err error) {
+	sqlStatement := fmt.Sprintf(`select distinct p.value from groups g
+                         join user_to_groups ug on ug.groupId = g.id
+                         cross join %v
+                         where ug.userId = $1 and g.flatId = $2 and g.deletionTimestamp = 0`, database.DialectOf(m.db).ArrayElements(`g.permissions`, `p`))
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, m.flatID)
+	if err != nil {
+		return []string{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return []string{}, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		return []string{}, err
+	}
+	return permissions, nil
+}
+
+// CheckUserHasPermission ...
+// return bool if any of the groups of a user grants a permission
+func (m *Manager) CheckUserHasPermission(userID string, permission string) (found bool, err error) {
+	permissions, err := m.GetPermissionsOfUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(permissions, permission), nil
+}
+
+// CheckUserMayGrantGroups ...
+// returns whether a user holds every permission which the named groups grant,
+// so that nobody is able to give an account more access than they have themselves.
+// Users who manage groups are able to grant any permission already, so may grant any group.
+// Names which aren't groups are left for validating the account
+func (m *Manager) CheckUserMayGrantGroups(userID string, groupNames []string) (allowed bool, err error) {
+	permissions, err := m.GetPermissionsOfUserByID(userID)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(permissions, PermissionAdminGroups) {
+		return true, nil
+	}
+	groups, err := m.List()
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		if !slices.Contains(groupNames, group.Name) {
+			continue
+		}
+		for _, permission := range group.Permissions {
+			if !slices.Contains(permissions, permission) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
@@ -2,12 +2,17 @@ package httpserver
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/flats"
+	"gitlab.com/flattrack/flattrack/internal/oidc"
+	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
@@ -50,13 +55,42 @@ func GetRequestIP(r *http.Request) (requestIP string) {
 	return headerValue
 }
 
+// GetRequestSession ...
+// returns the device which a request is from, for creating a session with
+func GetRequestSession(r *http.Request) types.UserSessionSpec {
+	requestIP := GetRequestIP(r)
+	if host, _, err := net.SplitHostPort(requestIP); err == nil {
+		requestIP = host
+	}
+	return types.UserSessionSpec{
+		UserAgent: r.UserAgent(),
+		IPAddress: requestIP,
+	}
+}
+
+// flatFromRequest ...
+// returns the flat which a request was selected for
+func flatFromRequest(r *http.Request) types.FlatSpec {
+	flat, _ := r.Context().Value(types.RequestContextKeyFlat).(types.FlatSpec)
+	return flat
+}
+
+// tokenCookiePath ...
+// returns the path of the token cookie, so each flat selected by path has it's own
+func (h *HTTPServer) tokenCookiePath() string {
+	if path := flats.BasePath(h.flat); path != "" {
+		return path
+	}
+	return "/"
+}
+
 // SetTokenCookie ...
 // sets the auth token in the token cookie
 func (h *HTTPServer) SetTokenCookie(w http.ResponseWriter, token string) {
 	secure := h.instanceURL == nil || h.instanceURL != nil && h.instanceURL.Scheme != "http"
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
-		Path:     "/",
+		Path:     h.tokenCookiePath(),
 		Value:    token,
 		MaxAge:   60 * 60 * 24 * 7,
 		HttpOnly: true,
@@ -71,7 +105,7 @@ func (h *HTTPServer) ClearTokenCookie(w http.ResponseWriter) {
 	secure := h.instanceURL == nil || h.instanceURL != nil && h.instanceURL.Scheme != "http"
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
-		Path:     "/",
+		Path:     h.tokenCookiePath(),
 		Value:    "",
 		Expires:  time.Unix(0, 0),
 		HttpOnly: true,
@@ -79,3 +113,63 @@ func (h *HTTPServer) ClearTokenCookie(w http.ResponseWriter) {
 		SameSite: http.SameSiteStrictMode,
 	})
 }
+
+// SetOIDCStateCookie ...
+// sets the cookie which binds a single sign-on login to the browser which started it.
+// It's sent along with the identity provider's redirect back, which is a request from another site
+func (h *HTTPServer) SetOIDCStateCookie(w http.ResponseWriter, browserState string) {
+	secure := h.instanceURL == nil || h.instanceURL != nil && h.instanceURL.Scheme != "http"
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oidc_state",
+		Path:     h.tokenCookiePath(),
+		Value:    browserState,
+		MaxAge:   int(oidc.AuthStateExpiry.Seconds()),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearOIDCStateCookie ...
+// clears the single sign-on login cookie
+func (h *HTTPServer) ClearOIDCStateCookie(w http.ResponseWriter) {
+	secure := h.instanceURL == nil || h.instanceURL != nil && h.instanceURL.Scheme != "http"
+	http.SetCookie(w, &http.Cookie{
+		Name:     "oidc_state",
+		Path:     h.tokenCookiePath(),
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// readAttachmentUpload ...
+// returns the name and contents of the file uploaded in the multipart form field "file",
+// refusing files larger than maxBytes
+func readAttachmentUpload(w http.ResponseWriter, r *http.Request, maxBytes int) (name string, data []byte, err error) {
+	// leave room for the rest of the form around the file
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes)+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return "", []byte{}, shoppinglist.ErrShoppingAttachmentTooLarge
+		}
+		return "", []byte{}, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			slog.Error("failed to close upload", "error", err)
+		}
+	}()
+	data, err = io.ReadAll(io.LimitReader(file, int64(maxBytes)+1))
+	if err != nil {
+		return "", []byte{}, err
+	}
+	if len(data) > maxBytes {
+		return "", []byte{}, shoppinglist.ErrShoppingAttachmentTooLarge
+	}
+	return header.Filename, data, nil
+}
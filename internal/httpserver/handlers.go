@@ -2,25 +2,39 @@ package httpserver
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"gitlab.com/flattrack/flattrack/internal/backups"
 	"gitlab.com/flattrack/flattrack/internal/common"
 	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/flats"
+	"gitlab.com/flattrack/flattrack/internal/groups"
+	"gitlab.com/flattrack/flattrack/internal/oidc"
+	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
+	"gitlab.com/flattrack/flattrack/internal/users"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 const (
 	//nolint:gosec
 	FlatTrackSchedulerSecretHeader = "X-FlatTrack-Scheduler-Secret"
+	//nolint:gosec
+	FlatTrackInstanceSecretHeader = "X-FlatTrack-Instance-Secret"
 )
 
 // FrontendOptions ...
@@ -30,6 +44,7 @@ type frontendOptions struct {
 	LoginMessage           string
 	MaintenanceModeMessage string
 	SetupMessage           string
+	FlatPath               string
 }
 
 // FrontendHandler ...
@@ -58,7 +73,9 @@ func frontendHandler(publicDir string, passthrough *frontendOptions) http.Handle
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if err := tmpl.Execute(w, passthrough); err != nil {
+		options := *passthrough
+		options.FlatPath = flats.BasePath(flatFromRequest(req))
+		if err := tmpl.Execute(w, options); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
@@ -101,6 +118,85 @@ func (h *HTTPServer) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
+// GetLegacyPasswordHashCount ...
+// returns the count of user accounts with passwords which are yet to be rehashed
+func (h *HTTPServer) GetLegacyPasswordHashCount(w http.ResponseWriter, r *http.Request) {
+	var context string
+	count, err := h.users.CountLegacyPasswordHashes()
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to count legacy password hashes",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "counted legacy password hashes",
+		},
+		Data: count,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetUserAuthLockouts ...
+// returns the IP addresses and emails which are locked out from logging in
+func (h *HTTPServer) GetUserAuthLockouts(w http.ResponseWriter, r *http.Request) {
+	var context string
+	code := http.StatusInternalServerError
+	response := "Failed to fetch login lockouts"
+
+	lockouts, err := h.users.UserAuthAttempts().ListLocked()
+	if err == nil {
+		response = "Fetched login lockouts"
+		code = http.StatusOK
+	} else {
+		context = err.Error()
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: response,
+		},
+		List: lockouts,
+	}
+	slog.Info("request log", "response", response, "context", context)
+	JSONResponse(r, w, code, JSONresp)
+}
+
+// DeleteUserAuthLockout ...
+// lets a locked out IP address or email log in again
+func (h *HTTPServer) DeleteUserAuthLockout(w http.ResponseWriter, r *http.Request) {
+	var context string
+	code := http.StatusInternalServerError
+	response := "Failed to remove login lockout"
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+	err := h.users.UserAuthAttempts().Unlock(id)
+	switch {
+	case err == nil:
+		response = "Removed login lockout"
+		code = http.StatusOK
+	case errors.Is(err, users.ErrUserAuthLockoutNotFound):
+		response = err.Error()
+		code = http.StatusNotFound
+	default:
+		context = err.Error()
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: response,
+		},
+	}
+	slog.Info("request log", "response", response, "context", context)
+	JSONResponse(r, w, code, JSONresp)
+}
+
 // GetUser ...
 // get a user by id or email (whatever is provided in the given respective order)
 func (h *HTTPServer) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -142,6 +238,216 @@ func (h *HTTPServer) GetUser(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
+// GetUserSessions ...
+// lists the sessions of a user account
+func (h *HTTPServer) GetUserSessions(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := h.users.GetByID(id, false)
+	if err != nil || user.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find user",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	sessions, err := h.users.UserSessions().List(user.ID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to list sessions",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched sessions",
+		},
+		List: sessions,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteUserSessions ...
+// revokes all sessions of a user account
+func (h *HTTPServer) DeleteUserSessions(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := h.users.GetByID(id, false)
+	if err != nil || user.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find user",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	if err := h.users.UserSessions().DeleteByUserID(user.ID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to revoke sessions",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "revoked sessions",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteUserSession ...
+// revokes a session of a user account
+func (h *HTTPServer) DeleteUserSession(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+	sessionID := vars["sessionId"]
+
+	// sessions aren't kept per flat, so the account is checked to be in this flat first
+	user, err := h.users.GetByID(id, false)
+	if err != nil || user.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find user",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	session, err := h.users.UserSessions().Get(sessionID)
+	if err != nil || session.UserID != user.ID {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find session",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	if err := h.users.UserSessions().Delete(session.ID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to revoke session",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "revoked session",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteUserTOTP ...
+// removes two-factor authentication from a user account, such as when they've lost access to it
+func (h *HTTPServer) DeleteUserTOTP(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	user, err := h.users.GetByID(id, false)
+	if err != nil || user.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find user",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	if err := h.users.DeleteTOTP(user.ID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to remove two-factor authentication",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "removed two-factor authentication",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// checkMayGrantGroups ...
+// responds and returns false when the user of a request doesn't hold every permission which groups grant,
+// so that managing user accounts isn't able to give anyone more access than the user has themselves
+func (h *HTTPServer) checkMayGrantGroups(w http.ResponseWriter, r *http.Request, groupNames []string) bool {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	allowed, err := h.groups.CheckUserMayGrantGroups(reqClaims.ID, groupNames)
+	if err != nil {
+		slog.Error("failed to check permissions of groups", "error", err)
+		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to check permissions of groups",
+			},
+		})
+		return false
+	}
+	if !allowed {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Unable to manage user accounts in groups which grant permissions you don't have",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", fmt.Sprintf("groups %v", groupNames))
+		JSONResponse(r, w, http.StatusForbidden, JSONresp)
+		return false
+	}
+	return true
+}
+
 // PostUser ...
 // create a user
 func (h *HTTPServer) PostUser(w http.ResponseWriter, r *http.Request) {
@@ -158,6 +464,9 @@ func (h *HTTPServer) PostUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.checkMayGrantGroups(w, r, user.Groups) {
+		return
+	}
 	userAccount, err := h.users.Create(user, user.Password == "")
 	if err != nil {
 		context = err.Error()
@@ -200,7 +509,8 @@ func (h *HTTPServer) PutUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID := vars["id"]
 
-	if _, err := h.users.GetByID(userID, false); err != nil {
+	existingUserAccount, err := h.users.GetByID(userID, false)
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -212,6 +522,11 @@ func (h *HTTPServer) PutUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// changing an account, such as it's password, gives access to it, so the groups it's already in are checked too
+	if !h.checkMayGrantGroups(w, r, slices.Concat(userAccount.Groups, existingUserAccount.Groups)) {
+		return
+	}
+
 	// TODO disallow admins to remove their own admin group access
 	userAccountUpdated, err := h.users.UpdateAsAdmin(userID, userAccount)
 	if err != nil {
@@ -254,7 +569,8 @@ func (h *HTTPServer) PatchUser(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID := vars["id"]
 
-	if _, err := h.users.GetByID(userID, false); err != nil {
+	existingUserAccount, err := h.users.GetByID(userID, false)
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -266,6 +582,11 @@ func (h *HTTPServer) PatchUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// changing an account, such as it's password, gives access to it, so the groups it's already in are checked too
+	if !h.checkMayGrantGroups(w, r, slices.Concat(userAccount.Groups, existingUserAccount.Groups)) {
+		return
+	}
+
 	// TODO disallow admins to remove their own admin group access
 	userAccountPatched, err := h.users.PatchAsAdmin(userID, userAccount)
 	if err != nil {
@@ -514,22 +835,11 @@ func (h *HTTPServer) PatchProfile(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSystemInitialized ...
-// check if the server has been initialized
+// check if the flat has been initialized
 func (h *HTTPServer) GetSystemInitialized(w http.ResponseWriter, r *http.Request) {
 	var context string
 
-	initialised, err := h.system.GetHasInitialized()
-	if err != nil {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get system initialise status",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
+	initialised := h.flat.Initialized
 	response := "not initialised"
 	if initialised {
 		response = "initialised"
@@ -558,8 +868,26 @@ func (h *HTTPServer) UserAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	session := GetRequestSession(r)
+	retryAfter, err := h.users.CheckAuthLockout(session.IPAddress, user.Email)
+	if err != nil {
+		slog.Error("error checking login lockout", "error", err)
+		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Failed to check for too many failed logins",
+			},
+		})
+		return
+	}
+	if retryAfter > 0 {
+		authLockedOutResponse(r, w, retryAfter)
+		return
+	}
 	userInDB, err := h.users.GetByEmail(user.Email, false)
 	if err != nil {
+		if h.recordAuthFailure(r, w, session.IPAddress, user.Email) {
+			return
+		}
 		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
 				Response: "Failed to get user account",
@@ -583,6 +911,14 @@ func (h *HTTPServer) UserAuth(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if users.IsExpired(userInDB) {
+		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "User account has expired",
+			},
+		})
+		return
+	}
 	// Check password locally, fall back to remote if incorrect
 	matches, err := h.users.CheckUserPassword(userInDB.Email, user.Password)
 	if err != nil {
@@ -595,6 +931,9 @@ func (h *HTTPServer) UserAuth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !matches {
+		if h.recordAuthFailure(r, w, session.IPAddress, user.Email) {
+			return
+		}
 		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
 				Response: "Unable to authenticate",
@@ -602,28 +941,291 @@ func (h *HTTPServer) UserAuth(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	jwt, err := h.users.GenerateJWTauthToken(userInDB.ID, userInDB.AuthNonce, 0)
+	totpEnabled, err := h.users.TOTPEnabled(userInDB.ID)
 	if err != nil {
-		slog.Error("error checking password", "error", err)
-		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
+		slog.Error("error checking two-factor authentication", "error", err)
+		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "Failed to generate JWT",
+				Response: "Failed to check user account two-factor authentication",
 			},
 		})
 		return
 	}
-	h.SetTokenCookie(w, jwt)
-	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "Successfully authenticated user",
-		},
-		Data: jwt,
-	})
-}
-
-// UserAuth ...
+	if totpEnabled {
+		challenge, err := h.users.NewAuthChallenge(userInDB.ID)
+		if err != nil {
+			slog.Error("error creating auth challenge", "error", err)
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Failed to start two-factor authentication",
+				},
+			})
+			return
+		}
+		JSONResponse(r, w, http.StatusAccepted, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Two-factor authentication required",
+			},
+			Spec: challenge,
+		})
+		return
+	}
+	// failed logins are only forgotten once the whole login succeeds,
+	// so that a known password can't be used to keep guessing second factors
+	if err := h.users.ResetAuthFailures(user.Email); err != nil {
+		slog.Error("error resetting failed logins", "error", err)
+	}
+	jwt, err := h.users.NewSession(userInDB, session)
+	if err != nil {
+		slog.Error("error checking password", "error", err)
+		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Failed to generate JWT",
+			},
+		})
+		return
+	}
+	h.SetTokenCookie(w, jwt)
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "Successfully authenticated user",
+		},
+		Data: jwt,
+	})
+}
+
+// authLockedOutResponse ...
+// responds that a login is locked out, and how many seconds until it may be tried again
+func authLockedOutResponse(r *http.Request, w http.ResponseWriter, retryAfter time.Duration) {
+	retryAfter = retryAfter.Round(time.Second) + time.Second
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	JSONResponse(r, w, http.StatusTooManyRequests, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: fmt.Sprintf("Too many failed logins, please try again in %v", retryAfter),
+		},
+	})
+}
+
+// recordAuthFailure ...
+// counts a failed login, responding and returning true if it caused a lockout
+func (h *HTTPServer) recordAuthFailure(r *http.Request, w http.ResponseWriter, ipAddress string, email string) bool {
+	retryAfter, err := h.users.RecordAuthFailure(ipAddress, email)
+	if err != nil {
+		slog.Error("error recording failed login", "error", err)
+		return false
+	}
+	if retryAfter <= 0 {
+		return false
+	}
+	authLockedOutResponse(r, w, retryAfter)
+	return true
+}
+
+// PostUserAuthChallenge ...
+// completes a login with a second factor
+func (h *HTTPServer) PostUserAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	var answer types.UserAuthChallengeAnswer
+	if err := json.NewDecoder(r.Body).Decode(&answer); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	// incorrect codes are counted like incorrect passwords, against the IP address and the email of the account
+	session := GetRequestSession(r)
+	challenge, err := h.users.UserAuthChallenges().Get(answer.Token)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Unable to authenticate",
+			},
+		}
+		if errors.Is(err, users.ErrUserAuthChallengeNotFound) {
+			JSONresp.Metadata.Response = err.Error()
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusForbidden, JSONresp)
+		return
+	}
+	user, err := h.users.GetByID(challenge.UserID, false)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Unable to authenticate",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusForbidden, JSONresp)
+		return
+	}
+	retryAfter, err := h.users.CheckAuthLockout(session.IPAddress, user.Email)
+	if err != nil {
+		slog.Error("error checking login lockout", "error", err)
+		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Failed to check for too many failed logins",
+			},
+		})
+		return
+	}
+	if retryAfter > 0 {
+		authLockedOutResponse(r, w, retryAfter)
+		return
+	}
+
+	jwt, err := h.users.AnswerAuthChallenge(answer, session)
+	if err != nil {
+		context = err.Error()
+		if errors.Is(err, users.ErrUserTOTPCodeInvalid) && h.recordAuthFailure(r, w, session.IPAddress, user.Email) {
+			return
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Unable to authenticate",
+			},
+		}
+		if errors.Is(err, users.ErrUserAuthChallengeNotFound) || errors.Is(err, users.ErrUserTOTPCodeInvalid) {
+			JSONresp.Metadata.Response = err.Error()
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusForbidden, JSONresp)
+		return
+	}
+	if err := h.users.ResetAuthFailures(user.Email); err != nil {
+		slog.Error("error resetting failed logins", "error", err)
+	}
+	h.SetTokenCookie(w, jwt)
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "Successfully authenticated user",
+		},
+		Data: jwt,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetUserAuthOIDC ...
+// returns if logging in with an identity provider is enabled
+func (h *HTTPServer) GetUserAuthOIDC(w http.ResponseWriter, r *http.Request) {
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "Fetched single sign-on availability",
+		},
+		Data: h.oidc.Enabled(),
+	})
+}
+
+// redirectToLogin ...
+// sends a browser back to the login page of it's flat with a message
+func redirectToLogin(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, flats.BasePath(flatFromRequest(r))+"/login?error="+url.QueryEscape(message), http.StatusFound)
+}
+
+// GetUserAuthOIDCLogin ...
+// sends a browser to the identity provider to log in
+func (h *HTTPServer) GetUserAuthOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	authURL, browserState, err := h.oidc.AuthCodeURL(r.FormValue("redirect"))
+	if err != nil {
+		slog.Error("failed to start single sign-on login", "error", err)
+		response := "Failed to start single sign-on login"
+		if errors.Is(err, oidc.ErrOIDCNotEnabled) {
+			response = err.Error()
+		}
+		redirectToLogin(w, r, response)
+		return
+	}
+	h.SetOIDCStateCookie(w, browserState)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// GetUserAuthOIDCCallback ...
+// completes a login from the identity provider, then sends the browser on
+func (h *HTTPServer) GetUserAuthOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	if providerError := r.FormValue("error"); providerError != "" {
+		slog.Info("request log", "response", "Identity provider refused login", "context", providerError+" "+r.FormValue("error_description"))
+		redirectToLogin(w, r, "Identity provider refused login")
+		return
+	}
+	var browserState string
+	if cookie, err := r.Cookie("oidc_state"); err == nil {
+		browserState = cookie.Value
+	}
+	h.ClearOIDCStateCookie(w)
+	user, redirect, err := h.oidc.Login(r.FormValue("state"), r.FormValue("code"), browserState)
+	if err != nil {
+		context = err.Error()
+		response := "Unable to authenticate"
+		if errors.Is(err, oidc.ErrOIDCAuthStateNotFound) ||
+			errors.Is(err, oidc.ErrOIDCAuthStateMismatch) ||
+			errors.Is(err, oidc.ErrOIDCEmailNotVerified) ||
+			errors.Is(err, oidc.ErrOIDCAccountNotFound) {
+			response = err.Error()
+		}
+		slog.Info("request log", "response", response, "context", context)
+		redirectToLogin(w, r, response)
+		return
+	}
+	if !user.Registered {
+		redirectToLogin(w, r, "User account is not yet registered")
+		return
+	}
+	if user.Disabled {
+		redirectToLogin(w, r, "User account has been disabled")
+		return
+	}
+	if users.IsExpired(user) {
+		redirectToLogin(w, r, "User account has expired")
+		return
+	}
+	// accounts with two-factor authentication still need their second factor,
+	// which is asked for by the login page
+	totpEnabled, err := h.users.TOTPEnabled(user.ID)
+	if err != nil {
+		slog.Error("failed to check two-factor authentication", "id", user.ID, "error", err)
+		redirectToLogin(w, r, "Failed to check user account two-factor authentication")
+		return
+	}
+	if totpEnabled {
+		challenge, err := h.users.NewAuthChallenge(user.ID)
+		if err != nil {
+			slog.Error("failed to create auth challenge", "id", user.ID, "error", err)
+			redirectToLogin(w, r, "Failed to start two-factor authentication")
+			return
+		}
+		slog.Info("request log", "response", "Two-factor authentication required", "context", context)
+		http.Redirect(w, r, flats.BasePath(h.flat)+"/login?challenge="+url.QueryEscape(challenge.Token)+"&redirect="+url.QueryEscape(redirect), http.StatusFound)
+		return
+	}
+	jwt, err := h.users.NewSession(user, GetRequestSession(r))
+	if err != nil {
+		slog.Error("failed to create session", "id", user.ID, "error", err)
+		redirectToLogin(w, r, "Failed to generate JWT")
+		return
+	}
+	h.SetTokenCookie(w, jwt)
+	slog.Info("request log", "response", "Successfully authenticated user with identity provider", "context", context)
+	http.Redirect(w, r, flats.BasePath(h.flat)+redirect, http.StatusFound)
+}
+
+// UserAuth ...
 // authenticate a user
 func (h *HTTPServer) UserAuthLogOut(w http.ResponseWriter, r *http.Request) {
+	if valid, claims, err := h.users.ValidateJWTauthToken(r); err == nil && valid {
+		if err := h.users.UserSessions().Delete(claims.SessionID); err != nil {
+			slog.Error("failed to delete session", "id", claims.SessionID, "error", err)
+		}
+	}
 	h.ClearTokenCookie(w)
 	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
@@ -673,6 +1275,130 @@ func (h *HTTPServer) UserAuthValidate(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
+// PostUserAuthForgot ...
+// emails a link to reset the password of an account.
+// The response is the same whether or not an account has the email
+func (h *HTTPServer) PostUserAuthForgot(w http.ResponseWriter, r *http.Request) {
+	var user types.UserSpec
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+	// sent in the background, so that the response time doesn't reveal if an account has the email.
+	// It's queries outlive the request, so they aren't cancelled along with it
+	go h.withContext(context.WithoutCancel(r.Context())).sendPasswordResetEmail(user.Email)
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "if an account has the email, a link to reset it's password has been sent to it",
+		},
+	})
+}
+
+// sendPasswordResetEmail ...
+// emails a link to reset the password of the account with the email, if there is one
+func (h *HTTPServer) sendPasswordResetEmail(email string) {
+	if common.GetSMTPEnabled() != "true" {
+		slog.Warn("Unable to send password reset email, as SMTP is not enabled")
+		return
+	}
+	// links must not be built from the request, as it's Host header may be forged
+	if h.instanceURL == nil {
+		slog.Warn("Unable to send password reset email, as the instance URL is not set")
+		return
+	}
+	user, resetSecret, err := h.users.RequestPasswordReset(email)
+	if err != nil {
+		slog.Error("Failed to request password reset", "error", err)
+		return
+	}
+	if user.ID == "" {
+		return
+	}
+	link := *h.instanceURL
+	link.Path = "/forgot-password"
+	link.RawQuery = url.Values{
+		"id":     []string{resetSecret.ID},
+		"secret": []string{resetSecret.Secret},
+	}.Encode()
+	if err := h.emails.SendPasswordResetEmail(user.Email, user.Names, link.String()); err != nil {
+		slog.Error("Failed to send password reset email", "error", err)
+	}
+}
+
+// GetUserAuthForgotValid ...
+// returns if a password reset secret is able to be used
+func (h *HTTPServer) GetUserAuthForgotValid(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	secret := r.FormValue("secret")
+
+	resetSecret, err := h.users.UserPasswordResetSecrets().Get(id, secret)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get password reset secret",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched password reset secret valid",
+		},
+		Data: resetSecret.ID != "",
+	}
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostUserAuthForgotReset ...
+// sets the password of an account using a password reset secret
+func (h *HTTPServer) PostUserAuthForgotReset(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	secret := r.FormValue("secret")
+
+	var user types.UserSpec
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	if err := h.users.ResetPassword(id, secret, user.Password); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to reset password",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "reset password",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
 // UserAuthReset ...
 // invalidates all JWTs
 func (h *HTTPServer) UserAuthReset(w http.ResponseWriter, r *http.Request) {
@@ -680,11 +1406,1911 @@ func (h *HTTPServer) UserAuthReset(w http.ResponseWriter, r *http.Request) {
 	jwtUserID := reqClaims.ID
 	var context string
 
-	if err := h.users.GenerateNewAuthNonce(jwtUserID); err != nil {
+	if err := h.users.GenerateNewAuthNonce(jwtUserID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find user account with id: " + jwtUserID,
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "reset all authentication tokens",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetUserAuthSessions ...
+// lists the sessions of the current user account
+func (h *HTTPServer) GetUserAuthSessions(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	sessions, err := h.users.UserSessions().List(jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to list sessions",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == reqClaims.SessionID
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched sessions",
+		},
+		List: sessions,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteUserAuthSession ...
+// revokes a session of the current user account
+func (h *HTTPServer) DeleteUserAuthSession(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	session, err := h.users.UserSessions().Get(id)
+	if err != nil || session.UserID != jwtUserID {
+		if err != nil {
+			context = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find session",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	if err := h.users.UserSessions().Delete(session.ID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to revoke session",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if session.ID == reqClaims.SessionID {
+		h.ClearTokenCookie(w)
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "revoked session",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetUserAuthTokens ...
+// lists the personal access tokens of the current user account
+func (h *HTTPServer) GetUserAuthTokens(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	accessTokens, err := h.users.UserAccessTokens().List(jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to list personal access tokens",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched personal access tokens",
+		},
+		List: accessTokens,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostUserAuthToken ...
+// creates a personal access token for the current user account, responding with the only copy of the token
+func (h *HTTPServer) PostUserAuthToken(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var accessToken types.UserAccessTokenSpec
+	if err := json.NewDecoder(r.Body).Decode(&accessToken); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+	if common.StringInStringSlice(users.AccessTokenScopeAdmin, accessToken.Scopes) {
+		permissions, err := h.groups.GetPermissionsOfUserByID(jwtUserID)
+		if err != nil || !slices.ContainsFunc(permissions, groups.IsAdminPermission) {
+			if err != nil {
+				context = err.Error()
+			}
+			JSONresp := types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "only admins are able to create personal access tokens with the admin scope",
+				},
+			}
+			slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+			JSONResponse(r, w, http.StatusForbidden, JSONresp)
+			return
+		}
+	}
+	accessTokenCreated, err := h.users.UserAccessTokens().Create(jwtUserID, accessToken)
+	if err != nil {
+		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to create personal access token"
+		if errors.Is(err, users.ErrUserAccessTokenInvalidName) ||
+			errors.Is(err, users.ErrUserAccessTokenInvalidScope) ||
+			errors.Is(err, users.ErrUserAccessTokenInvalidExpiry) {
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: response,
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, code, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "created personal access token",
+		},
+		Spec: accessTokenCreated,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// DeleteUserAuthToken ...
+// revokes a personal access token of the current user account
+func (h *HTTPServer) DeleteUserAuthToken(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.users.UserAccessTokens().Delete(jwtUserID, id); err != nil {
+		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to revoke personal access token"
+		if errors.Is(err, users.ErrUserAccessTokenNotFound) {
+			code = http.StatusNotFound
+			response = "failed to find personal access token"
+		}
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: response,
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, code, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "revoked personal access token",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetUserAuthTOTP ...
+// responds whether the current user account has two-factor authentication enabled
+func (h *HTTPServer) GetUserAuthTOTP(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	enabled, err := h.users.TOTPEnabled(jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to check two-factor authentication",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "checked two-factor authentication",
+		},
+		Data: enabled,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostUserAuthTOTP ...
+// creates a time-based one-time password secret for the current user account to enrol with
+func (h *HTTPServer) PostUserAuthTOTP(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	totp, err := h.users.EnrolTOTP(jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to set up two-factor authentication",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "set up two-factor authentication",
+		},
+		Spec: totp,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// PostUserAuthTOTPEnable ...
+// enables two-factor authentication for the current user account, given a code from their authenticator app
+func (h *HTTPServer) PostUserAuthTOTPEnable(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var code types.UserTOTPCode
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	recoveryCodes, err := h.users.EnableTOTP(jwtUserID, code.Code)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to enable two-factor authentication",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "enabled two-factor authentication",
+		},
+		List: recoveryCodes,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteUserAuthTOTP ...
+// disables two-factor authentication for the current user account, given a code from their authenticator app or a recovery code
+func (h *HTTPServer) DeleteUserAuthTOTP(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var code types.UserTOTPCode
+	if err := json.NewDecoder(r.Body).Decode(&code); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	if err := h.users.DisableTOTP(jwtUserID, code.Code); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to disable two-factor authentication",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "disabled two-factor authentication",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// UserCanIgroup ...
+// respond whether the current user account is in a group
+func (h *HTTPServer) UserCanIgroup(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	vars := mux.Vars(r)
+	groupName := vars["name"]
+
+	group, err := h.groups.GetByName(groupName)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get group by name: " + groupName,
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	userIsInGroup, err := h.groups.CheckUserInGroup(jwtUserID, group.Name)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to check whether user is in group",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched user is in group",
+		},
+		Data: userIsInGroup,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetSettingsFlatName ...
+// responds with the name of the flat
+func (h *HTTPServer) GetSettingsFlatName(w http.ResponseWriter, r *http.Request) {
+	var context string
+	flatName, err := h.settings.GetFlatName()
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get flat name setting",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if flatName == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "flat name is not set",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched flat name",
+		},
+		Spec: flatName,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// SetSettingsFlatName ...
+// update the flat's name
+func (h *HTTPServer) SetSettingsFlatName(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	var flatName types.FlatName
+	if err := json.NewDecoder(r.Body).Decode(&flatName); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	if err := h.settings.SetFlatName(flatName.FlatName); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to set flat name setting",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "set flat name",
+		},
+		Spec: true,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostAdminRegister ...
+// register the flat in the instance of FlatTrack
+func (h *HTTPServer) PostAdminRegister(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	if h.flat.Initialized {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "system is initialised",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusOK, JSONresp)
+		return
+	}
+
+	var registrationForm types.Registration
+	if err := json.NewDecoder(r.Body).Decode(&registrationForm); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+	registrationForm.Secret = r.FormValue("secret")
+
+	registered, jwt, err := h.registration.Register(registrationForm, GetRequestSession(r))
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to register instance",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	h.SetTokenCookie(w, jwt)
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "registered",
+		},
+		Spec: registered,
+		Data: jwt,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// GetShoppingList ...
+// responds with list of shopping lists
+func (h *HTTPServer) GetShoppingList(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	shoppingList, err := h.shoppinglist.ShoppingList().Get(id)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched shopping list",
+		},
+		Spec: shoppingList,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetShoppingLists ...
+// responds with shopping list by id
+func (h *HTTPServer) GetShoppingLists(w http.ResponseWriter, r *http.Request) {
+	var context string
+	modificationTimestampAfterString := r.FormValue("modificationTimestampAfter")
+	creationTimestampAfterString := r.FormValue("creationTimestampAfter")
+	limitString := r.FormValue("limit")
+	pageString := r.FormValue("page")
+	modificationTimestampAfter, err := strconv.ParseInt(modificationTimestampAfterString, 10, 64)
+	if err != nil && modificationTimestampAfterString != "" {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "unable to parse value for limiting request for shopping lists",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	creationTimestampAfter, err := strconv.ParseInt(creationTimestampAfterString, 10, 64)
+	if err != nil && creationTimestampAfterString != "" {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "unable to parse value for limiting request for shopping lists",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	limit, err := strconv.Atoi(limitString)
+	if err != nil && pageString != "" {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "unable to parse value for limiting request for shopping lists",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	page, err := strconv.Atoi(pageString)
+	if err != nil && pageString != "" {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "unable to parse value for limiting request for shopping lists",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	options := types.ShoppingListOptions{
+		SortBy: r.FormValue("sortBy"),
+		Limit:  limit,
+		Page:   page,
+		Selector: types.ShoppingListSelector{
+			Completed:                  r.FormValue("completed"),
+			ModificationTimestampAfter: modificationTimestampAfter,
+			CreationTimestampAfter:     creationTimestampAfter,
+		},
+	}
+
+	shoppingLists, err := h.shoppinglist.ShoppingList().List(options)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping lists",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched shopping lists",
+		},
+		List: shoppingLists,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostShoppingList ...
+// creates a new shopping list to add items to
+func (h *HTTPServer) PostShoppingList(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingList types.ShoppingListSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	options := types.ShoppingItemOptions{
+		Selector: types.ShoppingItemSelector{
+			TemplateListItemSelector: r.FormValue("templateListItemSelector"),
+		},
+	}
+
+	shoppingList.Author = jwtUserID
+	shoppingListInserted, err := h.shoppinglist.ShoppingList().Create(shoppingList, options)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to create shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "created shopping list",
+		},
+		Spec: shoppingListInserted,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// PatchShoppingList ...
+// patches an existing shopping list
+func (h *HTTPServer) PatchShoppingList(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingList types.ShoppingListSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	shoppingList.AuthorLast = jwtUserID
+	shoppingListPatched, err := h.shoppinglist.ShoppingList().Patch(list.ID, shoppingList)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to patch shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "patched shopping list",
+		},
+		Spec: shoppingListPatched,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PutShoppingList ...
+// updates an existing shopping list
+func (h *HTTPServer) PutShoppingList(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingList types.ShoppingListSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	shoppingList.AuthorLast = jwtUserID
+	shoppingListUpdated, err := h.shoppinglist.ShoppingList().Update(list.ID, shoppingList)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to update shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "updated shopping list",
+		},
+		Spec: shoppingListUpdated,
+	}
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteShoppingList ...
+// delete a new shopping list by it's id
+func (h *HTTPServer) DeleteShoppingList(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	if err := h.shoppinglist.ShoppingList().Delete(list.ID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to delete shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "deleted shopping list",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostShoppingListExpense ...
+// creates an expense for the total of a completed shopping list
+func (h *HTTPServer) PostShoppingListExpense(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var expense types.ExpenseSpec
+	if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	expense.Author = jwtUserID
+	expenseInserted, err := h.expenses.Expense().CreateFromShoppingList(list.ID, expense)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to create expense from shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "created expense from shopping list",
+		},
+		Spec: expenseInserted,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// GetShoppingListEvents ...
+// streams changes to the items of a shopping list as server-sent events
+func (h *HTTPServer) GetShoppingListEvents(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(id)
+	if errors.Is(err, shoppinglist.ErrShoppingListNotFound) {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	// the stream is held open for longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to stream shopping list events",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	events, unsubscribe := h.shoppinglist.ShoppingItemEvent().Subscribe(list.ID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("failed to flush shopping list events", "listId", list.ID, "error", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("failed to marshal shopping list event", "listId", list.ID, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %v\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// GetShoppingListItems ...
+// responds with shopping items by list id
+func (h *HTTPServer) GetShoppingListItems(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	options := types.ShoppingItemOptions{
+		SortBy: r.FormValue("sortBy"),
+		Selector: types.ShoppingItemSelector{
+			Obtained: r.FormValue("obtained"),
+		},
+	}
+
+	list, err := h.shoppinglist.ShoppingList().Get(id)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	// TODO add item selectors for this endpoint
+	shoppingListItems, err := h.shoppinglist.ShoppingItem().List(list.ID, options)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list items",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched shopping list items",
+		},
+		List: shoppingListItems,
+	}
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetShoppingListItem ...
+// responds with list of shopping lists
+func (h *HTTPServer) GetShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	itemID := vars["itemId"]
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	shoppingListItem, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if shoppingListItem.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetch shopping list item",
+		},
+		Spec: shoppingListItem,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostItemToShoppingList ...
+// adds an item to a shopping list
+func (h *HTTPServer) PostItemToShoppingList(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingItem types.ShoppingItemSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	shoppingItem.Author = jwtUserID
+	shoppingItemInserted, err := h.shoppinglist.ShoppingItem().AddItemToList(list.ID, shoppingItem)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to add item to shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "added item to shopping list",
+		},
+		Spec: shoppingItemInserted,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// PatchShoppingListCompleted ...
+// adds an item to a shopping list
+func (h *HTTPServer) PatchShoppingListCompleted(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingList types.ShoppingListSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+
+	patchedList, err := h.shoppinglist.ShoppingList().SetListCompleted(list.ID, shoppingList.Completed, jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to set shopping list as completed",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "shopping list set as completed",
+		},
+		Spec: patchedList,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PatchShoppingListItem ...
+// patches an item in a shopping list
+func (h *HTTPServer) PatchShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingItem types.ShoppingItemSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if item.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	shoppingItem.AuthorLast = jwtUserID
+	patchedItem, err := h.shoppinglist.ShoppingItem().Patch(listID, item.ID, shoppingItem)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to patch shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "patched shopping list item",
+		},
+		Spec: patchedItem,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PutShoppingListItem ...
+// updates an item in a shopping list
+func (h *HTTPServer) PutShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingItem types.ShoppingItemSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if item.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	shoppingItem.AuthorLast = jwtUserID
+	updatedItem, err := h.shoppinglist.ShoppingItem().Update(listID, item.ID, shoppingItem)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to update shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "updated shopping list item",
+		},
+		Spec: updatedItem,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PatchShoppingListItemObtained ...
+// patches an item in a shopping list
+func (h *HTTPServer) PatchShoppingListItemObtained(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var shoppingItem types.ShoppingItemSpec
+	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	itemID := vars["id"]
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if item.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	patchedItem, err := h.shoppinglist.ShoppingItem().SetItemObtained(listID, item.ID, shoppingItem.Obtained, jwtUserID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "set shopping list item as obtained",
+		},
+		Spec: patchedItem,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteShoppingListItem ...
+// delete a shopping list item by it's id
+func (h *HTTPServer) DeleteShoppingListItem(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	vars := mux.Vars(r)
+	itemID := vars["itemId"]
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if item.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list item",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	if err := h.shoppinglist.ShoppingItem().Delete(item.ID, list.ID, jwtUserID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to remove item from shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "removed item from shopping list",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// DeleteShoppingListTagItems ...
+// delete a shopping list items by matching a tag
+func (h *HTTPServer) DeleteShoppingListTagItems(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+	var item types.ShoppingItemSpec
+
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	vars := mux.Vars(r)
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	if err := h.shoppinglist.ShoppingItem().DeleteTagItems(list.ID, item.Tag, jwtUserID); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to remove items from shopping list by tag name",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "removed items from shopping list by tag name",
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetShoppingListItemTags ...
+// responds with tags used in shopping list items from a list
+func (h *HTTPServer) GetShoppingListItemTags(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	listID := vars["listId"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	tags, err := h.shoppinglist.ShoppingTag().ListTagsInList(list.ID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get tags from shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched tags from shopping list",
+		},
+		List: tags,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// UpdateShoppingListItemTag ...
+// updates then tag name used in shopping list items from a list
+func (h *HTTPServer) UpdateShoppingListItemTag(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	listID := vars["listId"]
+	tag := vars["tagName"]
+
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to update shopping list tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if list.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	var tagUpdate types.ShoppingTag
+	if err := json.NewDecoder(r.Body).Decode(&tagUpdate); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	tagUpdated, err := h.shoppinglist.ShoppingTag().UpdateInList(list.ID, tag, tagUpdate.Name)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to update shopping list tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "updated shopping list tag",
+		},
+		Spec: tagUpdated,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetAllShoppingTags ...
+// responds with all tags used in shopping list items
+func (h *HTTPServer) GetAllShoppingTags(w http.ResponseWriter, r *http.Request) {
+	var context string
+	options := types.ShoppingTagOptions{
+		SortBy: r.FormValue("sortBy"),
+	}
+
+	tags, err := h.shoppinglist.ShoppingTag().List(options)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping list tags",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched shopping list tags",
+		},
+		List: tags,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PostShoppingTag ...
+// creates a tag name
+func (h *HTTPServer) PostShoppingTag(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var tag types.ShoppingTag
+	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	tag.Author = jwtUserID
+	tagCreated, err := h.shoppinglist.ShoppingTag().Create(tag)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to create shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "created shopping tag",
+		},
+		Spec: tagCreated,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// GetShoppingTag ...
+// gets a shopping tag by id
+func (h *HTTPServer) GetShoppingTag(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	tag, err := h.shoppinglist.ShoppingTag().Get(id)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if tag.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched shopping tag",
+		},
+		Spec: tag,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// UpdateShoppingTag ...
+// updates a tag name
+func (h *HTTPServer) UpdateShoppingTag(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	tag, err := h.shoppinglist.ShoppingTag().Get(id)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	if tag.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+
+	var tagUpdate types.ShoppingTag
+	if err := json.NewDecoder(r.Body).Decode(&tagUpdate); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+	tagUpdate.AuthorLast = jwtUserID
+	tagUpdated, err := h.shoppinglist.ShoppingTag().Update(tag.ID, tagUpdate)
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to find user account with id: " + jwtUserID,
+				Response: "failed to update shopping tag",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -693,79 +3319,74 @@ func (h *HTTPServer) UserAuthReset(w http.ResponseWriter, r *http.Request) {
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "reset all authentication tokens",
+			Response: "updated shopping tag",
 		},
+		Spec: tagUpdated,
 	}
-	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// UserCanIgroup ...
-// respond whether the current user account is in a group
-func (h *HTTPServer) UserCanIgroup(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// DeleteShoppingTag ...
+// deletes a shopping tag by id
+func (h *HTTPServer) DeleteShoppingTag(w http.ResponseWriter, r *http.Request) {
 	var context string
 
 	vars := mux.Vars(r)
-	groupName := vars["name"]
+	id := vars["id"]
 
-	group, err := h.groups.GetByName(groupName)
+	tag, err := h.shoppinglist.ShoppingTag().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get group by name: " + groupName,
+				Response: "failed to get shopping tag",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
+	if tag.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get shopping tag",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
 
-	userIsInGroup, err := h.groups.CheckUserInGroup(jwtUserID, group.Name)
-	if err != nil {
+	if err := h.shoppinglist.ShoppingTag().Delete(tag.ID); err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to check whether user is in group",
+				Response: "failed to delete shopping tag",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched user is in group",
+			Response: "deleted shopping tag",
 		},
-		Data: userIsInGroup,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetSettingsFlatName ...
-// responds with the name of the flat
-func (h *HTTPServer) GetSettingsFlatName(w http.ResponseWriter, r *http.Request) {
+// GetSettingsShoppingListNotes ...
+// responds with the notes for shopping lists
+func (h *HTTPServer) GetSettingsShoppingListNotes(w http.ResponseWriter, r *http.Request) {
 	var context string
-	flatName, err := h.settings.GetFlatName()
+	notes, err := h.settings.GetShoppingListNotes()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get flat name setting",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-	if flatName == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "flat name is not set",
+				Response: "failed to get shopping notes",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -774,21 +3395,21 @@ func (h *HTTPServer) GetSettingsFlatName(w http.ResponseWriter, r *http.Request)
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched flat name",
+			Response: "fetched shopping notes",
 		},
-		Spec: flatName,
+		Spec: notes,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// SetSettingsFlatName ...
-// update the flat's name
-func (h *HTTPServer) SetSettingsFlatName(w http.ResponseWriter, r *http.Request) {
+// PutSettingsShoppingList ...
+// update the notes for shopping lists
+func (h *HTTPServer) PutSettingsShoppingList(w http.ResponseWriter, r *http.Request) {
 	var context string
 
-	var flatName types.FlatName
-	if err := json.NewDecoder(r.Body).Decode(&flatName); err != nil {
+	var notes types.ShoppingListNotes
+	if err := json.NewDecoder(r.Body).Decode(&notes); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -798,57 +3419,66 @@ func (h *HTTPServer) SetSettingsFlatName(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.settings.SetFlatName(flatName.FlatName); err != nil {
+	if err := h.settings.SetShoppingListNotes(notes.Notes); err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		if err.Error() == "Unable to set shopping list notes as it is either invalid, too short, or too long" {
+			code = http.StatusBadRequest
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to set flat name setting",
+				Response: "failed to get shopping notes",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "set flat name",
+			Response: "set shopping notes",
 		},
-		Spec: true,
+		Spec: notes.Notes,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PostAdminRegister ...
-// register the instance of FlatTrack
-func (h *HTTPServer) PostAdminRegister(w http.ResponseWriter, r *http.Request) {
+// GetSettingsFlatNotes ...
+// responds with the notes for flat
+func (h *HTTPServer) GetSettingsFlatNotes(w http.ResponseWriter, r *http.Request) {
 	var context string
-
-	initialized, err := h.system.GetHasInitialized()
+	notes, err := h.settings.GetFlatNotes()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get system initialised status",
+				Response: "failed to get flat notes",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if initialized {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "system is initialised",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusOK, JSONresp)
-		return
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched flat notes",
+		},
+		Spec: types.FlatNotes{
+			Notes: notes,
+		},
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	var registrationForm types.Registration
-	if err := json.NewDecoder(r.Body).Decode(&registrationForm); err != nil {
+// PutSettingsFlatNotes ...
+// update the notes for flat
+func (h *HTTPServer) PutSettingsFlatNotes(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	var notes types.FlatNotes
+	if err := json.NewDecoder(r.Body).Decode(&notes); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -857,45 +3487,38 @@ func (h *HTTPServer) PostAdminRegister(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	registrationForm.Secret = r.FormValue("secret")
 
-	registered, jwt, err := h.registration.Register(registrationForm)
-	if err != nil {
+	if err := h.settings.SetFlatNotes(notes.Notes); err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to register instance",
+				Response: "failed to get flat notes",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	h.SetTokenCookie(w, jwt)
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "registered",
+			Response: "set flat notes",
 		},
-		Spec: registered,
-		Data: jwt,
+		Spec: notes.Notes,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusCreated, JSONresp)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetShoppingList ...
-// responds with list of shopping lists
-func (h *HTTPServer) GetShoppingList(w http.ResponseWriter, r *http.Request) {
+// GetSettingsShoppingListKeepPolicy ...
+// responds with the keepPolicy for shopping lists
+func (h *HTTPServer) GetSettingsShoppingListKeepPolicy(w http.ResponseWriter, r *http.Request) {
 	var context string
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	shoppingList, err := h.shoppinglist.ShoppingList().Get(id)
+	keepPolicy, err := h.settings.GetShoppingListKeepPolicy()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get shopping keep policy",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -904,113 +3527,130 @@ func (h *HTTPServer) GetShoppingList(w http.ResponseWriter, r *http.Request) {
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping list",
+			Response: "fetched shopping keep policy",
 		},
-		Spec: shoppingList,
+		Spec: keepPolicy,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetShoppingLists ...
-// responds with shopping list by id
-func (h *HTTPServer) GetShoppingLists(w http.ResponseWriter, r *http.Request) {
+// PutSettingsShoppingListKeepPolicy ...
+// update the keep policy for shopping lists
+func (h *HTTPServer) PutSettingsShoppingListKeepPolicy(w http.ResponseWriter, r *http.Request) {
 	var context string
-	modificationTimestampAfterString := r.FormValue("modificationTimestampAfter")
-	creationTimestampAfterString := r.FormValue("creationTimestampAfter")
-	limitString := r.FormValue("limit")
-	pageString := r.FormValue("page")
-	modificationTimestampAfter, err := strconv.ParseInt(modificationTimestampAfterString, 10, 64)
-	if err != nil && modificationTimestampAfterString != "" {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
+
+	var spec types.ShoppingListKeepPolicySpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "unable to parse value for limiting request for shopping lists",
+				Response: "failed to read request body",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		})
 		return
 	}
-	creationTimestampAfter, err := strconv.ParseInt(creationTimestampAfterString, 10, 64)
-	if err != nil && creationTimestampAfterString != "" {
+
+	if err := h.settings.SetShoppingListKeepPolicy(spec.KeepPolicy); err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		if err.Error() == "Unable to set shopping list keep policy as it is invalid" {
+			code = http.StatusBadRequest
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "unable to parse value for limiting request for shopping lists",
+				Response: "failed to get shopping keep policy",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
-	limit, err := strconv.Atoi(limitString)
-	if err != nil && pageString != "" {
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "set shopping keep policy",
+		},
+		Spec: spec.KeepPolicy,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetTasks ...
+// responds with a list of tasks
+func (h *HTTPServer) GetTasks(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	tasks, err := h.tasks.Task().List()
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "unable to parse value for limiting request for shopping lists",
+				Response: "failed to get tasks",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	page, err := strconv.Atoi(pageString)
-	if err != nil && pageString != "" {
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched tasks",
+		},
+		List: tasks,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetTask ...
+// responds with a task by id
+func (h *HTTPServer) GetTask(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	task, err := h.tasks.Task().Get(id)
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "unable to parse value for limiting request for shopping lists",
+				Response: "failed to get task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-
-	options := types.ShoppingListOptions{
-		SortBy: r.FormValue("sortBy"),
-		Limit:  limit,
-		Page:   page,
-		Selector: types.ShoppingListSelector{
-			Completed:                  r.FormValue("completed"),
-			ModificationTimestampAfter: modificationTimestampAfter,
-			CreationTimestampAfter:     creationTimestampAfter,
-		},
-	}
-
-	shoppingLists, err := h.shoppinglist.ShoppingList().List(options)
-	if err != nil {
-		context = err.Error()
+	if task.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping lists",
+				Response: "failed to find task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping lists",
+			Response: "fetched task",
 		},
-		List: shoppingLists,
+		Spec: task,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PostShoppingList ...
-// creates a new shopping list to add items to
-func (h *HTTPServer) PostShoppingList(w http.ResponseWriter, r *http.Request) {
+// PostTask ...
+// creates a new task
+func (h *HTTPServer) PostTask(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var shoppingList types.ShoppingListSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+	var task types.TaskSpec
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -1020,19 +3660,13 @@ func (h *HTTPServer) PostShoppingList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	options := types.ShoppingItemOptions{
-		Selector: types.ShoppingItemSelector{
-			TemplateListItemSelector: r.FormValue("templateListItemSelector"),
-		},
-	}
-
-	shoppingList.Author = jwtUserID
-	shoppingListInserted, err := h.shoppinglist.ShoppingList().Create(shoppingList, options)
+	task.Author = jwtUserID
+	taskInserted, err := h.tasks.Task().Create(task)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to create shopping list",
+				Response: "failed to create task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1041,23 +3675,23 @@ func (h *HTTPServer) PostShoppingList(w http.ResponseWriter, r *http.Request) {
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "created shopping list",
+			Response: "created task",
 		},
-		Spec: shoppingListInserted,
+		Spec: taskInserted,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusCreated, JSONresp)
 }
 
-// PatchShoppingList ...
-// patches an existing shopping list
-func (h *HTTPServer) PatchShoppingList(w http.ResponseWriter, r *http.Request) {
+// PatchTask ...
+// patches an existing task
+func (h *HTTPServer) PatchTask(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var shoppingList types.ShoppingListSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+	var task types.TaskPatchSpec
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -1068,53 +3702,63 @@ func (h *HTTPServer) PatchShoppingList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	listID := vars["id"]
+	id := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	existingTask, err := h.tasks.Task().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
+	if existingTask.ID == "" {
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to find task",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
 
-	shoppingList.AuthorLast = jwtUserID
-	shoppingListPatched, err := h.shoppinglist.ShoppingList().Patch(list.ID, shoppingList)
+	task.AuthorLast = jwtUserID
+	taskPatched, err := h.tasks.Task().Patch(existingTask.ID, task)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to patch shopping list",
+				Response: "failed to patch task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "patched shopping list",
+			Response: "patched task",
 		},
-		Spec: shoppingListPatched,
+		Spec: taskPatched,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PutShoppingList ...
-// updates an existing shopping list
-func (h *HTTPServer) PutShoppingList(w http.ResponseWriter, r *http.Request) {
+// PutTask ...
+// updates an existing task
+func (h *HTTPServer) PutTask(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var shoppingList types.ShoppingListSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
+	var task types.TaskSpec
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -1125,360 +3769,246 @@ func (h *HTTPServer) PutShoppingList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	vars := mux.Vars(r)
-	listID := vars["id"]
+	id := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	existingTask, err := h.tasks.Task().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-
-	shoppingList.AuthorLast = jwtUserID
-	shoppingListUpdated, err := h.shoppinglist.ShoppingList().Update(list.ID, shoppingList)
-	if err != nil {
-		context = err.Error()
+	if existingTask.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to update shopping list",
+				Response: "failed to find task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	JSONresp := types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "updated shopping list",
-		},
-		Spec: shoppingListUpdated,
-	}
-	JSONResponse(r, w, http.StatusOK, JSONresp)
-}
-
-// DeleteShoppingList ...
-// delete a new shopping list by it's id
-func (h *HTTPServer) DeleteShoppingList(w http.ResponseWriter, r *http.Request) {
-	var context string
-
-	vars := mux.Vars(r)
-	listID := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	task.AuthorLast = jwtUserID
+	taskUpdated, err := h.tasks.Task().Update(existingTask.ID, task)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-
-	if err := h.shoppinglist.ShoppingList().Delete(list.ID); err != nil {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to delete shopping list",
+				Response: "failed to update task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "deleted shopping list",
+			Response: "updated task",
 		},
+		Spec: taskUpdated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetShoppingListItems ...
-// responds with shopping items by list id
-func (h *HTTPServer) GetShoppingListItems(w http.ResponseWriter, r *http.Request) {
+// DeleteTask ...
+// deletes a task and it's occurrences by it's id
+func (h *HTTPServer) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	var context string
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	options := types.ShoppingItemOptions{
-		SortBy: r.FormValue("sortBy"),
-		Selector: types.ShoppingItemSelector{
-			Obtained: r.FormValue("obtained"),
-		},
-	}
-
-	list, err := h.shoppinglist.ShoppingList().Get(id)
-	if err != nil {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-
-	// TODO add item selectors for this endpoint
-	shoppingListItems, err := h.shoppinglist.ShoppingItem().List(list.ID, options)
+	task, err := h.tasks.Task().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list items",
+				Response: "failed to get task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	JSONresp := types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping list items",
-		},
-		List: shoppingListItems,
-	}
-	JSONResponse(r, w, http.StatusOK, JSONresp)
-}
-
-// GetShoppingListItem ...
-// responds with list of shopping lists
-func (h *HTTPServer) GetShoppingListItem(w http.ResponseWriter, r *http.Request) {
-	var context string
-	vars := mux.Vars(r)
-	itemID := vars["itemId"]
-	listID := vars["listId"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
-	if err != nil {
-		context = err.Error()
+	if task.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to find task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
 
-	shoppingListItem, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
-	if err != nil {
+	if err := h.tasks.Task().Delete(task.ID); err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to delete task",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if shoppingListItem.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
-	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetch shopping list item",
+			Response: "deleted task",
 		},
-		Spec: shoppingListItem,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PostItemToShoppingList ...
-// adds an item to a shopping list
-func (h *HTTPServer) PostItemToShoppingList(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// GetTaskOccurrences ...
+// responds with occurrences of tasks, optionally for a single task
+func (h *HTTPServer) GetTaskOccurrences(w http.ResponseWriter, r *http.Request) {
 	var context string
+	vars := mux.Vars(r)
 
-	var shoppingItem types.ShoppingItemSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+	dueTimestampAfterString := r.FormValue("dueTimestampAfter")
+	dueTimestampBeforeString := r.FormValue("dueTimestampBefore")
+	dueTimestampAfter, err := strconv.ParseInt(dueTimestampAfterString, 10, 64)
+	if err != nil && dueTimestampAfterString != "" {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
+				Response: "unable to parse value for limiting request for task occurrences",
 			},
-		})
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
-
-	vars := mux.Vars(r)
-	listID := vars["id"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
-	if err != nil {
+	dueTimestampBefore, err := strconv.ParseInt(dueTimestampBeforeString, 10, 64)
+	if err != nil && dueTimestampBeforeString != "" {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "unable to parse value for limiting request for task occurrences",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
 
-	shoppingItem.Author = jwtUserID
-	shoppingItemInserted, err := h.shoppinglist.ShoppingItem().AddItemToList(list.ID, shoppingItem)
+	selector := types.TaskOccurrenceSelector{
+		TaskID:             vars["id"],
+		Assignee:           r.FormValue("assignee"),
+		Completed:          r.FormValue("completed"),
+		DueTimestampAfter:  dueTimestampAfter,
+		DueTimestampBefore: dueTimestampBefore,
+	}
+
+	occurrences, err := h.tasks.Occurrence().List(selector)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to add item to shopping list",
+				Response: "failed to get task occurrences",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "added item to shopping list",
+			Response: "fetched task occurrences",
 		},
-		Spec: shoppingItemInserted,
+		List: occurrences,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusCreated, JSONresp)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PatchShoppingListCompleted ...
-// adds an item to a shopping list
-func (h *HTTPServer) PatchShoppingListCompleted(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// GetTaskOccurrence ...
+// responds with an occurrence of a task by id
+func (h *HTTPServer) GetTaskOccurrence(w http.ResponseWriter, r *http.Request) {
 	var context string
-
-	var shoppingList types.ShoppingListSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingList); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
-	}
-
 	vars := mux.Vars(r)
-	listID := vars["id"]
+	taskID := vars["taskId"]
+	id := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	occurrence, err := h.tasks.Occurrence().Get(taskID, id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get task occurrence",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-
-	patchedList, err := h.shoppinglist.ShoppingList().SetListCompleted(list.ID, shoppingList.Completed, jwtUserID)
-	if err != nil {
-		context = err.Error()
+	if occurrence.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to set shopping list as completed",
+				Response: "failed to find task occurrence",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "shopping list set as completed",
+			Response: "fetched task occurrence",
 		},
-		Spec: patchedList,
+		Spec: occurrence,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PatchShoppingListItem ...
-// patches an item in a shopping list
-func (h *HTTPServer) PatchShoppingListItem(w http.ResponseWriter, r *http.Request) {
+// PatchTaskOccurrenceCompleted ...
+// sets an occurrence of a task as completed or not
+func (h *HTTPServer) PatchTaskOccurrenceCompleted(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var shoppingItem types.ShoppingItemSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+	var occurrence types.TaskOccurrenceSpec
+	if err := json.NewDecoder(r.Body).Decode(&occurrence); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
-	}
-
-	vars := mux.Vars(r)
-	itemID := vars["id"]
-	listID := vars["listId"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
-	if err != nil {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-	if list.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to read request body",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		})
 		return
 	}
 
-	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	vars := mux.Vars(r)
+	taskID := vars["taskId"]
+	id := vars["id"]
+
+	existingOccurrence, err := h.tasks.Occurrence().Get(taskID, id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get task occurrence",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if item.ID == "" {
+	if existingOccurrence.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to find task occurrence",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1486,13 +4016,12 @@ func (h *HTTPServer) PatchShoppingListItem(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	shoppingItem.AuthorLast = jwtUserID
-	patchedItem, err := h.shoppinglist.ShoppingItem().Patch(listID, item.ID, shoppingItem)
+	patchedOccurrence, err := h.tasks.Occurrence().SetCompleted(existingOccurrence.TaskID, existingOccurrence.ID, occurrence.Completed, jwtUserID)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to patch shopping list item",
+				Response: "failed to set task occurrence as completed",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1501,114 +4030,126 @@ func (h *HTTPServer) PatchShoppingListItem(w http.ResponseWriter, r *http.Reques
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "patched shopping list item",
+			Response: "set task occurrence as completed",
 		},
-		Spec: patchedItem,
+		Spec: patchedOccurrence,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PutShoppingListItem ...
-// updates an item in a shopping list
-func (h *HTTPServer) PutShoppingListItem(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// GetTaskHistory ...
+// responds with who was assigned and who completed past occurrences of tasks
+func (h *HTTPServer) GetTaskHistory(w http.ResponseWriter, r *http.Request) {
 	var context string
+	vars := mux.Vars(r)
 
-	var shoppingItem types.ShoppingItemSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
+	taskID := vars["id"]
+	if taskID == "" {
+		taskID = r.FormValue("taskId")
+	}
+	selector := types.TaskHistorySelector{
+		TaskID: taskID,
+		UserID: r.FormValue("userId"),
 	}
 
-	vars := mux.Vars(r)
-	itemID := vars["id"]
-	listID := vars["listId"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	history, err := h.tasks.Rotation().History(selector)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get task history",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if list.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched task history",
+		},
+		List: history,
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+// GetExpenses ...
+// responds with a list of expenses
+func (h *HTTPServer) GetExpenses(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	expenses, err := h.expenses.Expense().List()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to get expenses",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if item.ID == "" {
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched expenses",
+		},
+		List: expenses,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// GetExpense ...
+// responds with an expense by id
+func (h *HTTPServer) GetExpense(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	expense, err := h.expenses.Expense().Get(id)
+	if err != nil {
+		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to get expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-
-	shoppingItem.AuthorLast = jwtUserID
-	updatedItem, err := h.shoppinglist.ShoppingItem().Update(listID, item.ID, shoppingItem)
-	if err != nil {
-		context = err.Error()
+	if expense.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to update shopping list item",
+				Response: "failed to find expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "updated shopping list item",
+			Response: "fetched expense",
 		},
-		Spec: updatedItem,
+		Spec: expense,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PatchShoppingListItemObtained ...
-// patches an item in a shopping list
-func (h *HTTPServer) PatchShoppingListItemObtained(w http.ResponseWriter, r *http.Request) {
+// PostExpense ...
+// creates a new expense
+func (h *HTTPServer) PostExpense(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var shoppingItem types.ShoppingItemSpec
-	if err := json.NewDecoder(r.Body).Decode(&shoppingItem); err != nil {
+	var expense types.ExpenseSpec
+	if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -1618,49 +4159,66 @@ func (h *HTTPServer) PatchShoppingListItemObtained(w http.ResponseWriter, r *htt
 		return
 	}
 
-	vars := mux.Vars(r)
-	itemID := vars["id"]
-	listID := vars["listId"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	expense.Author = jwtUserID
+	expenseInserted, err := h.expenses.Expense().Create(expense)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to create expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
-	if list.ID == "" {
-		JSONresp := types.JSONMessageResponse{
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "created expense",
+		},
+		Spec: expenseInserted,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
+}
+
+// PutExpense ...
+// updates an existing expense
+func (h *HTTPServer) PutExpense(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var expense types.ExpenseSpec
+	if err := json.NewDecoder(r.Body).Decode(&expense); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to read request body",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		})
 		return
 	}
 
-	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	existingExpense, err := h.expenses.Expense().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to get expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if item.ID == "" {
+	if existingExpense.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to find expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1668,55 +4226,53 @@ func (h *HTTPServer) PatchShoppingListItemObtained(w http.ResponseWriter, r *htt
 		return
 	}
 
-	patchedItem, err := h.shoppinglist.ShoppingItem().SetItemObtained(listID, item.ID, shoppingItem.Obtained, jwtUserID)
+	expense.AuthorLast = jwtUserID
+	expenseUpdated, err := h.expenses.Expense().Update(existingExpense.ID, expense)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to update expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "set shopping list item as obtained",
+			Response: "updated expense",
 		},
-		Spec: patchedItem,
+		Spec: expenseUpdated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// DeleteShoppingListItem ...
-// delete a shopping list item by it's id
-func (h *HTTPServer) DeleteShoppingListItem(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// DeleteExpense ...
+// deletes an expense by it's id
+func (h *HTTPServer) DeleteExpense(w http.ResponseWriter, r *http.Request) {
 	var context string
 
 	vars := mux.Vars(r)
-	itemID := vars["itemId"]
-	listID := vars["listId"]
+	id := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	expense, err := h.expenses.Expense().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if list.ID == "" {
+	if expense.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to find expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1724,34 +4280,37 @@ func (h *HTTPServer) DeleteShoppingListItem(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	item, err := h.shoppinglist.ShoppingItem().Get(list.ID, itemID)
-	if err != nil {
+	if err := h.expenses.Expense().Delete(expense.ID); err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
+				Response: "failed to delete expense",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if item.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list item",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "deleted expense",
+		},
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	if err := h.shoppinglist.ShoppingItem().Delete(item.ID, list.ID, jwtUserID); err != nil {
+// GetExpenseBalances ...
+// responds with how much each flatmate has paid and owes
+func (h *HTTPServer) GetExpenseBalances(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	balances, err := h.expenses.Balance().List()
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to remove item from shopping list",
+				Response: "failed to get expense balances",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1760,62 +4319,51 @@ func (h *HTTPServer) DeleteShoppingListItem(w http.ResponseWriter, r *http.Reque
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "removed item from shopping list",
+			Response: "fetched expense balances",
 		},
+		List: balances,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// DeleteShoppingListTagItems ...
-// delete a shopping list items by matching a tag
-func (h *HTTPServer) DeleteShoppingListTagItems(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// GetExpenseSettleUp ...
+// responds with the transfers needed to settle all balances
+func (h *HTTPServer) GetExpenseSettleUp(w http.ResponseWriter, r *http.Request) {
 	var context string
-	var item types.ShoppingItemSpec
 
-	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
-	}
-
-	vars := mux.Vars(r)
-	listID := vars["listId"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	transfers, err := h.expenses.Balance().SettleUp()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get transfers to settle up",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if list.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched transfers to settle up",
+		},
+		List: transfers,
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	if err := h.shoppinglist.ShoppingItem().DeleteTagItems(list.ID, item.Tag, jwtUserID); err != nil {
+// GetAllGroups ...
+// returns a list of all groups
+func (h *HTTPServer) GetAllGroups(w http.ResponseWriter, r *http.Request) {
+	var context string
+	groups, err := h.groups.List()
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to remove items from shopping list by tag name",
+				Response: "failed to get groups",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -1824,97 +4372,123 @@ func (h *HTTPServer) DeleteShoppingListTagItems(w http.ResponseWriter, r *http.R
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "removed items from shopping list by tag name",
+			Response: "fetched groups",
 		},
+		List: groups,
 	}
-	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetShoppingListItemTags ...
-// responds with tags used in shopping list items from a list
-func (h *HTTPServer) GetShoppingListItemTags(w http.ResponseWriter, r *http.Request) {
+// GetGroup ...
+// returns a group by id
+func (h *HTTPServer) GetGroup(w http.ResponseWriter, r *http.Request) {
 	var context string
+
 	vars := mux.Vars(r)
-	listID := vars["listId"]
+	id := vars["id"]
 
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	group, err := h.groups.GetByID(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get group",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	if list.ID == "" {
+	if group.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
+				Response: "failed to get group",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		return
+	}
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched group",
+		},
+		Spec: group,
+	})
+}
+
+// GetAdminGroupPermissions ...
+// returns the permissions which groups are able to grant
+func (h *HTTPServer) GetAdminGroupPermissions(w http.ResponseWriter, r *http.Request) {
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched group permissions",
+		},
+		List: groups.Permissions,
+	})
+}
+
+// PostAdminGroup ...
+// creates a group with a set of permissions
+func (h *HTTPServer) PostAdminGroup(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	var group types.GroupSpec
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		})
 		return
 	}
-	tags, err := h.shoppinglist.ShoppingTag().ListTagsInList(list.ID)
+	groupCreated, err := h.groups.Create(group)
 	if err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to create group"
+		switch {
+		case errors.Is(err, groups.ErrGroupNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, groups.ErrGroupInvalidName),
+			errors.Is(err, groups.ErrGroupNameTaken),
+			errors.Is(err, groups.ErrGroupInvalidDescription),
+			errors.Is(err, groups.ErrGroupInvalidPermission),
+			errors.Is(err, groups.ErrGroupBuiltIn),
+			errors.Is(err, groups.ErrGroupHasMembers):
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get tags from shopping list",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched tags from shopping list",
+			Response: "created group",
 		},
-		List: tags,
+		Spec: groupCreated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
 }
 
-// UpdateShoppingListItemTag ...
-// updates then tag name used in shopping list items from a list
-func (h *HTTPServer) UpdateShoppingListItemTag(w http.ResponseWriter, r *http.Request) {
+// PutAdminGroup ...
+// updates the name, description and permissions of a group
+func (h *HTTPServer) PutAdminGroup(w http.ResponseWriter, r *http.Request) {
 	var context string
 	vars := mux.Vars(r)
-	listID := vars["listId"]
-	tag := vars["tagName"]
-
-	list, err := h.shoppinglist.ShoppingList().Get(listID)
-	if err != nil {
-		context = err.Error()
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to update shopping list tag",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-	if list.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
-	}
+	id := vars["id"]
 
-	var tagUpdate types.ShoppingTag
-	if err := json.NewDecoder(r.Body).Decode(&tagUpdate); err != nil {
+	var group types.GroupSpec
+	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -1923,84 +4497,133 @@ func (h *HTTPServer) UpdateShoppingListItemTag(w http.ResponseWriter, r *http.Re
 		})
 		return
 	}
-
-	tagUpdated, err := h.shoppinglist.ShoppingTag().UpdateInList(list.ID, tag, tagUpdate.Name)
+	groupUpdated, err := h.groups.Update(id, group)
 	if err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to update group"
+		switch {
+		case errors.Is(err, groups.ErrGroupNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, groups.ErrGroupInvalidName),
+			errors.Is(err, groups.ErrGroupNameTaken),
+			errors.Is(err, groups.ErrGroupInvalidDescription),
+			errors.Is(err, groups.ErrGroupInvalidPermission),
+			errors.Is(err, groups.ErrGroupBuiltIn),
+			errors.Is(err, groups.ErrGroupHasMembers):
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to update shopping list tag",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "updated shopping list tag",
+			Response: "updated group",
 		},
-		Spec: tagUpdated,
+		Spec: groupUpdated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetAllShoppingTags ...
-// responds with all tags used in shopping list items
-func (h *HTTPServer) GetAllShoppingTags(w http.ResponseWriter, r *http.Request) {
+// DeleteAdminGroup ...
+// deletes a group which no user accounts are in
+func (h *HTTPServer) DeleteAdminGroup(w http.ResponseWriter, r *http.Request) {
 	var context string
-	options := types.ShoppingTagOptions{
-		SortBy: r.FormValue("sortBy"),
-	}
+	vars := mux.Vars(r)
+	id := vars["id"]
 
-	tags, err := h.shoppinglist.ShoppingTag().List(options)
-	if err != nil {
+	if err := h.groups.Delete(id); err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to delete group"
+		switch {
+		case errors.Is(err, groups.ErrGroupNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, groups.ErrGroupInvalidName),
+			errors.Is(err, groups.ErrGroupNameTaken),
+			errors.Is(err, groups.ErrGroupInvalidDescription),
+			errors.Is(err, groups.ErrGroupInvalidPermission),
+			errors.Is(err, groups.ErrGroupBuiltIn),
+			errors.Is(err, groups.ErrGroupHasMembers):
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping list tags",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping list tags",
+			Response: "deleted group",
 		},
-		List: tags,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PostShoppingTag ...
-// creates a tag name
-func (h *HTTPServer) PostShoppingTag(w http.ResponseWriter, r *http.Request) {
+// UserCanIpermission ...
+// responds whether the current user account has a permission
+func (h *HTTPServer) UserCanIpermission(w http.ResponseWriter, r *http.Request) {
 	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	jwtUserID := reqClaims.ID
 	var context string
 
-	var tag types.ShoppingTag
-	if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+	vars := mux.Vars(r)
+	permission := vars["name"]
+
+	hasPermission, err := h.groups.CheckUserHasPermission(jwtUserID, permission)
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
+				Response: "failed to check whether user has permission",
 			},
-		})
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched user has permission",
+		},
+		Data: hasPermission,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	tag.Author = jwtUserID
-	tagCreated, err := h.shoppinglist.ShoppingTag().Create(tag)
+// GetUserConfirms ...
+// returns a list of account confirms
+// TODO should this exist?
+func (h *HTTPServer) GetUserConfirms(w http.ResponseWriter, r *http.Request) {
+	var context string
+	userIDSelector := r.FormValue("userId")
+	userCreationSecretSelector := types.UserCreationSecretSelector{
+		UserID: userIDSelector,
+	}
+
+	creationSecrets, err := h.users.UserCreationSecrets().List(userCreationSecretSelector)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to create shopping tag",
+				Response: "failed to get user creation secrets",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -2009,37 +4632,38 @@ func (h *HTTPServer) PostShoppingTag(w http.ResponseWriter, r *http.Request) {
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "created shopping tag",
+			Response: "fetched user creation secrets",
 		},
-		Spec: tagCreated,
+		List: creationSecrets,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusCreated, JSONresp)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetShoppingTag ...
-// gets a shopping tag by id
-func (h *HTTPServer) GetShoppingTag(w http.ResponseWriter, r *http.Request) {
+// GetUserConfirm ...
+// returns an account confirm by id
+func (h *HTTPServer) GetUserConfirm(w http.ResponseWriter, r *http.Request) {
 	var context string
+
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	tag, err := h.shoppinglist.ShoppingTag().Get(id)
+	creationSecret, err := h.users.UserCreationSecrets().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
+				Response: "failed to get user creation secret",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	if tag.ID == "" {
+	if creationSecret.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
+				Response: "failed to get user creation secret",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -2048,49 +4672,66 @@ func (h *HTTPServer) GetShoppingTag(w http.ResponseWriter, r *http.Request) {
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping tag",
+			Response: "fetched user creation secret",
 		},
-		Spec: tag,
+		Spec: creationSecret,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// UpdateShoppingTag ...
-// updates a tag name
-func (h *HTTPServer) UpdateShoppingTag(w http.ResponseWriter, r *http.Request) {
-	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
-	jwtUserID := reqClaims.ID
+// GetUserConfirmValid ...
+// returns if an account confirm is valid by id
+// TODO should this exist?
+func (h *HTTPServer) GetUserConfirmValid(w http.ResponseWriter, r *http.Request) {
 	var context string
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	tag, err := h.shoppinglist.ShoppingTag().Get(id)
+	creationSecret, err := h.users.UserCreationSecrets().Get(id)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
+				Response: "failed to get user creation secret",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	if tag.ID == "" {
+	if creationSecret.ID == "" {
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
+				Response: "failed to get user creation secret",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
+
 	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched user creation secret valid",
+		},
+		Data: creationSecret.ID != "" && creationSecret.Secret != "" && creationSecret.Valid,
+	}
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
 
-	var tagUpdate types.ShoppingTag
-	if err := json.NewDecoder(r.Body).Decode(&tagUpdate); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
+// PostUserConfirm ...
+// confirm a user account
+func (h *HTTPServer) PostUserConfirm(w http.ResponseWriter, r *http.Request) {
+	var context string
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	secret := r.FormValue("secret")
+
+	var user types.UserSpec
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
 				Response: "failed to read request body",
@@ -2098,156 +4739,150 @@ func (h *HTTPServer) UpdateShoppingTag(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	tagUpdate.AuthorLast = jwtUserID
-	tagUpdated, err := h.shoppinglist.ShoppingTag().Update(tag.ID, tagUpdate)
+
+	jwt, err := h.users.ConfirmUserAccount(id, secret, user, GetRequestSession(r))
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to update shopping tag",
+				Response: "failed to confirm user account",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
+	h.SetTokenCookie(w, jwt)
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "updated shopping tag",
+			Response: "confirmed user account",
 		},
-		Spec: tagUpdated,
+		Data: jwt,
 	}
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
 }
 
-// DeleteShoppingTag ...
-// deletes a shopping tag by id
-func (h *HTTPServer) DeleteShoppingTag(w http.ResponseWriter, r *http.Request) {
+// GetVersion ...
+// returns version information about the instance
+func (h *HTTPServer) GetVersion(w http.ResponseWriter, r *http.Request) {
 	var context string
 
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	tag, err := h.shoppinglist.ShoppingTag().Get(id)
+	version := common.GetAppBuildVersion()
+	commitHash := common.GetAppBuildHash()
+	mode := common.GetAppBuildMode()
+	date := common.GetAppBuildDate()
+	golangVersion := runtime.Version()
+	databaseVersion, err := database.GetVersion(h.db)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
+				Response: "failed to get database version",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	if tag.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping tag",
-			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
+	var postgresVersion, sqliteVersion string
+	if database.DialectOf(h.db) == database.DialectSQLite {
+		sqliteVersion = databaseVersion
+	} else {
+		postgresVersion = databaseVersion
 	}
-
-	if err := h.shoppinglist.ShoppingTag().Delete(tag.ID); err != nil {
+	osType := runtime.GOOS
+	osArch := runtime.GOARCH
+	schedulerLastRun, err := h.system.GetSchedulerLastRun()
+	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to delete shopping tag",
+				Response: "failed to get scheduler last run info",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
-		return
-	}
-	JSONresp := types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "deleted shopping tag",
-		},
-	}
-	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
-}
-
-// GetSettingsShoppingListNotes ...
-// responds with the notes for shopping lists
-func (h *HTTPServer) GetSettingsShoppingListNotes(w http.ResponseWriter, r *http.Request) {
-	var context string
-	notes, err := h.settings.GetShoppingListNotes()
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	backupLastRun, err := h.system.GetBackupLastRun()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping notes",
+				Response: "failed to get backup last run info",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
+
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping notes",
+			Response: "fetched version information",
+		},
+		Data: types.SystemVersion{
+			Version:          version,
+			CommitHash:       commitHash,
+			Mode:             mode,
+			Date:             date,
+			GolangVersion:    golangVersion,
+			PostgresVersion:  postgresVersion,
+			SQLiteVersion:    sqliteVersion,
+			OSType:           osType,
+			OSArch:           osArch,
+			SchedulerLastRun: schedulerLastRun,
+			BackupLastRun:    backupLastRun,
 		},
-		Spec: notes,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PutSettingsShoppingList ...
-// update the notes for shopping lists
-func (h *HTTPServer) PutSettingsShoppingList(w http.ResponseWriter, r *http.Request) {
-	var context string
-
-	var notes types.ShoppingListNotes
-	if err := json.NewDecoder(r.Body).Decode(&notes); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+func (h *HTTPServer) PostSchedulerRun(w http.ResponseWriter, r *http.Request) {
+	if !h.scheduling.GetEndpointEnabled() {
+		w.WriteHeader(http.StatusNotFound)
+		if _, err := w.Write([]byte(`Not found`)); err != nil {
+			slog.Error("failed to write response", "error", err)
+		}
+		return
+	}
+	secret, expectedSecret := r.Header.Get(FlatTrackSchedulerSecretHeader), h.scheduling.GetEndpointSecret()
+	if secret != expectedSecret {
+		JSONResponse(r, w, http.StatusUnauthorized, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
+				Response: "unexpected secret",
 			},
 		})
 		return
 	}
-
-	if err := h.settings.SetShoppingListNotes(notes.Notes); err != nil {
-		context = err.Error()
-		code := http.StatusInternalServerError
-		if err.Error() == "Unable to set shopping list notes as it is either invalid, too short, or too long" {
-			code = http.StatusBadRequest
-		}
-		JSONresp := types.JSONMessageResponse{
+	if err := h.scheduling.PerformWork(); err != nil {
+		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping notes",
+				Response: "failed to run work",
 			},
-		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, code, JSONresp)
+		})
 		return
 	}
-	JSONresp := types.JSONMessageResponse{
+	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "set shopping notes",
+			Response: "completed work",
 		},
-		Spec: notes.Notes,
-	}
-	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	})
 }
 
-// GetSettingsFlatNotes ...
-// responds with the notes for flat
-func (h *HTTPServer) GetSettingsFlatNotes(w http.ResponseWriter, r *http.Request) {
+// GetInstanceFlats ...
+// returns the flats which the instance hosts
+func (h *HTTPServer) GetInstanceFlats(w http.ResponseWriter, r *http.Request) {
 	var context string
-	notes, err := h.settings.GetFlatNotes()
+
+	flatList, err := h.flats.List()
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get flat notes",
+				Response: "failed to list flats",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -2256,23 +4891,21 @@ func (h *HTTPServer) GetSettingsFlatNotes(w http.ResponseWriter, r *http.Request
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched flat notes",
-		},
-		Spec: types.FlatNotes{
-			Notes: notes,
+			Response: "fetched flats",
 		},
+		List: flatList,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PutSettingsFlatNotes ...
-// update the notes for flat
-func (h *HTTPServer) PutSettingsFlatNotes(w http.ResponseWriter, r *http.Request) {
+// PostInstanceFlat ...
+// creates a flat, which is set up by visiting it
+func (h *HTTPServer) PostInstanceFlat(w http.ResponseWriter, r *http.Request) {
 	var context string
 
-	var notes types.FlatNotes
-	if err := json.NewDecoder(r.Body).Decode(&notes); err != nil {
+	var flat types.FlatSpec
+	if err := json.NewDecoder(r.Body).Decode(&flat); err != nil {
 		slog.Error("failed to unmarshal", "error", err)
 		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
@@ -2281,79 +4914,124 @@ func (h *HTTPServer) PutSettingsFlatNotes(w http.ResponseWriter, r *http.Request
 		})
 		return
 	}
-
-	if err := h.settings.SetFlatNotes(notes.Notes); err != nil {
+	flatCreated, err := h.flats.Create(flat)
+	if err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to create flat"
+		switch {
+		case errors.Is(err, flats.ErrFlatNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, flats.ErrFlatInvalidName),
+			errors.Is(err, flats.ErrFlatInvalidHostname),
+			errors.Is(err, flats.ErrFlatNameTaken),
+			errors.Is(err, flats.ErrFlatHostnameTaken),
+			errors.Is(err, flats.ErrFlatDefault),
+			errors.Is(err, flats.ErrFlatHasUsers):
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get flat notes",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "set flat notes",
+			Response: "created flat",
 		},
-		Spec: notes.Notes,
+		Spec: flatCreated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	JSONResponse(r, w, http.StatusCreated, JSONresp)
 }
 
-// GetSettingsShoppingListKeepPolicy ...
-// responds with the keepPolicy for shopping lists
-func (h *HTTPServer) GetSettingsShoppingListKeepPolicy(w http.ResponseWriter, r *http.Request) {
+// PutInstanceFlat ...
+// updates the name and hostname of a flat
+func (h *HTTPServer) PutInstanceFlat(w http.ResponseWriter, r *http.Request) {
 	var context string
-	keepPolicy, err := h.settings.GetShoppingListKeepPolicy()
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var flat types.FlatSpec
+	if err := json.NewDecoder(r.Body).Decode(&flat); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+	flatUpdated, err := h.flats.Update(id, flat)
 	if err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to update flat"
+		switch {
+		case errors.Is(err, flats.ErrFlatNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, flats.ErrFlatInvalidName),
+			errors.Is(err, flats.ErrFlatInvalidHostname),
+			errors.Is(err, flats.ErrFlatNameTaken),
+			errors.Is(err, flats.ErrFlatHostnameTaken),
+			errors.Is(err, flats.ErrFlatDefault),
+			errors.Is(err, flats.ErrFlatHasUsers):
+			code = http.StatusBadRequest
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping keep policy",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched shopping keep policy",
+			Response: "updated flat",
 		},
-		Spec: keepPolicy,
+		Spec: flatUpdated,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PutSettingsShoppingListKeepPolicy ...
-// update the keep policy for shopping lists
-func (h *HTTPServer) PutSettingsShoppingListKeepPolicy(w http.ResponseWriter, r *http.Request) {
+// DeleteInstanceFlat ...
+// deletes a flat which no longer has user accounts
+func (h *HTTPServer) DeleteInstanceFlat(w http.ResponseWriter, r *http.Request) {
 	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
 
-	var spec types.ShoppingListKeepPolicySpec
-	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
-		slog.Error("failed to unmarshal", "error", err)
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
-	}
-
-	if err := h.settings.SetShoppingListKeepPolicy(spec.KeepPolicy); err != nil {
+	if err := h.flats.Delete(id); err != nil {
 		context = err.Error()
 		code := http.StatusInternalServerError
-		if err.Error() == "Unable to set shopping list keep policy as it is invalid" {
+		response := "failed to delete flat"
+		switch {
+		case errors.Is(err, flats.ErrFlatNotFound):
+			code = http.StatusNotFound
+			response = err.Error()
+		case errors.Is(err, flats.ErrFlatInvalidName),
+			errors.Is(err, flats.ErrFlatInvalidHostname),
+			errors.Is(err, flats.ErrFlatNameTaken),
+			errors.Is(err, flats.ErrFlatHostnameTaken),
+			errors.Is(err, flats.ErrFlatDefault),
+			errors.Is(err, flats.ErrFlatHasUsers):
 			code = http.StatusBadRequest
+			response = err.Error()
 		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get shopping keep policy",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
@@ -2362,359 +5040,419 @@ func (h *HTTPServer) PutSettingsShoppingListKeepPolicy(w http.ResponseWriter, r
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "set shopping keep policy",
+			Response: "deleted flat",
 		},
-		Spec: spec.KeepPolicy,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetAllGroups ...
-// returns a list of all groups
-func (h *HTTPServer) GetAllGroups(w http.ResponseWriter, r *http.Request) {
+// GetInstanceBackup ...
+// returns a backup archive of all the flats of the instance
+func (h *HTTPServer) GetInstanceBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", backups.BackupContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="flattrack-backup-%v.ndjson"`, time.Now().UTC().Format("20060102T150405Z")))
+	backup, err := h.backups.Write(w)
+	if err != nil {
+		// the archive may have already started being sent,
+		// so the failure is only able to be logged
+		slog.Error("failed to write backup", "error", err)
+		return
+	}
+	slog.Info("request log", "response", "wrote backup", "migrationVersion", backup.MigrationVersion)
+}
+
+// PostInstanceRestore ...
+// replaces all the data of the instance with a backup archive
+func (h *HTTPServer) PostInstanceRestore(w http.ResponseWriter, r *http.Request) {
 	var context string
-	groups, err := h.groups.List()
+
+	backup, err := h.backups.Restore(r.Body)
 	if err != nil {
 		context = err.Error()
+		code := http.StatusInternalServerError
+		response := "failed to restore backup"
+		switch {
+		case errors.Is(err, backups.ErrBackupInvalid),
+			errors.Is(err, backups.ErrBackupFormatVersionUnsupported),
+			errors.Is(err, backups.ErrBackupMigrationVersionMismatch),
+			errors.Is(err, backups.ErrBackupUnknownTable):
+			code = http.StatusBadRequest
+			response = err.Error()
+		case errors.Is(err, backups.ErrBackupDatabaseDirty):
+			code = http.StatusServiceUnavailable
+			response = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get groups",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched groups",
+			Response: "restored backup",
 		},
-		List: groups,
+		Spec: backup,
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// GetGroup ...
-// returns a group by id
-func (h *HTTPServer) GetGroup(w http.ResponseWriter, r *http.Request) {
-	var context string
+// shoppingListImportMaxBytes is the largest file which is able to be imported into a shopping list
+const shoppingListImportMaxBytes = 1 << 20
+
+// shoppingListImportErrorResponse ...
+// returns the status code and response for an error from importing a shopping list
+func shoppingListImportErrorResponse(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, shoppinglist.ErrFailedToGetExistingShoppingList):
+		return http.StatusNotFound, err.Error()
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, "Unable to import shopping list file, as it is too large"
+	case errors.Is(err, shoppinglist.ErrShoppingListImportInvalidRows),
+		errors.Is(err, shoppinglist.ErrShoppingListImportTooManyItems),
+		errors.Is(err, shoppinglist.ErrShoppingListFileFormatInvalid),
+		errors.Is(err, shoppinglist.ErrShoppingListFileInvalid),
+		errors.Is(err, shoppinglist.ErrShoppingListFileNameColumn),
+		errors.Is(err, shoppinglist.ErrInvalidShoppingItemName),
+		errors.Is(err, shoppinglist.ErrInvalidShoppingListNotes):
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, "failed to import shopping list"
+}
 
+// GetShoppingListExport ...
+// responds with the items of a shopping list as a CSV or JSON file
+func (h *HTTPServer) GetShoppingListExport(w http.ResponseWriter, r *http.Request) {
+	var context string
 	vars := mux.Vars(r)
-	id := vars["id"]
+	listID := vars["id"]
 
-	group, err := h.groups.GetByID(id)
+	format, err := shoppinglist.ParseShoppingListFileFormat(r.FormValue("format"))
 	if err != nil {
-		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get group",
+				Response: err.Error(),
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		JSONResponse(r, w, http.StatusBadRequest, JSONresp)
 		return
 	}
-	if group.ID == "" {
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get group",
+				Response: "failed to get shopping list",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "fetched group",
-		},
-		Spec: group,
-	})
-}
-
-// GetUserConfirms ...
-// returns a list of account confirms
-// TODO should this exist?
-func (h *HTTPServer) GetUserConfirms(w http.ResponseWriter, r *http.Request) {
-	var context string
-	userIDSelector := r.FormValue("userId")
-	userCreationSecretSelector := types.UserCreationSecretSelector{
-		UserID: userIDSelector,
-	}
-
-	creationSecrets, err := h.users.UserCreationSecrets().List(userCreationSecretSelector)
+	items, err := h.shoppinglist.ShoppingItem().Export(list.ID)
 	if err != nil {
 		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get user creation secrets",
+				Response: "failed to export shopping list",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
 	}
-	JSONresp := types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "fetched user creation secrets",
-		},
-		List: creationSecrets,
+	contentType := "application/json"
+	if format == types.ShoppingListFileFormatCSV {
+		contentType = "text/csv; charset=utf-8"
 	}
-	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fmt.Sprintf("%v.%v", list.Name, format)}))
+	w.WriteHeader(http.StatusOK)
+	if err := shoppinglist.WriteShoppingListFile(w, format, items); err != nil {
+		slog.Error("failed to write shopping list export", "error", err)
+		return
+	}
+	slog.Info("request log", "response", "exported shopping list", "context", context)
 }
 
-// GetUserConfirm ...
-// returns an account confirm by id
-func (h *HTTPServer) GetUserConfirm(w http.ResponseWriter, r *http.Request) {
+// importShoppingListResponse ...
+// responds with the outcome of importing a file into a shopping list
+func importShoppingListResponse(r *http.Request, w http.ResponseWriter, result types.ShoppingListImportSpec, err error, successCode int) {
 	var context string
-
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	creationSecret, err := h.users.UserCreationSecrets().Get(id)
 	if err != nil {
 		context = err.Error()
+		code, response := shoppingListImportErrorResponse(err)
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get user creation secret",
+				Response: response,
 			},
 		}
-		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
-		return
-	}
-	if creationSecret.ID == "" {
-		JSONresp := types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get user creation secret",
-			},
+		if errors.Is(err, shoppinglist.ErrShoppingListImportInvalidRows) {
+			JSONresp.Spec = result
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
+	response := "imported shopping list"
+	if result.DryRun {
+		response = "checked shopping list import"
+		successCode = http.StatusOK
+	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched user creation secret",
+			Response: response,
 		},
-		Spec: creationSecret,
+		Spec: result,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-	JSONResponse(r, w, http.StatusOK, JSONresp)
+	JSONResponse(r, w, successCode, JSONresp)
 }
 
-// GetUserConfirmValid ...
-// returns if an account confirm is valid by id
-// TODO should this exist?
-func (h *HTTPServer) GetUserConfirmValid(w http.ResponseWriter, r *http.Request) {
+// PostShoppingListImport ...
+// adds the items of a CSV or JSON file to a shopping list.
+// With dryRun, only reports which rows are invalid
+func (h *HTTPServer) PostShoppingListImport(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	vars := mux.Vars(r)
+	listID := vars["id"]
+
+	format, err := shoppinglist.ParseShoppingListFileFormat(r.FormValue("format"))
+	if err != nil {
+		importShoppingListResponse(r, w, types.ShoppingListImportSpec{}, err, http.StatusOK)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, shoppingListImportMaxBytes)
+	result, err := h.shoppinglist.ShoppingItem().Import(listID, body, format, reqClaims.ID, r.FormValue("dryRun") == "true")
+	importShoppingListResponse(r, w, result, err, http.StatusOK)
+}
+
+// PostShoppingListsImport ...
+// creates a shopping list from the items of a CSV or JSON file, named by name.
+// With dryRun, only reports which rows are invalid
+func (h *HTTPServer) PostShoppingListsImport(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+
+	format, err := shoppinglist.ParseShoppingListFileFormat(r.FormValue("format"))
+	if err != nil {
+		importShoppingListResponse(r, w, types.ShoppingListImportSpec{}, err, http.StatusCreated)
+		return
+	}
+	shoppingList := types.ShoppingListSpec{
+		Name:   r.FormValue("name"),
+		Notes:  r.FormValue("notes"),
+		Author: reqClaims.ID,
+	}
+	body := http.MaxBytesReader(w, r.Body, shoppingListImportMaxBytes)
+	result, err := h.shoppinglist.ShoppingList().Import(shoppingList, body, format, r.FormValue("dryRun") == "true")
+	importShoppingListResponse(r, w, result, err, http.StatusCreated)
+}
+
+// shoppingAttachmentErrorResponse ...
+// returns the status code and response for an error from managing shopping attachments
+func shoppingAttachmentErrorResponse(err error, response string) (int, string) {
+	switch {
+	case errors.Is(err, shoppinglist.ErrShoppingAttachmentNotFound),
+		errors.Is(err, shoppinglist.ErrShoppingAttachmentThumbnailUnavailable),
+		errors.Is(err, shoppinglist.ErrShoppingAttachmentItemNotFound),
+		errors.Is(err, shoppinglist.ErrFailedToGetExistingShoppingList):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, shoppinglist.ErrShoppingAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, shoppinglist.ErrShoppingAttachmentContentTypeInvalid):
+		return http.StatusUnsupportedMediaType, err.Error()
+	case errors.Is(err, shoppinglist.ErrShoppingAttachmentEmpty),
+		errors.Is(err, shoppinglist.ErrShoppingAttachmentListNotCompleted),
+		errors.Is(err, shoppinglist.ErrShoppingAttachmentKindInvalid),
+		errors.Is(err, http.ErrMissingFile),
+		errors.Is(err, http.ErrNotMultipart):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, shoppinglist.ErrShoppingAttachmentStorageUnavailable):
+		return http.StatusServiceUnavailable, err.Error()
+	}
+	return http.StatusInternalServerError, response
+}
+
+// GetShoppingListAttachments ...
+// returns the receipts of a shopping list and the photos of it's items
+func (h *HTTPServer) GetShoppingListAttachments(w http.ResponseWriter, r *http.Request) {
 	var context string
 	vars := mux.Vars(r)
-	id := vars["id"]
+	listID := vars["listId"]
 
-	creationSecret, err := h.users.UserCreationSecrets().Get(id)
-	if err != nil {
-		context = err.Error()
+	list, err := h.shoppinglist.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		if err != nil {
+			context = err.Error()
+		}
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get user creation secret",
+				Response: "failed to get shopping list",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 		JSONResponse(r, w, http.StatusNotFound, JSONresp)
 		return
 	}
-	if creationSecret.ID == "" {
+	attachments, err := h.shoppinglist.ShoppingAttachment().List(list.ID)
+	if err != nil {
+		context = err.Error()
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get user creation secret",
+				Response: "failed to list shopping list attachments",
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusNotFound, JSONresp)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
 		return
-
 	}
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched user creation secret valid",
+			Response: "fetched shopping list attachments",
 		},
-		Data: creationSecret.ID != "" && creationSecret.Secret != "" && creationSecret.Valid,
+		List: attachments,
 	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-// PostUserConfirm ...
-// confirm a user account
-func (h *HTTPServer) PostUserConfirm(w http.ResponseWriter, r *http.Request) {
+// postShoppingAttachment ...
+// uploads the file in a request as an attachment of a shopping list
+func (h *HTTPServer) postShoppingAttachment(w http.ResponseWriter, r *http.Request, attachment types.ShoppingAttachmentSpec) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 	var context string
-
 	vars := mux.Vars(r)
-	id := vars["id"]
-
-	secret := r.FormValue("secret")
+	listID := vars["listId"]
 
-	var user types.UserSpec
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to read request body",
-			},
-		})
-		return
+	name, data, err := readAttachmentUpload(w, r, common.GetAttachmentMaxBytes())
+	if err == nil {
+		attachment.Name = name
+		attachment.Author = reqClaims.ID
+		attachment, err = h.shoppinglist.ShoppingAttachment().Create(listID, attachment, data)
 	}
-
-	jwt, err := h.users.ConfirmUserAccount(id, secret, user)
 	if err != nil {
 		context = err.Error()
+		code, response := shoppingAttachmentErrorResponse(err, "failed to upload attachment")
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to confirm user account",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
-	h.SetTokenCookie(w, jwt)
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "confirmed user account",
+			Response: "uploaded attachment",
 		},
-		Data: jwt,
+		Spec: attachment,
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusCreated, JSONresp)
 }
 
-// GetVersion ...
-// returns version information about the instance
-func (h *HTTPServer) GetVersion(w http.ResponseWriter, r *http.Request) {
+// PostShoppingListItemPhoto ...
+// uploads a photo of a shopping item, replacing the last one
+func (h *HTTPServer) PostShoppingListItemPhoto(w http.ResponseWriter, r *http.Request) {
+	h.postShoppingAttachment(w, r, types.ShoppingAttachmentSpec{
+		Kind:   types.ShoppingAttachmentKindPhoto,
+		ItemID: mux.Vars(r)["itemId"],
+	})
+}
+
+// PostShoppingListReceipt ...
+// uploads a receipt of a completed shopping list
+func (h *HTTPServer) PostShoppingListReceipt(w http.ResponseWriter, r *http.Request) {
+	h.postShoppingAttachment(w, r, types.ShoppingAttachmentSpec{
+		Kind: types.ShoppingAttachmentKindReceipt,
+	})
+}
+
+// serveShoppingAttachment ...
+// responds with the file of an attachment, or it's thumbnail
+func (h *HTTPServer) serveShoppingAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
 	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
 
-	version := common.GetAppBuildVersion()
-	commitHash := common.GetAppBuildHash()
-	mode := common.GetAppBuildMode()
-	date := common.GetAppBuildDate()
-	golangVersion := runtime.Version()
-	postgresVersion, err := database.GetVersion(h.db)
+	attachment, data, err := h.shoppinglist.ShoppingAttachment().Open(id, thumbnail)
 	if err != nil {
 		context = err.Error()
+		code, response := shoppingAttachmentErrorResponse(err, "failed to get attachment")
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get postgres version",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
-	osType := runtime.GOOS
-	osArch := runtime.GOARCH
-	schedulerLastRun, err := h.system.GetSchedulerLastRun()
-	if err != nil {
+	contentType := attachment.ContentType
+	if thumbnail {
+		contentType = "image/jpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
+
+// GetShoppingAttachment ...
+// responds with the file of an attachment
+func (h *HTTPServer) GetShoppingAttachment(w http.ResponseWriter, r *http.Request) {
+	h.serveShoppingAttachment(w, r, false)
+}
+
+// GetShoppingAttachmentThumbnail ...
+// responds with a small JPEG of an image attachment
+func (h *HTTPServer) GetShoppingAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	h.serveShoppingAttachment(w, r, true)
+}
+
+// DeleteShoppingAttachment ...
+// removes an attachment
+func (h *HTTPServer) DeleteShoppingAttachment(w http.ResponseWriter, r *http.Request) {
+	var context string
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.shoppinglist.ShoppingAttachment().Delete(id); err != nil {
 		context = err.Error()
+		code, response := shoppingAttachmentErrorResponse(err, "failed to delete attachment")
 		JSONresp := types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "failed to get scheduler last run info",
+				Response: response,
 			},
 		}
 		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
-		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		JSONResponse(r, w, code, JSONresp)
 		return
 	}
-
 	JSONresp := types.JSONMessageResponse{
 		Metadata: types.JSONResponseMetadata{
-			Response: "fetched version information",
-		},
-		Data: types.SystemVersion{
-			Version:          version,
-			CommitHash:       commitHash,
-			Mode:             mode,
-			Date:             date,
-			GolangVersion:    golangVersion,
-			PostgresVersion:  postgresVersion,
-			OSType:           osType,
-			OSArch:           osArch,
-			SchedulerLastRun: schedulerLastRun,
+			Response: "deleted attachment",
 		},
 	}
 	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
-func (h *HTTPServer) PostSchedulerRun(w http.ResponseWriter, r *http.Request) {
-	if !h.scheduling.GetEndpointEnabled() {
-		w.WriteHeader(http.StatusNotFound)
-		if _, err := w.Write([]byte(`Not found`)); err != nil {
-			slog.Error("failed to write response", "error", err)
-		}
-		return
-	}
-	secret, expectedSecret := r.Header.Get(FlatTrackSchedulerSecretHeader), h.scheduling.GetEndpointSecret()
-	if secret != expectedSecret {
-		JSONResponse(r, w, http.StatusUnauthorized, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "unexpected secret",
-			},
-		})
-		return
-	}
-	if err := h.scheduling.PerformWork(); err != nil {
-		JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
-			Metadata: types.JSONResponseMetadata{
-				Response: "failed to run work",
-			},
-		})
-		return
-	}
-	JSONResponse(r, w, http.StatusOK, types.JSONMessageResponse{
-		Metadata: types.JSONResponseMetadata{
-			Response: "completed work",
-		},
-	})
-}
-
-// GetServeFilestoreObjects ...
-// serves files or 404
-// func (router Router) GetServeFilestoreObjects(prefix string) http.HandlerFunc {
-// 	if router.FileAccess.Client == nil {
-// 		return HTTP404()
-// 	}
-// 	return func(w http.ResponseWriter, r *http.Request) {
-// 		doneCh := make(chan struct{})
-// 		defer close(doneCh)
-
-// 		path := strings.TrimPrefix(r.URL.Path, prefix+"/")
-// 		object, objectInfo, err := router.FileAccess.Get(path)
-// 		if objectInfo.Size == 0 {
-// 			w.WriteHeader(http.StatusNotFound)
-// 			if _, err := w.Write([]byte("File not found")); err != nil {
-// 				log.Printf("error: failed to write response; %v", err)
-// 			}
-// 			return
-// 		}
-// 		if err != nil {
-// 			log.Printf("%#v\n", err)
-// 			w.WriteHeader(http.StatusInternalServerError)
-// 			if _, err := w.Write([]byte("An error occurred with retrieving the requested object")); err != nil {
-// 				slog.Error("failed to write response", "error", err)
-// 			}
-// 			return
-// 		}
-// 		log.Println(objectInfo.Key, objectInfo.Size, objectInfo.ContentType)
-// 		w.Header().Set("content-length", fmt.Sprintf("%d", objectInfo.Size))
-// 		w.Header().Set("content-type", objectInfo.ContentType)
-// 		w.Header().Set("accept-ranges", "bytes")
-// 		w.WriteHeader(http.StatusOK)
-// 		if _, err := w.Write(object); err != nil {
-// 			slog.Error("failed to write response", "error", err)
-// 		}
-// 	}
-// }
-
 // Root ...
 // /api endpoint
 func (h *HTTPServer) Root(w http.ResponseWriter, r *http.Request) {
@@ -2763,6 +5501,90 @@ func (h *HTTPServer) Healthz(w http.ResponseWriter, r *http.Request) {
 	JSONResponse(r, w, http.StatusOK, JSONresp)
 }
 
+// GetSettingsRequireAdminTwoFactor ...
+// responds whether members of the admin group must use two-factor authentication
+func (h *HTTPServer) GetSettingsRequireAdminTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var context string
+	required, err := h.settings.GetRequireAdminTwoFactor()
+	if err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to get admin two-factor authentication policy",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "fetched admin two-factor authentication policy",
+		},
+		Spec: types.AdminTwoFactorPolicySpec{
+			Required: required,
+		},
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
+// PutSettingsRequireAdminTwoFactor ...
+// sets whether members of the admin group must use two-factor authentication
+func (h *HTTPServer) PutSettingsRequireAdminTwoFactor(w http.ResponseWriter, r *http.Request) {
+	reqClaims := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+	jwtUserID := reqClaims.ID
+	var context string
+
+	var spec types.AdminTwoFactorPolicySpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		slog.Error("failed to unmarshal", "error", err)
+		JSONResponse(r, w, http.StatusBadRequest, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to read request body",
+			},
+		})
+		return
+	}
+
+	if spec.Required {
+		// avoid locking the admin out of the settings which they are changing
+		enabled, err := h.users.TOTPEnabled(jwtUserID)
+		if err != nil || !enabled {
+			if err != nil {
+				context = err.Error()
+			}
+			JSONresp := types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "two-factor authentication must be enabled for your account before requiring it for admins",
+				},
+			}
+			slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+			JSONResponse(r, w, http.StatusBadRequest, JSONresp)
+			return
+		}
+	}
+	if err := h.settings.SetRequireAdminTwoFactor(spec.Required); err != nil {
+		context = err.Error()
+		JSONresp := types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "failed to set admin two-factor authentication policy",
+			},
+		}
+		slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+		JSONResponse(r, w, http.StatusInternalServerError, JSONresp)
+		return
+	}
+	JSONresp := types.JSONMessageResponse{
+		Metadata: types.JSONResponseMetadata{
+			Response: "set admin two-factor authentication policy",
+		},
+		Spec: spec,
+	}
+	slog.Info("request log", "response", JSONresp.Metadata.Response, "context", context)
+	JSONResponse(r, w, http.StatusOK, JSONresp)
+}
+
 // HTTPvalidateJWT ...
 // middleware for checking JWT auth token validity
 func (h *HTTPServer) HTTPvalidateJWT(next http.HandlerFunc) http.HandlerFunc {
@@ -2795,34 +5617,131 @@ func (h *HTTPServer) HTTPvalidateJWT(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// HTTPcheckGroupsFromID ...
-// middleware for checking if a route can be accessed given a ID and groupID
-func (h *HTTPServer) HTTPcheckGroupsFromID(next http.HandlerFunc, groupsAllowed ...string) http.HandlerFunc {
+// HTTPcheckPermissions ...
+// only allows requests from user accounts which are in groups granting each of the permissions
+func (h *HTTPServer) HTTPcheckPermissions(next http.HandlerFunc, permissionsRequired ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqClaims, ok := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+		if !ok {
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Unable to find claims",
+				},
+			})
+			return
+		}
+		jwtUserID := reqClaims.ID
+		permissions, err := h.groups.GetPermissionsOfUserByID(jwtUserID)
+		if err != nil {
+			slog.Error("Failed to get permissions of user", "uid", jwtUserID, "error", err)
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Unable to check permissions",
+				},
+			})
+			return
+		}
+		found := 0
+		for _, permission := range permissionsRequired {
+			if common.StringInStringSlice(permission, permissions) {
+				found++
+			}
+		}
+		if found == len(permissionsRequired) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.Info("User tried to access route that is protected by permissions", "uid", jwtUserID, "permissionsRequired", permissionsRequired)
+		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Forbidden",
+			},
+		})
+	}
+}
+
+// HTTPcheckScopes ...
+// only allows requests authenticated with a personal access token when the token has each of the scopes.
+// Routes without scopes are unable to be used with personal access tokens, only by logging in
+func (h *HTTPServer) HTTPcheckScopes(next http.HandlerFunc, scopesRequired ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqClaims, ok := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
+		if !ok {
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Unable to find claims",
+				},
+			})
+			return
+		}
+		if reqClaims.AccessTokenID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		found := 0
+		for _, scope := range scopesRequired {
+			if users.AccessTokenHasScope(reqClaims.Scopes, scope) {
+				found++
+			}
+		}
+		if len(scopesRequired) > 0 && found == len(scopesRequired) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slog.Info("Personal access token tried to access route without the scope for it", "uid", reqClaims.ID, "accessTokenId", reqClaims.AccessTokenID, "scopesRequired", scopesRequired)
+		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
+			Metadata: types.JSONResponseMetadata{
+				Response: "Forbidden, the personal access token does not have the scope for this",
+			},
+		})
+	}
+}
+
+// HTTPcheckAdminTwoFactor ...
+// middleware for checking that an admin has two-factor authentication enabled, when it is required for admins
+func (h *HTTPServer) HTTPcheckAdminTwoFactor(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		reqClaims, ok := r.Context().Value(types.RequestContextKeyClaimAuth).(*types.JWTclaim)
 		if !ok {
 			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
 				Metadata: types.JSONResponseMetadata{
-					Response: "Unable to find claims",
+					Response: "Unable to find claims",
+				},
+			})
+			return
+		}
+		required, err := h.settings.GetRequireAdminTwoFactor()
+		if err != nil {
+			slog.Error("Failed to get admin two-factor authentication policy", "error", err)
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Unable to check two-factor authentication",
+				},
+			})
+			return
+		}
+		if !required {
+			next.ServeHTTP(w, r)
+			return
+		}
+		enabled, err := h.users.TOTPEnabled(reqClaims.ID)
+		if err != nil {
+			slog.Error("Failed to check two-factor authentication", "error", err)
+			JSONResponse(r, w, http.StatusInternalServerError, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "Unable to check two-factor authentication",
 				},
 			})
 			return
 		}
-		jwtUserID := reqClaims.ID
-		found := 0
-		for _, group := range groupsAllowed {
-			if userInGroup, err := h.groups.CheckUserInGroup(jwtUserID, group); userInGroup && err == nil {
-				found++
-			}
-		}
-		if found == len(groupsAllowed) {
+		if enabled {
 			next.ServeHTTP(w, r)
 			return
 		}
-		slog.Info("User tried to access route that is protected by group access", "uid", jwtUserID, "groupsAllowed", groupsAllowed)
+		slog.Info("Admin without two-factor authentication tried to access route that requires it", "uid", reqClaims.ID)
 		JSONResponse(r, w, http.StatusForbidden, types.JSONMessageResponse{
 			Metadata: types.JSONResponseMetadata{
-				Response: "Forbidden",
+				Response: "Two-factor authentication must be enabled for your account to use admin features",
 			},
 		})
 	}
@@ -2838,6 +5757,29 @@ func (h *HTTPServer) HTTPMaintenanceMode(next http.HandlerFunc) http.HandlerFunc
 	}
 }
 
+// HTTPcheckInstanceSecret ...
+// requires the instance admin secret for managing the flats of the instance,
+// which are not found unless the secret is set
+func (h *HTTPServer) HTTPcheckInstanceSecret(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expectedSecret := common.GetInstanceAdminSecret()
+		if expectedSecret == "" {
+			h.HTTP404()(w, r)
+			return
+		}
+		secret := r.Header.Get(FlatTrackInstanceSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(expectedSecret)) != 1 {
+			JSONResponse(r, w, http.StatusUnauthorized, types.JSONMessageResponse{
+				Metadata: types.JSONResponseMetadata{
+					Response: "unexpected secret",
+				},
+			})
+			return
+		}
+		next(w, r)
+	}
+}
+
 // HTTP404 ...
 // responds with 404
 func (h *HTTPServer) HTTP404() http.HandlerFunc {
@@ -2860,387 +5802,962 @@ func (h *HTTPServer) HTTPMethodNotAllowed() http.HandlerFunc {
 	}
 }
 
+// flatHandlerFunc ...
+// a handler which is called on the copy of the server for the flat of a request
+type flatHandlerFunc func(h *HTTPServer, w http.ResponseWriter, r *http.Request)
+
+// flatHandler ...
+// returns a handler which calls a handler with the copy of the server
+// for the flat of a request, along with the middleware which it builds on that copy.
+// The queries of the copy are cancelled when the client disconnects or they run for too long,
+// while the request itself keeps it's context so that event streams stay open
+func (h *HTTPServer) flatHandler(handlerFunc flatHandlerFunc, middleware func(fh *HTTPServer, next http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), common.GetDBRequestTimeout())
+		defer cancel()
+		fh := h.forFlat(flatFromRequest(r)).withContext(ctx)
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			handlerFunc(fh, w, r)
+		}
+		middleware(fh, handler)(w, r)
+	}
+}
+
 func (h *HTTPServer) registerAPIHandlers(router *mux.Router) {
 	routes := []struct {
-		EndpointPath     string
-		HandlerFunc      http.HandlerFunc
-		HTTPMethod       string
-		RequireAuth      bool
-		RequireAllGroups []string
+		EndpointPath       string
+		HandlerFunc        flatHandlerFunc
+		HTTPMethod         string
+		RequireAuth        bool
+		RequirePermissions []string
+		RequireScopes      []string
 	}{
 		{
 			EndpointPath: "",
-			HandlerFunc:  h.Root,
+			HandlerFunc:  (*HTTPServer).Root,
 			HTTPMethod:   http.MethodGet,
 		},
 		{
 			EndpointPath: "/system/initialized",
-			HandlerFunc:  h.GetSystemInitialized,
+			HandlerFunc:  (*HTTPServer).GetSystemInitialized,
 			HTTPMethod:   http.MethodGet,
 		},
 		{
 			EndpointPath: "/system/schedule",
-			HandlerFunc:  h.PostSchedulerRun,
+			HandlerFunc:  (*HTTPServer).PostSchedulerRun,
 			HTTPMethod:   http.MethodPost,
 		},
 		{
 			EndpointPath: "/system/version",
-			HandlerFunc:  h.GetVersion,
+			HandlerFunc:  (*HTTPServer).GetVersion,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
 			EndpointPath: "/system/flatName",
-			HandlerFunc:  h.GetSettingsFlatName,
+			HandlerFunc:  (*HTTPServer).GetSettingsFlatName,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath:     "/admin/settings/flatName",
-			HandlerFunc:      h.SetSettingsFlatName,
-			HTTPMethod:       http.MethodPost,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/flatName",
+			HandlerFunc:        (*HTTPServer).SetSettingsFlatName,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
+		},
+		{
+			EndpointPath:       "/admin/settings/shoppingListNotes",
+			HandlerFunc:        (*HTTPServer).PutSettingsShoppingList,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
+		},
+		{
+			EndpointPath:       "/admin/settings/flatNotes",
+			HandlerFunc:        (*HTTPServer).GetSettingsFlatNotes,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
-			EndpointPath:     "/admin/settings/shoppingListNotes",
-			HandlerFunc:      h.PutSettingsShoppingList,
-			HTTPMethod:       http.MethodPut,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/flatNotes",
+			HandlerFunc:        (*HTTPServer).PutSettingsFlatNotes,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
-			EndpointPath:     "/admin/settings/flatNotes",
-			HandlerFunc:      h.GetSettingsFlatNotes,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/requireAdminTwoFactor",
+			HandlerFunc:        (*HTTPServer).GetSettingsRequireAdminTwoFactor,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
-			EndpointPath:     "/admin/settings/flatNotes",
-			HandlerFunc:      h.PutSettingsFlatNotes,
-			HTTPMethod:       http.MethodPut,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/requireAdminTwoFactor",
+			HandlerFunc:        (*HTTPServer).PutSettingsRequireAdminTwoFactor,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
-			EndpointPath:     "/admin/settings/shoppingListKeepPolicy",
-			HandlerFunc:      h.GetSettingsShoppingListKeepPolicy,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/shoppingListKeepPolicy",
+			HandlerFunc:        (*HTTPServer).GetSettingsShoppingListKeepPolicy,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
-			EndpointPath:     "/admin/settings/shoppingListKeepPolicy",
-			HandlerFunc:      h.PutSettingsShoppingListKeepPolicy,
-			HTTPMethod:       http.MethodPut,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/settings/shoppingListKeepPolicy",
+			HandlerFunc:        (*HTTPServer).PutSettingsShoppingListKeepPolicy,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminSettings},
 		},
 		{
 			EndpointPath: "/admin/register",
-			HandlerFunc:  h.PostAdminRegister,
+			HandlerFunc:  (*HTTPServer).PostAdminRegister,
 			HTTPMethod:   http.MethodPost,
 		},
 		{
-			EndpointPath:     "/admin/users",
-			HandlerFunc:      h.GetAllUsers,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/users",
+			HandlerFunc:        (*HTTPServer).GetAllUsers,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/lockouts",
+			HandlerFunc:        (*HTTPServer).GetUserAuthLockouts,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/lockouts/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteUserAuthLockout,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/legacyPasswordHashes",
+			HandlerFunc:        (*HTTPServer).GetLegacyPasswordHashCount,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}",
+			HandlerFunc:        (*HTTPServer).GetUser,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users",
+			HandlerFunc:        (*HTTPServer).PostUser,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}",
+			HandlerFunc:        (*HTTPServer).PatchUser,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}/disabled",
+			HandlerFunc:        (*HTTPServer).PatchUserDisabled,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}/sessions",
+			HandlerFunc:        (*HTTPServer).GetUserSessions,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}/sessions",
+			HandlerFunc:        (*HTTPServer).DeleteUserSessions,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}/sessions/{sessionId}",
+			HandlerFunc:        (*HTTPServer).DeleteUserSession,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}/totp",
+			HandlerFunc:        (*HTTPServer).DeleteUserTOTP,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}",
+			HandlerFunc:        (*HTTPServer).PutUser,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
+		},
+		{
+			EndpointPath:       "/admin/users/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteUser,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
 		},
 		{
-			EndpointPath:     "/admin/users/{id}",
-			HandlerFunc:      h.GetUser,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/useraccountconfirms",
+			HandlerFunc:        (*HTTPServer).GetUserConfirms,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
 		},
 		{
-			EndpointPath:     "/admin/users",
-			HandlerFunc:      h.PostUser,
-			HTTPMethod:       http.MethodPost,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/useraccountconfirms/{id}",
+			HandlerFunc:        (*HTTPServer).GetUserConfirm,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminUsers},
 		},
 		{
-			EndpointPath:     "/admin/users/{id}",
-			HandlerFunc:      h.PatchUser,
-			HTTPMethod:       http.MethodPatch,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups",
+			HandlerFunc:        (*HTTPServer).GetAllGroups,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
-			EndpointPath:     "/admin/users/{id}/disabled",
-			HandlerFunc:      h.PatchUserDisabled,
-			HTTPMethod:       http.MethodPatch,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups/permissions",
+			HandlerFunc:        (*HTTPServer).GetAdminGroupPermissions,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
-			EndpointPath:     "/admin/users/{id}",
-			HandlerFunc:      h.PutUser,
-			HTTPMethod:       http.MethodPut,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups",
+			HandlerFunc:        (*HTTPServer).PostAdminGroup,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
-			EndpointPath:     "/admin/users/{id}",
-			HandlerFunc:      h.DeleteUser,
-			HTTPMethod:       http.MethodDelete,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups/{id}",
+			HandlerFunc:        (*HTTPServer).GetGroup,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
-			EndpointPath:     "/admin/useraccountconfirms",
-			HandlerFunc:      h.GetUserConfirms,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups/{id}",
+			HandlerFunc:        (*HTTPServer).PutAdminGroup,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
-			EndpointPath:     "/admin/useraccountconfirms/{id}",
-			HandlerFunc:      h.GetUserConfirm,
-			HTTPMethod:       http.MethodGet,
-			RequireAuth:      true,
-			RequireAllGroups: []string{"admin"},
+			EndpointPath:       "/admin/groups/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteAdminGroup,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionAdminGroups},
 		},
 		{
 			EndpointPath: "/user/auth",
-			HandlerFunc:  h.UserAuthValidate,
+			HandlerFunc:  (*HTTPServer).UserAuthValidate,
 			HTTPMethod:   http.MethodGet,
 		},
 		{
 			EndpointPath: "/user/auth",
-			HandlerFunc:  h.UserAuth,
+			HandlerFunc:  (*HTTPServer).UserAuth,
 			HTTPMethod:   http.MethodPost,
 		},
 		{
 			EndpointPath: "/user/auth",
-			HandlerFunc:  h.UserAuthLogOut,
+			HandlerFunc:  (*HTTPServer).UserAuthLogOut,
 			HTTPMethod:   http.MethodDelete,
 		},
 		{
 			EndpointPath: "/user/auth/reset",
-			HandlerFunc:  h.UserAuthReset,
+			HandlerFunc:  (*HTTPServer).UserAuthReset,
 			HTTPMethod:   http.MethodPost,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/user/confirm/{id}",
-			HandlerFunc:  h.GetUserConfirmValid,
-			HTTPMethod:   http.MethodGet,
+			EndpointPath: "/user/auth/challenge",
+			HandlerFunc:  (*HTTPServer).PostUserAuthChallenge,
+			HTTPMethod:   http.MethodPost,
 		},
 		{
-			EndpointPath: "/user/confirm/{id}",
-			HandlerFunc:  h.PostUserConfirm,
-			HTTPMethod:   http.MethodPost,
+			EndpointPath: "/user/auth/oidc",
+			HandlerFunc:  (*HTTPServer).GetUserAuthOIDC,
+			HTTPMethod:   http.MethodGet,
 		},
 		{
-			EndpointPath: "/user/profile",
-			HandlerFunc:  h.GetProfile,
+			EndpointPath: "/user/auth/totp",
+			HandlerFunc:  (*HTTPServer).GetUserAuthTOTP,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/user/profile",
-			HandlerFunc:  h.PutProfile,
-			HTTPMethod:   http.MethodPut,
+			EndpointPath: "/user/auth/totp",
+			HandlerFunc:  (*HTTPServer).PostUserAuthTOTP,
+			HTTPMethod:   http.MethodPost,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/user/profile",
-			HandlerFunc:  h.PatchProfile,
-			HTTPMethod:   http.MethodPatch,
+			EndpointPath: "/user/auth/totp/enable",
+			HandlerFunc:  (*HTTPServer).PostUserAuthTOTPEnable,
+			HTTPMethod:   http.MethodPost,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/users",
-			HandlerFunc:  h.GetAllUsers,
-			HTTPMethod:   http.MethodGet,
+			EndpointPath: "/user/auth/totp",
+			HandlerFunc:  (*HTTPServer).DeleteUserAuthTOTP,
+			HTTPMethod:   http.MethodDelete,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/users/{id}",
-			HandlerFunc:  h.GetUser,
+			EndpointPath: "/user/auth/sessions",
+			HandlerFunc:  (*HTTPServer).GetUserAuthSessions,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/groups",
-			HandlerFunc:  h.GetAllGroups,
-			HTTPMethod:   http.MethodGet,
+			EndpointPath: "/user/auth/sessions/{id}",
+			HandlerFunc:  (*HTTPServer).DeleteUserAuthSession,
+			HTTPMethod:   http.MethodDelete,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/groups/{id}",
-			HandlerFunc:  h.GetGroup,
+			EndpointPath: "/user/auth/tokens",
+			HandlerFunc:  (*HTTPServer).GetUserAuthTokens,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/user/can-i/group/{name}",
-			HandlerFunc:  h.UserCanIgroup,
-			HTTPMethod:   http.MethodGet,
+			EndpointPath: "/user/auth/tokens",
+			HandlerFunc:  (*HTTPServer).PostUserAuthToken,
+			HTTPMethod:   http.MethodPost,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/settings/notes",
-			HandlerFunc:  h.GetSettingsShoppingListNotes,
-			HTTPMethod:   http.MethodGet,
+			EndpointPath: "/user/auth/tokens/{id}",
+			HandlerFunc:  (*HTTPServer).DeleteUserAuthToken,
+			HTTPMethod:   http.MethodDelete,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists",
-			HandlerFunc:  h.GetShoppingLists,
+			EndpointPath: "/user/auth/forgot",
+			HandlerFunc:  (*HTTPServer).PostUserAuthForgot,
+			HTTPMethod:   http.MethodPost,
+		},
+		{
+			EndpointPath: "/user/auth/forgot/{id}",
+			HandlerFunc:  (*HTTPServer).GetUserAuthForgotValid,
 			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}",
-			HandlerFunc:  h.GetShoppingList,
+			EndpointPath: "/user/auth/forgot/{id}",
+			HandlerFunc:  (*HTTPServer).PostUserAuthForgotReset,
+			HTTPMethod:   http.MethodPost,
+		},
+		{
+			EndpointPath: "/user/confirm/{id}",
+			HandlerFunc:  (*HTTPServer).GetUserConfirmValid,
 			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}",
-			HandlerFunc:  h.PatchShoppingList,
-			HTTPMethod:   http.MethodPatch,
+			EndpointPath: "/user/confirm/{id}",
+			HandlerFunc:  (*HTTPServer).PostUserConfirm,
+			HTTPMethod:   http.MethodPost,
+		},
+		{
+			EndpointPath: "/user/profile",
+			HandlerFunc:  (*HTTPServer).GetProfile,
+			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}",
-			HandlerFunc:  h.PutShoppingList,
+			EndpointPath: "/user/profile",
+			HandlerFunc:  (*HTTPServer).PutProfile,
 			HTTPMethod:   http.MethodPut,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}/completed",
-			HandlerFunc:  h.PatchShoppingListCompleted,
+			EndpointPath: "/user/profile",
+			HandlerFunc:  (*HTTPServer).PatchProfile,
 			HTTPMethod:   http.MethodPatch,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}",
-			HandlerFunc:  h.DeleteShoppingList,
-			HTTPMethod:   http.MethodDelete,
-			RequireAuth:  true,
+			EndpointPath:       "/users",
+			HandlerFunc:        (*HTTPServer).GetAllUsers,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionFlatmatesRead},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists",
-			HandlerFunc:  h.PostShoppingList,
-			HTTPMethod:   http.MethodPost,
-			RequireAuth:  true,
+			EndpointPath:       "/users/{id}",
+			HandlerFunc:        (*HTTPServer).GetUser,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionFlatmatesRead},
+		},
+		{
+			EndpointPath:       "/groups",
+			HandlerFunc:        (*HTTPServer).GetAllGroups,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionFlatmatesRead},
+		},
+		{
+			EndpointPath:       "/groups/{id}",
+			HandlerFunc:        (*HTTPServer).GetGroup,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionFlatmatesRead},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}/items",
-			HandlerFunc:  h.GetShoppingListItems,
+			EndpointPath: "/user/can-i/permission/{name}",
+			HandlerFunc:  (*HTTPServer).UserCanIpermission,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/items/{itemId}",
-			HandlerFunc:  h.GetShoppingListItem,
+			EndpointPath: "/user/can-i/group/{name}",
+			HandlerFunc:  (*HTTPServer).UserCanIgroup,
 			HTTPMethod:   http.MethodGet,
 			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{id}/items",
-			HandlerFunc:  h.PostItemToShoppingList,
-			HTTPMethod:   http.MethodPost,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/settings/notes",
+			HandlerFunc:        (*HTTPServer).GetSettingsShoppingListNotes,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/items/{id}",
-			HandlerFunc:  h.PatchShoppingListItem,
-			HTTPMethod:   http.MethodPatch,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists",
+			HandlerFunc:        (*HTTPServer).GetShoppingLists,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/items/{id}",
-			HandlerFunc:  h.PutShoppingListItem,
-			HTTPMethod:   http.MethodPut,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}",
+			HandlerFunc:        (*HTTPServer).GetShoppingList,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/items/{id}/obtained",
-			HandlerFunc:  h.PatchShoppingListItemObtained,
-			HTTPMethod:   http.MethodPatch,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}",
+			HandlerFunc:        (*HTTPServer).PatchShoppingList,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/items/{itemId}",
-			HandlerFunc:  h.DeleteShoppingListItem,
-			HTTPMethod:   http.MethodDelete,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}",
+			HandlerFunc:        (*HTTPServer).PutShoppingList,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/tag",
-			HandlerFunc:  h.DeleteShoppingListTagItems,
-			HTTPMethod:   http.MethodDelete,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/completed",
+			HandlerFunc:        (*HTTPServer).PatchShoppingListCompleted,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/tags",
-			HandlerFunc:  h.GetShoppingListItemTags,
-			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteShoppingList,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/lists/{listId}/tags/{tagName}",
-			HandlerFunc:  h.UpdateShoppingListItemTag,
-			HTTPMethod:   http.MethodPut,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists",
+			HandlerFunc:        (*HTTPServer).PostShoppingList,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/tags",
-			HandlerFunc:  h.PostShoppingTag,
-			HTTPMethod:   http.MethodPost,
-			RequireAuth:  true,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/expense",
+			HandlerFunc:        (*HTTPServer).PostShoppingListExpense,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite, groups.PermissionExpensesWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/tags",
-			HandlerFunc:  h.GetAllShoppingTags,
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/events",
+			HandlerFunc:        (*HTTPServer).GetShoppingListEvents,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/items",
+			HandlerFunc:        (*HTTPServer).GetShoppingListItems,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{itemId}",
+			HandlerFunc:        (*HTTPServer).GetShoppingListItem,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/items",
+			HandlerFunc:        (*HTTPServer).PostItemToShoppingList,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{id}",
+			HandlerFunc:        (*HTTPServer).PatchShoppingListItem,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{id}",
+			HandlerFunc:        (*HTTPServer).PutShoppingListItem,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{id}/obtained",
+			HandlerFunc:        (*HTTPServer).PatchShoppingListItemObtained,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{itemId}",
+			HandlerFunc:        (*HTTPServer).DeleteShoppingListItem,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/tag",
+			HandlerFunc:        (*HTTPServer).DeleteShoppingListTagItems,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/tags",
+			HandlerFunc:        (*HTTPServer).GetShoppingListItemTags,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/tags/{tagName}",
+			HandlerFunc:        (*HTTPServer).UpdateShoppingListItemTag,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/import",
+			HandlerFunc:        (*HTTPServer).PostShoppingListsImport,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/export",
+			HandlerFunc:        (*HTTPServer).GetShoppingListExport,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{id}/import",
+			HandlerFunc:        (*HTTPServer).PostShoppingListImport,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/attachments",
+			HandlerFunc:        (*HTTPServer).GetShoppingListAttachments,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/items/{itemId}/photo",
+			HandlerFunc:        (*HTTPServer).PostShoppingListItemPhoto,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/lists/{listId}/receipts",
+			HandlerFunc:        (*HTTPServer).PostShoppingListReceipt,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/attachments/{id}",
+			HandlerFunc:        (*HTTPServer).GetShoppingAttachment,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/attachments/{id}/thumbnail",
+			HandlerFunc:        (*HTTPServer).GetShoppingAttachmentThumbnail,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/attachments/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteShoppingAttachment,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/tags",
+			HandlerFunc:        (*HTTPServer).PostShoppingTag,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/tags",
+			HandlerFunc:        (*HTTPServer).GetAllShoppingTags,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/tags/{id}",
+			HandlerFunc:        (*HTTPServer).GetShoppingTag,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListRead},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListRead},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/tags/{id}",
+			HandlerFunc:        (*HTTPServer).UpdateShoppingTag,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/shoppinglist/tags/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteShoppingTag,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionShoppingListWrite},
+			RequireScopes:      []string{users.AccessTokenScopeShoppingListWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks",
+			HandlerFunc:        (*HTTPServer).GetTasks,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks",
+			HandlerFunc:        (*HTTPServer).PostTask,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}",
+			HandlerFunc:        (*HTTPServer).GetTask,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}",
+			HandlerFunc:        (*HTTPServer).PatchTask,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}",
+			HandlerFunc:        (*HTTPServer).PutTask,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteTask,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}/occurrences",
+			HandlerFunc:        (*HTTPServer).GetTaskOccurrences,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{taskId}/occurrences/{id}",
+			HandlerFunc:        (*HTTPServer).GetTaskOccurrence,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{taskId}/occurrences/{id}/completed",
+			HandlerFunc:        (*HTTPServer).PatchTaskOccurrenceCompleted,
+			HTTPMethod:         http.MethodPatch,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksWrite},
+		},
+		{
+			EndpointPath:       "/apps/tasks/tasks/{id}/history",
+			HandlerFunc:        (*HTTPServer).GetTaskHistory,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/history",
+			HandlerFunc:        (*HTTPServer).GetTaskHistory,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/tasks/occurrences",
+			HandlerFunc:        (*HTTPServer).GetTaskOccurrences,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionTasksRead},
+		},
+		{
+			EndpointPath:       "/apps/expenses/expenses",
+			HandlerFunc:        (*HTTPServer).GetExpenses,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesRead},
+		},
+		{
+			EndpointPath:       "/apps/expenses/expenses",
+			HandlerFunc:        (*HTTPServer).PostExpense,
+			HTTPMethod:         http.MethodPost,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesWrite},
+		},
+		{
+			EndpointPath:       "/apps/expenses/expenses/{id}",
+			HandlerFunc:        (*HTTPServer).GetExpense,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesRead},
+		},
+		{
+			EndpointPath:       "/apps/expenses/expenses/{id}",
+			HandlerFunc:        (*HTTPServer).PutExpense,
+			HTTPMethod:         http.MethodPut,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesWrite},
+		},
+		{
+			EndpointPath:       "/apps/expenses/expenses/{id}",
+			HandlerFunc:        (*HTTPServer).DeleteExpense,
+			HTTPMethod:         http.MethodDelete,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesWrite},
+		},
+		{
+			EndpointPath:       "/apps/expenses/balances",
+			HandlerFunc:        (*HTTPServer).GetExpenseBalances,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesRead},
+		},
+		{
+			EndpointPath:       "/apps/expenses/settleup",
+			HandlerFunc:        (*HTTPServer).GetExpenseSettleUp,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionExpensesRead},
+		},
+		{
+			EndpointPath:       "/flat/info",
+			HandlerFunc:        (*HTTPServer).GetSettingsFlatNotes,
+			HTTPMethod:         http.MethodGet,
+			RequireAuth:        true,
+			RequirePermissions: []string{groups.PermissionFlatmatesRead},
+		},
+	}
+	for _, r := range routes {
+		requiresAdmin := slices.ContainsFunc(r.RequirePermissions, groups.IsAdminPermission)
+		scopes := r.RequireScopes
+		// admin routes always need the admin scope, as well as any of their own
+		if requiresAdmin {
+			scopes = append([]string{users.AccessTokenScopeAdmin}, scopes...)
+		}
+		middleware := func(fh *HTTPServer, handler http.HandlerFunc) http.HandlerFunc {
+			if fh.maintenanceMode {
+				handler = fh.HTTPMaintenanceMode(handler)
+			}
+			if requiresAdmin {
+				handler = fh.HTTPcheckAdminTwoFactor(handler)
+			}
+			if len(r.RequirePermissions) > 0 {
+				handler = fh.HTTPcheckPermissions(handler, r.RequirePermissions...)
+			}
+			if r.RequireAuth {
+				handler = fh.HTTPcheckScopes(handler, scopes...)
+				handler = fh.HTTPvalidateJWT(handler)
+			}
+			// NOTE handlers go in reverse order of dependency
+			return handler
+		}
+		router.HandleFunc(r.EndpointPath, h.flatHandler(r.HandlerFunc, middleware)).Methods(r.HTTPMethod)
+	}
+	h.registerInstanceHandlers(router)
+}
+
+// registerBrowserHandlers ...
+// registers API endpoints which browsers navigate to, rather than requesting JSON from
+func (h *HTTPServer) registerBrowserHandlers(router *mux.Router) {
+	routes := []struct {
+		EndpointPath string
+		HandlerFunc  flatHandlerFunc
+		HTTPMethod   string
+	}{
+		{
+			EndpointPath: "/user/auth/oidc/login",
+			HandlerFunc:  (*HTTPServer).GetUserAuthOIDCLogin,
 			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/tags/{id}",
-			HandlerFunc:  h.GetShoppingTag,
+			EndpointPath: "/user/auth/oidc/callback",
+			HandlerFunc:  (*HTTPServer).GetUserAuthOIDCCallback,
 			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
+		},
+	}
+	middleware := func(fh *HTTPServer, handler http.HandlerFunc) http.HandlerFunc {
+		if fh.maintenanceMode {
+			handler = fh.HTTPMaintenanceMode(handler)
+		}
+		return handler
+	}
+	for _, r := range routes {
+		router.HandleFunc(r.EndpointPath, h.flatHandler(r.HandlerFunc, middleware)).Methods(r.HTTPMethod)
+	}
+}
+
+// registerInstanceHandlers ...
+// registers API endpoints which manage the flats of the instance, rather than a flat
+func (h *HTTPServer) registerInstanceHandlers(router *mux.Router) {
+	routes := []struct {
+		EndpointPath string
+		HandlerFunc  http.HandlerFunc
+		HTTPMethod   string
+	}{
+		{
+			EndpointPath: "/instance/flats",
+			HandlerFunc:  h.GetInstanceFlats,
+			HTTPMethod:   http.MethodGet,
+		},
+		{
+			EndpointPath: "/instance/flats",
+			HandlerFunc:  h.PostInstanceFlat,
+			HTTPMethod:   http.MethodPost,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/tags/{id}",
-			HandlerFunc:  h.UpdateShoppingTag,
+			EndpointPath: "/instance/flats/{id}",
+			HandlerFunc:  h.PutInstanceFlat,
 			HTTPMethod:   http.MethodPut,
-			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/apps/shoppinglist/tags/{id}",
-			HandlerFunc:  h.DeleteShoppingTag,
+			EndpointPath: "/instance/flats/{id}",
+			HandlerFunc:  h.DeleteInstanceFlat,
 			HTTPMethod:   http.MethodDelete,
-			RequireAuth:  true,
 		},
 		{
-			EndpointPath: "/flat/info",
-			HandlerFunc:  h.GetSettingsFlatNotes,
+			EndpointPath: "/instance/backup",
+			HandlerFunc:  h.GetInstanceBackup,
 			HTTPMethod:   http.MethodGet,
-			RequireAuth:  true,
+		},
+		{
+			EndpointPath: "/instance/restore",
+			HandlerFunc:  h.PostInstanceRestore,
+			HTTPMethod:   http.MethodPost,
 		},
 	}
 	for _, r := range routes {
-		handler := r.HandlerFunc
+		handler := h.HTTPcheckInstanceSecret(r.HandlerFunc)
 		if h.maintenanceMode {
 			handler = h.HTTPMaintenanceMode(handler)
 		}
-		for _, g := range r.RequireAllGroups {
-			handler = h.HTTPcheckGroupsFromID(handler, g)
-		}
-		if r.RequireAuth {
-			handler = h.HTTPvalidateJWT(handler)
-		}
-		// NOTE handlers go in reverse order of dependency
 		router.HandleFunc(r.EndpointPath, handler).Methods(r.HTTPMethod)
 	}
 }
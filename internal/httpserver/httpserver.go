@@ -11,21 +11,26 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/NYTimes/gziphandler"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"gitlab.com/flattrack/flattrack/internal/backups"
 	"gitlab.com/flattrack/flattrack/internal/common"
 	"gitlab.com/flattrack/flattrack/internal/emails"
+	"gitlab.com/flattrack/flattrack/internal/expenses"
+	"gitlab.com/flattrack/flattrack/internal/flats"
 	"gitlab.com/flattrack/flattrack/internal/groups"
 	"gitlab.com/flattrack/flattrack/internal/health"
 	"gitlab.com/flattrack/flattrack/internal/migrations"
+	"gitlab.com/flattrack/flattrack/internal/oidc"
 	"gitlab.com/flattrack/flattrack/internal/registration"
 	"gitlab.com/flattrack/flattrack/internal/scheduling"
 	"gitlab.com/flattrack/flattrack/internal/settings"
 	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
 	"gitlab.com/flattrack/flattrack/internal/system"
+	"gitlab.com/flattrack/flattrack/internal/tasks"
 	"gitlab.com/flattrack/flattrack/internal/users"
+	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 type HTTPServer struct {
@@ -42,8 +47,17 @@ type HTTPServer struct {
 	settings        *settings.Manager
 	system          *system.Manager
 	scheduling      *scheduling.Manager
+	tasks           *tasks.Manager
+	expenses        *expenses.Manager
+	oidc            *oidc.Manager
+	flats           *flats.Manager
+	backups         *backups.Manager
 	maintenanceMode bool
 	instanceURL     *url.URL
+
+	// flat is the flat which the managers of a copy
+	// of the server from forFlat are for
+	flat types.FlatSpec
 }
 
 func NewHTTPServer(
@@ -58,6 +72,11 @@ func NewHTTPServer(
 	settings *settings.Manager,
 	system *system.Manager,
 	scheduling *scheduling.Manager,
+	tasks *tasks.Manager,
+	expenses *expenses.Manager,
+	oidc *oidc.Manager,
+	flats *flats.Manager,
+	backups *backups.Manager,
 	maintenanceMode bool,
 ) (h *HTTPServer) {
 	var err error
@@ -73,6 +92,11 @@ func NewHTTPServer(
 	h.settings = settings
 	h.system = system
 	h.scheduling = scheduling
+	h.tasks = tasks
+	h.expenses = expenses
+	h.oidc = oidc
+	h.flats = flats
+	h.backups = backups
 	h.maintenanceMode = maintenanceMode
 	h.instanceURL, err = common.GetInstanceURL()
 	if err != nil {
@@ -82,13 +106,18 @@ func NewHTTPServer(
 	router := mux.NewRouter().StrictSlash(true)
 	router.HandleFunc("/_healthz", h.Healthz).
 		Headers("Accept", "application/json")
+	// event streams are requested by browsers with an Accept of text/event-stream
 	apiRouter := router.
 		PathPrefix("/api").
-		Headers("Accept", "application/json").
+		HeadersRegexp("Accept", "^(application/json|text/event-stream)$").
 		Subrouter()
 	apiRouter.NotFoundHandler = h.HTTP404()
 	apiRouter.MethodNotAllowedHandler = h.HTTPMethodNotAllowed()
 	h.registerAPIHandlers(apiRouter)
+	browserRouter := router.
+		PathPrefix("/api").
+		Subrouter()
+	h.registerBrowserHandlers(browserRouter)
 
 	passthrough := &frontendOptions{
 		LoginMessage:           common.GetAppLoginMessage(),
@@ -105,12 +134,12 @@ func NewHTTPServer(
 	router.Use(logging)
 	router.Use(h.RewriteToDomain)
 	router.Use(c.Handler)
-	router.Use(gziphandler.GzipHandler)
+	router.Use(compress)
 	router.NotFoundHandler = h.HTTP404()
 	router.MethodNotAllowedHandler = h.HTTPMethodNotAllowed()
 
 	h.server = &http.Server{
-		Handler:      router,
+		Handler:      h.selectFlat(router),
 		Addr:         common.GetAppPort(),
 		WriteTimeout: 15 * time.Second,
 		ReadTimeout:  15 * time.Second,
@@ -118,6 +147,39 @@ func NewHTTPServer(
 	return h
 }
 
+// forFlat ...
+// returns a copy of the server whose managers are for a flat
+func (h *HTTPServer) forFlat(flat types.FlatSpec) *HTTPServer {
+	fh := *h
+	fh.flat = flat
+	fh.users = h.users.ForFlat(flat.ID)
+	fh.shoppinglist = h.shoppinglist.ForFlat(flat.ID)
+	fh.groups = h.groups.ForFlat(flat.ID)
+	fh.registration = h.registration.ForFlat(flat.ID)
+	fh.settings = h.settings.ForFlat(flat.ID)
+	fh.tasks = h.tasks.ForFlat(flat.ID)
+	fh.expenses = h.expenses.ForFlat(flat.ID)
+	fh.oidc = h.oidc.ForFlat(flat)
+	return &fh
+}
+
+// withContext ...
+// returns a copy of the server whose managers run their queries with a context
+func (h *HTTPServer) withContext(ctx context.Context) *HTTPServer {
+	ch := *h
+	ch.users = h.users.WithContext(ctx)
+	ch.shoppinglist = h.shoppinglist.WithContext(ctx)
+	ch.groups = h.groups.WithContext(ctx)
+	ch.settings = h.settings.WithContext(ctx)
+	ch.system = h.system.WithContext(ctx)
+	ch.registration = h.registration.WithContext(ctx)
+	ch.tasks = h.tasks.WithContext(ctx)
+	ch.expenses = h.expenses.WithContext(ctx)
+	ch.oidc = h.oidc.WithContext(ctx)
+	ch.flats = h.flats.WithContext(ctx)
+	return &ch
+}
+
 func (h *HTTPServer) Listen() {
 	slog.Info("HTTP listening on " + h.server.Addr)
 	done := make(chan os.Signal, 1)
@@ -1,13 +1,20 @@
 package httpserver
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"maps"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/NYTimes/gziphandler"
+
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/flats"
+	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 type statusRecorder struct {
@@ -20,6 +27,18 @@ func (r *statusRecorder) WriteHeader(status int) {
 	r.ResponseWriter.WriteHeader(status)
 }
 
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap ...
+// allows http.ResponseController to reach the underlying ResponseWriter
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // scrubHeaders to remove sensitive data logged
 func scrubHeaders(in http.Header) (o http.Header) {
 	headers := []string{"Authorization", "Cookie"}
@@ -57,6 +76,19 @@ func logging(next http.Handler) http.Handler {
 	})
 }
 
+// compress ...
+// gzip responses, except for event streams which must reach the client as they are written
+func compress(next http.Handler) http.Handler {
+	gzipped := gziphandler.GzipHandler(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "text/event-stream" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gzipped.ServeHTTP(w, r)
+	})
+}
+
 // HTTPHeaderBackendAllowTypes headers to check for content type
 type HTTPHeaderBackendAllowTypes string
 
@@ -73,6 +105,7 @@ func (h *HTTPServer) RewriteToDomain(next http.Handler) http.Handler {
 		if h.instanceURL != nil &&
 			r.Host != h.instanceURL.Host &&
 			!slices.Contains(noRedirectDomains, r.Host) &&
+			!strings.EqualFold(flatFromRequest(r).Hostname, r.Host) &&
 			r.URL.Path != "/_healthz" {
 			sourceHost := r.Host
 			r.URL.Host = h.instanceURL.Host
@@ -84,3 +117,46 @@ func (h *HTTPServer) RewriteToDomain(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// selectFlat ...
+// selects the flat which a request is for, being the flat of the hostname it's sent to or
+// the flat named at the start of it's path, otherwise the default flat.
+// The flat is removed from the start of the path, so the request is routed as usual
+func (h *HTTPServer) selectFlat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.maintenanceMode || r.URL.Path == "/_healthz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		flat, err := h.flats.GetByHostname(r.Host)
+		if err != nil && !errors.Is(err, flats.ErrFlatNotFound) {
+			slog.Error("Unable to get flat by hostname", "host", r.Host, "error", err)
+			http.Error(w, "Unable to find flat", http.StatusInternalServerError)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, flats.PathPrefix) {
+			name, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, flats.PathPrefix), "/")
+			flat, err = h.flats.GetByName(name)
+			if errors.Is(err, flats.ErrFlatNotFound) {
+				h.HTTP404()(w, r)
+				return
+			}
+			if err != nil {
+				slog.Error("Unable to get flat by name", "name", name, "error", err)
+				http.Error(w, "Unable to find flat", http.StatusInternalServerError)
+				return
+			}
+			r.URL.Path = "/" + path
+			r.URL.RawPath = ""
+		}
+		if flat.ID == "" {
+			flat, err = h.flats.GetDefault()
+			if err != nil {
+				slog.Error("Unable to get default flat", "error", err)
+				http.Error(w, "Unable to find flat", http.StatusInternalServerError)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), types.RequestContextKeyFlat, flat)))
+	})
+}
@@ -20,16 +20,34 @@ package migrations
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"slices"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
 
 	// allow file-based migrations
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+// sqliteMigrationsDirectory is the directory of the migrations path with the migrations for SQLite,
+// which are kept at the same versions as the migrations for Postgres
+const sqliteMigrationsDirectory = "sqlite"
+
+var (
+	ErrMigrationDirty             = fmt.Errorf("Unable to migrate database, as a migration failed part way through and left the schema dirty")
+	ErrMigrationVersionNotFound   = fmt.Errorf("Unable to migrate database, as there is no migration with that version")
+	ErrMigrationNothingToRollBack = fmt.Errorf("Unable to roll back database, as no migrations have been applied")
+	ErrMigrationNotDirty          = fmt.Errorf("Unable to force database version, as the schema is not dirty")
 )
 
 type Manager struct {
@@ -42,15 +60,47 @@ func NewManager(db *sql.DB) *Manager {
 	}
 }
 
-// Migrate ...
-// creates all the tables via the migration sql files
-func (m *Manager) Migrate() (err error) {
+// sourceURL ...
+// returns the location of the migration sql files for the database
+func (m *Manager) sourceURL() string {
 	migrationPath := common.GetMigrationsPath()
+	if database.DialectOf(m.db) == database.DialectSQLite {
+		return fmt.Sprintf("file://%v/%v", migrationPath, sqliteMigrationsDirectory)
+	}
+	return fmt.Sprintf("file://%v", migrationPath)
+}
+
+// instance ...
+// returns a migrate instance for the database and the migration sql files
+func (m *Manager) instance() (*migrate.Migrate, error) {
+	if database.DialectOf(m.db) == database.DialectSQLite {
+		driver, err := sqlite.WithInstance(m.db, &sqlite.Config{})
+		if err != nil {
+			return nil, err
+		}
+		return migrate.NewWithDatabaseInstance(m.sourceURL(), "sqlite", driver)
+	}
 	driver, err := postgres.WithInstance(m.db, &postgres.Config{})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	mi, err := migrate.NewWithDatabaseInstance(fmt.Sprintf("file:///%v", migrationPath), "postgres", driver)
+	return migrate.NewWithDatabaseInstance(m.sourceURL(), "postgres", driver)
+}
+
+// migrationError ...
+// returns the error of running migrations, where a dirty schema is reported along with it's version
+func migrationError(err error) error {
+	var dirty migrate.ErrDirty
+	if errors.As(err, &dirty) {
+		return fmt.Errorf("%w (version %v)", ErrMigrationDirty, dirty.Version)
+	}
+	return err
+}
+
+// Migrate ...
+// creates all the tables via the migration sql files
+func (m *Manager) Migrate() (err error) {
+	mi, err := m.instance()
 	if err != nil {
 		return err
 	}
@@ -60,7 +110,7 @@ func (m *Manager) Migrate() (err error) {
 		slog.Info("database is up to date")
 		err = nil
 	} else if err != nil && err.Error() != "no change" {
-		return err
+		return migrationError(err)
 	} else if err == nil {
 		slog.Info("database migrated successfully")
 	}
@@ -70,12 +120,7 @@ func (m *Manager) Migrate() (err error) {
 // Reset ...
 // removes all tables
 func (m *Manager) Reset() (err error) {
-	migrationPath := common.GetMigrationsPath()
-	driver, err := postgres.WithInstance(m.db, &postgres.Config{})
-	if err != nil {
-		return err
-	}
-	mi, err := migrate.NewWithDatabaseInstance(fmt.Sprintf("file://%v", migrationPath), "postgres", driver)
+	mi, err := m.instance()
 	if err != nil {
 		return err
 	}
@@ -88,3 +133,146 @@ func (m *Manager) Reset() (err error) {
 	}
 	return err
 }
+
+// Version ...
+// returns the version of the latest migration applied to the database,
+// and whether it failed part way through
+func (m *Manager) Version() (version uint, dirty bool, err error) {
+	mi, err := m.instance()
+	if err != nil {
+		return 0, false, err
+	}
+	version, dirty, err = mi.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// List ...
+// returns every migration of the migration sql files in order, and whether each is applied to the database
+func (m *Manager) List() (migrations []types.MigrationSpec, err error) {
+	version, _, err := m.Version()
+	if err != nil {
+		return []types.MigrationSpec{}, err
+	}
+	sourceDriver, err := source.Open(m.sourceURL())
+	if err != nil {
+		return []types.MigrationSpec{}, err
+	}
+	defer func() {
+		if err := sourceDriver.Close(); err != nil {
+			slog.Error("failed to close migrations", "error", err)
+		}
+	}()
+	migrations = []types.MigrationSpec{}
+	next, err := sourceDriver.First()
+	for ; err == nil; next, err = sourceDriver.Next(next) {
+		up, name, readErr := sourceDriver.ReadUp(next)
+		if readErr != nil {
+			return []types.MigrationSpec{}, readErr
+		}
+		if err := up.Close(); err != nil {
+			return []types.MigrationSpec{}, err
+		}
+		migrations = append(migrations, types.MigrationSpec{
+			Version: next,
+			Name:    name,
+			Applied: next <= version,
+		})
+	}
+	// the end of the migrations is reported as them not existing
+	if !errors.Is(err, os.ErrNotExist) {
+		return []types.MigrationSpec{}, err
+	}
+	return migrations, nil
+}
+
+// Pending ...
+// returns the migrations which are yet to be applied to the database, in the order they will be
+func (m *Manager) Pending() (pending []types.MigrationSpec, err error) {
+	migrations, err := m.List()
+	if err != nil {
+		return []types.MigrationSpec{}, err
+	}
+	pending = []types.MigrationSpec{}
+	for _, migration := range migrations {
+		if !migration.Applied {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// checkVersion ...
+// returns an error unless there is a migration with a version
+func (m *Manager) checkVersion(version uint) error {
+	migrations, err := m.List()
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(migrations, func(migration types.MigrationSpec) bool {
+		return migration.Version == version
+	}) {
+		return fmt.Errorf("%w: %v", ErrMigrationVersionNotFound, version)
+	}
+	return nil
+}
+
+// MigrateTo ...
+// applies or rolls back migrations until the database is at a version
+func (m *Manager) MigrateTo(version uint) (err error) {
+	if err := m.checkVersion(version); err != nil {
+		return err
+	}
+	mi, err := m.instance()
+	if err != nil {
+		return err
+	}
+	slog.Info("migrating database", "version", version)
+	if err := mi.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return migrationError(err)
+	}
+	return nil
+}
+
+// Rollback ...
+// rolls back the latest migration applied to the database
+func (m *Manager) Rollback() (err error) {
+	version, _, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return ErrMigrationNothingToRollBack
+	}
+	mi, err := m.instance()
+	if err != nil {
+		return err
+	}
+	slog.Info("rolling back database", "version", version)
+	return migrationError(mi.Steps(-1))
+}
+
+// Force ...
+// marks a dirty database as being at a version and no longer dirty,
+// once it has been repaired by hand after a migration failed part way through
+func (m *Manager) Force(version uint) (err error) {
+	_, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if !dirty {
+		return ErrMigrationNotDirty
+	}
+	if err := m.checkVersion(version); err != nil {
+		return err
+	}
+	mi, err := m.instance()
+	if err != nil {
+		return err
+	}
+	slog.Info("forcing database version", "version", version)
+	// versions are timestamps, which fit in an int
+	return mi.Force(int(version)) //nolint:gosec
+}
@@ -0,0 +1,525 @@
+/*
+  oidc
+    log in with an OpenID Connect identity provider
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/flats"
+	"gitlab.com/flattrack/flattrack/internal/groups"
+	"gitlab.com/flattrack/flattrack/internal/users"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// CallbackPath is where the identity provider returns users to after logging in
+	CallbackPath = "/api/user/auth/oidc/callback"
+	// providerMetadataExpiry is how long discovered identity provider metadata is reused for
+	providerMetadataExpiry = time.Hour
+	// providerResponseLimit is the most which is read from a response of the identity provider
+	providerResponseLimit = 1 << 20
+)
+
+var (
+	ErrOIDCNotEnabled        = fmt.Errorf("Single sign-on is not enabled")
+	ErrOIDCAuthStateNotFound = fmt.Errorf("Single sign-on login has expired or was already used")
+	ErrOIDCAuthStateMismatch = fmt.Errorf("Single sign-on login was started in a different browser, please log in again")
+	ErrOIDCInvalidIDToken    = fmt.Errorf("Identity provider returned an invalid ID token")
+	ErrOIDCEmailNotVerified  = fmt.Errorf("Identity provider has not verified the email address")
+	ErrOIDCAccountNotFound   = fmt.Errorf("No user account exists for the email address")
+)
+
+// providerMetadata ...
+// the fields of an identity provider's discovery document which are used
+type providerMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// tokenResponse ...
+// the response of an identity provider's token endpoint
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// idTokenClaims ...
+// the claims of a verified ID token which are used
+type idTokenClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+type Manager struct {
+	db     *sql.DB
+	ctx    context.Context
+	users  *users.Manager
+	groups *groups.Manager
+	client *http.Client
+
+	issuer        string
+	clientID      string
+	clientSecret  string
+	instanceURL   *url.URL
+	redirectURL   string
+	autoProvision bool
+	groupsClaim   string
+	adminGroup    string
+
+	// provider is shared between the managers of every flat,
+	// as they all log in with the same identity provider
+	provider *providerCache
+}
+
+// providerCache ...
+// the discovered metadata and signing keys of the identity provider
+type providerCache struct {
+	lock     sync.Mutex
+	metadata providerMetadata
+	expiry   time.Time
+	keys     map[string]any
+}
+
+func NewManager(db *sql.DB, users *users.Manager, groups *groups.Manager) *Manager {
+	m := &Manager{
+		db:            db,
+		ctx:           context.Background(),
+		users:         users,
+		groups:        groups,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		issuer:        common.GetOIDCIssuer(),
+		clientID:      common.GetOIDCClientID(),
+		clientSecret:  common.GetOIDCClientSecret(),
+		autoProvision: common.GetOIDCAutoProvision(),
+		groupsClaim:   common.GetOIDCGroupsClaim(),
+		adminGroup:    common.GetOIDCAdminGroup(),
+		provider:      &providerCache{},
+	}
+	instanceURL, err := common.GetInstanceURL()
+	if err != nil {
+		slog.Error("Failed to get instance URL", "error", err)
+	}
+	if instanceURL != nil {
+		m.instanceURL = instanceURL
+		m.redirectURL = strings.TrimSuffix(instanceURL.String(), "/") + CallbackPath
+	}
+	if m.issuer != "" && m.redirectURL == "" {
+		slog.Warn("Single sign-on is configured but unavailable without APP_URL being set")
+	}
+	return m
+}
+
+// ForFlat ...
+// returns a manager which logs in to the user accounts of a flat,
+// with the identity provider returning users to the flat's hostname or path
+func (m *Manager) ForFlat(flat types.FlatSpec) *Manager {
+	flatManager := *m
+	flatManager.users = m.users.ForFlat(flat.ID)
+	flatManager.groups = m.groups.ForFlat(flat.ID)
+	if m.instanceURL != nil {
+		flatURL := *m.instanceURL
+		if flat.Hostname != "" {
+			flatURL.Host = flat.Hostname
+		}
+		flatManager.redirectURL = strings.TrimSuffix(flatURL.String(), "/") + flats.BasePath(flat) + CallbackPath
+	}
+	return &flatManager
+}
+
+// WithContext ...
+// returns a manager whose queries and requests to the identity provider are run with a context,
+// so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	contextManager := *m
+	contextManager.ctx = ctx
+	contextManager.users = m.users.WithContext(ctx)
+	contextManager.groups = m.groups.WithContext(ctx)
+	return &contextManager
+}
+
+// Enabled ...
+// returns if users are able to log in with the identity provider
+func (m *Manager) Enabled() bool {
+	return m.issuer != "" && m.clientID != "" && m.redirectURL != ""
+}
+
+// BrowserState ...
+// returns the value which binds a login to the browser which started it, being a hash of it's state
+func BrowserState(state string) string {
+	return common.HashSHA512(state)
+}
+
+// AuthCodeURL ...
+// starts a login, returning the identity provider URL to send the user to
+// and the value to keep in the browser, which must be given back to complete the login.
+// Once logged in, the user is sent back to the relative redirect
+func (m *Manager) AuthCodeURL(redirect string) (authURL string, browserState string, err error) {
+	if !m.Enabled() {
+		return "", "", ErrOIDCNotEnabled
+	}
+	provider, err := m.discover()
+	if err != nil {
+		return "", "", err
+	}
+	authState, err := m.AuthStates().Create(redirect)
+	if err != nil {
+		return "", "", err
+	}
+	challenge := sha256.Sum256([]byte(authState.CodeVerifier))
+	scopes := []string{"openid", "email", "profile"}
+	if m.adminGroup != "" {
+		scopes = append(scopes, m.groupsClaim)
+	}
+	u, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", err
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", m.clientID)
+	query.Set("redirect_uri", m.redirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", authState.State)
+	query.Set("nonce", authState.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), BrowserState(authState.State), nil
+}
+
+// Login ...
+// completes a login from the identity provider's callback, returning
+// the user account of the verified email and the relative redirect.
+// The login must be completed by the browser which started it, so that
+// nobody is able to log someone else in to their own account by sending them a callback
+func (m *Manager) Login(state string, code string, browserState string) (user types.UserSpec, redirect string, err error) {
+	if !m.Enabled() {
+		return types.UserSpec{}, "", ErrOIDCNotEnabled
+	}
+	if subtle.ConstantTimeCompare([]byte(BrowserState(state)), []byte(browserState)) != 1 {
+		return types.UserSpec{}, "", ErrOIDCAuthStateMismatch
+	}
+	authState, err := m.AuthStates().Redeem(state)
+	if err != nil {
+		return types.UserSpec{}, "", err
+	}
+	provider, err := m.discover()
+	if err != nil {
+		return types.UserSpec{}, "", err
+	}
+	idToken, err := m.exchange(provider, code, authState.CodeVerifier)
+	if err != nil {
+		return types.UserSpec{}, "", err
+	}
+	claims, err := m.verify(idToken, authState.Nonce)
+	if err != nil {
+		return types.UserSpec{}, "", err
+	}
+	user, err = m.userFromClaims(claims)
+	if err != nil {
+		return types.UserSpec{}, "", err
+	}
+	return user, authState.Redirect, nil
+}
+
+// userFromClaims ...
+// returns the user account of the verified email of an ID token,
+// provisioning it and updating it's admin group membership as configured
+func (m *Manager) userFromClaims(claims idTokenClaims) (user types.UserSpec, err error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return types.UserSpec{}, ErrOIDCEmailNotVerified
+	}
+	user, err = m.users.GetByEmail(claims.Email, false)
+	if errors.Is(err, users.ErrFailedToFindAccount) {
+		if !m.autoProvision {
+			return types.UserSpec{}, ErrOIDCAccountNotFound
+		}
+		return m.provision(claims)
+	}
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	if m.adminGroup != "" {
+		return m.syncAdminGroup(user, common.StringInStringSlice(m.adminGroup, claims.Groups))
+	}
+	return user, nil
+}
+
+// provision ...
+// creates a user account in the default groups for an ID token
+func (m *Manager) provision(claims idTokenClaims) (user types.UserSpec, err error) {
+	defaultGroups, err := m.groups.GetDefault()
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	groupNames := []string{}
+	for _, group := range defaultGroups {
+		groupNames = append(groupNames, group.Name)
+	}
+	if m.adminGroup != "" &&
+		common.StringInStringSlice(m.adminGroup, claims.Groups) &&
+		!common.StringInStringSlice(groups.GroupAdmin, groupNames) {
+		groupNames = append(groupNames, groups.GroupAdmin)
+	}
+	names := []rune(strings.TrimSpace(claims.Name))
+	if len(names) == 0 {
+		names = []rune(strings.Split(claims.Email, "@")[0])
+	}
+	if len(names) > 60 {
+		names = names[:60]
+	}
+	user, err = m.users.Provision(types.UserSpec{
+		Names:  string(names),
+		Email:  claims.Email,
+		Groups: groupNames,
+	})
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	slog.Info("Provisioned user account from identity provider", "id", user.ID, "subject", claims.Subject)
+	return user, nil
+}
+
+// syncAdminGroup ...
+// adds or removes a user account from the admin group
+func (m *Manager) syncAdminGroup(user types.UserSpec, admin bool) (types.UserSpec, error) {
+	if common.StringInStringSlice(groups.GroupAdmin, user.Groups) == admin {
+		return user, nil
+	}
+	adminGroup, err := m.groups.GetByName(groups.GroupAdmin)
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	if admin {
+		err = m.groups.AddUserToGroup(user.ID, adminGroup.ID)
+	} else {
+		err = m.groups.RemoveUserFromGroup(user.ID, adminGroup.ID)
+	}
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	user.Groups, err = m.groups.GetGroupNamesOfUserByID(user.ID)
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	slog.Info("Updated admin group membership from identity provider", "id", user.ID, "admin", admin)
+	return user, nil
+}
+
+// discover ...
+// returns the identity provider's metadata and signing keys, fetching them once they're stale
+func (m *Manager) discover() (provider providerMetadata, err error) {
+	m.provider.lock.Lock()
+	defer m.provider.lock.Unlock()
+	if time.Now().Before(m.provider.expiry) {
+		return m.provider.metadata, nil
+	}
+	if err := m.getJSON(strings.TrimSuffix(m.issuer, "/")+"/.well-known/openid-configuration", &provider); err != nil {
+		return providerMetadata{}, err
+	}
+	if provider.Issuer != m.issuer {
+		return providerMetadata{}, fmt.Errorf("identity provider issuer '%v' does not match '%v'", provider.Issuer, m.issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return providerMetadata{}, fmt.Errorf("identity provider discovery document is missing endpoints")
+	}
+	keys, err := m.fetchKeys(provider.JWKSURI)
+	if err != nil {
+		return providerMetadata{}, err
+	}
+	m.provider.metadata = provider
+	m.provider.keys = keys
+	m.provider.expiry = time.Now().Add(providerMetadataExpiry)
+	return provider, nil
+}
+
+// keyFunc ...
+// returns the identity provider's key which signed a token, refetching
+// the keys when it's unknown in case they have been rotated
+func (m *Manager) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	m.provider.lock.Lock()
+	defer m.provider.lock.Unlock()
+	if key, ok := m.provider.keys[kid]; ok {
+		return key, nil
+	}
+	keys, err := m.fetchKeys(m.provider.metadata.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	m.provider.keys = keys
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key '%v'", kid)
+}
+
+// fetchKeys ...
+// returns the signing keys of the identity provider by their id
+func (m *Manager) fetchKeys(jwksURI string) (keys map[string]any, err error) {
+	var keySet jsonWebKeySet
+	if err := m.getJSON(jwksURI, &keySet); err != nil {
+		return nil, err
+	}
+	return keySet.publicKeys()
+}
+
+// exchange ...
+// swaps an authorization code for an ID token, proving the login was started here with the code verifier
+func (m *Manager) exchange(provider providerMetadata, code string, codeVerifier string) (idToken string, err error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", m.redirectURL)
+	form.Set("code_verifier", codeVerifier)
+	useBasicAuth := m.clientSecret != "" &&
+		(len(provider.TokenEndpointAuthMethodsSupported) == 0 ||
+			common.StringInStringSlice("client_secret_basic", provider.TokenEndpointAuthMethodsSupported))
+	if !useBasicAuth {
+		form.Set("client_id", m.clientID)
+		if m.clientSecret != "" {
+			form.Set("client_secret", m.clientSecret)
+		}
+	}
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasicAuth {
+		req.SetBasicAuth(url.QueryEscape(m.clientID), url.QueryEscape(m.clientSecret))
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("failed to close response body", "error", err)
+		}
+	}()
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, providerResponseLimit)).Decode(&token); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("identity provider refused the authorization code (%v): %v %v", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", ErrOIDCInvalidIDToken
+	}
+	return token.IDToken, nil
+}
+
+// verify ...
+// checks that an ID token was signed by the identity provider for this login and returns it's claims
+func (m *Manager) verify(idToken string, nonce string) (claims idTokenClaims, err error) {
+	mapClaims := jwt.MapClaims{}
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithAudience(m.clientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if _, err := parser.ParseWithClaims(idToken, mapClaims, m.keyFunc); err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: %v", ErrOIDCInvalidIDToken, err)
+	}
+	tokenNonce, _ := mapClaims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return idTokenClaims{}, fmt.Errorf("%w: nonce does not match", ErrOIDCInvalidIDToken)
+	}
+	audience, err := mapClaims.GetAudience()
+	if err != nil {
+		return idTokenClaims{}, fmt.Errorf("%w: %v", ErrOIDCInvalidIDToken, err)
+	}
+	if authorizedParty, _ := mapClaims["azp"].(string); len(audience) > 1 && authorizedParty != m.clientID {
+		return idTokenClaims{}, fmt.Errorf("%w: authorized party does not match", ErrOIDCInvalidIDToken)
+	}
+	claims.Subject, _ = mapClaims.GetSubject()
+	claims.Email, _ = mapClaims["email"].(string)
+	claims.Name, _ = mapClaims["name"].(string)
+	// some identity providers send booleans as strings
+	switch emailVerified := mapClaims["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = emailVerified
+	case string:
+		claims.EmailVerified = emailVerified == "true"
+	}
+	switch groupsClaim := mapClaims[m.groupsClaim].(type) {
+	case []any:
+		for _, group := range groupsClaim {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	case string:
+		claims.Groups = []string{groupsClaim}
+	}
+	return claims, nil
+}
+
+// getJSON ...
+// fetches and decodes a JSON document from the identity provider
+func (m *Manager) getJSON(endpoint string, output any) error {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			slog.Error("failed to close response body", "error", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %v from identity provider at '%v'", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, providerResponseLimit)).Decode(output)
+}
@@ -0,0 +1,170 @@
+/*
+  oidc
+    oidcauthstate
+      single use state of logins in progress with an identity provider
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// AuthStateExpiry is how long a login with the identity provider may take
+	AuthStateExpiry = 10 * time.Minute
+)
+
+// authStateFromRows ...
+// constructs an OIDCAuthStateSpec from rows
+func authStateFromRows(rows *sql.Rows) (authState types.OIDCAuthStateSpec, err error) {
+	if err := rows.Scan(&authState.ID, &authState.State, &authState.Nonce, &authState.CodeVerifier, &authState.Redirect, &authState.ExpiryTimestamp, &authState.CreationTimestamp, &authState.ModificationTimestamp, &authState.DeletionTimestamp); err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	return authState, nil
+}
+
+// randomToken ...
+// returns a URL safe random value
+func randomToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// sanitiseRedirect ...
+// returns the redirect if it's a path on this site, otherwise the home page
+func sanitiseRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") ||
+		strings.HasPrefix(redirect, "//") ||
+		strings.HasPrefix(redirect, "/\\") ||
+		len(redirect) > 512 {
+		return "/"
+	}
+	return redirect
+}
+
+type AuthStateManager struct {
+	manager *Manager
+	db      *sql.DB
+	ctx     context.Context
+}
+
+func (m *Manager) AuthStates() *AuthStateManager {
+	return &AuthStateManager{
+		manager: m,
+		db:      m.db,
+		ctx:     m.ctx,
+	}
+}
+
+// Create ...
+// creates the state, nonce and code verifier for a login.
+// Only a hash of the state is stored, so the returned state is the only copy of it
+func (m *AuthStateManager) Create(redirect string) (authStateInserted types.OIDCAuthStateSpec, err error) {
+	state, err := randomToken()
+	if err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	codeVerifier, err := randomToken()
+	if err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	sqlStatement := `insert into oidc_auth_state (state, nonce, codeVerifier, redirect, expiryTimestamp)
+                         values ($1, $2, $3, $4, $5)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, common.HashSHA512(state), nonce, codeVerifier, sanitiseRedirect(redirect), time.Now().Add(AuthStateExpiry).Unix())
+	if err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		authStateInserted, err = authStateFromRows(rows)
+		if err != nil {
+			return types.OIDCAuthStateSpec{}, err
+		}
+	}
+	authStateInserted.State = state
+	return authStateInserted, nil
+}
+
+// Redeem ...
+// deletes an unexpired login state, returning it.
+// Deleting as it's read ensures that each login is only able to be completed once
+func (m *AuthStateManager) Redeem(state string) (authState types.OIDCAuthStateSpec, err error) {
+	sqlStatement := `delete from oidc_auth_state
+                         where state = $1 and expiryTimestamp > $2
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, common.HashSHA512(state), time.Now().Unix())
+	if err != nil {
+		return types.OIDCAuthStateSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		authState, err = authStateFromRows(rows)
+		if err != nil {
+			return types.OIDCAuthStateSpec{}, err
+		}
+	}
+	if authState.ID == "" {
+		return types.OIDCAuthStateSpec{}, ErrOIDCAuthStateNotFound
+	}
+	return authState, nil
+}
+
+// DeleteExpired ...
+// deletes the state of logins which were never completed
+func (m *AuthStateManager) DeleteExpired() error {
+	sqlStatement := `delete from oidc_auth_state where expiryTimestamp <= $1`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired single sign-on login states", "count", n)
+	}
+	return nil
+}
@@ -19,12 +19,13 @@
 package registration
 
 import (
+	"context"
 	"fmt"
 
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/flats"
 	"gitlab.com/flattrack/flattrack/internal/groups"
 	"gitlab.com/flattrack/flattrack/internal/settings"
-	"gitlab.com/flattrack/flattrack/internal/system"
 	"gitlab.com/flattrack/flattrack/internal/users"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
@@ -36,23 +37,48 @@ var (
 
 type Manager struct {
 	user     *users.Manager
-	system   *system.Manager
+	flats    *flats.Manager
 	settings *settings.Manager
 	secret   string
+	flatID   string
 }
 
-func NewManager(users *users.Manager, system *system.Manager, settings *settings.Manager) *Manager {
+func NewManager(users *users.Manager, flats *flats.Manager, settings *settings.Manager) *Manager {
 	return &Manager{
 		user:     users,
-		system:   system,
+		flats:    flats,
 		settings: settings,
 		secret:   common.GetRegistrationSecret(),
 	}
 }
 
+// ForFlat ...
+// returns a manager for setting up a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		user:     m.user.ForFlat(flatID),
+		flats:    m.flats,
+		settings: m.settings.ForFlat(flatID),
+		secret:   m.secret,
+		flatID:   flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		user:     m.user.WithContext(ctx),
+		flats:    m.flats.WithContext(ctx),
+		settings: m.settings.WithContext(ctx),
+		secret:   m.secret,
+		flatID:   m.flatID,
+	}
+}
+
 // Register ...
-// perform initial FlatTrack instance setup
-func (m *Manager) Register(registration types.Registration) (successful bool, jwt string, err error) {
+// perform initial setup of a flat
+func (m *Manager) Register(registration types.Registration, session types.UserSessionSpec) (successful bool, jwt string, err error) {
 	if m.secret != "" && registration.Secret != m.secret {
 		return false, "", fmt.Errorf("a matching setup secret must be passed to registration")
 	}
@@ -76,11 +102,11 @@ func (m *Manager) Register(registration types.Registration) (successful bool, jw
 	if err != nil || user.ID == "" {
 		return false, "", err
 	}
-	jwt, err = m.user.GenerateJWTauthToken(user.ID, user.AuthNonce, 0)
+	jwt, err = m.user.NewSession(user, session)
 	if err != nil {
 		return false, "", err
 	}
-	err = m.system.SetHasInitialized()
+	err = m.flats.SetInitialized(m.flatID)
 	if err != nil {
 		return false, "", err
 	}
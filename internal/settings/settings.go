@@ -19,26 +19,76 @@
 package settings
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"strconv"
 
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
+// defaults ...
+// the settings which every flat is created with
+var defaults = map[string]string{
+	"flatName":               "",
+	"timezone":               "",
+	"language":               "en_US",
+	"flatNotes":              "",
+	"shoppingListNotes":      "",
+	"shoppingListKeepPolicy": string(types.ShoppingListKeepPolicyAlways),
+	"requireAdminTwoFactor":  "false",
+}
+
 type Manager struct {
-	db *sql.DB
+	db     *sql.DB
+	ctx    context.Context
+	flatID string
 }
 
 func NewManager(db *sql.DB) *Manager {
 	return &Manager{
-		db: db,
+		db:  db,
+		ctx: context.Background(),
+	}
+}
+
+// ForFlat ...
+// returns a manager for the settings of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    m.ctx,
+		flatID: flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    ctx,
+		flatID: m.flatID,
+	}
+}
+
+// CreateDefaults ...
+// creates the settings of a new flat
+func (m *Manager) CreateDefaults() (err error) {
+	sqlStatement := `insert into settings (name, value, flatId) values ($1, $2, $3)
+                         on conflict (flatId, name) do nothing`
+	for name, value := range defaults {
+		if _, err := m.db.ExecContext(m.ctx, sqlStatement, name, value, m.flatID); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (m *Manager) get(key string) (output string, err error) {
-	sqlStatement := `select value from settings where name = $1`
-	rows, err := m.db.Query(sqlStatement, key)
+	sqlStatement := `select value from settings where flatId = $1 and name = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, key)
 	if err != nil {
 		return "", err
 	}
@@ -60,8 +110,8 @@ func (m *Manager) set(key string, value string, validation func() (err error)) (
 	if err := validation(); err != nil {
 		return err
 	}
-	sqlStatement := `update settings set value = $1 where name = $2;`
-	rows, err := m.db.Query(sqlStatement, value, key)
+	sqlStatement := `update settings set value = $1 where flatId = $2 and name = $3;`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, value, m.flatID, key)
 	if err != nil {
 		return err
 	}
@@ -240,3 +290,22 @@ func (m *Manager) SetShoppingListKeepPolicy(input types.ShoppingListKeepPolicy)
 	}
 	return nil
 }
+
+// GetRequireAdminTwoFactor ...
+// returns whether members of the admin group must use two-factor authentication
+func (m *Manager) GetRequireAdminTwoFactor() (required bool, err error) {
+	value, err := m.get("requireAdminTwoFactor")
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+// SetRequireAdminTwoFactor ...
+// sets whether members of the admin group must use two-factor authentication
+func (m *Manager) SetRequireAdminTwoFactor(required bool) (err error) {
+	if err := m.set("requireAdminTwoFactor", strconv.FormatBool(required), func() error { return nil }); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+/*
+  shoppinglist
+    shoppingattachment
+      photos of shopping items and receipts of shopping lists, kept in file storage
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package shoppinglist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/files"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// shoppingAttachmentFilePrefix begins the names of attachments in file storage
+	shoppingAttachmentFilePrefix = "attachment"
+	// shoppingAttachmentThumbnailSuffix ends the names of the thumbnails of attachments in file storage
+	shoppingAttachmentThumbnailSuffix = "-thumbnail"
+	// shoppingAttachmentThumbnailMaxDimension is the largest width or height of a thumbnail
+	shoppingAttachmentThumbnailMaxDimension = 320
+	// shoppingAttachmentNameMaxLength is the longest name of an uploaded file which is kept
+	shoppingAttachmentNameMaxLength = 255
+)
+
+var (
+	ErrShoppingAttachmentStorageUnavailable   = fmt.Errorf("Unable to use attachments, as file storage is not configured")
+	ErrShoppingAttachmentNotFound             = fmt.Errorf("Unable to find attachment")
+	ErrShoppingAttachmentTooLarge             = fmt.Errorf("Unable to upload attachment, as it is too large")
+	ErrShoppingAttachmentEmpty                = fmt.Errorf("Unable to upload attachment, as it is empty")
+	ErrShoppingAttachmentContentTypeInvalid   = fmt.Errorf("Unable to upload attachment, as it is not a supported type of file")
+	ErrShoppingAttachmentItemNotFound         = fmt.Errorf("Unable to attach photo, as the item was not found")
+	ErrShoppingAttachmentListNotCompleted     = fmt.Errorf("Unable to attach receipt, as the shopping list is not completed")
+	ErrShoppingAttachmentKindInvalid          = fmt.Errorf("Unable to use attachment kind, as it is not Photo or Receipt")
+	ErrShoppingAttachmentThumbnailUnavailable = fmt.Errorf("Unable to find thumbnail, as the attachment doesn't have one")
+)
+
+// shoppingAttachmentContentTypes ...
+// the types of files which each kind of attachment is able to be
+var shoppingAttachmentContentTypes = map[types.ShoppingAttachmentKind][]string{
+	types.ShoppingAttachmentKindPhoto:   {"image/jpeg", "image/png", "image/gif", "image/webp"},
+	types.ShoppingAttachmentKindReceipt: {"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"},
+}
+
+type ShoppingAttachmentManager struct {
+	manager *Manager
+	db      queryer
+	ctx     context.Context
+}
+
+func (m *Manager) ShoppingAttachment() *ShoppingAttachmentManager {
+	return &ShoppingAttachmentManager{
+		manager: m,
+		db:      m.queryer(),
+		ctx:     m.ctx,
+	}
+}
+
+// fileAccess ...
+// returns the file storage for attachments
+func (m *ShoppingAttachmentManager) fileAccess() (files.FileAccess, error) {
+	if m.manager.files == nil {
+		return nil, ErrShoppingAttachmentStorageUnavailable
+	}
+	return m.manager.files.WithPrefix(shoppingAttachmentFilePrefix), nil
+}
+
+// getAttachmentObjectFromRows ...
+// returns an attachment object from rows
+func getAttachmentObjectFromRows(rows *sql.Rows) (attachment types.ShoppingAttachmentSpec, err error) {
+	if err := rows.Scan(&attachment.ID, &attachment.ListID, &attachment.ItemID, &attachment.Kind, &attachment.Name, &attachment.ContentType, &attachment.Size, &attachment.HasThumbnail, &attachment.Author, &attachment.CreationTimestamp, &attachment.ModificationTimestamp, &attachment.DeletionTimestamp, &attachment.FlatID); err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	return attachment, nil
+}
+
+// Validate ...
+// given the kind and contents of an attachment, returns the type of file which it is
+func (m *ShoppingAttachmentManager) Validate(kind types.ShoppingAttachmentKind, data []byte) (contentType string, err error) {
+	allowed, ok := shoppingAttachmentContentTypes[kind]
+	if !ok {
+		return "", ErrShoppingAttachmentKindInvalid
+	}
+	if len(data) == 0 {
+		return "", ErrShoppingAttachmentEmpty
+	}
+	if len(data) > common.GetAttachmentMaxBytes() {
+		return "", ErrShoppingAttachmentTooLarge
+	}
+	// the type is found from the contents, as the type which the upload claims isn't able to be trusted
+	contentType, _, _ = strings.Cut(http.DetectContentType(data), ";")
+	if !slices.Contains(allowed, contentType) {
+		return "", ErrShoppingAttachmentContentTypeInvalid
+	}
+	return contentType, nil
+}
+
+// List ...
+// returns the attachments of a list, and the photos of it's items
+func (m *ShoppingAttachmentManager) List(listID string) (attachments []types.ShoppingAttachmentSpec, err error) {
+	sqlStatement := `select * from shopping_attachment where listId = $1 and flatId = $2 order by creationTimestamp`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, m.manager.flatID)
+	if err != nil {
+		return []types.ShoppingAttachmentSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		attachment, err := getAttachmentObjectFromRows(rows)
+		if err != nil {
+			return []types.ShoppingAttachmentSpec{}, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// Get ...
+// given an id, returns an attachment
+func (m *ShoppingAttachmentManager) Get(id string) (attachment types.ShoppingAttachmentSpec, err error) {
+	sqlStatement := `select * from shopping_attachment where id = $1 and flatId = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		attachment, err = getAttachmentObjectFromRows(rows)
+		if err != nil {
+			return types.ShoppingAttachmentSpec{}, err
+		}
+	}
+	if attachment.ID == "" {
+		return types.ShoppingAttachmentSpec{}, ErrShoppingAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+// Create ...
+// uploads an attachment to a list, being a photo of an item or a receipt of the completed list.
+// An item only has one photo, so a new photo replaces the last
+func (m *ShoppingAttachmentManager) Create(listID string, attachment types.ShoppingAttachmentSpec, data []byte) (attachmentInserted types.ShoppingAttachmentSpec, err error) {
+	fileAccess, err := m.fileAccess()
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	contentType, err := m.Validate(attachment.Kind, data)
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	list, err := m.manager.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		return types.ShoppingAttachmentSpec{}, ErrFailedToGetExistingShoppingList
+	}
+	replacing := []types.ShoppingAttachmentSpec{}
+	switch attachment.Kind {
+	case types.ShoppingAttachmentKindPhoto:
+		item, err := m.manager.ShoppingItem().Get(list.ID, attachment.ItemID)
+		if err != nil {
+			return types.ShoppingAttachmentSpec{}, err
+		}
+		if item.ID == "" {
+			return types.ShoppingAttachmentSpec{}, ErrShoppingAttachmentItemNotFound
+		}
+		existing, err := m.List(list.ID)
+		if err != nil {
+			return types.ShoppingAttachmentSpec{}, err
+		}
+		for _, a := range existing {
+			if a.ItemID == item.ID {
+				replacing = append(replacing, a)
+			}
+		}
+	case types.ShoppingAttachmentKindReceipt:
+		if !list.Completed {
+			return types.ShoppingAttachmentSpec{}, ErrShoppingAttachmentListNotCompleted
+		}
+		attachment.ItemID = ""
+	}
+	attachment.Name = strings.TrimSpace(filepath.Base(attachment.Name))
+	if attachment.Name == "." || attachment.Name == string(filepath.Separator) {
+		attachment.Name = ""
+	}
+	if len(attachment.Name) > shoppingAttachmentNameMaxLength {
+		attachment.Name = attachment.Name[:shoppingAttachmentNameMaxLength]
+	}
+	var thumbnail []byte
+	if strings.HasPrefix(contentType, "image/") {
+		thumbnail, err = files.Thumbnail(data, shoppingAttachmentThumbnailMaxDimension)
+		if err != nil {
+			// attachments are still useful without a thumbnail, such as for image types which aren't able to be scaled
+			slog.Info("Unable to make thumbnail of attachment", "contentType", contentType, "error", err)
+		}
+	}
+
+	sqlStatement := `insert into shopping_attachment (listId, itemId, kind, name, contentType, size, hasThumbnail, author, flatId)
+                         values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, list.ID, attachment.ItemID, attachment.Kind, attachment.Name, contentType, len(data), len(thumbnail) > 0, attachment.Author, m.manager.flatID)
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		attachmentInserted, err = getAttachmentObjectFromRows(rows)
+		if err != nil {
+			return types.ShoppingAttachmentSpec{}, err
+		}
+	}
+	if err := fileAccess.Put(attachmentInserted.ID, data); err != nil {
+		return types.ShoppingAttachmentSpec{}, m.deleteFailedUpload(attachmentInserted, err)
+	}
+	if len(thumbnail) > 0 {
+		if err := fileAccess.Put(attachmentInserted.ID+shoppingAttachmentThumbnailSuffix, thumbnail); err != nil {
+			return types.ShoppingAttachmentSpec{}, m.deleteFailedUpload(attachmentInserted, err)
+		}
+	}
+	for _, a := range replacing {
+		if err := m.Delete(a.ID); err != nil {
+			slog.Error("Unable to delete replaced photo", "id", a.ID, "error", err)
+		}
+	}
+	return attachmentInserted, nil
+}
+
+// deleteFailedUpload ...
+// removes an attachment whose file failed to upload, returning the upload error
+func (m *ShoppingAttachmentManager) deleteFailedUpload(attachment types.ShoppingAttachmentSpec, uploadErr error) error {
+	if err := m.Delete(attachment.ID); err != nil {
+		slog.Error("Unable to delete attachment which failed to upload", "id", attachment.ID, "error", err)
+	}
+	return uploadErr
+}
+
+// Open ...
+// given an id, returns an attachment and it's file, or it's thumbnail
+func (m *ShoppingAttachmentManager) Open(id string, thumbnail bool) (attachment types.ShoppingAttachmentSpec, data []byte, err error) {
+	fileAccess, err := m.fileAccess()
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, []byte{}, err
+	}
+	attachment, err = m.Get(id)
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, []byte{}, err
+	}
+	name := attachment.ID
+	if thumbnail {
+		if !attachment.HasThumbnail {
+			return types.ShoppingAttachmentSpec{}, []byte{}, ErrShoppingAttachmentThumbnailUnavailable
+		}
+		name += shoppingAttachmentThumbnailSuffix
+	}
+	data, _, err = fileAccess.Get(name)
+	if err != nil {
+		return types.ShoppingAttachmentSpec{}, []byte{}, err
+	}
+	return attachment, data, nil
+}
+
+// deleteFiles ...
+// removes the file of an attachment, and it's thumbnail, from file storage
+func (m *ShoppingAttachmentManager) deleteFiles(attachment types.ShoppingAttachmentSpec) error {
+	fileAccess, err := m.fileAccess()
+	if err != nil {
+		return err
+	}
+	if err := fileAccess.Delete(attachment.ID); err != nil {
+		return err
+	}
+	if attachment.HasThumbnail {
+		if err := fileAccess.Delete(attachment.ID + shoppingAttachmentThumbnailSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete ...
+// given an id, removes an attachment and it's files
+func (m *ShoppingAttachmentManager) Delete(id string) (err error) {
+	attachment, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := m.deleteFiles(attachment); err != nil {
+		return err
+	}
+	sqlStatement := `delete from shopping_attachment where id = $1 and flatId = $2`
+	_, err = m.db.ExecContext(m.ctx, sqlStatement, attachment.ID, m.manager.flatID)
+	return err
+}
+
+// DeleteOrphaned ...
+// removes the attachments, and their files, whose list or item has been deleted
+func (m *ShoppingAttachmentManager) DeleteOrphaned() (err error) {
+	if m.manager.files == nil {
+		return nil
+	}
+	sqlStatement := `select * from shopping_attachment a
+                         where flatId = $1
+                         and (not exists (select 1 from shopping_list l where l.id = a.listId)
+                              or (a.itemId <> '' and not exists (select 1 from shopping_item i where i.id = a.itemId)))`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	orphaned := []types.ShoppingAttachmentSpec{}
+	for rows.Next() {
+		attachment, err := getAttachmentObjectFromRows(rows)
+		if err != nil {
+			return err
+		}
+		orphaned = append(orphaned, attachment)
+	}
+	if len(orphaned) == 0 {
+		return nil
+	}
+	for _, attachment := range orphaned {
+		if err := m.Delete(attachment.ID); err != nil {
+			return err
+		}
+	}
+	slog.Info("Removed orphaned shopping attachments", "count", len(orphaned))
+	return nil
+}
@@ -20,29 +20,51 @@
 package shoppinglist
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/imdario/mergo"
 
+	"gitlab.com/flattrack/flattrack/internal/database"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 type ShoppingItemManager struct {
 	manager *Manager
-	db      *sql.DB
+	db      queryer
+	ctx     context.Context
 }
 
 func (m *Manager) ShoppingItem() *ShoppingItemManager {
 	return &ShoppingItemManager{
 		manager: m,
-		db:      m.db,
+		db:      m.queryer(),
+		ctx:     m.ctx,
 	}
 }
 
 // Validate ...
 // given a shopping list item, return it's validity
 func (m *ShoppingItemManager) Validate(item types.ShoppingItemSpec) (valid bool, err error) {
+	if valid, err := m.validateFields(item); !valid || err != nil {
+		return valid, err
+	}
+	if item.TemplateID != "" {
+		list, err := m.manager.ShoppingList().Get(item.TemplateID)
+		if err != nil || list.ID == "" {
+			return false, ErrShoppingListByIDNotFoundForTemplate
+		}
+	}
+	return true, nil
+}
+
+// validateFields ...
+// given a shopping list item, return the validity of it's fields, without checking it's template
+func (m *ShoppingItemManager) validateFields(item types.ShoppingItemSpec) (valid bool, err error) {
 	if len(item.Name) == 0 || len(item.Name) >= 30 || item.Name == "" {
 		return false, ErrInvalidShoppingItemName
 	}
@@ -55,12 +77,6 @@ func (m *ShoppingItemManager) Validate(item types.ShoppingItemSpec) (valid bool,
 	if item.Quantity < 1 {
 		return false, ErrInvalidItemQuantityMustBeOne
 	}
-	if item.TemplateID != "" {
-		list, err := m.manager.ShoppingList().Get(item.TemplateID)
-		if err != nil || list.ID == "" {
-			return false, ErrShoppingListByIDNotFoundForTemplate
-		}
-	}
 	return true, nil
 }
 
@@ -74,10 +90,10 @@ func (m *ShoppingItemManager) List(listID string, options types.ShoppingItemOpti
 		return []types.ShoppingItemSpec{}, err
 	}
 
-	sqlQueryValues := []interface{}{listID}
-	sqlStatement := `select * from shopping_item where listId = $1`
+	sqlQueryValues := []interface{}{listID, m.manager.flatID}
+	sqlStatement := fmt.Sprintf(`select %v from shopping_item where listId = $1 and listId in (select id from shopping_list where flatId = $2)`, ShoppingItemColumns)
 	if options.Selector.Obtained != "" && options.Selector.TemplateListItemSelector != "" {
-		sqlStatement += ` and obtained = $2`
+		sqlStatement += ` and obtained = $3`
 		sqlQueryValues = append(sqlQueryValues, obtained)
 	}
 	switch options.Selector.TemplateListItemSelector {
@@ -111,7 +127,7 @@ func (m *ShoppingItemManager) List(listID string, options types.ShoppingItemOpti
 	default:
 		sqlStatement += ` order by tag asc, name asc`
 	}
-	rows, err := m.db.Query(sqlStatement, sqlQueryValues...)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, sqlQueryValues...)
 	if err != nil {
 		slog.Error("failed to query database", "error", err)
 		return []types.ShoppingItemSpec{}, err
@@ -122,7 +138,7 @@ func (m *ShoppingItemManager) List(listID string, options types.ShoppingItemOpti
 		}
 	}()
 	for rows.Next() {
-		item, err := getItemObjectFromRows(rows)
+		item, err := ShoppingItemColumns.Scan(rows)
 		if err != nil {
 			return []types.ShoppingItemSpec{}, err
 		}
@@ -134,8 +150,8 @@ func (m *ShoppingItemManager) List(listID string, options types.ShoppingItemOpti
 // Get ...
 // given an item id, return it's properties
 func (m *ShoppingItemManager) Get(listid, itemID string) (item types.ShoppingItemSpec, err error) {
-	sqlStatement := `select * from shopping_item where listid = $1 and id = $2`
-	rows, err := m.db.Query(sqlStatement, listid, itemID)
+	sqlStatement := fmt.Sprintf(`select %v from shopping_item where listid = $1 and id = $2 and listId in (select id from shopping_list where flatId = $3)`, ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listid, itemID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -145,7 +161,7 @@ func (m *ShoppingItemManager) Get(listid, itemID string) (item types.ShoppingIte
 		}
 	}()
 	rows.Next()
-	item, err = getItemObjectFromRows(rows)
+	item, err = ShoppingItemColumns.Scan(rows)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -166,10 +182,11 @@ func (m *ShoppingItemManager) AddItemToList(listID string, item types.ShoppingIt
 
 	item.AuthorLast = item.Author
 
-	sqlStatement := `insert into shopping_item (listId, name, price, quantity, notes, author, authorLast, tag, obtained, templateId)
-                         values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, listID, item.Name, item.Price, item.Quantity, item.Notes, item.Author, item.AuthorLast, item.Tag, item.Obtained, &item.TemplateID)
+	sqlStatement := fmt.Sprintf(`insert into shopping_item (listId, name, price, quantity, notes, author, authorLast, tag, obtained, templateId)
+                         select $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+                         where exists (select 1 from shopping_list where id = $1 and flatId = $11)
+                         returning %v`, ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, item.Name, item.Price, item.Quantity, item.Notes, item.Author, item.AuthorLast, item.Tag, item.Obtained, &item.TemplateID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -179,14 +196,106 @@ func (m *ShoppingItemManager) AddItemToList(listID string, item types.ShoppingIt
 		}
 	}()
 	for rows.Next() {
-		itemInserted, err = getItemObjectFromRows(rows)
+		itemInserted, err = ShoppingItemColumns.Scan(rows)
 		if err != nil {
 			return types.ShoppingItemSpec{}, err
 		}
 	}
+	if itemInserted.ID == "" {
+		return types.ShoppingItemSpec{}, ErrFailedToGetExistingShoppingList
+	}
+	m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeCreated, itemInserted)
 	return itemInserted, nil
 }
 
+// AddItemsToList ...
+// adds many new items in a single statement, all together or not at all
+func (m *ShoppingItemManager) AddItemsToList(listID string, items []types.ShoppingItemSpec) (itemsInserted []types.ShoppingItemSpec, err error) {
+	for _, item := range items {
+		valid, err := m.Validate(item)
+		if !valid || err != nil {
+			return []types.ShoppingItemSpec{}, err
+		}
+	}
+	itemsInserted, err = m.insertItems(listID, items)
+	if err != nil {
+		return []types.ShoppingItemSpec{}, err
+	}
+	m.manager.ShoppingItemEvent().publishAll(types.ShoppingItemEventTypeCreated, itemsInserted)
+	return itemsInserted, nil
+}
+
+// insertItems ...
+// adds the rows for many items in a single statement, without checking their templates
+func (m *ShoppingItemManager) insertItems(listID string, items []types.ShoppingItemSpec) (itemsInserted []types.ShoppingItemSpec, err error) {
+	if len(items) == 0 {
+		return []types.ShoppingItemSpec{}, nil
+	}
+	rowsOfItems := []map[string]any{}
+	for _, item := range items {
+		if valid, err := m.validateFields(item); !valid || err != nil {
+			return []types.ShoppingItemSpec{}, err
+		}
+		if item.Tag == "" {
+			item.Tag = "Untagged"
+		}
+		rowsOfItems = append(rowsOfItems, map[string]any{
+			"name":       item.Name,
+			"price":      item.Price,
+			"quantity":   item.Quantity,
+			"notes":      item.Notes,
+			"author":     item.Author,
+			"authorLast": item.Author,
+			"tag":        item.Tag,
+			"obtained":   item.Obtained,
+			"templateId": item.TemplateID,
+		})
+	}
+
+	itemsJSON, err := json.Marshal(rowsOfItems)
+	if err != nil {
+		return []types.ShoppingItemSpec{}, err
+	}
+	// the items are passed as a single JSON array parameter, which is read into rows in order
+	dialect := database.DialectOf(m.manager.db)
+	fields := []string{}
+	for _, field := range []struct{ name, fieldType string }{
+		{"name", "text"}, {"price", "float8"}, {"quantity", "int"}, {"notes", "text"}, {"author", "text"},
+		{"authorLast", "text"}, {"tag", "text"}, {"obtained", "bool"}, {"templateId", "text"},
+	} {
+		fields = append(fields, dialect.JSONField(`i.value`, field.name, field.fieldType))
+	}
+	sqlStatement := fmt.Sprintf(`insert into shopping_item (listId, name, price, quantity, notes, author, authorLast, tag, obtained, templateId)
+                         select $1, %v
+                         from %v
+                         where exists (select 1 from shopping_list where id = $1 and flatId = $3)
+                         order by i.position
+                         returning %v`, strings.Join(fields, ", "), dialect.JSONElements(`$2`, `i`), ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, string(itemsJSON), m.manager.flatID)
+	if err != nil {
+		return []types.ShoppingItemSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		item, err := ShoppingItemColumns.Scan(rows)
+		if err != nil {
+			return []types.ShoppingItemSpec{}, err
+		}
+		itemsInserted = append(itemsInserted, item)
+	}
+	if err := rows.Err(); err != nil {
+		return []types.ShoppingItemSpec{}, err
+	}
+	if len(itemsInserted) != len(items) {
+		return []types.ShoppingItemSpec{}, ErrFailedToGetExistingShoppingList
+	}
+	return itemsInserted, nil
+}
+
 // Patch ...
 // patches a shopping item
 func (m *ShoppingItemManager) Patch(listid string, itemID string, item types.ShoppingItemSpec) (itemPatched types.ShoppingItemSpec, err error) {
@@ -208,8 +317,8 @@ func (m *ShoppingItemManager) Patch(listid string, itemID string, item types.Sho
 		item.Tag = "Untagged"
 	}
 
-	sqlStatement := `update shopping_item set name = $2, price = $3, quantity = $4, notes = $5, authorLast = $6, tag = $7, obtained = $8, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $1 returning *`
-	rows, err := m.db.Query(sqlStatement, itemID, item.Name, item.Price, item.Quantity, item.Notes, item.AuthorLast, item.Tag, item.Obtained)
+	sqlStatement := fmt.Sprintf(`update shopping_item set name = $2, price = $3, quantity = $4, notes = $5, authorLast = $6, tag = $7, obtained = $8, modificationTimestamp = %v where id = $1 and listId in (select id from shopping_list where flatId = $9) returning %v`, database.DialectOf(m.manager.db).Now(), ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, itemID, item.Name, item.Price, item.Quantity, item.Notes, item.AuthorLast, item.Tag, item.Obtained, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -219,7 +328,7 @@ func (m *ShoppingItemManager) Patch(listid string, itemID string, item types.Sho
 		}
 	}()
 	for rows.Next() {
-		itemPatched, err = getItemObjectFromRows(rows)
+		itemPatched, err = ShoppingItemColumns.Scan(rows)
 		if err != nil {
 			return types.ShoppingItemSpec{}, err
 		}
@@ -232,6 +341,7 @@ func (m *ShoppingItemManager) Patch(listid string, itemID string, item types.Sho
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
+	m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeUpdated, itemPatched)
 	return itemPatched, nil
 }
 
@@ -247,8 +357,8 @@ func (m *ShoppingItemManager) Update(listID string, itemID string, item types.Sh
 		item.Tag = "Untagged"
 	}
 
-	sqlStatement := `update shopping_item set name = $3, price = $4, quantity = $5, notes = $6, authorLast = $7, tag = $8, obtained = $9, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where listId = $1 and id = $2 returning *`
-	rows, err := m.db.Query(sqlStatement, listID, itemID, item.Name, item.Price, item.Quantity, item.Notes, item.AuthorLast, item.Tag, item.Obtained)
+	sqlStatement := fmt.Sprintf(`update shopping_item set name = $3, price = $4, quantity = $5, notes = $6, authorLast = $7, tag = $8, obtained = $9, modificationTimestamp = %v where listId = $1 and id = $2 and listId in (select id from shopping_list where flatId = $10) returning %v`, database.DialectOf(m.manager.db).Now(), ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, itemID, item.Name, item.Price, item.Quantity, item.Notes, item.AuthorLast, item.Tag, item.Obtained, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -258,7 +368,7 @@ func (m *ShoppingItemManager) Update(listID string, itemID string, item types.Sh
 		}
 	}()
 	for rows.Next() {
-		itemUpdated, err = getItemObjectFromRows(rows)
+		itemUpdated, err = ShoppingItemColumns.Scan(rows)
 		if err != nil {
 			return types.ShoppingItemSpec{}, err
 		}
@@ -270,14 +380,15 @@ func (m *ShoppingItemManager) Update(listID string, itemID string, item types.Sh
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
+	m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeUpdated, itemUpdated)
 	return itemUpdated, nil
 }
 
 // SetItemObtained ...
 // updates the item's obtained field
 func (m *ShoppingItemManager) SetItemObtained(listID string, itemID string, obtained bool, authorLast string) (item types.ShoppingItemSpec, err error) {
-	sqlStatement := `update shopping_item set obtained = $3 where listId = $1 and id = $2 returning *`
-	rows, err := m.db.Query(sqlStatement, listID, itemID, obtained)
+	sqlStatement := fmt.Sprintf(`update shopping_item set obtained = $3 where listId = $1 and id = $2 and listId in (select id from shopping_list where flatId = $4) returning %v`, ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, itemID, obtained, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
@@ -287,7 +398,7 @@ func (m *ShoppingItemManager) SetItemObtained(listID string, itemID string, obta
 		}
 	}()
 	for rows.Next() {
-		item, err = getItemObjectFromRows(rows)
+		item, err = ShoppingItemColumns.Scan(rows)
 		if err != nil {
 			return types.ShoppingItemSpec{}, err
 		}
@@ -300,26 +411,34 @@ func (m *ShoppingItemManager) SetItemObtained(listID string, itemID string, obta
 	if err != nil {
 		return types.ShoppingItemSpec{}, err
 	}
+	m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeObtained, item)
 	return item, nil
 }
 
-// getItemObjectFromRows ...
-// returns an item object from rows
-func getItemObjectFromRows(rows *sql.Rows) (item types.ShoppingItemSpec, err error) {
-	if err := rows.Scan(&item.ID, &item.ListID, &item.Name, &item.Price, &item.Quantity, &item.Notes, &item.Obtained, &item.Tag, &item.Author, &item.AuthorLast, &item.CreationTimestamp, &item.ModificationTimestamp, &item.DeletionTimestamp, &item.TemplateID); err != nil {
-		return types.ShoppingItemSpec{}, err
-	}
-	if err := rows.Err(); err != nil {
-		return types.ShoppingItemSpec{}, err
-	}
-	return item, nil
+// ShoppingItemColumns ...
+// the columns of shopping items, along with the fields of a ShoppingItemSpec which they are scanned into
+var ShoppingItemColumns = database.Columns[types.ShoppingItemSpec]{
+	{Name: "id", Field: func(item *types.ShoppingItemSpec) any { return &item.ID }},
+	{Name: "listId", Field: func(item *types.ShoppingItemSpec) any { return &item.ListID }},
+	{Name: "name", Field: func(item *types.ShoppingItemSpec) any { return &item.Name }},
+	{Name: "price", Field: func(item *types.ShoppingItemSpec) any { return &item.Price }},
+	{Name: "quantity", Field: func(item *types.ShoppingItemSpec) any { return &item.Quantity }},
+	{Name: "notes", Field: func(item *types.ShoppingItemSpec) any { return &item.Notes }},
+	{Name: "obtained", Field: func(item *types.ShoppingItemSpec) any { return &item.Obtained }},
+	{Name: "tag", Field: func(item *types.ShoppingItemSpec) any { return &item.Tag }},
+	{Name: "author", Field: func(item *types.ShoppingItemSpec) any { return &item.Author }},
+	{Name: "authorLast", Field: func(item *types.ShoppingItemSpec) any { return &item.AuthorLast }},
+	{Name: "creationTimestamp", Field: func(item *types.ShoppingItemSpec) any { return &item.CreationTimestamp }},
+	{Name: "modificationTimestamp", Field: func(item *types.ShoppingItemSpec) any { return &item.ModificationTimestamp }},
+	{Name: "deletionTimestamp", Field: func(item *types.ShoppingItemSpec) any { return &item.DeletionTimestamp }},
+	{Name: "templateId", Field: func(item *types.ShoppingItemSpec) any { return &item.TemplateID }},
 }
 
 // Delete ...
 // given an item id, remove it
 func (m *ShoppingItemManager) Delete(id string, listID string, authorLast string) (err error) {
-	sqlStatement := `delete from shopping_item where id = $1 and listId = $2`
-	rows, err := m.db.Query(sqlStatement, id, listID)
+	sqlStatement := `delete from shopping_item where id = $1 and listId = $2 and listId in (select id from shopping_list where flatId = $3)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, listID, m.manager.flatID)
 	if err != nil {
 		return err
 	}
@@ -336,14 +455,15 @@ func (m *ShoppingItemManager) Delete(id string, listID string, authorLast string
 	if err != nil {
 		return err
 	}
+	m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeDeleted, types.ShoppingItemSpec{ID: id, ListID: listID})
 	return nil
 }
 
 // Delete ...
 // given an item id, remove it
 func (m *ShoppingItemManager) DeleteTagItems(listID string, tagName string, authorLast string) (err error) {
-	sqlStatement := `delete from shopping_item where listId = $1 and tag = $2`
-	rows, err := m.db.Query(sqlStatement, listID, tagName)
+	sqlStatement := `delete from shopping_item where listId = $1 and tag = $2 and listId in (select id from shopping_list where flatId = $3) returning id`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, tagName, m.manager.flatID)
 	if err != nil {
 		return err
 	}
@@ -352,6 +472,17 @@ func (m *ShoppingItemManager) DeleteTagItems(listID string, tagName string, auth
 			slog.Error("failed to close rows", "error", err)
 		}
 	}()
+	deletedIDs := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		deletedIDs = append(deletedIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
 	shoppingListPatch := types.ShoppingListSpec{
 		AuthorLast: authorLast,
@@ -360,6 +491,9 @@ func (m *ShoppingItemManager) DeleteTagItems(listID string, tagName string, auth
 	if err != nil {
 		return err
 	}
+	for _, id := range deletedIDs {
+		m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeDeleted, types.ShoppingItemSpec{ID: id, ListID: listID})
+	}
 	return nil
 }
 
@@ -367,8 +501,8 @@ func (m *ShoppingItemManager) DeleteTagItems(listID string, tagName string, auth
 // given an item id, remove all items
 // only intended to be called when deleting list
 func (m *ShoppingItemManager) DeleteAll(listID string) (err error) {
-	sqlStatement := `delete from shopping_item where listId = $1`
-	rows, err := m.db.Query(sqlStatement, listID)
+	sqlStatement := `delete from shopping_item where listId = $1 and listId in (select id from shopping_list where flatId = $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, m.manager.flatID)
 	if err != nil {
 		return err
 	}
@@ -383,8 +517,8 @@ func (m *ShoppingItemManager) DeleteAll(listID string) (err error) {
 // GetListItemCount ...
 // returns a count of the items in a list
 func (m *ShoppingItemManager) GetListItemCount(listID string) (count int, err error) {
-	sqlStatement := `select count(*) from shopping_item where listId = $1`
-	rows, err := m.db.Query(sqlStatement, listID)
+	sqlStatement := `select count(*) from shopping_item where listId = $1 and listId in (select id from shopping_list where flatId = $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, m.manager.flatID)
 	if err != nil {
 		return count, err
 	}
@@ -407,7 +541,7 @@ func (m *ShoppingItemManager) UntemplateItemsFromDeletedLists() error {
                            set templateid = ''
                          where not exists (select * from shopping_list where shopping_list.id = shopping_item.templateid)
                          and templateid <> ''`
-	res, err := m.db.Exec(sqlStatement)
+	res, err := m.db.ExecContext(m.ctx, sqlStatement)
 	if err != nil {
 		return err
 	}
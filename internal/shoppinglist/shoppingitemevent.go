@@ -0,0 +1,220 @@
+/*
+  shoppinglist
+    shoppingitemevent
+      publish and subscribe to changes of shopping list items
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package shoppinglist
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// shoppingItemEventChannel is the Postgres notification channel
+	// which item events are published to, reaching every instance
+	shoppingItemEventChannel = "shopping_item_event"
+	// shoppingItemEventBufferSize is how many events a subscriber
+	// may fall behind by before events are dropped for it
+	shoppingItemEventBufferSize = 32
+)
+
+type ShoppingItemEventManager struct {
+	manager *Manager
+	db      queryer
+	ctx     context.Context
+}
+
+func (m *Manager) ShoppingItemEvent() *ShoppingItemEventManager {
+	return &ShoppingItemEventManager{
+		manager: m,
+		db:      m.queryer(),
+		ctx:     m.ctx,
+	}
+}
+
+// Publish ...
+// notifies all instances of a change to an item
+func (m *ShoppingItemEventManager) Publish(eventType types.ShoppingItemEventType, item types.ShoppingItemSpec) (err error) {
+	event := types.ShoppingItemEvent{
+		Type:   eventType,
+		ListID: item.ListID,
+		Item:   item,
+	}
+	if !database.DialectOf(m.manager.db).Notifies() {
+		m.dispatchLocal(event)
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	sqlStatement := `select pg_notify($1, $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, shoppingItemEventChannel, string(payload))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// publish ...
+// notifies all instances of a change to an item, logging failures.
+// Events are best effort, so a failure must not fail the change itself
+func (m *ShoppingItemEventManager) publish(eventType types.ShoppingItemEventType, item types.ShoppingItemSpec) {
+	if err := m.Publish(eventType, item); err != nil {
+		slog.Error("failed to publish shopping item event", "type", eventType, "listId", item.ListID, "itemId", item.ID, "error", err)
+	}
+}
+
+// publishAll ...
+// notifies all instances of a change to many items in a single statement, logging failures
+func (m *ShoppingItemEventManager) publishAll(eventType types.ShoppingItemEventType, items []types.ShoppingItemSpec) {
+	if len(items) == 0 {
+		return
+	}
+	if !database.DialectOf(m.manager.db).Notifies() {
+		for _, item := range items {
+			m.dispatchLocal(types.ShoppingItemEvent{
+				Type:   eventType,
+				ListID: item.ListID,
+				Item:   item,
+			})
+		}
+		return
+	}
+	payloads := []string{}
+	for _, item := range items {
+		payload, err := json.Marshal(types.ShoppingItemEvent{
+			Type:   eventType,
+			ListID: item.ListID,
+			Item:   item,
+		})
+		if err != nil {
+			slog.Error("failed to marshal shopping item event", "type", eventType, "listId", item.ListID, "itemId", item.ID, "error", err)
+			return
+		}
+		payloads = append(payloads, string(payload))
+	}
+	sqlStatement := `select pg_notify($1, payload) from unnest($2::text[]) as payload`
+	if _, err := m.db.ExecContext(m.ctx, sqlStatement, shoppingItemEventChannel, pq.Array(payloads)); err != nil {
+		slog.Error("failed to publish shopping item events", "type", eventType, "listId", items[0].ListID, "count", len(items), "error", err)
+	}
+}
+
+// Subscribe ...
+// returns a channel of events for items in a list and
+// a function to call once no longer interested in them
+func (m *ShoppingItemEventManager) Subscribe(listID string) (events <-chan types.ShoppingItemEvent, unsubscribe func()) {
+	subscriber := make(chan types.ShoppingItemEvent, shoppingItemEventBufferSize)
+	m.manager.subscribers.lock.Lock()
+	if m.manager.subscribers.lists[listID] == nil {
+		m.manager.subscribers.lists[listID] = map[chan types.ShoppingItemEvent]struct{}{}
+	}
+	m.manager.subscribers.lists[listID][subscriber] = struct{}{}
+	m.manager.subscribers.lock.Unlock()
+	return subscriber, func() {
+		m.manager.subscribers.lock.Lock()
+		defer m.manager.subscribers.lock.Unlock()
+		delete(m.manager.subscribers.lists[listID], subscriber)
+		if len(m.manager.subscribers.lists[listID]) == 0 {
+			delete(m.manager.subscribers.lists, listID)
+		}
+	}
+}
+
+// dispatch ...
+// sends an event to the subscribers of it's list
+func (m *ShoppingItemEventManager) dispatch(event types.ShoppingItemEvent) {
+	m.manager.subscribers.lock.RLock()
+	defer m.manager.subscribers.lock.RUnlock()
+	for subscriber := range m.manager.subscribers.lists[event.ListID] {
+		select {
+		case subscriber <- event:
+		default:
+			slog.Warn("dropping shopping item event for slow subscriber", "type", event.Type, "listId", event.ListID, "itemId", event.Item.ID)
+		}
+	}
+}
+
+// dispatchLocal ...
+// sends an event to the subscribers of this instance, for databases which have no notifications.
+// Events of a transaction are held until it's committed, like notifications in Postgres
+func (m *ShoppingItemEventManager) dispatchLocal(event types.ShoppingItemEvent) {
+	if m.manager.tx != nil && m.manager.pendingEvents != nil {
+		*m.manager.pendingEvents = append(*m.manager.pendingEvents, event)
+		return
+	}
+	m.dispatch(event)
+}
+
+// Listen ...
+// receives item events published by all instances and
+// dispatches them to the subscribers of this instance
+func (m *ShoppingItemEventManager) Listen(connectionString string) {
+	if !database.DialectOf(m.manager.db).Notifies() {
+		slog.Info("Sending shopping item events within this instance, as the database is only used by a single instance")
+		return
+	}
+	listener := pq.NewListener(connectionString, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("shopping item event listener error", "error", err)
+		}
+	})
+	defer func() {
+		if err := listener.Close(); err != nil {
+			slog.Error("failed to close shopping item event listener", "error", err)
+		}
+	}()
+	if err := listener.Listen(shoppingItemEventChannel); err != nil {
+		slog.Error("failed to listen for shopping item events", "error", err)
+		return
+	}
+	slog.Info("Listening for shopping item events")
+	for {
+		select {
+		case notification := <-listener.Notify:
+			// a nil notification is sent after reconnecting
+			if notification == nil {
+				continue
+			}
+			var event types.ShoppingItemEvent
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				slog.Error("failed to read shopping item event", "error", err)
+				continue
+			}
+			m.dispatch(event)
+		case <-time.After(90 * time.Second):
+			go func() {
+				if err := listener.Ping(); err != nil {
+					slog.Error("failed to ping shopping item event listener", "error", err)
+				}
+			}()
+		}
+	}
+}
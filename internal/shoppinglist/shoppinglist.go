@@ -20,14 +20,19 @@
 package shoppinglist
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/lib/pq"
 
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/files"
 	"gitlab.com/flattrack/flattrack/internal/settings"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
@@ -47,29 +52,130 @@ var (
 	ErrInvalidShoppingListNotes                  = fmt.Errorf("Unable to save shopping list notes, as they are too long")
 	ErrInvalidShoppingItemNotes                  = fmt.Errorf("Unable to save shopping item notes, as they are too long")
 	ErrShoppingListByIDNotFoundForTemplate       = fmt.Errorf("Unable to find list to use as template from provided id")
+	ErrShoppingListNotFound                      = fmt.Errorf("Unable to find shopping list")
 )
 
 type Manager struct {
 	db              *sql.DB
+	tx              *sql.Tx
+	ctx             context.Context
 	settingsManager *settings.Manager
+	files           files.FileAccess
+	flatID          string
+
+	// subscribers are shared between the managers of every flat,
+	// as events from the database arrive on a single connection
+	subscribers *shoppingItemSubscribers
+	// pendingEvents are the item events of a transaction which are dispatched once it's committed,
+	// for databases which have no notifications to send them with
+	pendingEvents *[]types.ShoppingItemEvent
 }
 
-func NewManager(db *sql.DB, settingsManager *settings.Manager) *Manager {
+// shoppingItemSubscribers ...
+// the channels subscribed to the item events of each list
+type shoppingItemSubscribers struct {
+	lists map[string]map[chan types.ShoppingItemEvent]struct{}
+	lock  sync.RWMutex
+}
+
+func NewManager(db *sql.DB, settingsManager *settings.Manager, files files.FileAccess) *Manager {
 	return &Manager{
 		db:              db,
+		ctx:             context.Background(),
 		settingsManager: settingsManager,
+		files:           files,
+		subscribers: &shoppingItemSubscribers{
+			lists: map[string]map[chan types.ShoppingItemEvent]struct{}{},
+		},
+	}
+}
+
+// ForFlat ...
+// returns a manager for the shopping lists of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		db:              m.db,
+		ctx:             m.ctx,
+		settingsManager: m.settingsManager.ForFlat(flatID),
+		files:           m.files,
+		flatID:          flatID,
+		subscribers:     m.subscribers,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:              m.db,
+		tx:              m.tx,
+		ctx:             ctx,
+		settingsManager: m.settingsManager.WithContext(ctx),
+		files:           m.files,
+		flatID:          m.flatID,
+		subscribers:     m.subscribers,
+		pendingEvents:   m.pendingEvents,
+	}
+}
+
+// queryer ...
+// runs statements against either the database or a transaction of it
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// queryer ...
+// returns the transaction of the manager, otherwise the database
+func (m *Manager) queryer() queryer {
+	if m.tx != nil {
+		return m.tx
 	}
+	return m.db
+}
+
+// InTransaction ...
+// runs fn with a manager whose changes are all kept once it returns without error, or none of them.
+// Item events are only sent once the changes are kept
+func (m *Manager) InTransaction(fn func(m *Manager) error) (err error) {
+	if m.tx != nil {
+		return fn(m)
+	}
+	tx, err := m.db.BeginTx(m.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			slog.Error("failed to rollback shopping list transaction", "error", err)
+		}
+	}()
+	txManager := *m
+	txManager.tx = tx
+	txManager.pendingEvents = &[]types.ShoppingItemEvent{}
+	if err := fn(&txManager); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, event := range *txManager.pendingEvents {
+		m.ShoppingItemEvent().dispatch(event)
+	}
+	return nil
 }
 
 type ShoppingListManager struct {
 	manager *Manager
-	db      *sql.DB
+	db      queryer
+	ctx     context.Context
 }
 
 func (m *Manager) ShoppingList() *ShoppingListManager {
 	return &ShoppingListManager{
 		manager: m,
-		db:      m.db,
+		db:      m.queryer(),
+		ctx:     m.ctx,
 	}
 }
 
@@ -94,15 +200,15 @@ func (m *ShoppingListManager) Validate(shoppingList types.ShoppingListSpec) (val
 // List ...
 // returns a list of all shopping lists (name, notes, author, etc...)
 func (m *ShoppingListManager) List(options types.ShoppingListOptions) (shoppingLists []types.ShoppingListSpec, err error) {
-	sqlStatement := `select * from shopping_list where deletionTimestamp = 0 `
-	fields := []any{}
+	sqlStatement := fmt.Sprintf(`select %v from shopping_list where flatId = $1 and deletionTimestamp = 0 `, ShoppingListColumns)
+	fields := []any{m.manager.flatID}
 
 	if options.SortBy == types.ShoppingListSortByTemplated {
-		sqlStatement = `with popularity as (
-                          select id, (select count(*) from shopping_list where templateid = c.id) as tally from shopping_list c)
-                        select id, name, notes, author, authorlast, completed, creationtimestamp, modificationtimestamp, deletiontimestamp, templateid, total_tag_exclude
+		sqlStatement = fmt.Sprintf(`with popularity as (
+                          select id, (select count(*) from shopping_list where templateid = c.id) as tally from shopping_list c where flatId = $1)
+                        select %v
                         from shopping_list
-                        join popularity using(id) where deletiontimestamp = 0 `
+                        join popularity using(id) where flatId = $1 and deletiontimestamp = 0 `, ShoppingListColumns.Prefixed("shopping_list"))
 	}
 
 	if options.Selector.ModificationTimestampBefore != 0 {
@@ -151,7 +257,7 @@ func (m *ShoppingListManager) List(options types.ShoppingListOptions) (shoppingL
 		fields = append(fields, pageNumber)
 	}
 
-	rows, err := m.db.Query(sqlStatement, fields...)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, fields...)
 	if err != nil {
 		return []types.ShoppingListSpec{}, err
 	}
@@ -161,7 +267,7 @@ func (m *ShoppingListManager) List(options types.ShoppingListOptions) (shoppingL
 		}
 	}()
 	for rows.Next() {
-		shoppingList, err := getListObjectFromRows(rows)
+		shoppingList, err := ShoppingListColumns.Scan(rows)
 		if err != nil {
 			return []types.ShoppingListSpec{}, err
 		}
@@ -183,8 +289,8 @@ func (m *ShoppingListManager) List(options types.ShoppingListOptions) (shoppingL
 // Get ...
 // returns a given shopping list, by it's ID
 func (m *ShoppingListManager) Get(listID string) (shoppingList types.ShoppingListSpec, err error) {
-	sqlStatement := `select * from shopping_list where id = $1 and deletionTimestamp = 0`
-	rows, err := m.db.Query(sqlStatement, listID)
+	sqlStatement := fmt.Sprintf(`select %v from shopping_list where id = $1 and flatId = $2 and deletionTimestamp = 0`, ShoppingListColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
@@ -193,11 +299,20 @@ func (m *ShoppingListManager) Get(listID string) (shoppingList types.ShoppingLis
 			slog.Error("failed to close rows", "error", err)
 		}
 	}()
-	rows.Next()
-	shoppingList, err = getListObjectFromRows(rows)
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return types.ShoppingListSpec{}, err
+		}
+		return types.ShoppingListSpec{}, ErrShoppingListNotFound
+	}
+	shoppingList, err = ShoppingListColumns.Scan(rows)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
+	// the rows are closed before counting, as a transaction runs one statement at a time
+	if err := rows.Close(); err != nil {
+		return types.ShoppingListSpec{}, err
+	}
 	shoppingList.Count, err = m.manager.ShoppingItem().GetListItemCount(shoppingList.ID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
@@ -226,10 +341,29 @@ func (m *ShoppingListManager) Create(shoppingList types.ShoppingListSpec, option
 	shoppingList.AuthorLast = shoppingList.Author
 	shoppingList.Completed = false
 
-	sqlStatement := `insert into shopping_list (name, notes, author, authorLast, completed, templateId, total_tag_exclude)
-                         values ($1, $2, $3, $4, $5, $6, $7)
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.Author, shoppingList.AuthorLast, shoppingList.Completed, shoppingList.TemplateID, pq.Array(shoppingList.TotalTagExclude))
+	// the list and the items copied from it's template are kept all together or not at all
+	if err := m.manager.InTransaction(func(m *Manager) error {
+		shoppingListInserted, err = m.ShoppingList().insert(shoppingList)
+		if err != nil {
+			return err
+		}
+		if shoppingList.TemplateID == "" {
+			return nil
+		}
+		return m.ShoppingList().copyTemplateItems(shoppingListInserted, options)
+	}); err != nil {
+		return types.ShoppingListSpec{}, err
+	}
+	return shoppingListInserted, nil
+}
+
+// insert ...
+// adds a row for a shopping list
+func (m *ShoppingListManager) insert(shoppingList types.ShoppingListSpec) (shoppingListInserted types.ShoppingListSpec, err error) {
+	sqlStatement := fmt.Sprintf(`insert into shopping_list (name, notes, author, authorLast, completed, templateId, total_tag_exclude, flatId)
+                         values ($1, $2, $3, $4, $5, $6, $7, $8)
+                         returning %v`, ShoppingListColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.Author, shoppingList.AuthorLast, shoppingList.Completed, shoppingList.TemplateID, pq.Array(shoppingList.TotalTagExclude), m.manager.flatID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
@@ -238,27 +372,30 @@ func (m *ShoppingListManager) Create(shoppingList types.ShoppingListSpec, option
 			slog.Error("failed to close rows", "error", err)
 		}
 	}()
-	rows.Next()
-	shoppingListInserted, err = getListObjectFromRows(rows)
-	if err != nil || shoppingListInserted.ID == "" {
-		slog.Error("Failed to get list object from rows", "error", err)
-		return types.ShoppingListSpec{}, ErrFailedToCreateShoppingList
+	for rows.Next() {
+		shoppingListInserted, err = ShoppingListColumns.Scan(rows)
+		if err != nil {
+			slog.Error("Failed to get list object from rows", "error", err)
+			return types.ShoppingListSpec{}, ErrFailedToCreateShoppingList
+		}
 	}
-	if shoppingList.TemplateID == "" {
-		return shoppingListInserted, nil
+	if shoppingListInserted.ID == "" {
+		return types.ShoppingListSpec{}, ErrFailedToCreateShoppingList
 	}
+	return shoppingListInserted, nil
+}
 
-	// if using other list as a template
+// copyTemplateItems ...
+// adds the items of the template of a new shopping list to it, in a single statement
+func (m *ShoppingListManager) copyTemplateItems(shoppingList types.ShoppingListSpec, options types.ShoppingItemOptions) error {
 	shoppingListItems, err := m.manager.ShoppingItem().List(shoppingList.TemplateID, options)
 	if err != nil {
-		if err := m.Delete(shoppingListInserted.ID); err != nil {
-			return types.ShoppingListSpec{}, err
-		}
-		return types.ShoppingListSpec{}, ErrFailedToGetItemsFromShoppingList
+		slog.Error("Failed to get items of template", "error", err)
+		return ErrFailedToGetItemsFromShoppingList
 	}
-
+	newItems := []types.ShoppingItemSpec{}
 	for _, item := range shoppingListItems {
-		newItem := types.ShoppingItemSpec{
+		newItems = append(newItems, types.ShoppingItemSpec{
 			Name:       item.Name,
 			Notes:      item.Notes,
 			Price:      item.Price,
@@ -267,17 +404,14 @@ func (m *ShoppingListManager) Create(shoppingList types.ShoppingListSpec, option
 			Author:     shoppingList.Author,
 			AuthorLast: shoppingList.Author,
 			TemplateID: shoppingList.TemplateID,
-		}
-		_, err := m.manager.ShoppingItem().AddItemToList(shoppingListInserted.ID, newItem)
-		if err != nil {
-			slog.Error("Failed to add item to list", "error", err)
-			if err := m.Delete(shoppingListInserted.ID); err != nil {
-				return types.ShoppingListSpec{}, err
-			}
-			return types.ShoppingListSpec{}, ErrFailedToAddItemToShoppingListFromTemplate
-		}
+		})
 	}
-	return shoppingListInserted, nil
+	// the list is new, so nothing is subscribed to events of it's items yet
+	if _, err := m.manager.ShoppingItem().insertItems(shoppingList.ID, newItems); err != nil {
+		slog.Error("Failed to add items from template", "error", err)
+		return ErrFailedToAddItemToShoppingListFromTemplate
+	}
+	return nil
 }
 
 // Patch ...
@@ -296,9 +430,9 @@ func (m *ShoppingListManager) Patch(listID string, shoppingList types.ShoppingLi
 		return types.ShoppingListSpec{}, err
 	}
 
-	sqlStatement := `update shopping_list set name = $1, notes = $2, authorLast = $3, completed = $4, total_tag_exclude = $5, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $6
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.AuthorLast, shoppingList.Completed, pq.Array(shoppingList.TotalTagExclude), listID)
+	sqlStatement := fmt.Sprintf(`update shopping_list set name = $1, notes = $2, authorLast = $3, completed = $4, total_tag_exclude = $5, modificationTimestamp = %v where id = $6 and flatId = $7
+                         returning %v`, database.DialectOf(m.manager.db).Now(), ShoppingListColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.AuthorLast, shoppingList.Completed, pq.Array(shoppingList.TotalTagExclude), listID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
@@ -308,7 +442,7 @@ func (m *ShoppingListManager) Patch(listID string, shoppingList types.ShoppingLi
 		}
 	}()
 	rows.Next()
-	shoppingListPatched, err = getListObjectFromRows(rows)
+	shoppingListPatched, err = ShoppingListColumns.Scan(rows)
 	if err != nil || shoppingListPatched.ID == "" {
 		slog.Error("Failed to get shopping list from rows", "error", err)
 		return types.ShoppingListSpec{}, ErrFailedToPatchShoppingList
@@ -324,9 +458,10 @@ func (m *ShoppingListManager) Update(listID string, shoppingList types.ShoppingL
 		return types.ShoppingListSpec{}, err
 	}
 
-	sqlStatement := `update shopping_list set name = $1, notes = $2, authorLast = $3, completed = $4, total_tag_exclude = $5::text[], modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $6
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.AuthorLast, shoppingList.Completed, pq.Array(shoppingList.TotalTagExclude), listID)
+	dialect := database.DialectOf(m.manager.db)
+	sqlStatement := fmt.Sprintf(`update shopping_list set name = $1, notes = $2, authorLast = $3, completed = $4, total_tag_exclude = %v, modificationTimestamp = %v where id = $6 and flatId = $7
+                         returning %v`, dialect.Cast(`$5`, `text[]`), dialect.Now(), ShoppingListColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, shoppingList.Name, shoppingList.Notes, shoppingList.AuthorLast, shoppingList.Completed, pq.Array(shoppingList.TotalTagExclude), listID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
@@ -336,7 +471,7 @@ func (m *ShoppingListManager) Update(listID string, shoppingList types.ShoppingL
 		}
 	}()
 	rows.Next()
-	shoppingListUpdated, err = getListObjectFromRows(rows)
+	shoppingListUpdated, err = ShoppingListColumns.Scan(rows)
 	if err != nil || shoppingListUpdated.ID == "" {
 		slog.Error("Failed to get shopping list from rows", "error", err)
 		return types.ShoppingListSpec{}, ErrFailedToCreateShoppingList
@@ -347,8 +482,8 @@ func (m *ShoppingListManager) Update(listID string, shoppingList types.ShoppingL
 // SetListCompleted ...
 // updates the list's completed field
 func (m *ShoppingListManager) SetListCompleted(listID string, completed bool, userID string) (list types.ShoppingListSpec, err error) {
-	sqlStatement := `update shopping_list set completed = $1 where id = $2 returning *`
-	rows, err := m.db.Query(sqlStatement, completed, listID)
+	sqlStatement := fmt.Sprintf(`update shopping_list set completed = $1 where id = $2 and flatId = $3 returning %v`, ShoppingListColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, completed, listID, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingListSpec{}, err
 	}
@@ -358,7 +493,7 @@ func (m *ShoppingListManager) SetListCompleted(listID string, completed bool, us
 		}
 	}()
 	for rows.Next() {
-		list, err = getListObjectFromRows(rows)
+		list, err = ShoppingListColumns.Scan(rows)
 		if err != nil {
 			return types.ShoppingListSpec{}, err
 		}
@@ -367,44 +502,72 @@ func (m *ShoppingListManager) SetListCompleted(listID string, completed bool, us
 	return list, nil
 }
 
-// getListObjectFromRows ...
-// returns a shopping list object from rows
-func getListObjectFromRows(rows *sql.Rows) (list types.ShoppingListSpec, err error) {
-	if err := rows.Scan(&list.ID, &list.Name, &list.Notes, &list.Author, &list.AuthorLast, &list.Completed, &list.CreationTimestamp, &list.ModificationTimestamp, &list.DeletionTimestamp, &list.TemplateID, pq.Array(&list.TotalTagExclude)); err != nil {
-		return types.ShoppingListSpec{}, err
-	}
-	err = rows.Err()
-	if err != nil {
-		return types.ShoppingListSpec{}, err
-	}
-	return list, nil
+// ShoppingListColumns ...
+// the columns of shopping lists, along with the fields of a ShoppingListSpec which they are scanned into
+var ShoppingListColumns = database.Columns[types.ShoppingListSpec]{
+	{Name: "id", Field: func(list *types.ShoppingListSpec) any { return &list.ID }},
+	{Name: "name", Field: func(list *types.ShoppingListSpec) any { return &list.Name }},
+	{Name: "notes", Field: func(list *types.ShoppingListSpec) any { return &list.Notes }},
+	{Name: "author", Field: func(list *types.ShoppingListSpec) any { return &list.Author }},
+	{Name: "authorLast", Field: func(list *types.ShoppingListSpec) any { return &list.AuthorLast }},
+	{Name: "completed", Field: func(list *types.ShoppingListSpec) any { return &list.Completed }},
+	{Name: "creationTimestamp", Field: func(list *types.ShoppingListSpec) any { return &list.CreationTimestamp }},
+	{Name: "modificationTimestamp", Field: func(list *types.ShoppingListSpec) any { return &list.ModificationTimestamp }},
+	{Name: "deletionTimestamp", Field: func(list *types.ShoppingListSpec) any { return &list.DeletionTimestamp }},
+	{Name: "templateId", Field: func(list *types.ShoppingListSpec) any { return &list.TemplateID }},
+	{Name: "total_tag_exclude", Field: func(list *types.ShoppingListSpec) any { return pq.Array(&list.TotalTagExclude) }},
+	{Name: "flatId", Field: func(list *types.ShoppingListSpec) any { return &list.FlatID }},
 }
 
 // DeleteShoppingList ...
-// deletes a shopping list, given a shopping list Id
+// deletes a shopping list and it's items together, given a shopping list Id
 func (m *ShoppingListManager) Delete(listID string) (err error) {
-	err = m.manager.ShoppingItem().DeleteAll(listID)
-	if err != nil {
-		return ErrFailedToRemoveAllItemsFromList
-	}
-	sqlStatement := `delete from shopping_list where id = $1`
-	rows, err := m.db.Query(sqlStatement, listID)
+	return m.manager.InTransaction(func(m *Manager) error {
+		if err := m.ShoppingItem().DeleteAll(listID); err != nil {
+			return ErrFailedToRemoveAllItemsFromList
+		}
+		sqlStatement := `delete from shopping_list where id = $1 and flatId = $2`
+		if _, err := m.queryer().ExecContext(m.ctx, sqlStatement, listID, m.flatID); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// GetListCount ...
+// returns a count lists
+func (m *ShoppingListManager) GetListCount() (count int, err error) {
+	sqlStatement := `select count(*) from shopping_list where flatId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
 			slog.Error("failed to close rows", "error", err)
 		}
 	}()
-	return nil
+	rows.Next()
+	if err := rows.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
-// GetListCount ...
-// returns a count lists
-func (m *ShoppingListManager) GetListCount() (count int, err error) {
-	sqlStatement := `select count(*) from shopping_list`
-	rows, err := m.db.Query(sqlStatement)
+// GetTotal ...
+// returns the total price of the items in a list, excluding items with tags in the list's TotalTagExclude
+func (m *ShoppingListManager) GetTotal(listID string) (total float64, err error) {
+	list, err := m.Get(listID)
+	if err != nil || list.ID == "" {
+		return 0, ErrFailedToGetExistingShoppingList
+	}
+	if list.TotalTagExclude == nil {
+		list.TotalTagExclude = []string{}
+	}
+	dialect := database.DialectOf(m.manager.db)
+	sqlStatement := fmt.Sprintf(`select coalesce(sum(price * quantity), 0) from shopping_item
+                         where listId = $1 and not (%v)`, dialect.ArrayContains(dialect.Cast(`$2`, `text[]`), `coalesce(tag, '')`))
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, list.ID, pq.Array(list.TotalTagExclude))
 	if err != nil {
 		return 0, err
 	}
@@ -414,80 +577,88 @@ func (m *ShoppingListManager) GetListCount() (count int, err error) {
 		}
 	}()
 	rows.Next()
-	if err := rows.Scan(&count); err != nil {
+	if err := rows.Scan(&total); err != nil {
 		return 0, err
 	}
-	return count, nil
+	return total, nil
 }
 
 // DeleteCleanup ...
-// cleans up shopping lists older than policy
-func (m *ShoppingListManager) DeleteCleanup() (string, func() error) {
-	return types.CronTabScheduleShoppingListCleanup, func() error {
-		policy, err := m.manager.settingsManager.GetShoppingListKeepPolicy()
-		if err != nil {
-			return err
-		}
-		timestamp := time.Now()
-		limit := -1
-		switch policy {
-		case types.ShoppingListKeepPolicyThreeMonths:
-			timestamp = timestamp.AddDate(0, -3, 0)
-		case types.ShoppingListKeepPolicySixMonths:
-			timestamp = timestamp.AddDate(0, -6, 0)
-		case types.ShoppingListKeepPolicyOneYear:
-			timestamp = timestamp.AddDate(-1, 0, 0)
-		case types.ShoppingListKeepPolicyTwoYears:
-			timestamp = timestamp.AddDate(-2, 0, 0)
-		case types.ShoppingListKeepPolicyLast10:
-			limit = 10
-		case types.ShoppingListKeepPolicyLast50:
-			limit = 50
-		case types.ShoppingListKeepPolicyLast100:
-			limit = 100
-		default:
-			return nil
+// cleans up shopping lists older than the policy of the flat,
+// along with the attachments of deleted lists and items
+func (m *ShoppingListManager) DeleteCleanup() error {
+	if err := m.deleteOld(); err != nil {
+		return err
+	}
+	return m.manager.ShoppingAttachment().DeleteOrphaned()
+}
+
+// deleteOld ...
+// deletes the shopping lists older than the policy of the flat
+func (m *ShoppingListManager) deleteOld() error {
+	policy, err := m.manager.settingsManager.GetShoppingListKeepPolicy()
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	limit := -1
+	switch policy {
+	case types.ShoppingListKeepPolicyThreeMonths:
+		timestamp = timestamp.AddDate(0, -3, 0)
+	case types.ShoppingListKeepPolicySixMonths:
+		timestamp = timestamp.AddDate(0, -6, 0)
+	case types.ShoppingListKeepPolicyOneYear:
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	case types.ShoppingListKeepPolicyTwoYears:
+		timestamp = timestamp.AddDate(-2, 0, 0)
+	case types.ShoppingListKeepPolicyLast10:
+		limit = 10
+	case types.ShoppingListKeepPolicyLast50:
+		limit = 50
+	case types.ShoppingListKeepPolicyLast100:
+		limit = 100
+	default:
+		return nil
+	}
+	lists, err := m.List(types.ShoppingListOptions{
+		Selector: types.ShoppingListSelector{
+			ModificationTimestampBefore: timestamp.Unix(),
+		},
+		SortBy: types.ShoppingListSortByLastUpdated,
+	})
+	if err != nil {
+		return err
+	}
+	if limit != -1 {
+		removeAmount := len(lists) - limit
+		if len(lists) < limit {
+			removeAmount = 0
 		}
-		lists, err := m.List(types.ShoppingListOptions{
-			Selector: types.ShoppingListSelector{
-				ModificationTimestampBefore: timestamp.Unix(),
-			},
-			SortBy: types.ShoppingListSortByLastUpdated,
-		})
-		if err != nil {
+		lists = lists[:removeAmount]
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+	slog.Info("Shopping List Cleanup", "message", fmt.Sprintf("Deleting old %v lists with policy %v", len(lists), policy))
+	listIDs := []string{}
+	for _, list := range lists {
+		if err := m.Delete(list.ID); err != nil {
 			return err
 		}
-		if limit != -1 {
-			removeAmount := len(lists) - limit
-			if len(lists) < limit {
-				removeAmount = 0
-			}
-			lists = lists[:removeAmount]
-		}
-		if len(lists) == 0 {
-			return nil
-		}
-		slog.Info("Shopping List Cleanup", "message", fmt.Sprintf("Deleting old %v lists with policy %v", len(lists), policy))
-		listIDs := []string{}
-		for _, list := range lists {
-			if err := m.Delete(list.ID); err != nil {
-				return err
-			}
-			listIDs = append(listIDs, list.ID)
-		}
-		slog.Info("Shopping List Cleanup", "message", fmt.Sprintf("Deleting old %v lists cleaned up", len(lists)), "lists", listIDs)
-		return nil
+		listIDs = append(listIDs, list.ID)
 	}
+	slog.Info("Shopping List Cleanup", "message", fmt.Sprintf("Deleting old %v lists cleaned up", len(lists)), "lists", listIDs)
+	return nil
 }
 
 // UntemplateListsFromDeletedLists ...
 // removed templateid from shopping items where the list has been deleted
 func (m *ShoppingListManager) UntemplateListsFromDeletedLists() error {
-	sqlStatement := `update shopping_list a
+	sqlStatement := `update shopping_list as a
                            set templateid = ''
                          where not exists (select * from shopping_list b where b.id = a.templateid)
                          and templateid <> ''`
-	res, err := m.db.Exec(sqlStatement)
+	res, err := m.db.ExecContext(m.ctx, sqlStatement)
 	if err != nil {
 		return err
 	}
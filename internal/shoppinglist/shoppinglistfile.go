@@ -0,0 +1,317 @@
+/*
+  shoppinglist
+    list file
+      export and import shopping lists as CSV or JSON files
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package shoppinglist
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+// ShoppingListImportMaxItems is the most items which are able to be imported at once
+const ShoppingListImportMaxItems = 500
+
+var (
+	ErrShoppingListFileFormatInvalid    = fmt.Errorf("Unable to use file format, as it must be either 'csv' or 'json'")
+	ErrShoppingListFileInvalid          = fmt.Errorf("Unable to read shopping list file")
+	ErrShoppingListFileNameColumn       = fmt.Errorf("Unable to read shopping list file, as it has no name column")
+	ErrShoppingListImportTooManyItems   = fmt.Errorf("Unable to import shopping list file, as it has too many items")
+	ErrShoppingListImportInvalidRows    = fmt.Errorf("Unable to import shopping list file, as some rows are invalid")
+	ErrShoppingListImportInvalidNumber  = fmt.Errorf("Unable to use the provided number")
+	ErrShoppingListImportInvalidBoolean = fmt.Errorf("Unable to use the provided obtained value, as it must be true or false")
+)
+
+// shoppingListFileColumns ...
+// the columns of an exported CSV file, in order
+var shoppingListFileColumns = []string{"name", "quantity", "price", "tag", "notes", "obtained"}
+
+// ParseShoppingListFileFormat ...
+// returns the file format for a name, defaulting to JSON
+func ParseShoppingListFileFormat(format string) (types.ShoppingListFileFormat, error) {
+	switch types.ShoppingListFileFormat(strings.ToLower(format)) {
+	case types.ShoppingListFileFormatCSV:
+		return types.ShoppingListFileFormatCSV, nil
+	case types.ShoppingListFileFormatJSON, "":
+		return types.ShoppingListFileFormatJSON, nil
+	}
+	return "", ErrShoppingListFileFormatInvalid
+}
+
+// Export ...
+// returns the items of a shopping list as they are exported
+func (m *ShoppingItemManager) Export(listID string) (items []types.ShoppingItemFileSpec, err error) {
+	list, err := m.manager.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		return []types.ShoppingItemFileSpec{}, ErrFailedToGetExistingShoppingList
+	}
+	listItems, err := m.List(list.ID, types.ShoppingItemOptions{})
+	if err != nil {
+		return []types.ShoppingItemFileSpec{}, err
+	}
+	items = []types.ShoppingItemFileSpec{}
+	for _, item := range listItems {
+		items = append(items, types.ShoppingItemFileSpec{
+			Name:     item.Name,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+			Tag:      item.Tag,
+			Notes:    item.Notes,
+			Obtained: item.Obtained,
+		})
+	}
+	return items, nil
+}
+
+// WriteShoppingListFile ...
+// writes the items of a shopping list as a file
+func WriteShoppingListFile(w io.Writer, format types.ShoppingListFileFormat, items []types.ShoppingItemFileSpec) error {
+	if format == types.ShoppingListFileFormatJSON {
+		return json.NewEncoder(w).Encode(items)
+	}
+	writer := csv.NewWriter(w)
+	if err := writer.Write(shoppingListFileColumns); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := writer.Write([]string{
+			item.Name,
+			strconv.Itoa(item.Quantity),
+			strconv.FormatFloat(item.Price, 'f', -1, 64),
+			item.Tag,
+			item.Notes,
+			strconv.FormatBool(item.Obtained),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// shoppingListFileRow ...
+// an item read from a file, or why it is unable to be read.
+// Rows are numbered by their line in CSV files and their position in JSON files, from one
+type shoppingListFileRow struct {
+	row  int
+	item types.ShoppingItemFileSpec
+	err  error
+}
+
+// readShoppingListCSVRow ...
+// returns the item in a row of a CSV file, given the position of each column
+func readShoppingListCSVRow(record []string, columns map[string]int) (item types.ShoppingItemFileSpec, err error) {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	item.Name = field("name")
+	item.Tag = field("tag")
+	item.Notes = field("notes")
+	item.Quantity = 1
+	if quantity := field("quantity"); quantity != "" {
+		if item.Quantity, err = strconv.Atoi(quantity); err != nil {
+			return item, fmt.Errorf("%w: quantity '%v'", ErrShoppingListImportInvalidNumber, quantity)
+		}
+	}
+	if price := strings.TrimPrefix(field("price"), "$"); price != "" {
+		if item.Price, err = strconv.ParseFloat(price, 64); err != nil {
+			return item, fmt.Errorf("%w: price '%v'", ErrShoppingListImportInvalidNumber, price)
+		}
+	}
+	if obtained := field("obtained"); obtained != "" {
+		if item.Obtained, err = strconv.ParseBool(obtained); err != nil {
+			return item, ErrShoppingListImportInvalidBoolean
+		}
+	}
+	return item, nil
+}
+
+// readShoppingListFile ...
+// reads the rows of a file, where CSV files name their columns in the first row
+func readShoppingListFile(r io.Reader, format types.ShoppingListFileFormat) (rows []shoppingListFileRow, err error) {
+	if format == types.ShoppingListFileFormatJSON {
+		items := []types.ShoppingItemFileSpec{}
+		if err := json.NewDecoder(r).Decode(&items); err != nil {
+			return []shoppingListFileRow{}, fmt.Errorf("%w: %w", ErrShoppingListFileInvalid, err)
+		}
+		for i, item := range items {
+			if item.Quantity == 0 {
+				item.Quantity = 1
+			}
+			rows = append(rows, shoppingListFileRow{row: i + 1, item: item})
+		}
+		return rows, nil
+	}
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return []shoppingListFileRow{}, fmt.Errorf("%w: %w", ErrShoppingListFileInvalid, err)
+	}
+	// spreadsheets may begin files with a byte order mark
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return []shoppingListFileRow{}, ErrShoppingListFileNameColumn
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return []shoppingListFileRow{}, fmt.Errorf("%w: %w", ErrShoppingListFileInvalid, err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		item, err := readShoppingListCSVRow(record, columns)
+		rows = append(rows, shoppingListFileRow{row: line, item: item, err: err})
+	}
+	return rows, nil
+}
+
+// validateImport ...
+// returns the items of a file to add to a list, along with the rows which are invalid
+func (m *ShoppingItemManager) validateImport(rows []shoppingListFileRow, author string) (items []types.ShoppingItemSpec, rowErrors []types.ShoppingListImportRowError, err error) {
+	if len(rows) > ShoppingListImportMaxItems {
+		return []types.ShoppingItemSpec{}, []types.ShoppingListImportRowError{}, fmt.Errorf("%w (%v of at most %v)", ErrShoppingListImportTooManyItems, len(rows), ShoppingListImportMaxItems)
+	}
+	rowErrors = []types.ShoppingListImportRowError{}
+	for _, row := range rows {
+		item := types.ShoppingItemSpec{
+			Name:     row.item.Name,
+			Quantity: row.item.Quantity,
+			Price:    row.item.Price,
+			Tag:      row.item.Tag,
+			Notes:    row.item.Notes,
+			Obtained: row.item.Obtained,
+			Author:   author,
+		}
+		err := row.err
+		if err == nil {
+			_, err = m.Validate(item)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, types.ShoppingListImportRowError{
+				Row:   row.row,
+				Name:  row.item.Name,
+				Error: err.Error(),
+			})
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, rowErrors, nil
+}
+
+// Import ...
+// adds the items of a file to an existing shopping list.
+// When dryRun is set, or any row is invalid, nothing is added and the invalid rows are returned
+func (m *ShoppingItemManager) Import(listID string, r io.Reader, format types.ShoppingListFileFormat, author string, dryRun bool) (result types.ShoppingListImportSpec, err error) {
+	list, err := m.manager.ShoppingList().Get(listID)
+	if err != nil || list.ID == "" {
+		return types.ShoppingListImportSpec{}, ErrFailedToGetExistingShoppingList
+	}
+	rows, err := readShoppingListFile(r, format)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	items, rowErrors, err := m.validateImport(rows, author)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	result = types.ShoppingListImportSpec{
+		List:   list,
+		DryRun: dryRun,
+		Items:  len(items),
+		Errors: rowErrors,
+	}
+	if len(rowErrors) > 0 {
+		return result, ErrShoppingListImportInvalidRows
+	}
+	if dryRun {
+		return result, nil
+	}
+	if _, err := m.AddItemsToList(list.ID, items); err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	result.List, err = m.manager.ShoppingList().Get(list.ID)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	return result, nil
+}
+
+// Import ...
+// creates a shopping list from the items of a file.
+// When dryRun is set, or the list or any row is invalid, nothing is created and the invalid rows are returned
+func (m *ShoppingListManager) Import(shoppingList types.ShoppingListSpec, r io.Reader, format types.ShoppingListFileFormat, dryRun bool) (result types.ShoppingListImportSpec, err error) {
+	shoppingList.TemplateID = ""
+	if _, err := m.Validate(shoppingList); err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	rows, err := readShoppingListFile(r, format)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	items, rowErrors, err := m.manager.ShoppingItem().validateImport(rows, shoppingList.Author)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	result = types.ShoppingListImportSpec{
+		List:   shoppingList,
+		DryRun: dryRun,
+		Items:  len(items),
+		Errors: rowErrors,
+	}
+	if len(rowErrors) > 0 {
+		return result, ErrShoppingListImportInvalidRows
+	}
+	if dryRun {
+		return result, nil
+	}
+	var listCreated types.ShoppingListSpec
+	if err := m.manager.InTransaction(func(m *Manager) error {
+		listCreated, err = m.ShoppingList().Create(shoppingList, types.ShoppingItemOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = m.ShoppingItem().AddItemsToList(listCreated.ID, items)
+		return err
+	}); err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	result.List, err = m.Get(listCreated.ID)
+	if err != nil {
+		return types.ShoppingListImportSpec{}, err
+	}
+	return result, nil
+}
@@ -20,22 +20,25 @@
 package shoppinglist
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
 	"log/slog"
 
+	"gitlab.com/flattrack/flattrack/internal/database"
 	"gitlab.com/flattrack/flattrack/pkg/types"
 )
 
 type ShoppingTagManager struct {
 	manager *Manager
-	db      *sql.DB
+	db      queryer
+	ctx     context.Context
 }
 
 func (m *Manager) ShoppingTag() *ShoppingTagManager {
 	return &ShoppingTagManager{
 		manager: m,
-		db:      m.db,
+		db:      m.queryer(),
+		ctx:     m.ctx,
 	}
 }
 
@@ -57,10 +60,10 @@ func (m *ShoppingTagManager) Create(newTag types.ShoppingTag) (tag types.Shoppin
 		return types.ShoppingTag{}, err
 	}
 	newTag.AuthorLast = newTag.Author
-	sqlStatement := `insert into shopping_list_tag (name, author, authorLast)
-                         values ($1, $2, $3)
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, newTag.Name, newTag.Author, newTag.AuthorLast)
+	sqlStatement := fmt.Sprintf(`insert into shopping_list_tag (name, author, authorLast, flatId)
+                         values ($1, $2, $3, $4)
+                         returning %v`, ShoppingTagColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, newTag.Name, newTag.Author, newTag.AuthorLast, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -71,7 +74,7 @@ func (m *ShoppingTagManager) Create(newTag types.ShoppingTag) (tag types.Shoppin
 	}()
 	rows.Next()
 
-	tag, err = getTagObjectFromRows(rows)
+	tag, err = ShoppingTagColumns.Scan(rows)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -81,8 +84,8 @@ func (m *ShoppingTagManager) Create(newTag types.ShoppingTag) (tag types.Shoppin
 // ListTagsInList ...
 // returns a list of tags used in items in a list
 func (m *ShoppingTagManager) ListTagsInList(listID string) (tags []string, err error) {
-	sqlStatement := `select distinct tag from shopping_item where listId = $1 order by tag`
-	rows, err := m.db.Query(sqlStatement, listID)
+	sqlStatement := `select distinct tag from shopping_item where listId = $1 and listId in (select id from shopping_list where flatId = $2) order by tag`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, m.manager.flatID)
 	if err != nil {
 		return []string{}, err
 	}
@@ -104,8 +107,8 @@ func (m *ShoppingTagManager) ListTagsInList(listID string) (tags []string, err e
 // GetInList ...
 // returns a tags used in items in a list
 func (m *ShoppingTagManager) GetInList(listID string, tag string) (tagInDB string, err error) {
-	sqlStatement := `select tag from shopping_item where listId = $1 and tag = $2`
-	rows, err := m.db.Query(sqlStatement, listID, tag)
+	sqlStatement := `select tag from shopping_item where listId = $1 and tag = $2 and listId in (select id from shopping_list where flatId = $3)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, tag, m.manager.flatID)
 	if err != nil {
 		return "", err
 	}
@@ -137,8 +140,8 @@ func (m *ShoppingTagManager) UpdateInList(listID string, tag string, tagUpdate s
 	if !valid {
 		return "", ErrInvalidShoppingItemTag
 	}
-	sqlStatement := `update shopping_item set tag = $3 where listId = $1 and tag = $2 returning tag`
-	rows, err := m.db.Query(sqlStatement, listID, tag, tagUpdate)
+	sqlStatement := fmt.Sprintf(`update shopping_item set tag = $3 where listId = $1 and tag = $2 and listId in (select id from shopping_list where flatId = $4) returning %v`, ShoppingItemColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, listID, tag, tagUpdate, m.manager.flatID)
 	if err != nil {
 		return "", err
 	}
@@ -147,18 +150,28 @@ func (m *ShoppingTagManager) UpdateInList(listID string, tag string, tagUpdate s
 			slog.Error("failed to close rows", "error", err)
 		}
 	}()
-	rows.Next()
-	if err := rows.Scan(&tagNew); err != nil {
-		return "", err
+	items := []types.ShoppingItemSpec{}
+	for rows.Next() {
+		item, err := ShoppingItemColumns.Scan(rows)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return "", ErrFailedToFindShoppingTagToUpdate
 	}
-	return tagNew, nil
+	for _, item := range items {
+		m.manager.ShoppingItemEvent().publish(types.ShoppingItemEventTypeUpdated, item)
+	}
+	return items[0].Tag, nil
 }
 
 // Get ...
 // returns a tag, given an id
 func (m *ShoppingTagManager) Get(id string) (tag types.ShoppingTag, err error) {
-	sqlStatement := `select * from shopping_list_tag where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := fmt.Sprintf(`select %v from shopping_list_tag where id = $1 and flatId = $2`, ShoppingTagColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -168,7 +181,7 @@ func (m *ShoppingTagManager) Get(id string) (tag types.ShoppingTag, err error) {
 		}
 	}()
 	rows.Next()
-	tag, err = getTagObjectFromRows(rows)
+	tag, err = ShoppingTagColumns.Scan(rows)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -178,32 +191,32 @@ func (m *ShoppingTagManager) Get(id string) (tag types.ShoppingTag, err error) {
 // List ...
 // returns a list of all tags used in items across lists
 func (m *ShoppingTagManager) List(options types.ShoppingTagOptions) (tags []types.ShoppingTag, err error) {
-	sqlStatement := `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by name asc`
+	sqlStatement := fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by name asc`, ShoppingTagColumns)
 	switch options.SortBy {
 	case types.ShoppingTagSortByRecentlyUpdated:
-		sqlStatement = `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by modificationTimestamp desc`
+		sqlStatement = fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by modificationTimestamp desc`, ShoppingTagColumns)
 	case types.ShoppingTagSortByLastUpdated:
-		sqlStatement = `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by modificationTimestamp asc`
+		sqlStatement = fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by modificationTimestamp asc`, ShoppingTagColumns)
 	case types.ShoppingTagSortByLastAdded:
-		sqlStatement = `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by creationTimestamp asc`
+		sqlStatement = fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by creationTimestamp asc`, ShoppingTagColumns)
 	case types.ShoppingTagSortByAlphabeticalDescending:
-		sqlStatement = `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by name asc`
+		sqlStatement = fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by name asc`, ShoppingTagColumns)
 	case types.ShoppingTagSortByAlphabeticalAscending:
-		sqlStatement = `select * from shopping_list_tag
-                         where deletionTimestamp = 0
-	                 order by name desc`
+		sqlStatement = fmt.Sprintf(`select %v from shopping_list_tag
+                         where flatId = $1 and deletionTimestamp = 0
+	                 order by name desc`, ShoppingTagColumns)
 	}
-	rows, err := m.db.Query(sqlStatement)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
 	if err != nil {
 		return []types.ShoppingTag{}, err
 	}
@@ -213,7 +226,7 @@ func (m *ShoppingTagManager) List(options types.ShoppingTagOptions) (tags []type
 		}
 	}()
 	for rows.Next() {
-		tag, err := getTagObjectFromRows(rows)
+		tag, err := ShoppingTagColumns.Scan(rows)
 		if err != nil {
 			return []types.ShoppingTag{}, err
 		}
@@ -239,8 +252,8 @@ func (m *ShoppingTagManager) Update(id string, tag types.ShoppingTag) (tagUpdate
 	if tag.Name != "" && len(tag.Name) == 0 || len(tag.Name) > 30 {
 		return types.ShoppingTag{}, fmt.Errorf("Unable to use the provided tag, as it is either empty or too long or too short")
 	}
-	sqlStatement := `update shopping_list_tag set name = $2, authorLast = $3, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $1 returning *`
-	rows, err := m.db.Query(sqlStatement, id, tag.Name, tag.AuthorLast)
+	sqlStatement := fmt.Sprintf(`update shopping_list_tag set name = $2, authorLast = $3, modificationTimestamp = %v where id = $1 and flatId = $4 returning %v`, database.DialectOf(m.manager.db).Now(), ShoppingTagColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, tag.Name, tag.AuthorLast, m.manager.flatID)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -250,7 +263,7 @@ func (m *ShoppingTagManager) Update(id string, tag types.ShoppingTag) (tagUpdate
 		}
 	}()
 	rows.Next()
-	tagUpdated, err = getTagObjectFromRows(rows)
+	tagUpdated, err = ShoppingTagColumns.Scan(rows)
 	if err != nil {
 		return types.ShoppingTag{}, err
 	}
@@ -260,8 +273,8 @@ func (m *ShoppingTagManager) Update(id string, tag types.ShoppingTag) (tagUpdate
 // Delete ...
 // deletes a shopping tag
 func (m *ShoppingTagManager) Delete(id string) (err error) {
-	sqlStatement := `delete from shopping_list_tag where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := `delete from shopping_list_tag where id = $1 and flatId = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
 	if err != nil {
 		return err
 	}
@@ -273,15 +286,15 @@ func (m *ShoppingTagManager) Delete(id string) (err error) {
 	return nil
 }
 
-// getTagObjectFromRows ...
-// returns a shopping tag object from rows
-func getTagObjectFromRows(rows *sql.Rows) (tag types.ShoppingTag, err error) {
-	if err := rows.Scan(&tag.ID, &tag.Name, &tag.Author, &tag.AuthorLast, &tag.CreationTimestamp, &tag.ModificationTimestamp, &tag.DeletionTimestamp); err != nil {
-		return types.ShoppingTag{}, err
-	}
-	err = rows.Err()
-	if err != nil {
-		return types.ShoppingTag{}, err
-	}
-	return tag, nil
+// ShoppingTagColumns ...
+// the columns of shopping tags, along with the fields of a ShoppingTag which they are scanned into
+var ShoppingTagColumns = database.Columns[types.ShoppingTag]{
+	{Name: "id", Field: func(tag *types.ShoppingTag) any { return &tag.ID }},
+	{Name: "name", Field: func(tag *types.ShoppingTag) any { return &tag.Name }},
+	{Name: "author", Field: func(tag *types.ShoppingTag) any { return &tag.Author }},
+	{Name: "authorLast", Field: func(tag *types.ShoppingTag) any { return &tag.AuthorLast }},
+	{Name: "creationTimestamp", Field: func(tag *types.ShoppingTag) any { return &tag.CreationTimestamp }},
+	{Name: "modificationTimestamp", Field: func(tag *types.ShoppingTag) any { return &tag.ModificationTimestamp }},
+	{Name: "deletionTimestamp", Field: func(tag *types.ShoppingTag) any { return &tag.DeletionTimestamp }},
+	{Name: "flatId", Field: func(tag *types.ShoppingTag) any { return &tag.FlatID }},
 }
@@ -19,6 +19,7 @@
 package system
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"log/slog"
@@ -28,18 +29,29 @@ import (
 
 // Manager for system configuration
 type Manager struct {
-	db *sql.DB
+	db  *sql.DB
+	ctx context.Context
 }
 
 func NewManager(db *sql.DB) *Manager {
 	return &Manager{
-		db: db,
+		db:  db,
+		ctx: context.Background(),
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:  m.db,
+		ctx: ctx,
 	}
 }
 
 func (m *Manager) getValue(name string) (output string, err error) {
 	sqlStatement := `select value from system where name = $1`
-	rows, err := m.db.Query(sqlStatement, name)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, name)
 	if err != nil {
 		return "", err
 	}
@@ -58,7 +70,7 @@ func (m *Manager) getValue(name string) (output string, err error) {
 
 func (m *Manager) setValue(name, value string) (err error) {
 	sqlStatement := `update system set value = $2 where name = $1`
-	rows, err := m.db.Query(sqlStatement, name, value)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, name, value)
 	if err != nil {
 		return err
 	}
@@ -70,22 +82,6 @@ func (m *Manager) setValue(name, value string) (err error) {
 	return nil
 }
 
-// GetHasInitialized ...
-// return if the FlatTrack instance has initialized
-func (m *Manager) GetHasInitialized() (bool, error) {
-	val, err := m.getValue("initialized")
-	if err != nil {
-		return false, err
-	}
-	return val == "true", nil
-}
-
-// SetHasInitialized ...
-// set if the FlatTrack instance has been initialized
-func (m *Manager) SetHasInitialized() (err error) {
-	return m.setValue("initialized", "true")
-}
-
 // GetJWTsecret ...
 // return the JWT secret, used in authentication
 func (m *Manager) GetJWTsecret() (string, error) {
@@ -111,6 +107,30 @@ func (m *Manager) GetSchedulerLastRun() (types.SchedulerLastRun, error) {
 	return lastRun, nil
 }
 
+// GetBackupLastRun ...
+// returns the date and state of the last scheduled backup
+func (m *Manager) GetBackupLastRun() (types.BackupLastRun, error) {
+	val, err := m.getValue("backupLastRun")
+	if err != nil {
+		return types.BackupLastRun{}, err
+	}
+	var lastRun types.BackupLastRun
+	if err := json.Unmarshal([]byte(val), &lastRun); err != nil {
+		return types.BackupLastRun{}, err
+	}
+	return lastRun, nil
+}
+
+// SetBackupLastRun ...
+// sets the date and state of the last scheduled backup
+func (m *Manager) SetBackupLastRun(lastRun types.BackupLastRun) (err error) {
+	b, err := json.Marshal(lastRun)
+	if err != nil {
+		return err
+	}
+	return m.setValue("backupLastRun", string(b))
+}
+
 // SetSchedulerLastRun ...
 // set if the FlatTrack instance has been initialized
 func (m *Manager) SetSchedulerLastRun(lastRun types.SchedulerLastRun) (err error) {
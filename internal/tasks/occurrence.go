@@ -0,0 +1,271 @@
+/*
+  tasks
+    occurrence
+      manage generated occurrences of flat tasks
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+type OccurrenceManager struct {
+	manager *Manager
+	db      *sql.DB
+	ctx     context.Context
+}
+
+func (m *Manager) Occurrence() *OccurrenceManager {
+	return &OccurrenceManager{
+		manager: m,
+		db:      m.db,
+		ctx:     m.ctx,
+	}
+}
+
+// List ...
+// returns a list of task occurrences, filtered by the selector
+func (m *OccurrenceManager) List(selector types.TaskOccurrenceSelector) (occurrences []types.TaskOccurrenceSpec, err error) {
+	sqlStatement := `select * from task_occurrence where taskId in (select id from task where flatId = $1) and deletionTimestamp = 0 `
+	fields := []any{m.manager.flatID}
+
+	if selector.TaskID != "" {
+		sqlStatement += fmt.Sprintf(`and taskId = $%v `, len(fields)+1)
+		fields = append(fields, selector.TaskID)
+	}
+	if selector.Assignee != "" {
+		sqlStatement += fmt.Sprintf(`and %v `, database.DialectOf(m.db).ArrayContains(`assignees`, fmt.Sprintf(`$%v`, len(fields)+1)))
+		fields = append(fields, selector.Assignee)
+	}
+	switch selector.Completed {
+	case "true":
+		sqlStatement += `and completed = true `
+	case "false":
+		sqlStatement += `and completed = false `
+	}
+	if selector.DueTimestampAfter != 0 {
+		sqlStatement += fmt.Sprintf(`and dueTimestamp > $%v `, len(fields)+1)
+		fields = append(fields, selector.DueTimestampAfter)
+	}
+	if selector.DueTimestampBefore != 0 {
+		sqlStatement += fmt.Sprintf(`and dueTimestamp < $%v `, len(fields)+1)
+		fields = append(fields, selector.DueTimestampBefore)
+	}
+	sqlStatement += `order by dueTimestamp desc`
+
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, fields...)
+	if err != nil {
+		return []types.TaskOccurrenceSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		occurrence, err := getOccurrenceObjectFromRows(rows)
+		if err != nil {
+			return []types.TaskOccurrenceSpec{}, err
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+// Get ...
+// returns an occurrence of a task, by it's ID
+func (m *OccurrenceManager) Get(taskID string, id string) (occurrence types.TaskOccurrenceSpec, err error) {
+	sqlStatement := `select * from task_occurrence where taskId = $1 and id = $2 and taskId in (select id from task where flatId = $3) and deletionTimestamp = 0`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, taskID, id, m.manager.flatID)
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		occurrence, err = getOccurrenceObjectFromRows(rows)
+		if err != nil {
+			return types.TaskOccurrenceSpec{}, err
+		}
+	}
+	return occurrence, nil
+}
+
+// GetLatest ...
+// returns the occurrence of a task which is due last
+func (m *OccurrenceManager) GetLatest(taskID string) (occurrence types.TaskOccurrenceSpec, err error) {
+	sqlStatement := `select * from task_occurrence where taskId = $1 and taskId in (select id from task where flatId = $2) and deletionTimestamp = 0 order by dueTimestamp desc limit 1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, taskID, m.manager.flatID)
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		occurrence, err = getOccurrenceObjectFromRows(rows)
+		if err != nil {
+			return types.TaskOccurrenceSpec{}, err
+		}
+	}
+	return occurrence, nil
+}
+
+// Create ...
+// adds an occurrence for a task
+func (m *OccurrenceManager) Create(occurrence types.TaskOccurrenceSpec) (occurrenceInserted types.TaskOccurrenceSpec, err error) {
+	if occurrence.Assignees == nil {
+		occurrence.Assignees = []string{}
+	}
+	sqlStatement := `insert into task_occurrence (taskId, assignees, dueTimestamp)
+                         values ($1, $2, $3)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, occurrence.TaskID, pq.Array(occurrence.Assignees), occurrence.DueTimestamp)
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	occurrenceInserted, err = getOccurrenceObjectFromRows(rows)
+	if err != nil || occurrenceInserted.ID == "" {
+		slog.Error("Failed to get task occurrence object from rows", "error", err)
+		return types.TaskOccurrenceSpec{}, ErrFailedToCreateTaskOccurrence
+	}
+	return occurrenceInserted, nil
+}
+
+// GenerateNext ...
+// creates the next occurrence of a task if there isn't one upcoming,
+// returning an empty occurrence if one wasn't needed
+func (m *OccurrenceManager) GenerateNext(task types.TaskSpec) (occurrence types.TaskOccurrenceSpec, err error) {
+	latest, err := m.GetLatest(task.ID)
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	due := time.Unix(task.StartTimestamp, 0)
+	if latest.ID != "" {
+		if task.Recurrence == types.TaskRecurrenceNever {
+			return types.TaskOccurrenceSpec{}, nil
+		}
+		now := time.Now()
+		due = time.Unix(latest.DueTimestamp, 0)
+		if due.After(now) {
+			return types.TaskOccurrenceSpec{}, nil
+		}
+		for !due.After(now) {
+			due = nextDueTimestamp(task.Recurrence, due)
+		}
+	}
+	assignees := task.Assignees
+	if task.Rotate {
+		assignee, position, err := m.manager.Rotation().Next(task)
+		if err != nil {
+			return types.TaskOccurrenceSpec{}, err
+		}
+		assignees = []string{}
+		if assignee != "" {
+			assignees = append(assignees, assignee)
+		}
+		if err := m.manager.Rotation().SetPosition(task.ID, position); err != nil {
+			return types.TaskOccurrenceSpec{}, err
+		}
+	}
+	return m.Create(types.TaskOccurrenceSpec{
+		TaskID:       task.ID,
+		Assignees:    assignees,
+		DueTimestamp: due.Unix(),
+	})
+}
+
+// SetCompleted ...
+// updates the occurrence's completed field
+func (m *OccurrenceManager) SetCompleted(taskID string, id string, completed bool, userID string) (occurrence types.TaskOccurrenceSpec, err error) {
+	completedBy := ""
+	if completed {
+		completedBy = userID
+	}
+	sqlStatement := fmt.Sprintf(`update task_occurrence
+                           set completed = $1,
+                               completedBy = $2,
+                               completedTimestamp = case when $1 then %[1]v else 0 end,
+                               modificationTimestamp = %[1]v
+                         where taskId = $3 and id = $4 and taskId in (select id from task where flatId = $5)
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, completed, completedBy, taskID, id, m.manager.flatID)
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		occurrence, err = getOccurrenceObjectFromRows(rows)
+		if err != nil {
+			return types.TaskOccurrenceSpec{}, err
+		}
+	}
+	return occurrence, nil
+}
+
+// DeleteAll ...
+// deletes all occurrences of a task
+func (m *OccurrenceManager) DeleteAll(taskID string) (err error) {
+	sqlStatement := `delete from task_occurrence where taskId = $1 and taskId in (select id from task where flatId = $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, taskID, m.manager.flatID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// getOccurrenceObjectFromRows ...
+// returns a task occurrence object from rows
+func getOccurrenceObjectFromRows(rows *sql.Rows) (occurrence types.TaskOccurrenceSpec, err error) {
+	if err := rows.Scan(&occurrence.ID, &occurrence.TaskID, pq.Array(&occurrence.Assignees), &occurrence.DueTimestamp, &occurrence.Completed, &occurrence.CompletedBy, &occurrence.CompletedTimestamp, &occurrence.CreationTimestamp, &occurrence.ModificationTimestamp, &occurrence.DeletionTimestamp); err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	err = rows.Err()
+	if err != nil {
+		return types.TaskOccurrenceSpec{}, err
+	}
+	return occurrence, nil
+}
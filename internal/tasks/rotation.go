@@ -0,0 +1,155 @@
+/*
+  tasks
+    rotation
+      manage the rotation of tasks between flatmates
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tasks
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/groups"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+type RotationManager struct {
+	manager *Manager
+	db      *sql.DB
+	ctx     context.Context
+}
+
+func (m *Manager) Rotation() *RotationManager {
+	return &RotationManager{
+		manager: m,
+		db:      m.db,
+		ctx:     m.ctx,
+	}
+}
+
+// List ...
+// returns the ordered user ids which a task rotates through.
+// Without assignees, a task rotates through all flatmembers in the order their accounts were created,
+// so renaming or adding a flatmember doesn't change who is next
+func (m *RotationManager) List(task types.TaskSpec) (rotation []string, eligible map[string]bool, err error) {
+	flatmembers, err := m.manager.users.List(false, types.UserSelector{Group: groups.GroupFlatmember})
+	if err != nil {
+		return []string{}, map[string]bool{}, err
+	}
+	slices.SortStableFunc(flatmembers, func(a, b types.UserSpec) int {
+		return cmp.Or(cmp.Compare(a.CreationTimestamp, b.CreationTimestamp), cmp.Compare(a.ID, b.ID))
+	})
+	eligible = map[string]bool{}
+	for _, user := range flatmembers {
+		if user.Disabled || user.DeletionTimestamp != 0 {
+			continue
+		}
+		eligible[user.ID] = true
+		if len(task.Assignees) == 0 {
+			rotation = append(rotation, user.ID)
+		}
+	}
+	if len(task.Assignees) > 0 {
+		rotation = task.Assignees
+	}
+	return rotation, eligible, nil
+}
+
+// Next ...
+// returns the next eligible user in the rotation of a task and
+// the position to continue the rotation from afterwards.
+// Disabled and deactivated accounts are skipped
+func (m *RotationManager) Next(task types.TaskSpec) (assignee string, position int, err error) {
+	rotation, eligible, err := m.List(task)
+	if err != nil {
+		return "", task.RotationPosition, err
+	}
+	for i := range rotation {
+		index := (task.RotationPosition + i) % len(rotation)
+		if !eligible[rotation[index]] {
+			continue
+		}
+		return rotation[index], (index + 1) % len(rotation), nil
+	}
+	return "", task.RotationPosition, nil
+}
+
+// SetPosition ...
+// updates the position in the rotation of a task
+func (m *RotationManager) SetPosition(taskID string, position int) (err error) {
+	sqlStatement := `update task set rotationPosition = $1 where id = $2 and flatId = $3`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, position, taskID, m.manager.flatID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// History ...
+// returns who was assigned and who completed past occurrences of tasks
+func (m *RotationManager) History(selector types.TaskHistorySelector) (history []types.TaskHistoryEntry, err error) {
+	sqlStatement := `select o.id, o.taskId, t.name, o.assignees, o.dueTimestamp, o.completed, o.completedBy, o.completedTimestamp
+                         from task_occurrence o
+                         join task t on t.id = o.taskId
+                         where t.flatId = $2 and o.deletionTimestamp = 0
+                         and (o.completed = true or o.dueTimestamp <= $1) `
+	fields := []any{time.Now().Unix(), m.manager.flatID}
+
+	if selector.TaskID != "" {
+		sqlStatement += fmt.Sprintf(`and o.taskId = $%v `, len(fields)+1)
+		fields = append(fields, selector.TaskID)
+	}
+	if selector.UserID != "" {
+		sqlStatement += fmt.Sprintf(`and (%v or o.completedBy = $%v) `, database.DialectOf(m.db).ArrayContains(`o.assignees`, fmt.Sprintf(`$%v`, len(fields)+1)), len(fields)+1)
+		fields = append(fields, selector.UserID)
+	}
+	sqlStatement += `order by o.dueTimestamp desc`
+
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, fields...)
+	if err != nil {
+		return []types.TaskHistoryEntry{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		var entry types.TaskHistoryEntry
+		if err := rows.Scan(&entry.OccurrenceID, &entry.TaskID, &entry.TaskName, pq.Array(&entry.Assignees), &entry.DueTimestamp, &entry.Completed, &entry.CompletedBy, &entry.CompletedTimestamp); err != nil {
+			return []types.TaskHistoryEntry{}, err
+		}
+		if err := rows.Err(); err != nil {
+			return []types.TaskHistoryEntry{}, err
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
@@ -17,3 +17,344 @@
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package tasks
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/imdario/mergo"
+	"github.com/lib/pq"
+
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/users"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+var (
+	ErrFailedToCreateTask            = fmt.Errorf("Failed to create task")
+	ErrFailedToCreateTaskOccurrence  = fmt.Errorf("Failed to create task occurrence")
+	ErrFailedToGetExistingTask       = fmt.Errorf("Failed to get existing task")
+	ErrFailedToPatchTask             = fmt.Errorf("Failed to patch task")
+	ErrFailedToRemoveTaskOccurrences = fmt.Errorf("Failed to remove all occurrences of task")
+	ErrFailedToUpdateTaskFields      = fmt.Errorf("Failed to update fields in the task")
+	ErrInvalidTaskAssignee           = fmt.Errorf("Unable to use the provided assignee, as the user account does not exist")
+	ErrInvalidTaskDescription        = fmt.Errorf("Unable to save task description, as it is too long")
+	ErrInvalidTaskName               = fmt.Errorf("Unable to use the provided name, as it is either empty or too long or too short")
+	ErrInvalidTaskRecurrence         = fmt.Errorf("Unable to use the provided recurrence, as it is not a known recurrence")
+)
+
+type Manager struct {
+	db     *sql.DB
+	ctx    context.Context
+	users  *users.Manager
+	flatID string
+}
+
+func NewManager(db *sql.DB, users *users.Manager) *Manager {
+	return &Manager{
+		db:    db,
+		ctx:   context.Background(),
+		users: users,
+	}
+}
+
+// ForFlat ...
+// returns a manager for the tasks of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    m.ctx,
+		users:  m.users.ForFlat(flatID),
+		flatID: flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		db:     m.db,
+		ctx:    ctx,
+		users:  m.users.WithContext(ctx),
+		flatID: m.flatID,
+	}
+}
+
+type TaskManager struct {
+	manager *Manager
+	db      *sql.DB
+	ctx     context.Context
+}
+
+func (m *Manager) Task() *TaskManager {
+	return &TaskManager{
+		manager: m,
+		db:      m.db,
+		ctx:     m.ctx,
+	}
+}
+
+// Validate ...
+// given a task, return it's validity
+func (m *TaskManager) Validate(task types.TaskSpec) (valid bool, err error) {
+	if len(task.Name) == 0 || len(task.Name) >= 30 || task.Name == "" {
+		return false, ErrInvalidTaskName
+	}
+	if task.Description != "" && len(task.Description) > 250 {
+		return false, ErrInvalidTaskDescription
+	}
+	switch task.Recurrence {
+	case types.TaskRecurrenceNever,
+		types.TaskRecurrenceDaily,
+		types.TaskRecurrenceWeekly,
+		types.TaskRecurrenceFortnightly,
+		types.TaskRecurrenceMonthly:
+	default:
+		return false, ErrInvalidTaskRecurrence
+	}
+	for _, assignee := range task.Assignees {
+		exists, err := m.manager.users.UserAccountExists(assignee)
+		if err != nil || !exists {
+			return false, ErrInvalidTaskAssignee
+		}
+	}
+	return true, nil
+}
+
+// List ...
+// returns a list of all tasks
+func (m *TaskManager) List() (tasks []types.TaskSpec, err error) {
+	sqlStatement := `select * from task where flatId = $1 and deletionTimestamp = 0 order by creationTimestamp desc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.manager.flatID)
+	if err != nil {
+		return []types.TaskSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		task, err := getTaskObjectFromRows(rows)
+		if err != nil {
+			return []types.TaskSpec{}, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// Get ...
+// returns a given task, by it's ID
+func (m *TaskManager) Get(id string) (task types.TaskSpec, err error) {
+	sqlStatement := `select * from task where id = $1 and flatId = $2 and deletionTimestamp = 0`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
+	if err != nil {
+		return types.TaskSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		task, err = getTaskObjectFromRows(rows)
+		if err != nil {
+			return types.TaskSpec{}, err
+		}
+	}
+	return task, nil
+}
+
+// Create ...
+// creates a task and it's first occurrence
+func (m *TaskManager) Create(task types.TaskSpec) (taskInserted types.TaskSpec, err error) {
+	if task.Recurrence == "" {
+		task.Recurrence = types.TaskRecurrenceNever
+	}
+	if task.Assignees == nil {
+		task.Assignees = []string{}
+	}
+	if task.StartTimestamp == 0 {
+		task.StartTimestamp = time.Now().Unix()
+	}
+	valid, err := m.Validate(task)
+	if !valid || err != nil {
+		return types.TaskSpec{}, err
+	}
+	task.AuthorLast = task.Author
+
+	sqlStatement := `insert into task (name, description, assignees, recurrence, startTimestamp, author, authorLast, rotate, flatId)
+                         values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, task.Name, task.Description, pq.Array(task.Assignees), task.Recurrence, task.StartTimestamp, task.Author, task.AuthorLast, task.Rotate, m.manager.flatID)
+	if err != nil {
+		return types.TaskSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	taskInserted, err = getTaskObjectFromRows(rows)
+	if err != nil || taskInserted.ID == "" {
+		slog.Error("Failed to get task object from rows", "error", err)
+		return types.TaskSpec{}, ErrFailedToCreateTask
+	}
+
+	if _, err := m.manager.Occurrence().GenerateNext(taskInserted); err != nil {
+		slog.Error("Failed to create first occurrence of task", "error", err)
+		if err := m.Delete(taskInserted.ID); err != nil {
+			return types.TaskSpec{}, err
+		}
+		return types.TaskSpec{}, ErrFailedToCreateTaskOccurrence
+	}
+	return taskInserted, nil
+}
+
+// Patch ...
+// patches a task
+func (m *TaskManager) Patch(id string, taskPatch types.TaskPatchSpec) (taskPatched types.TaskSpec, err error) {
+	existingTask, err := m.Get(id)
+	if err != nil || existingTask.ID == "" {
+		return types.TaskSpec{}, ErrFailedToGetExistingTask
+	}
+	task := taskPatch.TaskSpec
+	err = mergo.Merge(&task, existingTask)
+	if err != nil {
+		return types.TaskSpec{}, ErrFailedToUpdateTaskFields
+	}
+	if taskPatch.Rotate != nil {
+		task.Rotate = *taskPatch.Rotate
+	}
+	valid, err := m.Validate(task)
+	if !valid || err != nil {
+		return types.TaskSpec{}, err
+	}
+
+	sqlStatement := fmt.Sprintf(`update task set name = $1, description = $2, assignees = $3, recurrence = $4, authorLast = $5, rotate = $6, modificationTimestamp = %v where id = $7 and flatId = $8
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, task.Name, task.Description, pq.Array(task.Assignees), task.Recurrence, task.AuthorLast, task.Rotate, id, m.manager.flatID)
+	if err != nil {
+		return types.TaskSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	taskPatched, err = getTaskObjectFromRows(rows)
+	if err != nil || taskPatched.ID == "" {
+		slog.Error("Failed to get task from rows", "error", err)
+		return types.TaskSpec{}, ErrFailedToPatchTask
+	}
+	return taskPatched, nil
+}
+
+// Update ...
+// updates a task
+func (m *TaskManager) Update(id string, task types.TaskSpec) (taskUpdated types.TaskSpec, err error) {
+	if task.Assignees == nil {
+		task.Assignees = []string{}
+	}
+	valid, err := m.Validate(task)
+	if !valid || err != nil {
+		return types.TaskSpec{}, err
+	}
+
+	sqlStatement := fmt.Sprintf(`update task set name = $1, description = $2, assignees = $3, recurrence = $4, authorLast = $5, rotate = $6, modificationTimestamp = %v where id = $7 and flatId = $8
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, task.Name, task.Description, pq.Array(task.Assignees), task.Recurrence, task.AuthorLast, task.Rotate, id, m.manager.flatID)
+	if err != nil {
+		return types.TaskSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	taskUpdated, err = getTaskObjectFromRows(rows)
+	if err != nil || taskUpdated.ID == "" {
+		slog.Error("Failed to get task from rows", "error", err)
+		return types.TaskSpec{}, ErrFailedToPatchTask
+	}
+	return taskUpdated, nil
+}
+
+// Delete ...
+// deletes a task and all of it's occurrences
+func (m *TaskManager) Delete(id string) (err error) {
+	if err := m.manager.Occurrence().DeleteAll(id); err != nil {
+		return ErrFailedToRemoveTaskOccurrences
+	}
+	sqlStatement := `delete from task where id = $1 and flatId = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.manager.flatID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// GenerateOccurrences ...
+// ensures that each recurring task of the flat has an upcoming occurrence,
+// advancing rotating tasks to the next flatmate
+func (m *TaskManager) GenerateOccurrences() error {
+	tasks, err := m.List()
+	if err != nil {
+		return err
+	}
+	generated := 0
+	for _, task := range tasks {
+		occurrence, err := m.manager.Occurrence().GenerateNext(task)
+		if err != nil {
+			return err
+		}
+		if occurrence.ID != "" {
+			generated++
+		}
+	}
+	if generated > 0 {
+		slog.Info("Task occurrences", "message", fmt.Sprintf("Generated %v task occurrences", generated))
+	}
+	return nil
+}
+
+// nextDueTimestamp ...
+// returns the time after due which the recurrence lands on
+func nextDueTimestamp(recurrence types.TaskRecurrence, due time.Time) time.Time {
+	switch recurrence {
+	case types.TaskRecurrenceDaily:
+		return due.AddDate(0, 0, 1)
+	case types.TaskRecurrenceWeekly:
+		return due.AddDate(0, 0, 7)
+	case types.TaskRecurrenceFortnightly:
+		return due.AddDate(0, 0, 14)
+	case types.TaskRecurrenceMonthly:
+		return due.AddDate(0, 1, 0)
+	}
+	return due
+}
+
+// getTaskObjectFromRows ...
+// returns a task object from rows
+func getTaskObjectFromRows(rows *sql.Rows) (task types.TaskSpec, err error) {
+	if err := rows.Scan(&task.ID, &task.Name, &task.Description, pq.Array(&task.Assignees), &task.Recurrence, &task.StartTimestamp, &task.Author, &task.AuthorLast, &task.CreationTimestamp, &task.ModificationTimestamp, &task.DeletionTimestamp, &task.Rotate, &task.RotationPosition, &task.FlatID); err != nil {
+		return types.TaskSpec{}, err
+	}
+	err = rows.Err()
+	if err != nil {
+		return types.TaskSpec{}, err
+	}
+	return task, nil
+}
@@ -0,0 +1,228 @@
+/*
+  users
+    passwordreset
+      single use secrets for resetting forgotten passwords
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// userPasswordResetSecretExpiry is how long a password reset secret may be used for
+	userPasswordResetSecretExpiry = time.Hour
+)
+
+// userPasswordResetSecretFromRows ...
+// constructs a UserPasswordResetSecretSpec from rows
+func userPasswordResetSecretFromRows(rows *sql.Rows) (resetSecret types.UserPasswordResetSecretSpec, err error) {
+	if err := rows.Scan(&resetSecret.ID, &resetSecret.UserID, &resetSecret.Secret, &resetSecret.ExpiryTimestamp, &resetSecret.CreationTimestamp, &resetSecret.ModificationTimestamp, &resetSecret.DeletionTimestamp); err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	return resetSecret, nil
+}
+
+type userPasswordResetSecretManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserPasswordResetSecrets() *userPasswordResetSecretManager {
+	return &userPasswordResetSecretManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// Get ...
+// returns an unexpired password reset secret by it's id and secret
+func (m *userPasswordResetSecretManager) Get(id string, secret string) (resetSecret types.UserPasswordResetSecretSpec, err error) {
+	sqlStatement := `select * from user_password_reset_secret where id = $1 and secret = $2 and expiryTimestamp > $3`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, common.HashSHA512(secret), time.Now().Unix())
+	if err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		resetSecret, err = userPasswordResetSecretFromRows(rows)
+		if err != nil {
+			return types.UserPasswordResetSecretSpec{}, err
+		}
+	}
+	if resetSecret.ID == "" {
+		return types.UserPasswordResetSecretSpec{}, ErrUserPasswordResetSecretNotFound
+	}
+	return resetSecret, nil
+}
+
+// Create ...
+// creates a password reset secret for a user account, replacing any existing ones.
+// Only a hash of the secret is stored, so the returned secret is the only copy of it
+func (m *userPasswordResetSecretManager) Create(userID string) (resetSecretInserted types.UserPasswordResetSecretSpec, err error) {
+	if err := m.DeleteByUserID(userID); err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	secret := hex.EncodeToString(secretBytes)
+	sqlStatement := `insert into user_password_reset_secret (userId, secret, expiryTimestamp)
+                         values ($1, $2, $3)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, common.HashSHA512(secret), time.Now().Add(userPasswordResetSecretExpiry).Unix())
+	if err != nil {
+		return types.UserPasswordResetSecretSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		resetSecretInserted, err = userPasswordResetSecretFromRows(rows)
+		if err != nil {
+			return types.UserPasswordResetSecretSpec{}, err
+		}
+	}
+	resetSecretInserted.Secret = secret
+	return resetSecretInserted, nil
+}
+
+// Redeem ...
+// deletes an unexpired password reset secret, returning the user account it belongs to.
+// Deleting as it's read ensures that the secret is only able to be used once
+func (m *userPasswordResetSecretManager) Redeem(id string, secret string) (userID string, err error) {
+	sqlStatement := `delete from user_password_reset_secret
+                         where id = $1 and secret = $2 and expiryTimestamp > $3
+                         returning userId`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, common.HashSHA512(secret), time.Now().Unix())
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		if err := rows.Scan(&userID); err != nil {
+			return "", err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrUserPasswordResetSecretNotFound
+	}
+	return userID, nil
+}
+
+// DeleteByUserID ...
+// deletes the password reset secrets of a user account
+func (m *userPasswordResetSecretManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_password_reset_secret where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// DeleteExpired ...
+// deletes password reset secrets which are no longer able to be used
+func (m *userPasswordResetSecretManager) DeleteExpired() error {
+	sqlStatement := `delete from user_password_reset_secret where expiryTimestamp <= $1`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired password reset secrets", "count", n)
+	}
+	return nil
+}
+
+// RequestPasswordReset ...
+// creates a password reset secret for the account with the email.
+// An empty user account is returned when there isn't one able to reset it's password
+func (m *Manager) RequestPasswordReset(email string) (user types.UserSpec, resetSecret types.UserPasswordResetSecretSpec, err error) {
+	if email == "" {
+		return types.UserSpec{}, types.UserPasswordResetSecretSpec{}, nil
+	}
+	user, err = m.GetByEmail(email, false)
+	if errors.Is(err, ErrFailedToFindAccount) {
+		return types.UserSpec{}, types.UserPasswordResetSecretSpec{}, nil
+	}
+	if err != nil {
+		return types.UserSpec{}, types.UserPasswordResetSecretSpec{}, err
+	}
+	if user.ID == "" || !user.Registered || user.Disabled || user.DeletionTimestamp != 0 {
+		return types.UserSpec{}, types.UserPasswordResetSecretSpec{}, nil
+	}
+	resetSecret, err = m.UserPasswordResetSecrets().Create(user.ID)
+	if err != nil {
+		return types.UserSpec{}, types.UserPasswordResetSecretSpec{}, err
+	}
+	return user, resetSecret, nil
+}
+
+// ResetPassword ...
+// sets the password of the user account which the reset secret belongs to,
+// invalidating all of it's existing logins
+func (m *Manager) ResetPassword(id string, secret string, password string) (err error) {
+	if !common.RegexMatchPassword(password) || password == "" {
+		return ErrUserAccountInvalidPassword
+	}
+	userID, err := m.UserPasswordResetSecrets().Redeem(id, secret)
+	if err != nil {
+		return err
+	}
+	if _, err := m.PatchAsAdmin(userID, types.UserSpec{Password: password}); err != nil {
+		return err
+	}
+	return m.GenerateNewAuthNonce(userID)
+}
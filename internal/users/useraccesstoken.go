@@ -0,0 +1,310 @@
+/*
+  users
+    useraccesstoken
+      long-lived tokens for scripts and integrations, limited by scope
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// AccessTokenPrefix begins every personal access token, so they are able to be told apart from JWTs
+	// and recognised by secret scanners
+	AccessTokenPrefix = "ftpat_"
+
+	// AccessTokenScopeShoppingListRead allows reading shopping lists, items and tags
+	AccessTokenScopeShoppingListRead = "shoppinglist:read"
+	// AccessTokenScopeShoppingListWrite allows changing shopping lists, items and tags, as well as reading them
+	AccessTokenScopeShoppingListWrite = "shoppinglist:write"
+	// AccessTokenScopeAdmin allows using admin features, if the user account is an admin
+	AccessTokenScopeAdmin = "admin"
+
+	// userAccessTokenNameMaxLength is the longest name of a personal access token
+	userAccessTokenNameMaxLength = 64
+	// userAccessTokenLastUsedInterval is how often the last used time of a token is updated,
+	// to avoid writing on every request
+	userAccessTokenLastUsedInterval = time.Minute
+)
+
+// AccessTokenScopes ...
+// the scopes which personal access tokens may be created with
+var AccessTokenScopes = []string{
+	AccessTokenScopeShoppingListRead,
+	AccessTokenScopeShoppingListWrite,
+	AccessTokenScopeAdmin,
+}
+
+// accessTokenScopeImplied ...
+// scopes which are also granted by another scope
+var accessTokenScopeImplied = map[string][]string{
+	AccessTokenScopeShoppingListWrite: {AccessTokenScopeShoppingListRead},
+}
+
+// AccessTokenHasScope ...
+// returns whether a set of scopes grants a scope, either directly or by implication
+func AccessTokenHasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope || slices.Contains(accessTokenScopeImplied[s], scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// userAccessTokenFromRows ...
+// constructs a UserAccessTokenSpec from rows.
+// The token hash is never returned, as it isn't useful to anyone
+func userAccessTokenFromRows(rows *sql.Rows) (accessToken types.UserAccessTokenSpec, err error) {
+	var tokenHash string
+	if err := rows.Scan(&accessToken.ID, &accessToken.UserID, &accessToken.Name, &tokenHash, pq.Array(&accessToken.Scopes), &accessToken.ExpiryTimestamp, &accessToken.LastUsedTimestamp, &accessToken.CreationTimestamp, &accessToken.ModificationTimestamp, &accessToken.DeletionTimestamp); err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	return accessToken, nil
+}
+
+// ValidateAccessToken ...
+// checks the name, scopes and expiry of a personal access token to create
+func ValidateAccessToken(accessToken types.UserAccessTokenSpec) (types.UserAccessTokenSpec, error) {
+	accessToken.Name = strings.TrimSpace(accessToken.Name)
+	if accessToken.Name == "" || len(accessToken.Name) > userAccessTokenNameMaxLength {
+		return types.UserAccessTokenSpec{}, ErrUserAccessTokenInvalidName
+	}
+	if len(accessToken.Scopes) == 0 {
+		return types.UserAccessTokenSpec{}, ErrUserAccessTokenInvalidScope
+	}
+	scopes := []string{}
+	for _, scope := range accessToken.Scopes {
+		if !slices.Contains(AccessTokenScopes, scope) {
+			return types.UserAccessTokenSpec{}, ErrUserAccessTokenInvalidScope
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	accessToken.Scopes = scopes
+	if accessToken.ExpiryTimestamp != 0 && accessToken.ExpiryTimestamp <= time.Now().Unix() {
+		return types.UserAccessTokenSpec{}, ErrUserAccessTokenInvalidExpiry
+	}
+	return accessToken, nil
+}
+
+type userAccessTokenManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserAccessTokens() *userAccessTokenManager {
+	return &userAccessTokenManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// List ...
+// returns the unexpired personal access tokens of a user account, newest first
+func (m *userAccessTokenManager) List(userID string) (accessTokens []types.UserAccessTokenSpec, err error) {
+	sqlStatement := `select * from user_access_token
+                         where userId = $1 and (expiryTimestamp = 0 or expiryTimestamp > $2)
+                         order by creationTimestamp desc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, time.Now().Unix())
+	if err != nil {
+		return []types.UserAccessTokenSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		accessToken, err := userAccessTokenFromRows(rows)
+		if err != nil {
+			return []types.UserAccessTokenSpec{}, err
+		}
+		accessTokens = append(accessTokens, accessToken)
+	}
+	return accessTokens, nil
+}
+
+// Create ...
+// creates a personal access token for a user account.
+// Only a hash of the token is stored, so the returned token is the only copy of it
+func (m *userAccessTokenManager) Create(userID string, accessToken types.UserAccessTokenSpec) (accessTokenInserted types.UserAccessTokenSpec, err error) {
+	accessToken, err = ValidateAccessToken(accessToken)
+	if err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	token := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(tokenBytes)
+	sqlStatement := `insert into user_access_token (userId, name, token, scopes, expiryTimestamp)
+                         values ($1, $2, $3, $4, $5)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, accessToken.Name, common.HashSHA512(token), pq.Array(accessToken.Scopes), accessToken.ExpiryTimestamp)
+	if err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		accessTokenInserted, err = userAccessTokenFromRows(rows)
+		if err != nil {
+			return types.UserAccessTokenSpec{}, err
+		}
+	}
+	accessTokenInserted.Token = token
+	return accessTokenInserted, nil
+}
+
+// GetByToken ...
+// returns the unexpired personal access token which a token is for
+func (m *userAccessTokenManager) GetByToken(token string) (accessToken types.UserAccessTokenSpec, err error) {
+	sqlStatement := `select * from user_access_token
+                         where token = $1 and (expiryTimestamp = 0 or expiryTimestamp > $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, common.HashSHA512(token), time.Now().Unix())
+	if err != nil {
+		return types.UserAccessTokenSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		accessToken, err = userAccessTokenFromRows(rows)
+		if err != nil {
+			return types.UserAccessTokenSpec{}, err
+		}
+	}
+	if accessToken.ID == "" {
+		return types.UserAccessTokenSpec{}, ErrUserAccessTokenNotFound
+	}
+	return accessToken, nil
+}
+
+// Touch ...
+// updates the last used time of a personal access token, if it hasn't recently been
+func (m *userAccessTokenManager) Touch(accessToken types.UserAccessTokenSpec) (err error) {
+	now := time.Now()
+	if now.Sub(time.Unix(accessToken.LastUsedTimestamp, 0)) < userAccessTokenLastUsedInterval {
+		return nil
+	}
+	sqlStatement := `update user_access_token set lastUsedTimestamp = $2 where id = $1`
+	_, err = m.db.ExecContext(m.ctx, sqlStatement, accessToken.ID, now.Unix())
+	return err
+}
+
+// Delete ...
+// revokes a personal access token of a user account
+func (m *userAccessTokenManager) Delete(userID string, id string) (err error) {
+	sqlStatement := `delete from user_access_token where userId = $1 and id = $2`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, userID, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserAccessTokenNotFound
+	}
+	return nil
+}
+
+// DeleteByUserID ...
+// revokes all personal access tokens of a user account
+func (m *userAccessTokenManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_access_token where userId = $1`
+	_, err = m.db.ExecContext(m.ctx, sqlStatement, userID)
+	return err
+}
+
+// DeleteExpired ...
+// deletes personal access tokens which are no longer valid
+func (m *userAccessTokenManager) DeleteExpired() error {
+	sqlStatement := `delete from user_access_token where expiryTimestamp != 0 and expiryTimestamp <= $1`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired personal access tokens", "count", n)
+	}
+	return nil
+}
+
+// validateAccessToken ...
+// returns the claims of a personal access token, being the user account it's for and it's scopes
+func (m *Manager) validateAccessToken(token string) (valid bool, tokenClaims *types.JWTclaim, err error) {
+	accessToken, err := m.UserAccessTokens().GetByToken(token)
+	if err != nil {
+		if !errors.Is(err, ErrUserAccessTokenNotFound) {
+			slog.Error("Unable to get personal access token", "error", err)
+		}
+		return false, &types.JWTclaim{}, ErrAuthInvalid
+	}
+	user, err := m.GetByID(accessToken.UserID, true)
+	if err != nil || user.ID == "" || user.DeletionTimestamp != 0 {
+		if err != nil {
+			slog.Error("Unable to get user by ID", "error", err)
+		}
+		return false, &types.JWTclaim{}, ErrFailedToFindAuthTokenAccountID
+	}
+	if user.Disabled {
+		return false, &types.JWTclaim{}, ErrUserAccountIsDisabled
+	}
+	if IsExpired(user) {
+		return false, &types.JWTclaim{}, ErrUserAccountIsExpired
+	}
+	if err := m.UserAccessTokens().Touch(accessToken); err != nil {
+		slog.Error("Unable to update personal access token last used time", "id", accessToken.ID, "error", err)
+	}
+	return true, &types.JWTclaim{
+		ID:            user.ID,
+		AccessTokenID: accessToken.ID,
+		Scopes:        accessToken.Scopes,
+	}, nil
+}
@@ -0,0 +1,274 @@
+/*
+  users
+    userauthattempt
+      count failed logins and lock out those with too many
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// userAuthAttemptKindIP counts failed logins from an IP address
+	userAuthAttemptKindIP = "ip"
+	// userAuthAttemptKindEmail counts failed logins to an email, whether or not it has an account
+	userAuthAttemptKindEmail = "email"
+	// userAuthAttemptKeyMaxLength is the longest key stored, so failed logins can't store large values
+	userAuthAttemptKeyMaxLength = 254
+)
+
+// userAuthAttemptFromRows ...
+// constructs a UserAuthAttemptSpec from rows
+func userAuthAttemptFromRows(rows *sql.Rows) (attempt types.UserAuthAttemptSpec, err error) {
+	if err := rows.Scan(&attempt.ID, &attempt.Kind, &attempt.Key, &attempt.Failures, &attempt.WindowStartTimestamp, &attempt.LockedUntilTimestamp, &attempt.CreationTimestamp, &attempt.ModificationTimestamp, &attempt.DeletionTimestamp, &attempt.FlatID); err != nil {
+		return types.UserAuthAttemptSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserAuthAttemptSpec{}, err
+	}
+	return attempt, nil
+}
+
+// userAuthAttemptKey ...
+// normalises an IP address or email so variations of it are counted together
+func userAuthAttemptKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if len(key) > userAuthAttemptKeyMaxLength {
+		key = key[:userAuthAttemptKeyMaxLength]
+	}
+	return key
+}
+
+type userAuthAttemptManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserAuthAttempts() *userAuthAttemptManager {
+	return &userAuthAttemptManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// LockedUntil ...
+// returns when an IP address or email may log in again, which is in the past when it isn't locked out
+func (m *userAuthAttemptManager) LockedUntil(kind string, key string) (lockedUntil time.Time, err error) {
+	sqlStatement := `select lockedUntilTimestamp from user_auth_attempt where flatId = $1 and kind = $2 and key = $3`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.m.flatID, kind, userAuthAttemptKey(key))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	var lockedUntilTimestamp int64
+	for rows.Next() {
+		if err := rows.Scan(&lockedUntilTimestamp); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(lockedUntilTimestamp, 0), nil
+}
+
+// RecordFailure ...
+// counts a failed login for an IP address or email, locking it out once there are maxFailures
+// within the window. The count is incremented in the database, so concurrent failures are all counted
+func (m *userAuthAttemptManager) RecordFailure(kind string, key string, maxFailures int, window time.Duration, lockout time.Duration) (lockedUntil time.Time, err error) {
+	if maxFailures == 0 {
+		return time.Time{}, nil
+	}
+	now := time.Now()
+	sqlStatement := fmt.Sprintf(`insert into user_auth_attempt (kind, key, failures, windowStartTimestamp, flatId)
+                         values ($1, $2, 1, $3, $5)
+                         on conflict (flatId, kind, key) do update set
+                           failures = case when user_auth_attempt.windowStartTimestamp <= $4 then 1 else user_auth_attempt.failures + 1 end,
+                           windowStartTimestamp = case when user_auth_attempt.windowStartTimestamp <= $4 then $3 else user_auth_attempt.windowStartTimestamp end,
+                           modificationTimestamp = %v
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, kind, userAuthAttemptKey(key), now.Unix(), now.Add(-window).Unix(), m.m.flatID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	var attempt types.UserAuthAttemptSpec
+	for rows.Next() {
+		attempt, err = userAuthAttemptFromRows(rows)
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+	if attempt.Failures < maxFailures {
+		return time.Unix(attempt.LockedUntilTimestamp, 0), nil
+	}
+	lockedUntil = now.Add(lockout)
+	sqlStatement = fmt.Sprintf(`update user_auth_attempt set failures = 0, windowStartTimestamp = $2, lockedUntilTimestamp = $3, modificationTimestamp = %v where id = $1`, database.DialectOf(m.db).Now())
+	if _, err := m.db.ExecContext(m.ctx, sqlStatement, attempt.ID, now.Unix(), lockedUntil.Unix()); err != nil {
+		return time.Time{}, err
+	}
+	slog.Warn("Locked out logins after too many failures", "kind", kind, "key", attempt.Key, "lockedUntil", lockedUntil.Unix())
+	return lockedUntil, nil
+}
+
+// Reset ...
+// forgets the failed logins of an IP address or email
+func (m *userAuthAttemptManager) Reset(kind string, key string) (err error) {
+	sqlStatement := `delete from user_auth_attempt where flatId = $1 and kind = $2 and key = $3`
+	_, err = m.db.ExecContext(m.ctx, sqlStatement, m.m.flatID, kind, userAuthAttemptKey(key))
+	return err
+}
+
+// ListLocked ...
+// returns the IP addresses and emails which are locked out, with the user accounts of the emails
+func (m *userAuthAttemptManager) ListLocked() (lockouts []types.UserAuthLockoutSpec, err error) {
+	sqlStatement := `select a.id, a.kind, a.key, coalesce(u.id, ''), coalesce(u.names, ''), a.lockedUntilTimestamp
+                         from user_auth_attempt a
+                         left join users u on a.kind = $1 and lower(u.email) = a.key and u.flatId = a.flatId and u.deletionTimestamp = 0
+                         where a.flatId = $3 and a.lockedUntilTimestamp > $2
+                         order by a.lockedUntilTimestamp desc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userAuthAttemptKindEmail, time.Now().Unix(), m.m.flatID)
+	if err != nil {
+		return []types.UserAuthLockoutSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		var lockout types.UserAuthLockoutSpec
+		if err := rows.Scan(&lockout.ID, &lockout.Kind, &lockout.Key, &lockout.UserID, &lockout.Names, &lockout.LockedUntilTimestamp); err != nil {
+			return []types.UserAuthLockoutSpec{}, err
+		}
+		if err := rows.Err(); err != nil {
+			return []types.UserAuthLockoutSpec{}, err
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	return lockouts, nil
+}
+
+// Unlock ...
+// removes a lockout and the failed logins which caused it
+func (m *userAuthAttemptManager) Unlock(id string) (err error) {
+	sqlStatement := `delete from user_auth_attempt where id = $1 and lockedUntilTimestamp > $2 and flatId = $3`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, id, time.Now().Unix(), m.m.flatID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserAuthLockoutNotFound
+	}
+	return nil
+}
+
+// DeleteExpired ...
+// deletes failed login counts which are no longer locked out or within their window
+func (m *userAuthAttemptManager) DeleteExpired() error {
+	now := time.Now()
+	sqlStatement := `delete from user_auth_attempt where lockedUntilTimestamp <= $1 and windowStartTimestamp <= $2`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, now.Unix(), now.Add(-common.GetAuthFailureWindow()).Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired failed login counts", "count", n)
+	}
+	return nil
+}
+
+// CheckAuthLockout ...
+// returns how long until a login from the IP address to the email may be tried, or 0 if it may be now
+func (m *Manager) CheckAuthLockout(ipAddress string, email string) (retryAfter time.Duration, err error) {
+	for _, counter := range authAttemptCounters(ipAddress, email) {
+		lockedUntil, err := m.UserAuthAttempts().LockedUntil(counter.kind, counter.key)
+		if err != nil {
+			return 0, err
+		}
+		retryAfter = max(retryAfter, time.Until(lockedUntil))
+	}
+	return retryAfter, nil
+}
+
+// RecordAuthFailure ...
+// counts a failed login from the IP address to the email, returning how long until
+// another may be tried if this failure caused either of them to be locked out
+func (m *Manager) RecordAuthFailure(ipAddress string, email string) (retryAfter time.Duration, err error) {
+	window := common.GetAuthFailureWindow()
+	lockout := common.GetAuthLockoutDuration()
+	for _, counter := range authAttemptCounters(ipAddress, email) {
+		lockedUntil, err := m.UserAuthAttempts().RecordFailure(counter.kind, counter.key, counter.maxFailures, window, lockout)
+		if err != nil {
+			return 0, err
+		}
+		retryAfter = max(retryAfter, time.Until(lockedUntil))
+	}
+	return retryAfter, nil
+}
+
+// authAttemptCounter ...
+// something which failed logins are counted against
+type authAttemptCounter struct {
+	kind        string
+	key         string
+	maxFailures int
+}
+
+// authAttemptCounters ...
+// returns the counters which a login from an IP address to an email is limited by
+func authAttemptCounters(ipAddress string, email string) []authAttemptCounter {
+	return []authAttemptCounter{
+		{kind: userAuthAttemptKindIP, key: ipAddress, maxFailures: common.GetAuthMaxFailuresPerIP()},
+		{kind: userAuthAttemptKindEmail, key: email, maxFailures: common.GetAuthMaxFailuresPerEmail()},
+	}
+}
+
+// ResetAuthFailures ...
+// forgets the failed logins to an email, once it's been logged in to.
+// Failures from the IP address are kept, so one known password can't be used to keep guessing others
+func (m *Manager) ResetAuthFailures(email string) (err error) {
+	return m.UserAuthAttempts().Reset(userAuthAttemptKindEmail, email)
+}
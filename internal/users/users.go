@@ -19,7 +19,7 @@
 package users
 
 import (
-	"crypto/subtle"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -33,6 +33,7 @@ import (
 
 	jwt "github.com/golang-jwt/jwt/v5"
 	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
 	"gitlab.com/flattrack/flattrack/internal/groups"
 	"gitlab.com/flattrack/flattrack/internal/system"
 	"gitlab.com/flattrack/flattrack/pkg/types"
@@ -42,6 +43,11 @@ var (
 	jwtAlg *jwt.SigningMethodHMAC = jwt.SigningMethodHS256
 )
 
+const (
+	// authTokenExpiry is how long an auth token and it's session are valid for
+	authTokenExpiry = time.Hour * 24 * 5
+)
+
 var (
 	ErrAuthInvalid                                       = fmt.Errorf("Authentication has been invalidated, please log in again")
 	ErrEmailAddressAlreadyUsed                           = fmt.Errorf("Email address is unable to be used")
@@ -72,10 +78,24 @@ var (
 	ErrUserAccountInvalidName                            = fmt.Errorf("Unable to use the provided name, as it is either empty or too long or too short")
 	ErrUserAccountInvalidPassword                        = fmt.Errorf("Unable to use the provided password, as it is either empty of invalid")
 	ErrUserAccountInvalidPhoneNumber                     = fmt.Errorf("Unable to use the provided phone number")
-	ErrUserAccountMustBeInFlatmemberGroup                = fmt.Errorf("User account must be in the flatmember group")
 	ErrUserAccountIsDisabled                             = fmt.Errorf("Your user account is disabled")
+	ErrUserAccountIsExpired                              = fmt.Errorf("Your user account has expired")
 	ErrAuthorizationHeaderNotFound                       = fmt.Errorf("Unable to find authorization token (header doesn't exist)")
 	ErrUserAccountCreationSecretNotFound                 = fmt.Errorf("Failed to find user account creation secret")
+	ErrUserPasswordResetSecretNotFound                   = fmt.Errorf("Unable to reset password, as the reset link is invalid or has expired")
+	ErrUserSessionNotFound                               = fmt.Errorf("Unable to find session")
+	ErrUserTOTPNotFound                                  = fmt.Errorf("Two-factor authentication is not set up for this user account")
+	ErrUserTOTPAlreadyEnabled                            = fmt.Errorf("Two-factor authentication is already enabled for this user account")
+	ErrUserTOTPCodeInvalid                               = fmt.Errorf("Unable to use the provided code, as it is either invalid or has already been used")
+	ErrUserAuthChallengeNotFound                         = fmt.Errorf("Unable to complete login, as it has expired, please log in again")
+	ErrUserAuthLockoutNotFound                           = fmt.Errorf("Unable to find login lockout")
+	ErrUserAccessTokenNotFound                           = fmt.Errorf("Unable to find personal access token")
+	ErrUserAccessTokenInvalidName                        = fmt.Errorf("Unable to use the provided name for the personal access token, as it is either empty or too long")
+	ErrUserAccessTokenInvalidScope                       = fmt.Errorf("Unable to use the provided scopes for the personal access token, as there are none or they are unknown")
+	ErrUserAccessTokenInvalidExpiry                      = fmt.Errorf("Unable to use the provided expiry for the personal access token, as it is in the past")
+	ErrUserAccountInvalidExpiry                          = fmt.Errorf("Unable to use the provided expiry, as it is in the past")
+	ErrUserAccountGuestRequiresExpiry                    = fmt.Errorf("Unable to use the guest group without an expiry for the user account")
+	ErrUserAccountGuestWithOtherGroups                   = fmt.Errorf("Unable to use the guest group together with the flatmember or admin groups")
 )
 
 // UserManager manages user accounts
@@ -83,6 +103,8 @@ type Manager struct {
 	groups *groups.Manager
 	system *system.Manager
 	db     *sql.DB
+	ctx    context.Context
+	flatID string
 }
 
 func NewManager(db *sql.DB) *Manager {
@@ -90,6 +112,31 @@ func NewManager(db *sql.DB) *Manager {
 		groups: groups.NewManager(db),
 		system: system.NewManager(db),
 		db:     db,
+		ctx:    context.Background(),
+	}
+}
+
+// ForFlat ...
+// returns a manager for the user accounts of a flat
+func (m *Manager) ForFlat(flatID string) *Manager {
+	return &Manager{
+		groups: m.groups.ForFlat(flatID),
+		system: m.system,
+		db:     m.db,
+		ctx:    m.ctx,
+		flatID: flatID,
+	}
+}
+
+// WithContext ...
+// returns a manager whose queries are run with a context, so that they are cancelled along with it
+func (m *Manager) WithContext(ctx context.Context) *Manager {
+	return &Manager{
+		groups: m.groups.WithContext(ctx),
+		system: m.system.WithContext(ctx),
+		db:     m.db,
+		ctx:    ctx,
+		flatID: m.flatID,
 	}
 }
 
@@ -106,18 +153,22 @@ func (m *Manager) Validate(user types.UserSpec, allowEmptyPassword bool) (valid
 	if len(user.Groups) == 0 {
 		return false, ErrNoGroupsProvided
 	}
-	groupsIncludeFlatmember := false
 	for _, groupItem := range user.Groups {
-		if groupItem == "flatmember" {
-			groupsIncludeFlatmember = true
-		}
 		group, err := m.groups.GetByName(groupItem)
 		if err != nil || group.ID == "" {
 			return false, ErrUserAccountInvalidGroup
 		}
 	}
-	if !groupsIncludeFlatmember {
-		return false, ErrUserAccountMustBeInFlatmemberGroup
+	if slices.Contains(user.Groups, groups.GroupGuest) {
+		if user.ExpiryTimestamp == 0 {
+			return false, ErrUserAccountGuestRequiresExpiry
+		}
+		if slices.Contains(user.Groups, groups.GroupFlatmember) || slices.Contains(user.Groups, groups.GroupAdmin) {
+			return false, ErrUserAccountGuestWithOtherGroups
+		}
+	}
+	if user.ExpiryTimestamp < 0 {
+		return false, ErrUserAccountInvalidExpiry
 	}
 
 	if user.Birthday != 0 && !common.ValidateBirthday(user.Birthday) {
@@ -143,21 +194,62 @@ func (m *Manager) Create(user types.UserSpec, allowEmptyPassword bool) (userInse
 	if !validUser || err != nil {
 		return types.UserSpec{}, err
 	}
+	if !expiryIsInFuture(user.ExpiryTimestamp) {
+		return types.UserSpec{}, ErrUserAccountInvalidExpiry
+	}
 	localUser, err := m.GetByEmail(user.Email, false)
 	if err == nil || localUser.Email == user.Email || localUser.ID != "" {
 		return types.UserSpec{}, ErrEmailAddressAlreadyUsed
 	}
 	if user.Password != "" {
-		user.Password = common.HashSHA512(user.Password)
+		user.Password, err = common.HashPassword(user.Password)
+		if err != nil {
+			return types.UserSpec{}, err
+		}
 	}
 	if !allowEmptyPassword {
 		user.Registered = true
 	}
 
-	sqlStatement := `insert into users (names, email, password, phonenumber, birthday, contractAgreement, disabled, registered)
-                         values ($1, $2, $3, $4, $5, $6, $7, $8)
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, user.Names, user.Email, user.Password, user.PhoneNumber, user.Birthday, user.ContractAgreement, user.Disabled, user.Registered)
+	userInserted, err = m.insert(user)
+	if err != nil {
+		return types.UserSpec{}, err
+	}
+	if allowEmptyPassword {
+		if userCreationSecretInserted, err = m.UserCreationSecrets().Create(userInserted.ID); err != nil {
+			return types.UserSpec{}, err
+		}
+		if userCreationSecretInserted.ID == "" {
+			return types.UserSpec{}, ErrFailedToCreateUserCreationSecret
+		}
+	}
+
+	return userInserted, nil
+}
+
+// Provision ...
+// creates a registered user account without a password, for users who log in with an identity provider
+func (m *Manager) Provision(user types.UserSpec) (userInserted types.UserSpec, err error) {
+	user.Password = ""
+	user.Registered = true
+	validUser, err := m.Validate(user, true)
+	if !validUser || err != nil {
+		return types.UserSpec{}, err
+	}
+	localUser, err := m.GetByEmail(user.Email, false)
+	if err == nil || localUser.Email == user.Email || localUser.ID != "" {
+		return types.UserSpec{}, ErrEmailAddressAlreadyUsed
+	}
+	return m.insert(user)
+}
+
+// insert ...
+// stores a validated user account and it's groups
+func (m *Manager) insert(user types.UserSpec) (userInserted types.UserSpec, err error) {
+	sqlStatement := fmt.Sprintf(`insert into users (names, email, password, phonenumber, birthday, contractAgreement, disabled, registered, expiryTimestamp, flatId)
+                         values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+                         returning %v`, UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, user.Names, user.Email, user.Password, user.PhoneNumber, user.Birthday, user.ContractAgreement, user.Disabled, user.Registered, user.ExpiryTimestamp, m.flatID)
 	if err != nil {
 		return types.UserSpec{}, err
 	}
@@ -167,7 +259,7 @@ func (m *Manager) Create(user types.UserSpec, allowEmptyPassword bool) (userInse
 		}
 	}()
 	for rows.Next() {
-		userInserted, err = userObjectFromRows(rows)
+		userInserted, err = UserColumns.Scan(rows)
 		if err != nil {
 			return types.UserSpec{}, err
 		}
@@ -177,28 +269,19 @@ func (m *Manager) Create(user types.UserSpec, allowEmptyPassword bool) (userInse
 	}
 	userInserted.Groups = user.Groups
 	userInserted.Password = ""
-	if allowEmptyPassword {
-		if userCreationSecretInserted, err = m.UserCreationSecrets().Create(userInserted.ID); err != nil {
-			return types.UserSpec{}, err
-		}
-		if userCreationSecretInserted.ID == "" {
-			return types.UserSpec{}, ErrFailedToCreateUserCreationSecret
-		}
-	}
-
 	return userInserted, nil
 }
 
 // List ...
 // return all users in the database
 func (m *Manager) List(includePassword bool, selectors types.UserSelector) (users []types.UserSpec, err error) {
-	sqlStatement := `select * from users  `
+	sqlStatement := fmt.Sprintf(`select %v from users where flatId = $1 `, UserColumns)
 	if selectors.Deleted {
-		sqlStatement += ` where deletionTimestamp <> 0 `
+		sqlStatement += ` and deletionTimestamp <> 0 `
 	} else {
-		sqlStatement += ` where deletionTimestamp = 0 `
+		sqlStatement += ` and deletionTimestamp = 0 `
 	}
-	fields := []any{}
+	fields := []any{m.flatID}
 
 	if selectors.ModificationTimestampBefore != 0 {
 		sqlStatement += fmt.Sprintf(`and modificationTimestamp < $%v `, len(fields)+1)
@@ -225,7 +308,7 @@ func (m *Manager) List(includePassword bool, selectors types.UserSelector) (user
 		fields = append(fields, selectors.DeletionTimestampAfter)
 	}
 	sqlStatement += ` order by names `
-	rows, err := m.db.Query(sqlStatement, fields...)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, fields...)
 	if err != nil {
 		return []types.UserSpec{}, err
 	}
@@ -236,7 +319,7 @@ func (m *Manager) List(includePassword bool, selectors types.UserSelector) (user
 	}()
 	for rows.Next() {
 		found := true
-		user, err := userObjectFromRows(rows)
+		user, err := UserColumns.Scan(rows)
 		if err != nil {
 			return []types.UserSpec{}, err
 		}
@@ -284,35 +367,36 @@ func (m *Manager) Get(userSelect types.UserSpec, includePassword bool) (user typ
 	return user, nil
 }
 
-// userObjectFromRowsRestricted ...
-// construct a restricted UserSpec from database rows
-func userObjectFromRowsRestricted(rows *sql.Rows) (user types.UserSpec, err error) {
-	if err := rows.Scan(&user.ID, &user.Names, &user.Email, &user.PhoneNumber, &user.Birthday, &user.ContractAgreement, &user.Disabled, &user.Registered, &user.LastLogin, &user.CreationTimestamp, &user.ModificationTimestamp, &user.DeletionTimestamp); err != nil {
-		return types.UserSpec{}, err
-	}
-	if err := rows.Err(); err != nil {
-		return types.UserSpec{}, err
-	}
-	return user, nil
+// UserColumns ...
+// the columns of users, along with the fields of a UserSpec which they are scanned into
+var UserColumns = database.Columns[types.UserSpec]{
+	{Name: "id", Field: func(user *types.UserSpec) any { return &user.ID }},
+	{Name: "names", Field: func(user *types.UserSpec) any { return &user.Names }},
+	{Name: "email", Field: func(user *types.UserSpec) any { return &user.Email }},
+	{Name: "password", Field: func(user *types.UserSpec) any { return &user.Password }},
+	{Name: "phoneNumber", Field: func(user *types.UserSpec) any { return &user.PhoneNumber }},
+	{Name: "birthday", Field: func(user *types.UserSpec) any { return &user.Birthday }},
+	{Name: "contractAgreement", Field: func(user *types.UserSpec) any { return &user.ContractAgreement }},
+	{Name: "disabled", Field: func(user *types.UserSpec) any { return &user.Disabled }},
+	{Name: "registered", Field: func(user *types.UserSpec) any { return &user.Registered }},
+	{Name: "lastLogin", Field: func(user *types.UserSpec) any { return &user.LastLogin }},
+	{Name: "authNonce", Field: func(user *types.UserSpec) any { return &user.AuthNonce }},
+	{Name: "creationTimestamp", Field: func(user *types.UserSpec) any { return &user.CreationTimestamp }},
+	{Name: "modificationTimestamp", Field: func(user *types.UserSpec) any { return &user.ModificationTimestamp }},
+	{Name: "deletionTimestamp", Field: func(user *types.UserSpec) any { return &user.DeletionTimestamp }},
+	{Name: "expiryTimestamp", Field: func(user *types.UserSpec) any { return &user.ExpiryTimestamp }},
+	{Name: "flatId", Field: func(user *types.UserSpec) any { return &user.FlatID }},
 }
 
-// userObjectFromRows ...
-// construct a UserSpec from database rows
-func userObjectFromRows(rows *sql.Rows) (user types.UserSpec, err error) {
-	if err := rows.Scan(&user.ID, &user.Names, &user.Email, &user.Password, &user.PhoneNumber, &user.Birthday, &user.ContractAgreement, &user.Disabled, &user.Registered, &user.LastLogin, &user.AuthNonce, &user.CreationTimestamp, &user.ModificationTimestamp, &user.DeletionTimestamp); err != nil {
-		return types.UserSpec{}, err
-	}
-	if err := rows.Err(); err != nil {
-		return types.UserSpec{}, err
-	}
-	return user, nil
-}
+// userColumnsRestricted ...
+// the columns of users, except for the secrets which are never returned from changes to profiles
+var userColumnsRestricted = UserColumns.Without("password", "authNonce")
 
 // GetByID ...
 // given an id, return a UserSpec
 func (m *Manager) GetByID(id string, includePassword bool) (user types.UserSpec, err error) {
-	sqlStatement := `select * from users where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := fmt.Sprintf(`select %v from users where flatId = $1 and id = $2`, UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return types.UserSpec{}, err
 	}
@@ -322,7 +406,7 @@ func (m *Manager) GetByID(id string, includePassword bool) (user types.UserSpec,
 		}
 	}()
 	for rows.Next() {
-		user, err = userObjectFromRows(rows)
+		user, err = UserColumns.Scan(rows)
 		if err != nil {
 			return types.UserSpec{}, err
 		}
@@ -344,8 +428,8 @@ func (m *Manager) GetByID(id string, includePassword bool) (user types.UserSpec,
 // GetByEmail ...
 // given a email, return a UserSpec
 func (m *Manager) GetByEmail(email string, includePassword bool) (user types.UserSpec, err error) {
-	sqlStatement := `select * from users where email = $1`
-	rows, err := m.db.Query(sqlStatement, email)
+	sqlStatement := fmt.Sprintf(`select %v from users where flatId = $1 and email = $2`, UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, email)
 	if err != nil {
 		return types.UserSpec{}, err
 	}
@@ -355,7 +439,7 @@ func (m *Manager) GetByEmail(email string, includePassword bool) (user types.Use
 		}
 	}()
 	for rows.Next() {
-		user, err = userObjectFromRows(rows)
+		user, err = UserColumns.Scan(rows)
 		if err != nil {
 			return types.UserSpec{}, err
 		}
@@ -377,8 +461,8 @@ func (m *Manager) GetByEmail(email string, includePassword bool) (user types.Use
 // DeleteByID ...
 // given an id, remove the user account from all the groups and then delete a user account
 func (m *Manager) DeleteByID(id string) (err error) {
-	sqlStatement := `delete from users where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := `delete from users where flatId = $1 and id = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return err
 	}
@@ -405,7 +489,19 @@ func (m *Manager) DeactivateByID(id string) (err error) {
 	if err := m.UserCreationSecrets().DeleteByUserID(id); err != nil {
 		return err
 	}
-	sqlStatement := `
+	if err := m.UserPasswordResetSecrets().DeleteByUserID(id); err != nil {
+		return err
+	}
+	if err := m.UserSessions().DeleteByUserID(id); err != nil {
+		return err
+	}
+	if err := m.UserAccessTokens().DeleteByUserID(id); err != nil {
+		return err
+	}
+	if err := m.DeleteTOTP(id); err != nil {
+		return err
+	}
+	sqlStatement := fmt.Sprintf(`
         update users
         set
           names = '(Deleted User)',
@@ -413,9 +509,9 @@ func (m *Manager) DeactivateByID(id string) (err error) {
           birthday = 0,
           phoneNumber = '',
           password = '',
-          deletionTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int
-        where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+          deletionTimestamp = %v
+        where flatId = $1 and id = $2`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return err
 	}
@@ -428,33 +524,79 @@ func (m *Manager) DeactivateByID(id string) (err error) {
 }
 
 // CheckUserPassword ...
-// given an email and password, find the user account with the email, return if the password matches
+// given an email and password, find the user account with the email, return if the password matches.
+// When it matches a hash from an outdated algorithm, the hash is upgraded
 func (m *Manager) CheckUserPassword(email string, password string) (matches bool, err error) {
 	user, err := m.GetByEmail(email, true)
 	if err != nil {
 		return false, err
 	}
-	passwordHashed := common.HashSHA512(password)
-	if matches := subtle.ConstantTimeCompare([]byte(user.Password), []byte(passwordHashed)) == 1; matches {
-		return true, nil
+	matches, needsRehash, err := common.CheckPasswordHash(password, user.Password)
+	if err != nil {
+		return false, err
+	}
+	if !matches {
+		return false, nil
 	}
-	return false, nil
+	if needsRehash {
+		if err := m.SetPasswordHash(user.ID, password); err != nil {
+			slog.Error("Failed to upgrade password hash", "id", user.ID, "error", err)
+		}
+	}
+	return true, nil
+}
+
+// SetPasswordHash ...
+// replaces the stored hash of a user account's password, without changing the password
+func (m *Manager) SetPasswordHash(id string, password string) (err error) {
+	passwordHashed, err := common.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	sqlStatement := `update users set password = $3 where flatId = $1 and id = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id, passwordHashed)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("Failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// CountLegacyPasswordHashes ...
+// returns how many user accounts have a password hash which is yet to be upgraded
+func (m *Manager) CountLegacyPasswordHashes() (count int, err error) {
+	sqlStatement := `select count(*) from users where flatId = $1 and password like '$sha512$%' and deletionTimestamp = 0`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("Failed to close rows", "error", err)
+		}
+	}()
+	rows.Next()
+	if err := rows.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 // GenerateJWTauthToken ...
 // given an email, return a usable JWT token
-func (m *Manager) GenerateJWTauthToken(id string, authNonce string, expiresIn time.Duration) (tokenString string, err error) {
-	if expiresIn == 0 {
-		expiresIn = 24 * 5
-	}
+func (m *Manager) GenerateJWTauthToken(id string, authNonce string, sessionID string, expirationTime time.Time) (tokenString string, err error) {
 	secret, err := m.system.GetJWTsecret()
 	if err != nil {
 		return "", err
 	}
-	expirationTime := time.Now().Add(time.Hour * expiresIn)
 	token := jwt.NewWithClaims(jwtAlg, types.JWTclaim{
 		ID:        id,
 		AuthNonce: authNonce,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -468,7 +610,7 @@ func (m *Manager) GenerateJWTauthToken(id string, authNonce string, expiresIn ti
 }
 
 // GetAuthTokenFromHeader ...
-// given a request, retrieve the authoriation value
+// given a request, retrieve the authoriation value, either a JWT or a personal access token
 func GetAuthTokenFromHeader(r *http.Request) (string, error) {
 	c, err := r.Cookie("token")
 	if err != nil && !errors.Is(err, http.ErrNoCookie) {
@@ -481,25 +623,29 @@ func GetAuthTokenFromHeader(r *http.Request) (string, error) {
 		return "", ErrAuthorizationHeaderNotFound
 	}
 	authorizationHeader := strings.Split(tokenHeader, " ")
-	if authorizationHeader[0] != "bearer" || len(authorizationHeader) <= 1 {
+	if !strings.EqualFold(authorizationHeader[0], "bearer") || len(authorizationHeader) <= 1 {
 		return "", ErrAuthorizationHeaderNotFound
 	}
 	return authorizationHeader[1], nil
 }
 
 // ValidateJWTauthToken ...
-// given an HTTP request and Authorization header, return if auth is valid
+// given an HTTP request and Authorization header, return if auth is valid.
+// The token may either be a JWT from logging in or a personal access token
 func (m *Manager) ValidateJWTauthToken(r *http.Request) (valid bool, tokenClaims *types.JWTclaim, err error) {
-	secret, err := m.system.GetJWTsecret()
-	if err != nil {
-		slog.Error("Unable to get JWT secret", "error", err)
-		return false, &types.JWTclaim{}, ErrFailedToFindSystemAuthSecret
-	}
 	tokenHeaderJWT, err := GetAuthTokenFromHeader(r)
 	if err != nil {
 		slog.Error("Unable to get auth token from header", "error", err)
 		return false, &types.JWTclaim{}, err
 	}
+	if strings.HasPrefix(tokenHeaderJWT, AccessTokenPrefix) {
+		return m.validateAccessToken(tokenHeaderJWT)
+	}
+	secret, err := m.system.GetJWTsecret()
+	if err != nil {
+		slog.Error("Unable to get JWT secret", "error", err)
+		return false, &types.JWTclaim{}, ErrFailedToFindSystemAuthSecret
+	}
 	claims := &types.JWTclaim{}
 	token, err := jwt.ParseWithClaims(tokenHeaderJWT, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -534,18 +680,38 @@ func (m *Manager) ValidateJWTauthToken(r *http.Request) (valid bool, tokenClaims
 		return false, &types.JWTclaim{}, ErrAuthInvalid
 	}
 
+	session, err := m.UserSessions().Get(reqClaims.SessionID)
+	if err != nil || session.UserID != user.ID {
+		if err != nil && !errors.Is(err, ErrUserSessionNotFound) {
+			slog.Error("Unable to get session by ID", "error", err)
+		}
+		return false, &types.JWTclaim{}, ErrAuthInvalid
+	}
+	if err := m.UserSessions().Touch(session); err != nil {
+		slog.Error("Unable to update session last seen time", "id", session.ID, "error", err)
+	}
+
 	if user.Disabled {
 		return false, &types.JWTclaim{}, ErrUserAccountIsDisabled
 	}
+	if IsExpired(user) {
+		return false, &types.JWTclaim{}, ErrUserAccountIsExpired
+	}
 
 	return token.Valid, reqClaims, nil
 }
 
 // InvalidateAllAuthTokens ...
-// updates the authNonce to invalidate auth tokens
+// updates the authNonce to invalidate auth tokens, and revokes personal access tokens
 func (m *Manager) InvalidateAllAuthTokens(id string) (err error) {
-	sqlStatement := `update users set authNonce = md5(random()::text || clock_timestamp()::text)::uuid where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	if err := m.UserSessions().DeleteByUserID(id); err != nil {
+		return err
+	}
+	if err := m.UserAccessTokens().DeleteByUserID(id); err != nil {
+		return err
+	}
+	sqlStatement := fmt.Sprintf(`update users set authNonce = %v where flatId = $1 and id = $2`, database.DialectOf(m.db).NewID())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return err
 	}
@@ -608,14 +774,17 @@ func (m *Manager) Patch(id string, userAccount types.UserSpec) (userAccountPatch
 	if !valid || err != nil {
 		return types.UserSpec{}, err
 	}
-	passwordHashed := common.HashSHA512(userAccount.Password)
-	if noUpdatePassword {
-		passwordHashed = userAccount.Password
+	passwordHashed := userAccount.Password
+	if !noUpdatePassword {
+		passwordHashed, err = common.HashPassword(userAccount.Password)
+		if err != nil {
+			return types.UserSpec{}, err
+		}
 	}
 
-	sqlStatement := `update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $1
-                         returning id, names, email, phoneNumber, birthday, contractAgreement, disabled, registered, lastLogin, creationTimestamp, modificationTimestamp, deletionTimestamp`
-	rows, err := m.db.Query(sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday)
+	sqlStatement := fmt.Sprintf(`update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, modificationTimestamp = %v where id = $1 and flatId = $7
+                         returning %v`, database.DialectOf(m.db).Now(), userColumnsRestricted)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, m.flatID)
 	if err != nil {
 		// TODO add roll back, if there's failure
 		return types.UserSpec{}, err
@@ -626,7 +795,7 @@ func (m *Manager) Patch(id string, userAccount types.UserSpec) (userAccountPatch
 		}
 	}()
 	for rows.Next() {
-		userAccountPatched, err = userObjectFromRowsRestricted(rows)
+		userAccountPatched, err = userColumnsRestricted.Scan(rows)
 		if err != nil {
 			slog.Error("Failed to patch user account", "error", err)
 			return types.UserSpec{}, ErrFailedToPatchProfile
@@ -663,14 +832,20 @@ func (m *Manager) PatchAsAdmin(id string, userAccount types.UserSpec) (userAccou
 	if !valid || err != nil {
 		return types.UserSpec{}, err
 	}
-	passwordHashed := common.HashSHA512(userAccount.Password)
-	if noUpdatePassword {
-		passwordHashed = userAccount.Password
+	if userAccount.ExpiryTimestamp != existingUserAccount.ExpiryTimestamp && !expiryIsInFuture(userAccount.ExpiryTimestamp) {
+		return types.UserSpec{}, ErrUserAccountInvalidExpiry
+	}
+	passwordHashed := userAccount.Password
+	if !noUpdatePassword {
+		passwordHashed, err = common.HashPassword(userAccount.Password)
+		if err != nil {
+			return types.UserSpec{}, err
+		}
 	}
 
-	sqlStatement := `update users set names = $1, email = $2, password = $3, phoneNumber = $4, birthday = $5, contractAgreement = $6, registered = $7, lastLogin = $8, authNonce = $9, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $10
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement, userAccount.Registered, userAccount.LastLogin, userAccount.AuthNonce, id)
+	sqlStatement := fmt.Sprintf(`update users set names = $1, email = $2, password = $3, phoneNumber = $4, birthday = $5, contractAgreement = $6, registered = $7, lastLogin = $8, authNonce = $9, expiryTimestamp = $10, modificationTimestamp = %v where id = $11 and flatId = $12
+                         returning %v`, database.DialectOf(m.db).Now(), UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement, userAccount.Registered, userAccount.LastLogin, userAccount.AuthNonce, userAccount.ExpiryTimestamp, id, m.flatID)
 	if err != nil {
 		// TODO add roll back, if there's failure
 		return types.UserSpec{}, err
@@ -681,7 +856,7 @@ func (m *Manager) PatchAsAdmin(id string, userAccount types.UserSpec) (userAccou
 		}
 	}()
 	for rows.Next() {
-		userAccountPatched, err = userObjectFromRows(rows)
+		userAccountPatched, err = UserColumns.Scan(rows)
 		if err != nil {
 			slog.Error("Failed to get user object from rows", "error", err)
 			return types.UserSpec{}, ErrFailedToPatchUserAccount
@@ -717,11 +892,14 @@ func (m *Manager) Update(id string, userAccount types.UserSpec) (userAccountUpda
 			return types.UserSpec{}, ErrEmailAddressAlreadyUsed
 		}
 	}
-	passwordHashed := common.HashSHA512(userAccount.Password)
+	passwordHashed, err := common.HashPassword(userAccount.Password)
+	if err != nil {
+		return types.UserSpec{}, err
+	}
 
-	sqlStatement := `update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, contractAgreement = $7, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $1
-                         returning id, names, email, phoneNumber, birthday, contractAgreement, disabled, registered, lastLogin, creationTimestamp, modificationTimestamp, deletionTimestamp`
-	rows, err := m.db.Query(sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement)
+	sqlStatement := fmt.Sprintf(`update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, contractAgreement = $7, modificationTimestamp = %v where id = $1 and flatId = $8
+                         returning %v`, database.DialectOf(m.db).Now(), userColumnsRestricted)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement, m.flatID)
 	if err != nil {
 		// TODO add roll back, if there's failure
 		return types.UserSpec{}, err
@@ -732,7 +910,7 @@ func (m *Manager) Update(id string, userAccount types.UserSpec) (userAccountUpda
 		}
 	}()
 	for rows.Next() {
-		userAccountUpdated, err = userObjectFromRowsRestricted(rows)
+		userAccountUpdated, err = userColumnsRestricted.Scan(rows)
 		if err != nil {
 			slog.Info("Failed to get user object from rows", "error", err, "restricted", true)
 			return types.UserSpec{}, ErrFailedToUpdateProfile
@@ -762,11 +940,17 @@ func (m *Manager) UpdateAsAdmin(id string, userAccount types.UserSpec) (userAcco
 			return types.UserSpec{}, ErrEmailAddressAlreadyUsed
 		}
 	}
-	passwordHashed := common.HashSHA512(userAccount.Password)
+	if userAccount.ExpiryTimestamp != existingUserAccount.ExpiryTimestamp && !expiryIsInFuture(userAccount.ExpiryTimestamp) {
+		return types.UserSpec{}, ErrUserAccountInvalidExpiry
+	}
+	passwordHashed, err := common.HashPassword(userAccount.Password)
+	if err != nil {
+		return types.UserSpec{}, err
+	}
 
-	sqlStatement := `update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, contractAgreement = $7, registered = $8, lastLogin = $9, modificationTimestamp = date_part('epoch',CURRENT_TIMESTAMP)::int where id = $1
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement, userAccount.Registered, userAccount.LastLogin)
+	sqlStatement := fmt.Sprintf(`update users set names = $2, email = $3, password = $4, phoneNumber = $5, birthday = $6, contractAgreement = $7, registered = $8, lastLogin = $9, expiryTimestamp = $10, modificationTimestamp = %v where id = $1 and flatId = $11
+                         returning %v`, database.DialectOf(m.db).Now(), UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, userAccount.Names, userAccount.Email, passwordHashed, userAccount.PhoneNumber, userAccount.Birthday, userAccount.ContractAgreement, userAccount.Registered, userAccount.LastLogin, userAccount.ExpiryTimestamp, m.flatID)
 	if err != nil {
 		// TODO add roll back, if there's failure
 		return types.UserSpec{}, err
@@ -777,7 +961,7 @@ func (m *Manager) UpdateAsAdmin(id string, userAccount types.UserSpec) (userAcco
 		}
 	}()
 	for rows.Next() {
-		userAccountUpdated, err = userObjectFromRows(rows)
+		userAccountUpdated, err = UserColumns.Scan(rows)
 		if userAccountUpdated.ID == "" {
 			return types.UserSpec{}, ErrFailedToUpdateProfile
 		}
@@ -809,22 +993,25 @@ func userCreationSecretsFromRows(rows *sql.Rows) (creationSecret types.UserCreat
 }
 
 type userCreationSecretManager struct {
-	db *sql.DB
-	m  *Manager
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
 }
 
 func (m *Manager) UserCreationSecrets() *userCreationSecretManager {
 	return &userCreationSecretManager{
-		db: m.db,
-		m:  m,
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
 	}
 }
 
 // List ...
 // returns all UserCreationSecrets from the database
 func (m *userCreationSecretManager) List(secretsSelector types.UserCreationSecretSelector) (creationSecrets []types.UserCreationSecretSpec, err error) {
-	sqlStatement := `select * from user_creation_secret`
-	rows, err := m.db.Query(sqlStatement)
+	sqlStatement := `select * from user_creation_secret
+                         where userId in (select id from users where flatId = $1)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.m.flatID)
 	if err != nil {
 		return []types.UserCreationSecretSpec{}, err
 	}
@@ -858,8 +1045,9 @@ func (m *userCreationSecretManager) List(secretsSelector types.UserCreationSecre
 // Get ...
 // returns a UserCreationSecret by it's id from the database
 func (m *userCreationSecretManager) Get(id string) (creationSecret types.UserCreationSecretSpec, err error) {
-	sqlStatement := `select * from user_creation_secret where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := `select * from user_creation_secret
+                         where id = $1 and userId in (select id from users where flatId = $2)`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, m.m.flatID)
 	if err != nil {
 		return types.UserCreationSecretSpec{}, err
 	}
@@ -886,7 +1074,7 @@ func (m *userCreationSecretManager) Create(userID string) (userCreationSecretIns
 	sqlStatement := `insert into user_creation_secret (userId)
                          values ($1)
                          returning *`
-	rows, err := m.db.Query(sqlStatement, userID)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
 	if err != nil {
 		return types.UserCreationSecretSpec{}, err
 	}
@@ -908,7 +1096,7 @@ func (m *userCreationSecretManager) Create(userID string) (userCreationSecretIns
 // deletes the acccount creation secret, after it's been used
 func (m *userCreationSecretManager) Delete(id string) (err error) {
 	sqlStatement := `delete from user_creation_secret where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id)
 	if err != nil {
 		return err
 	}
@@ -924,7 +1112,7 @@ func (m *userCreationSecretManager) Delete(id string) (err error) {
 // deletes the acccount creation secret by userid, after it's been used
 func (m *userCreationSecretManager) DeleteByUserID(userID string) (err error) {
 	sqlStatement := `delete from user_creation_secret where userId = $1`
-	rows, err := m.db.Query(sqlStatement, userID)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
 	if err != nil {
 		return err
 	}
@@ -938,7 +1126,7 @@ func (m *userCreationSecretManager) DeleteByUserID(userID string) (err error) {
 
 // ConfirmUserAccount ...
 // confirms the user account
-func (m *Manager) ConfirmUserAccount(id string, secret string, user types.UserSpec) (tokenString string, err error) {
+func (m *Manager) ConfirmUserAccount(id string, secret string, user types.UserSpec, session types.UserSessionSpec) (tokenString string, err error) {
 	if user.Password == "" {
 		return "", ErrUserAccountConfirmPasswordRequiredForRegistration
 	}
@@ -976,7 +1164,7 @@ func (m *Manager) ConfirmUserAccount(id string, secret string, user types.UserSp
 	if err != nil {
 		return "", err
 	}
-	tokenString, err = m.GenerateJWTauthToken(userInDB.ID, userInDB.AuthNonce, 0)
+	tokenString, err = m.NewSession(userInDB, session)
 	if err != nil {
 		return "", err
 	}
@@ -986,8 +1174,8 @@ func (m *Manager) ConfirmUserAccount(id string, secret string, user types.UserSp
 // UserAccountExists ...
 // returns bool if user account exists
 func (m *Manager) UserAccountExists(id string) (exists bool, err error) {
-	sqlStatement := `select id from users where id = $1`
-	rows, err := m.db.Query(sqlStatement, id)
+	sqlStatement := `select id from users where flatId = $1 and id = $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return false, err
 	}
@@ -1012,9 +1200,12 @@ func (m *Manager) UserAccountExists(id string) (exists bool, err error) {
 // GenerateNewAuthNonce ...
 // given a user account id, generates a new auth nonce to reset all logins and invalidate all issued JWTs
 func (m *Manager) GenerateNewAuthNonce(id string) (err error) {
-	sqlStatement := `update users set authNonce = md5(random()::text || clock_timestamp()::text)::uuid where id = $1
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, id)
+	if err := m.UserSessions().DeleteByUserID(id); err != nil {
+		return err
+	}
+	sqlStatement := fmt.Sprintf(`update users set authNonce = %v where flatId = $1 and id = $2
+                         returning %v`, database.DialectOf(m.db).NewID(), UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, id)
 	if err != nil {
 		return err
 	}
@@ -1024,7 +1215,7 @@ func (m *Manager) GenerateNewAuthNonce(id string) (err error) {
 		}
 	}()
 	for rows.Next() {
-		_, err = userObjectFromRows(rows)
+		_, err = UserColumns.Scan(rows)
 		if err != nil {
 			return err
 		}
@@ -1035,9 +1226,9 @@ func (m *Manager) GenerateNewAuthNonce(id string) (err error) {
 // PatchDisabledAsAdmin ...
 // patches is user account to be disabled
 func (m *Manager) PatchDisabledAsAdmin(id string, disabled bool) (userAccount types.UserSpec, err error) {
-	sqlStatement := `update users set disabled = $2 where id = $1
-                         returning *`
-	rows, err := m.db.Query(sqlStatement, id, disabled)
+	sqlStatement := fmt.Sprintf(`update users set disabled = $2 where id = $1 and flatId = $3
+                         returning %v`, UserColumns)
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, disabled, m.flatID)
 	if err != nil {
 		return userAccount, err
 	}
@@ -1047,7 +1238,7 @@ func (m *Manager) PatchDisabledAsAdmin(id string, disabled bool) (userAccount ty
 		}
 	}()
 	for rows.Next() {
-		userAccount, err = userObjectFromRows(rows)
+		userAccount, err = UserColumns.Scan(rows)
 		if err != nil {
 			return types.UserSpec{}, err
 		}
@@ -1055,6 +1246,55 @@ func (m *Manager) PatchDisabledAsAdmin(id string, disabled bool) (userAccount ty
 	return userAccount, nil
 }
 
+// expiryIsInFuture ...
+// returns whether an expiry is unset or yet to pass
+func expiryIsInFuture(expiryTimestamp int64) bool {
+	return expiryTimestamp == 0 || expiryTimestamp > time.Now().Unix()
+}
+
+// IsExpired ...
+// returns if a user account has passed it's expiry, even when it is not yet disabled
+func IsExpired(user types.UserSpec) bool {
+	return !expiryIsInFuture(user.ExpiryTimestamp)
+}
+
+// DisableExpired ...
+// disables the user accounts which have passed their expiry, such as guests, and logs them out
+func (m *Manager) DisableExpired() error {
+	sqlStatement := `select id from users
+                         where flatId = $1 and disabled = false and deletionTimestamp = 0 and expiryTimestamp != 0 and expiryTimestamp <= $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, m.flatID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := m.PatchDisabledAsAdmin(id, true); err != nil {
+			return err
+		}
+		if err := m.InvalidateAllAuthTokens(id); err != nil {
+			return err
+		}
+		slog.Info("Disabled expired user account", "id", id)
+	}
+	return nil
+}
+
 // RemoveUnreferencedDeletedUsers ...
 // deletes users that aren't referenced in any tables
 func (m *Manager) RemoveUnreferencedDeletedUsers() error {
@@ -1069,8 +1309,13 @@ func (m *Manager) RemoveUnreferencedDeletedUsers() error {
 	sqlStatement := `
       select author, authorlast from shopping_list
       union select author, authorlast from shopping_item
-      union select author, authorlast from shopping_list_tag`
-	rows, err := m.db.Query(sqlStatement)
+      union select author, authorlast from shopping_list_tag
+      union select author, authorlast from task
+      union select author, authorlast from expense
+      union select payer, payer from expense
+      union select userId, userId from expense_share
+      union select author, author from shopping_attachment`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,220 @@
+/*
+  users
+    usersession
+      devices which user accounts are logged in on
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// userSessionLastSeenInterval is how often the last seen time of a session is updated,
+	// to avoid writing on every request
+	userSessionLastSeenInterval = time.Minute
+	// userSessionUserAgentMaxLength is how much of a user agent is stored
+	userSessionUserAgentMaxLength = 512
+)
+
+// userSessionFromRows ...
+// constructs a UserSessionSpec from rows
+func userSessionFromRows(rows *sql.Rows) (session types.UserSessionSpec, err error) {
+	if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress, &session.LastSeenTimestamp, &session.ExpiryTimestamp, &session.CreationTimestamp, &session.ModificationTimestamp, &session.DeletionTimestamp); err != nil {
+		return types.UserSessionSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserSessionSpec{}, err
+	}
+	return session, nil
+}
+
+type userSessionManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserSessions() *userSessionManager {
+	return &userSessionManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// Get ...
+// returns an unexpired session by it's id
+func (m *userSessionManager) Get(id string) (session types.UserSessionSpec, err error) {
+	sqlStatement := `select * from user_session where id = $1 and expiryTimestamp > $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id, time.Now().Unix())
+	if err != nil {
+		return types.UserSessionSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		session, err = userSessionFromRows(rows)
+		if err != nil {
+			return types.UserSessionSpec{}, err
+		}
+	}
+	if session.ID == "" {
+		return types.UserSessionSpec{}, ErrUserSessionNotFound
+	}
+	return session, nil
+}
+
+// List ...
+// returns the unexpired sessions of a user account, most recently seen first
+func (m *userSessionManager) List(userID string) (sessions []types.UserSessionSpec, err error) {
+	sqlStatement := `select * from user_session where userId = $1 and expiryTimestamp > $2 order by lastSeenTimestamp desc`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, time.Now().Unix())
+	if err != nil {
+		return []types.UserSessionSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		session, err := userSessionFromRows(rows)
+		if err != nil {
+			return []types.UserSessionSpec{}, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// Create ...
+// creates a session for a user account which expires at the given time
+func (m *userSessionManager) Create(userID string, session types.UserSessionSpec, expiry time.Time) (sessionInserted types.UserSessionSpec, err error) {
+	userAgent := session.UserAgent
+	if len(userAgent) > userSessionUserAgentMaxLength {
+		userAgent = userAgent[:userSessionUserAgentMaxLength]
+	}
+	sqlStatement := `insert into user_session (userId, userAgent, ipAddress, expiryTimestamp)
+                         values ($1, $2, $3, $4)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, userAgent, session.IPAddress, expiry.Unix())
+	if err != nil {
+		return types.UserSessionSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		sessionInserted, err = userSessionFromRows(rows)
+		if err != nil {
+			return types.UserSessionSpec{}, err
+		}
+	}
+	return sessionInserted, nil
+}
+
+// Touch ...
+// updates the last seen time of a session, if it hasn't recently been
+func (m *userSessionManager) Touch(session types.UserSessionSpec) (err error) {
+	now := time.Now()
+	if now.Sub(time.Unix(session.LastSeenTimestamp, 0)) < userSessionLastSeenInterval {
+		return nil
+	}
+	sqlStatement := `update user_session set lastSeenTimestamp = $2 where id = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, session.ID, now.Unix())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Delete ...
+// deletes a session, revoking the auth token issued for it
+func (m *userSessionManager) Delete(id string) (err error) {
+	sqlStatement := `delete from user_session where id = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// DeleteByUserID ...
+// deletes the sessions of a user account, revoking all of it's auth tokens
+func (m *userSessionManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_session where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// DeleteExpired ...
+// deletes sessions which auth tokens are no longer valid for
+func (m *userSessionManager) DeleteExpired() error {
+	sqlStatement := `delete from user_session where expiryTimestamp <= $1`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired user sessions", "count", n)
+	}
+	return nil
+}
+
+// NewSession ...
+// creates a session for a user account on a device, returning an auth token for it
+func (m *Manager) NewSession(user types.UserSpec, session types.UserSessionSpec) (tokenString string, err error) {
+	expiry := time.Now().Add(authTokenExpiry)
+	sessionInserted, err := m.UserSessions().Create(user.ID, session, expiry)
+	if err != nil {
+		return "", err
+	}
+	return m.GenerateJWTauthToken(user.ID, user.AuthNonce, sessionInserted.ID, expiry)
+}
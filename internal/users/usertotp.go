@@ -0,0 +1,560 @@
+/*
+  users
+    usertotp
+      two-factor authentication with time-based one-time passwords
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gitlab.com/flattrack/flattrack/internal/common"
+	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/pkg/types"
+)
+
+const (
+	// userRecoveryCodeCount is how many recovery codes are generated when enabling two-factor authentication
+	userRecoveryCodeCount = 10
+	// userAuthChallengeExpiry is how long a login has to be completed with a second factor
+	userAuthChallengeExpiry = 5 * time.Minute
+	// userAuthChallengeMaxAttempts is how many incorrect codes may be given before a login must be started again
+	userAuthChallengeMaxAttempts = 5
+)
+
+// normaliseRecoveryCode ...
+// returns a recovery code without formatting, so that it's able to be compared
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+	return code
+}
+
+// userTOTPFromRows ...
+// constructs a UserTOTPSpec from rows
+func userTOTPFromRows(rows *sql.Rows) (totp types.UserTOTPSpec, err error) {
+	if err := rows.Scan(&totp.ID, &totp.UserID, &totp.Secret, &totp.Enabled, &totp.LastUsedCounter, &totp.CreationTimestamp, &totp.ModificationTimestamp, &totp.DeletionTimestamp); err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	return totp, nil
+}
+
+type userTOTPManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserTOTP() *userTOTPManager {
+	return &userTOTPManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// GetByUserID ...
+// returns the time-based one-time password secret of a user account
+func (m *userTOTPManager) GetByUserID(userID string) (totp types.UserTOTPSpec, err error) {
+	sqlStatement := `select * from user_totp where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		totp, err = userTOTPFromRows(rows)
+		if err != nil {
+			return types.UserTOTPSpec{}, err
+		}
+	}
+	if totp.ID == "" {
+		return types.UserTOTPSpec{}, ErrUserTOTPNotFound
+	}
+	return totp, nil
+}
+
+// Create ...
+// creates a new disabled time-based one-time password secret for a user account, replacing any existing one
+func (m *userTOTPManager) Create(userID string) (totpInserted types.UserTOTPSpec, err error) {
+	secret, err := common.GenerateTOTPSecret()
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	sqlStatement := fmt.Sprintf(`insert into user_totp (userId, secret)
+                         values ($1, $2)
+                         on conflict (userId) do update set secret = $2, enabled = false, lastUsedCounter = 0, modificationTimestamp = %v
+                         returning *`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, secret)
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		totpInserted, err = userTOTPFromRows(rows)
+		if err != nil {
+			return types.UserTOTPSpec{}, err
+		}
+	}
+	return totpInserted, nil
+}
+
+// Use ...
+// records a period as used for a user account, failing if it or a later one already has been.
+// This ensures that each code is only able to be used once
+func (m *userTOTPManager) Use(userID string, counter int64, enable bool) (used bool, err error) {
+	sqlStatement := fmt.Sprintf(`update user_totp set lastUsedCounter = $2, enabled = enabled or $3, modificationTimestamp = %v
+                         where userId = $1 and lastUsedCounter < $2
+                         returning id`, database.DialectOf(m.db).Now())
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, counter, enable)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		used = true
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return used, nil
+}
+
+// DeleteByUserID ...
+// deletes the time-based one-time password secret of a user account
+func (m *userTOTPManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_totp where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+type userRecoveryCodeManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserRecoveryCodes() *userRecoveryCodeManager {
+	return &userRecoveryCodeManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// Create ...
+// creates new recovery codes for a user account, replacing any existing ones.
+// Only hashes of the codes are stored, so the returned codes are the only copy of them
+func (m *userRecoveryCodeManager) Create(userID string) (codes []string, err error) {
+	if err := m.DeleteByUserID(userID); err != nil {
+		return []string{}, err
+	}
+	for range userRecoveryCodeCount {
+		codeBytes := make([]byte, 10)
+		if _, err := rand.Read(codeBytes); err != nil {
+			return []string{}, err
+		}
+		code := hex.EncodeToString(codeBytes)
+		sqlStatement := `insert into user_recovery_code (userId, code) values ($1, $2)`
+		rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, common.HashSHA512(code))
+		if err != nil {
+			return []string{}, err
+		}
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+		codes = append(codes, code[:10]+"-"+code[10:])
+	}
+	return codes, nil
+}
+
+// Redeem ...
+// deletes a recovery code of a user account, returning if it existed.
+// Deleting as it's read ensures that the code is only able to be used once
+func (m *userRecoveryCodeManager) Redeem(userID string, code string) (redeemed bool, err error) {
+	sqlStatement := `delete from user_recovery_code where userId = $1 and code = $2
+                         returning id`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, common.HashSHA512(normaliseRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		redeemed = true
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	return redeemed, nil
+}
+
+// DeleteByUserID ...
+// deletes the recovery codes of a user account
+func (m *userRecoveryCodeManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_recovery_code where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// userAuthChallengeFromRows ...
+// constructs a UserAuthChallengeSpec from rows
+func userAuthChallengeFromRows(rows *sql.Rows) (challenge types.UserAuthChallengeSpec, err error) {
+	if err := rows.Scan(&challenge.ID, &challenge.UserID, &challenge.Token, &challenge.Attempts, &challenge.ExpiryTimestamp, &challenge.CreationTimestamp, &challenge.ModificationTimestamp, &challenge.DeletionTimestamp); err != nil {
+		return types.UserAuthChallengeSpec{}, err
+	}
+	if err := rows.Err(); err != nil {
+		return types.UserAuthChallengeSpec{}, err
+	}
+	return challenge, nil
+}
+
+type userAuthChallengeManager struct {
+	db  *sql.DB
+	ctx context.Context
+	m   *Manager
+}
+
+func (m *Manager) UserAuthChallenges() *userAuthChallengeManager {
+	return &userAuthChallengeManager{
+		db:  m.db,
+		ctx: m.ctx,
+		m:   m,
+	}
+}
+
+// Get ...
+// returns an unexpired challenge by it's token
+func (m *userAuthChallengeManager) Get(token string) (challenge types.UserAuthChallengeSpec, err error) {
+	sqlStatement := `select * from user_auth_challenge where token = $1 and expiryTimestamp > $2`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, common.HashSHA512(token), time.Now().Unix())
+	if err != nil {
+		return types.UserAuthChallengeSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		challenge, err = userAuthChallengeFromRows(rows)
+		if err != nil {
+			return types.UserAuthChallengeSpec{}, err
+		}
+	}
+	if challenge.ID == "" {
+		return types.UserAuthChallengeSpec{}, ErrUserAuthChallengeNotFound
+	}
+	return challenge, nil
+}
+
+// Create ...
+// creates a challenge for a user account to complete their login with.
+// Only a hash of the token is stored, so the returned token is the only copy of it
+func (m *userAuthChallengeManager) Create(userID string) (challengeInserted types.UserAuthChallengeSpec, err error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return types.UserAuthChallengeSpec{}, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+	sqlStatement := `insert into user_auth_challenge (userId, token, expiryTimestamp)
+                         values ($1, $2, $3)
+                         returning *`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID, common.HashSHA512(token), time.Now().Add(userAuthChallengeExpiry).Unix())
+	if err != nil {
+		return types.UserAuthChallengeSpec{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	for rows.Next() {
+		challengeInserted, err = userAuthChallengeFromRows(rows)
+		if err != nil {
+			return types.UserAuthChallengeSpec{}, err
+		}
+	}
+	challengeInserted.Token = token
+	return challengeInserted, nil
+}
+
+// AddAttempt ...
+// records an incorrect code given for a challenge, deleting it once out of attempts
+func (m *userAuthChallengeManager) AddAttempt(id string) (err error) {
+	sqlStatement := `update user_auth_challenge set attempts = attempts + 1 where id = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id)
+	if err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		slog.Error("failed to close rows", "error", err)
+	}
+	sqlStatement = `delete from user_auth_challenge where id = $1 and attempts >= $2`
+	rows, err = m.db.QueryContext(m.ctx, sqlStatement, id, userAuthChallengeMaxAttempts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Delete ...
+// deletes a challenge
+func (m *userAuthChallengeManager) Delete(id string) (err error) {
+	sqlStatement := `delete from user_auth_challenge where id = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// DeleteByUserID ...
+// deletes the challenges of a user account
+func (m *userAuthChallengeManager) DeleteByUserID(userID string) (err error) {
+	sqlStatement := `delete from user_auth_challenge where userId = $1`
+	rows, err := m.db.QueryContext(m.ctx, sqlStatement, userID)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			slog.Error("failed to close rows", "error", err)
+		}
+	}()
+	return nil
+}
+
+// DeleteExpired ...
+// deletes challenges which are no longer able to be completed
+func (m *userAuthChallengeManager) DeleteExpired() error {
+	sqlStatement := `delete from user_auth_challenge where expiryTimestamp <= $1`
+	res, err := m.db.ExecContext(m.ctx, sqlStatement, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Removed expired auth challenges", "count", n)
+	}
+	return nil
+}
+
+// TOTPEnabled ...
+// returns whether a user account has two-factor authentication enabled
+func (m *Manager) TOTPEnabled(userID string) (enabled bool, err error) {
+	totp, err := m.UserTOTP().GetByUserID(userID)
+	if errors.Is(err, ErrUserTOTPNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.Enabled, nil
+}
+
+// EnrolTOTP ...
+// creates a time-based one-time password secret for a user account to add to their authenticator app.
+// Two-factor authentication isn't enabled until a code from it is confirmed with EnableTOTP
+func (m *Manager) EnrolTOTP(userID string) (totp types.UserTOTPSpec, err error) {
+	enabled, err := m.TOTPEnabled(userID)
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	if enabled {
+		return types.UserTOTPSpec{}, ErrUserTOTPAlreadyEnabled
+	}
+	user, err := m.GetByID(userID, false)
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	totp, err = m.UserTOTP().Create(userID)
+	if err != nil {
+		return types.UserTOTPSpec{}, err
+	}
+	totp.URI = common.TOTPURI(totp.Secret, user.Email)
+	return totp, nil
+}
+
+// EnableTOTP ...
+// enables two-factor authentication for a user account once a code from their authenticator app is confirmed,
+// returning recovery codes for if they lose access to it
+func (m *Manager) EnableTOTP(userID string, code string) (recoveryCodes []string, err error) {
+	totp, err := m.UserTOTP().GetByUserID(userID)
+	if err != nil {
+		return []string{}, err
+	}
+	if totp.Enabled {
+		return []string{}, ErrUserTOTPAlreadyEnabled
+	}
+	counter, valid, err := common.ValidateTOTPCode(totp.Secret, code, time.Now())
+	if err != nil {
+		return []string{}, err
+	}
+	if !valid {
+		return []string{}, ErrUserTOTPCodeInvalid
+	}
+	used, err := m.UserTOTP().Use(userID, counter, true)
+	if err != nil {
+		return []string{}, err
+	}
+	if !used {
+		return []string{}, ErrUserTOTPCodeInvalid
+	}
+	return m.UserRecoveryCodes().Create(userID)
+}
+
+// DisableTOTP ...
+// disables two-factor authentication for a user account, given a code from their authenticator app or a recovery code
+func (m *Manager) DisableTOTP(userID string, code string) (err error) {
+	valid, err := m.CheckSecondFactor(userID, code)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrUserTOTPCodeInvalid
+	}
+	return m.DeleteTOTP(userID)
+}
+
+// DeleteTOTP ...
+// removes two-factor authentication from a user account
+func (m *Manager) DeleteTOTP(userID string) (err error) {
+	if err := m.UserRecoveryCodes().DeleteByUserID(userID); err != nil {
+		return err
+	}
+	if err := m.UserAuthChallenges().DeleteByUserID(userID); err != nil {
+		return err
+	}
+	return m.UserTOTP().DeleteByUserID(userID)
+}
+
+// CheckSecondFactor ...
+// returns whether a code from a user account's authenticator app or a recovery code is valid, using it up
+func (m *Manager) CheckSecondFactor(userID string, code string) (valid bool, err error) {
+	totp, err := m.UserTOTP().GetByUserID(userID)
+	if err != nil {
+		return false, err
+	}
+	if !totp.Enabled {
+		return false, ErrUserTOTPNotFound
+	}
+	code = strings.TrimSpace(code)
+	counter, valid, err := common.ValidateTOTPCode(totp.Secret, code, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if valid {
+		return m.UserTOTP().Use(userID, counter, false)
+	}
+	return m.UserRecoveryCodes().Redeem(userID, code)
+}
+
+// NewAuthChallenge ...
+// returns a challenge for completing the login of a user account with a second factor
+func (m *Manager) NewAuthChallenge(userID string) (challenge types.UserAuthChallengeSpec, err error) {
+	return m.UserAuthChallenges().Create(userID)
+}
+
+// AnswerAuthChallenge ...
+// completes a login with a second factor, creating a session for it
+func (m *Manager) AnswerAuthChallenge(answer types.UserAuthChallengeAnswer, session types.UserSessionSpec) (tokenString string, err error) {
+	challenge, err := m.UserAuthChallenges().Get(answer.Token)
+	if err != nil {
+		return "", err
+	}
+	valid, err := m.CheckSecondFactor(challenge.UserID, answer.Code)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		if err := m.UserAuthChallenges().AddAttempt(challenge.ID); err != nil {
+			return "", err
+		}
+		return "", ErrUserTOTPCodeInvalid
+	}
+	if err := m.UserAuthChallenges().Delete(challenge.ID); err != nil {
+		return "", err
+	}
+	user, err := m.GetByID(challenge.UserID, false)
+	if err != nil {
+		return "", err
+	}
+	if user.ID == "" || user.Disabled || user.DeletionTimestamp != 0 {
+		return "", ErrFailedToFindUserAccount
+	}
+	if IsExpired(user) {
+		return "", ErrUserAccountIsExpired
+	}
+	return m.NewSession(user, session)
+}
@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists task_occurrence;
+drop table if exists task;
+
+commit;
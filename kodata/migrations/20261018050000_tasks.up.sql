@@ -0,0 +1,43 @@
+-- flattrack.task definition
+
+begin;
+
+create table if not exists task (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  name text not null,
+  description text not null default '',
+  assignees text[] not null default '{}',
+  recurrence text not null default 'Never',
+  startTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
+
+comment on table task is 'The table task is used for storing flat tasks and chores, and how often they recur';
+
+create table if not exists task_occurrence (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  taskId text not null,
+  assignees text[] not null default '{}',
+  dueTimestamp int not null,
+  completed bool not null default false,
+  completedBy text not null default '',
+  completedTimestamp int not null default 0,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (taskId) references task(id)
+);
+
+comment on table task_occurrence is 'The table task_occurrence is used for storing each generated instance of a task and whether it has been completed';
+
+commit;
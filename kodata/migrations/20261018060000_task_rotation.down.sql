@@ -0,0 +1,7 @@
+begin;
+
+alter table task
+drop column if exists rotate,
+drop column if exists rotationPosition;
+
+commit;
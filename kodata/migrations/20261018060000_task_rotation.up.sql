@@ -0,0 +1,10 @@
+begin;
+
+alter table task
+add column rotate bool not null default false,
+add column rotationPosition int not null default 0;
+
+comment on column task.rotate is 'Whether each occurrence of the task is assigned to the next flatmate in the rotation';
+comment on column task.rotationPosition is 'The index into the rotation of the next flatmate to be assigned';
+
+commit;
@@ -0,0 +1,6 @@
+begin;
+
+drop table if exists expense_share;
+drop table if exists expense;
+
+commit;
@@ -0,0 +1,44 @@
+-- flattrack.expense definition
+
+begin;
+
+create table if not exists expense (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  name text not null,
+  notes text not null default '',
+  amount float8 not null default 0,
+  payer text not null,
+  splitType text not null default 'Equal',
+  shoppingListId text not null default '',
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (payer) references users(id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
+
+comment on table expense is 'The table expense is used for storing shared household expenses and who paid for them';
+
+create table if not exists expense_share (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  expenseId text not null,
+  userId text not null,
+  value float8 not null default 0,
+  amount float8 not null default 0,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (expenseId) references expense(id),
+  foreign key (userId) references users(id)
+);
+
+comment on table expense_share is 'The table expense_share is used for storing how much of an expense each flatmate owes';
+
+commit;
@@ -0,0 +1,7 @@
+-- flattrack.user_password_reset_secret rollback definition
+
+begin;
+
+drop table if exists user_password_reset_secret;
+
+commit;
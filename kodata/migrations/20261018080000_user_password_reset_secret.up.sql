@@ -0,0 +1,20 @@
+-- flattrack.user_password_reset_secret definition
+
+begin;
+
+create table if not exists user_password_reset_secret (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  secret text not null,
+  expiryTimestamp int not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_password_reset_secret is 'The table user_password_reset_secret is used for storing hashed single use secrets for resetting the password of user accounts';
+
+commit;
@@ -0,0 +1,10 @@
+begin;
+
+-- argon2id password hashes are unable to be reverted,
+-- leaving those accounts to reset their password
+update users
+set
+  password = substring(password from length('$sha512$') + 1)
+where password like '$sha512$%';
+
+commit;
@@ -0,0 +1,12 @@
+begin;
+
+-- mark unsalted SHA-512 password hashes with their algorithm,
+-- so they are able to be told apart from argon2id hashes and counted until upgraded on login
+update users
+set
+  password = '$sha512$' || password
+where password is not null
+  and password <> ''
+  and password not like '$%';
+
+commit;
@@ -0,0 +1,7 @@
+-- flattrack.user_session rollback definition
+
+begin;
+
+drop table if exists user_session;
+
+commit;
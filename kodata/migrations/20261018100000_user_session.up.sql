@@ -0,0 +1,22 @@
+-- flattrack.user_session definition
+
+begin;
+
+create table if not exists user_session (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  userAgent text not null default '',
+  ipAddress text not null default '',
+  lastSeenTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  expiryTimestamp int not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_session is 'The table user_session is used for storing the devices which user accounts are logged in on, so that they are able to be individually revoked';
+
+commit;
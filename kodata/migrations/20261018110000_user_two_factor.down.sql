@@ -0,0 +1,11 @@
+-- flattrack.user_totp rollback definition
+
+begin;
+
+delete from settings where name = 'requireAdminTwoFactor';
+
+drop table if exists user_auth_challenge;
+drop table if exists user_recovery_code;
+drop table if exists user_totp;
+
+commit;
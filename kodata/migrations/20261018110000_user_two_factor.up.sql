@@ -0,0 +1,59 @@
+-- flattrack.user_totp definition
+
+begin;
+
+create table if not exists user_totp (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  secret text not null,
+  enabled bool not null default false,
+  lastUsedCounter bigint not null default 0,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (userId),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_totp is 'The table user_totp is used for storing the time-based one-time password secrets of user accounts';
+
+create table if not exists user_recovery_code (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  code text not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_recovery_code is 'The table user_recovery_code is used for storing hashed single use codes for logging in without a time-based one-time password';
+
+create table if not exists user_auth_challenge (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  token text not null,
+  attempts int not null default 0,
+  expiryTimestamp int not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (token),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_auth_challenge is 'The table user_auth_challenge is used for storing hashed short lived tokens for completing a login with a second factor';
+
+insert into settings
+            (name, value)
+values
+    ('requireAdminTwoFactor', 'false')
+    on conflict do nothing;
+
+commit;
@@ -0,0 +1,7 @@
+-- flattrack.oidc_auth_state rollback definition
+
+begin;
+
+drop table if exists oidc_auth_state;
+
+commit;
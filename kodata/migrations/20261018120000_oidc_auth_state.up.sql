@@ -0,0 +1,22 @@
+-- flattrack.oidc_auth_state definition
+
+begin;
+
+create table if not exists oidc_auth_state (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  state text not null,
+  nonce text not null,
+  codeVerifier text not null,
+  redirect text not null default '',
+  expiryTimestamp int not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (state)
+);
+
+comment on table oidc_auth_state is 'The table oidc_auth_state is used for storing single use state of in progress OpenID Connect logins, keyed by a hash of the state';
+
+commit;
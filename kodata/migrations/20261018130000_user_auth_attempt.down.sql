@@ -0,0 +1,7 @@
+-- flattrack.user_auth_attempt rollback definition
+
+begin;
+
+drop table if exists user_auth_attempt;
+
+commit;
@@ -0,0 +1,22 @@
+-- flattrack.user_auth_attempt definition
+
+begin;
+
+create table if not exists user_auth_attempt (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  kind text not null,
+  key text not null,
+  failures int not null default 0,
+  windowStartTimestamp int not null,
+  lockedUntilTimestamp int not null default 0,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (kind, key)
+);
+
+comment on table user_auth_attempt is 'The table user_auth_attempt is used for counting failed logins by IP address and by email, to lock them out once there are too many';
+
+commit;
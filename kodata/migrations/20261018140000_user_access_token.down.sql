@@ -0,0 +1,7 @@
+-- flattrack.user_access_token rollback definition
+
+begin;
+
+drop table if exists user_access_token;
+
+commit;
@@ -0,0 +1,24 @@
+-- flattrack.user_access_token definition
+
+begin;
+
+create table if not exists user_access_token (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  userId text not null,
+  name text not null,
+  token text not null,
+  scopes text[] not null default '{}',
+  expiryTimestamp int not null default 0,
+  lastUsedTimestamp int not null default 0,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (token),
+  foreign key (userId) references users(id)
+);
+
+comment on table user_access_token is 'The table user_access_token is used for storing hashes of long-lived tokens which user accounts create for scripts and integrations, limited to the scopes they were created with';
+
+commit;
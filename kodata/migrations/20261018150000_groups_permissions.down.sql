@@ -0,0 +1,7 @@
+begin;
+
+drop index if exists groups_name_unique;
+
+alter table groups drop column if exists permissions;
+
+commit;
@@ -0,0 +1,9 @@
+begin;
+
+alter table users add column if not exists expiryTimestamp int not null default 0;
+
+insert into groups (name, defaultGroup, description, permissions) values ('guest', false, 'Temporary user account, such as for someone subletting a room', '{shoppinglist:read,shoppinglist:write}') on conflict (name) do nothing;
+
+comment on column users.expiryTimestamp is 'The time after which the user account is disabled, or 0 for never';
+
+commit;
@@ -0,0 +1,29 @@
+-- flattrack.flats rollback definition
+
+begin;
+
+insert into system (name, value)
+  values ('initialized', coalesce((select initialized::text from flats where name = 'default'), 'false'));
+
+-- group names and login attempts are unique across the instance again, so only those of the default flat are kept
+delete from user_to_groups where groupId in (select id from groups where flatId <> (select id from flats where name = 'default'));
+delete from groups where flatId <> (select id from flats where name = 'default');
+delete from user_auth_attempt where flatId <> (select id from flats where name = 'default');
+
+alter table user_auth_attempt drop constraint if exists user_auth_attempt_flatid_kind_key_key;
+alter table user_auth_attempt add constraint user_auth_attempt_kind_key_key unique (kind, key);
+drop index if exists settings_flat_name_unique;
+drop index if exists groups_flat_name_unique;
+create unique index if not exists groups_name_unique on groups (name);
+alter table users drop column if exists flatId;
+alter table groups drop column if exists flatId;
+alter table settings drop column if exists flatId;
+alter table shopping_list drop column if exists flatId;
+alter table shopping_list_tag drop column if exists flatId;
+alter table task drop column if exists flatId;
+alter table expense drop column if exists flatId;
+alter table user_auth_attempt drop column if exists flatId;
+
+drop table if exists flats;
+
+commit;
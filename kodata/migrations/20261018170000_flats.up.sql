@@ -0,0 +1,81 @@
+-- flattrack.flats definition
+
+begin;
+
+create table if not exists flats (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  name text not null,
+  hostname text not null default '',
+  initialized bool not null default false,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (name)
+);
+
+create unique index if not exists flats_hostname_unique on flats (hostname) where hostname <> '';
+
+comment on table flats is 'The table flats is used for storing the flats which the FlatTrack instance hosts, each with their own user accounts, groups, settings and apps';
+
+insert into flats (name, initialized)
+  values ('default', coalesce((select value = 'true' from system where name = 'initialized'), false))
+  on conflict (name) do nothing;
+delete from system where name = 'initialized';
+
+alter table users add column if not exists flatId text;
+update users set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table users alter column flatId set not null;
+alter table users add constraint users_flatid_fkey foreign key (flatId) references flats(id);
+comment on column users.flatId is 'The flat which the row belongs to';
+
+alter table groups add column if not exists flatId text;
+update groups set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table groups alter column flatId set not null;
+alter table groups add constraint groups_flatid_fkey foreign key (flatId) references flats(id);
+comment on column groups.flatId is 'The flat which the row belongs to';
+
+alter table settings add column if not exists flatId text;
+update settings set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table settings alter column flatId set not null;
+alter table settings add constraint settings_flatid_fkey foreign key (flatId) references flats(id);
+comment on column settings.flatId is 'The flat which the row belongs to';
+
+alter table shopping_list add column if not exists flatId text;
+update shopping_list set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table shopping_list alter column flatId set not null;
+alter table shopping_list add constraint shopping_list_flatid_fkey foreign key (flatId) references flats(id);
+comment on column shopping_list.flatId is 'The flat which the row belongs to';
+
+alter table shopping_list_tag add column if not exists flatId text;
+update shopping_list_tag set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table shopping_list_tag alter column flatId set not null;
+alter table shopping_list_tag add constraint shopping_list_tag_flatid_fkey foreign key (flatId) references flats(id);
+comment on column shopping_list_tag.flatId is 'The flat which the row belongs to';
+
+alter table task add column if not exists flatId text;
+update task set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table task alter column flatId set not null;
+alter table task add constraint task_flatid_fkey foreign key (flatId) references flats(id);
+comment on column task.flatId is 'The flat which the row belongs to';
+
+alter table expense add column if not exists flatId text;
+update expense set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table expense alter column flatId set not null;
+alter table expense add constraint expense_flatid_fkey foreign key (flatId) references flats(id);
+comment on column expense.flatId is 'The flat which the row belongs to';
+
+alter table user_auth_attempt add column if not exists flatId text;
+update user_auth_attempt set flatId = (select id from flats where name = 'default') where flatId is null;
+alter table user_auth_attempt alter column flatId set not null;
+alter table user_auth_attempt add constraint user_auth_attempt_flatid_fkey foreign key (flatId) references flats(id);
+comment on column user_auth_attempt.flatId is 'The flat which the row belongs to';
+
+drop index if exists groups_name_unique;
+create unique index if not exists groups_flat_name_unique on groups (flatId, name);
+create unique index if not exists settings_flat_name_unique on settings (flatId, name);
+alter table user_auth_attempt drop constraint if exists user_auth_attempt_kind_key_key;
+alter table user_auth_attempt add constraint user_auth_attempt_flatid_kind_key_key unique (flatId, kind, key);
+
+commit;
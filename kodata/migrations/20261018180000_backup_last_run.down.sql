@@ -0,0 +1,5 @@
+begin;
+
+delete from system where name = 'backupLastRun';
+
+commit;
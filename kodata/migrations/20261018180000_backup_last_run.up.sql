@@ -0,0 +1,9 @@
+begin;
+
+insert into system
+            (name, value)
+values
+    ('backupLastRun', '{}')
+    on conflict do nothing;
+
+commit;
@@ -0,0 +1,28 @@
+-- flattrack.shopping_attachment definition
+
+begin;
+
+create table if not exists shopping_attachment (
+  id text default md5(random()::text || clock_timestamp()::text)::uuid not null,
+  listId text not null,
+  itemId text not null default '',
+  kind text not null,
+  name text not null default '',
+  contentType text not null,
+  size int not null default 0,
+  hasThumbnail bool not null default false,
+  author text not null,
+  creationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  modificationTimestamp int not null default date_part('epoch',CURRENT_TIMESTAMP)::int,
+  deletionTimestamp int not null default 0,
+  flatId text not null,
+
+  primary key (id),
+  foreign key (author) references users(id),
+  foreign key (flatId) references flats(id)
+);
+
+comment on table shopping_attachment is 'The table shopping_attachment is used for storing the photos of shopping items and receipts of shopping lists, which are kept in file storage';
+comment on column shopping_attachment.itemId is 'The item which a photo is of, or empty for a receipt of the list';
+
+commit;
@@ -0,0 +1,20 @@
+-- flattrack.users definition
+
+create table if not exists users (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  names text not null,
+  email text not null,
+  password text,
+  phoneNumber text,
+  birthday int,
+  contractAgreement bool default false not null,
+  disabled bool default false not null,
+  registered bool default false not null,
+  lastLogin int not null default 0,
+  authNonce text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
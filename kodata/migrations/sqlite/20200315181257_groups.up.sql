@@ -0,0 +1,14 @@
+create table if not exists groups (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  defaultGroup bool not null default false,
+  description text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
+
+insert into groups (name, defaultGroup, description) values ('flatmember', true, 'Standard user account');
+insert into groups (name, defaultGroup, description) values ('admin', false, 'Administrative user account, allows for access to Admin panel and API');
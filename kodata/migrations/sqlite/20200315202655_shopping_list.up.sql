@@ -0,0 +1,15 @@
+create table if not exists shopping_list (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  notes text,
+  author text not null,
+  authorLast text not null,
+  completed bool not null default false,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
@@ -0,0 +1,20 @@
+create table if not exists shopping_item (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  listId text not null,
+  name text not null,
+  price float8 not null default 0,
+  quantity int not null default 1,
+  notes text,
+  obtained bool not null default false,
+  tag text,
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (listId) references shopping_list(id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
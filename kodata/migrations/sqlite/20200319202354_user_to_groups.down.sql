@@ -0,0 +1,3 @@
+-- flattrack.user_to_groups rollback definition
+
+drop table if exists user_to_groups;
@@ -0,0 +1,14 @@
+-- flattrack.user_to_groups definition
+
+create table if not exists user_to_groups (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  groupId text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id),
+  foreign key (groupId) references groups(id)
+);
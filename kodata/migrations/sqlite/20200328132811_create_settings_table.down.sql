@@ -0,0 +1,3 @@
+-- flattrack.settings rollback definition
+
+drop table if exists settings;
@@ -0,0 +1,16 @@
+-- flattrack.settings definition
+
+create table if not exists settings (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  value text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
+
+insert into settings (name, value) values ('flatName', '');
+insert into settings (name, value) values ('timezone', '');
+insert into settings (name, value) values ('language', 'en_US');
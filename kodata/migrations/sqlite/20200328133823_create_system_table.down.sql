@@ -0,0 +1,3 @@
+-- flattrack.system rollback definition
+
+drop table if exists system;
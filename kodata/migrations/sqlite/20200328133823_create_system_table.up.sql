@@ -0,0 +1,17 @@
+-- flattrack.system definition
+
+create table if not exists system (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  value text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
+
+insert into system (name, value) values ('initialized', 'false');
+insert into system (name, value) values ('jwtSecret', (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))));
+insert into system (name, value) values ('instanceUUID', (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))));
+insert into system (name, value) values ('instanceInitialized', (cast(strftime('%s', 'now') as int)));
@@ -0,0 +1,3 @@
+-- flattrack.user_creation_secret rollback definition
+
+drop table if exists user_creation_secret;
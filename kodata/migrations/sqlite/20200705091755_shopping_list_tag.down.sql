@@ -0,0 +1 @@
+drop table if exists shopping_list_tag;
@@ -0,0 +1,17 @@
+-- flattrack.shopping_list_tag definition
+
+create table if not exists shopping_list_tag (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
+
+-- migrate old values
+insert into shopping_list_tag (name, author, authorLast)
+  select tag, min(author), min(author) from shopping_item where tag <> '' group by tag order by tag;
@@ -0,0 +1,5 @@
+insert into settings
+            (name, value)
+values
+    ('shoppingListNotes', '')
+    on conflict do nothing;
@@ -0,0 +1,2 @@
+alter table shopping_list add column templateId text;
+alter table shopping_item add column templateId text;
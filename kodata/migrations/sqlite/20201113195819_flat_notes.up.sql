@@ -0,0 +1,5 @@
+insert into settings
+            (name, value)
+values
+    ('flatNotes', '')
+    on conflict do nothing;
@@ -0,0 +1,2 @@
+alter table shopping_list
+drop column total_tag_exclude;
@@ -0,0 +1,2 @@
+alter table shopping_list
+add column total_tag_exclude text[];
@@ -0,0 +1 @@
+drop table leader_election;
@@ -0,0 +1 @@
+delete from settings where name = 'shoppingListKeepPolicy';
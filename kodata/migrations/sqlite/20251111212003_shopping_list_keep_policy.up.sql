@@ -0,0 +1,5 @@
+insert into settings
+            (name, value)
+values
+    ('shoppingListKeepPolicy', 'Always')
+    on conflict do nothing;
@@ -0,0 +1,5 @@
+insert into system
+            (name, value)
+values
+    ('schedulerLastRun', '{}')
+    on conflict do nothing;
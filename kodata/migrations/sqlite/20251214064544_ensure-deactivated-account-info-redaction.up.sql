@@ -0,0 +1,8 @@
+update users
+set
+  names = '(Deleted User)',
+  email = '',
+  birthday = 0,
+  phoneNumber = '',
+  password = ''
+where deletionTimestamp <> 0;
@@ -0,0 +1,2 @@
+drop table if exists task_occurrence;
+drop table if exists task;
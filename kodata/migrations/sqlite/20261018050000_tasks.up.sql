@@ -0,0 +1,35 @@
+-- flattrack.task definition
+
+create table if not exists task (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  description text not null default '',
+  assignees text[] not null default '{}',
+  recurrence text not null default 'Never',
+  startTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
+
+create table if not exists task_occurrence (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  taskId text not null,
+  assignees text[] not null default '{}',
+  dueTimestamp int not null,
+  completed bool not null default false,
+  completedBy text not null default '',
+  completedTimestamp int not null default 0,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (taskId) references task(id)
+);
@@ -0,0 +1,2 @@
+alter table task drop column rotate;
+alter table task drop column rotationPosition;
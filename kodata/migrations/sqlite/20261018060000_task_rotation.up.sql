@@ -0,0 +1,4 @@
+-- whether each occurrence of the task is assigned to the next flatmate in the rotation
+alter table task add column rotate bool not null default false;
+-- the index into the rotation of the next flatmate to be assigned
+alter table task add column rotationPosition int not null default 0;
@@ -0,0 +1,2 @@
+drop table if exists expense_share;
+drop table if exists expense;
@@ -0,0 +1,36 @@
+-- flattrack.expense definition
+
+create table if not exists expense (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  notes text not null default '',
+  amount float8 not null default 0,
+  payer text not null,
+  splitType text not null default 'Equal',
+  shoppingListId text not null default '',
+  author text not null,
+  authorLast text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (payer) references users(id),
+  foreign key (author) references users(id),
+  foreign key (authorLast) references users(id)
+);
+
+create table if not exists expense_share (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  expenseId text not null,
+  userId text not null,
+  value float8 not null default 0,
+  amount float8 not null default 0,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (expenseId) references expense(id),
+  foreign key (userId) references users(id)
+);
@@ -0,0 +1,3 @@
+-- flattrack.user_password_reset_secret rollback definition
+
+drop table if exists user_password_reset_secret;
@@ -0,0 +1,6 @@
+-- argon2id password hashes are unable to be reverted,
+-- leaving those accounts to reset their password
+update users
+set
+  password = substr(password, length('$sha512$') + 1)
+where password like '$sha512$%';
@@ -0,0 +1,3 @@
+-- flattrack.user_session rollback definition
+
+drop table if exists user_session;
@@ -0,0 +1,16 @@
+-- flattrack.user_session definition
+
+create table if not exists user_session (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  userAgent text not null default '',
+  ipAddress text not null default '',
+  lastSeenTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  expiryTimestamp int not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id)
+);
@@ -0,0 +1,7 @@
+-- flattrack.user_totp rollback definition
+
+delete from settings where name = 'requireAdminTwoFactor';
+
+drop table if exists user_auth_challenge;
+drop table if exists user_recovery_code;
+drop table if exists user_totp;
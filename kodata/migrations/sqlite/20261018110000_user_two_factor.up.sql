@@ -0,0 +1,49 @@
+-- flattrack.user_totp definition
+
+create table if not exists user_totp (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  secret text not null,
+  enabled bool not null default false,
+  lastUsedCounter bigint not null default 0,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (userId),
+  foreign key (userId) references users(id)
+);
+
+create table if not exists user_recovery_code (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  code text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  foreign key (userId) references users(id)
+);
+
+create table if not exists user_auth_challenge (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  token text not null,
+  attempts int not null default 0,
+  expiryTimestamp int not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (token),
+  foreign key (userId) references users(id)
+);
+
+insert into settings
+            (name, value)
+values
+    ('requireAdminTwoFactor', 'false')
+    on conflict do nothing;
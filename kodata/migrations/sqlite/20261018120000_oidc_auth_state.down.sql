@@ -0,0 +1,3 @@
+-- flattrack.oidc_auth_state rollback definition
+
+drop table if exists oidc_auth_state;
@@ -0,0 +1,16 @@
+-- flattrack.oidc_auth_state definition
+
+create table if not exists oidc_auth_state (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  state text not null,
+  nonce text not null,
+  codeVerifier text not null,
+  redirect text not null default '',
+  expiryTimestamp int not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (state)
+);
@@ -0,0 +1,3 @@
+-- flattrack.user_auth_attempt rollback definition
+
+drop table if exists user_auth_attempt;
@@ -0,0 +1,18 @@
+-- flattrack.user_auth_attempt definition
+
+create table if not exists user_auth_attempt (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  kind text not null,
+  key text not null,
+  failures int not null default 0,
+  windowStartTimestamp int not null,
+  lockedUntilTimestamp int not null default 0,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id)
+);
+
+-- an index instead of a table constraint, as SQLite is unable to drop table constraints
+create unique index if not exists user_auth_attempt_kind_key_key on user_auth_attempt (kind, key);
@@ -0,0 +1,3 @@
+-- flattrack.user_access_token rollback definition
+
+drop table if exists user_access_token;
@@ -0,0 +1,18 @@
+-- flattrack.user_access_token definition
+
+create table if not exists user_access_token (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  userId text not null,
+  name text not null,
+  token text not null,
+  scopes text[] not null default '{}',
+  expiryTimestamp int not null default 0,
+  lastUsedTimestamp int not null default 0,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (token),
+  foreign key (userId) references users(id)
+);
@@ -0,0 +1,3 @@
+drop index if exists groups_name_unique;
+
+alter table groups drop column permissions;
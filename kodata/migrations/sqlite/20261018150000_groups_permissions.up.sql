@@ -0,0 +1,7 @@
+-- the permissions which user accounts in the group are granted
+alter table groups add column permissions text[] not null default '{}';
+
+update groups set permissions = '{shoppinglist:read,shoppinglist:write,tasks:read,tasks:write,expenses:read,expenses:write,flatmates:read}' where name = 'flatmember';
+update groups set permissions = '{admin:settings,admin:users,admin:groups}' where name = 'admin';
+
+create unique index if not exists groups_name_unique on groups (name);
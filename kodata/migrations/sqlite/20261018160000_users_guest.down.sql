@@ -0,0 +1,4 @@
+delete from user_to_groups where groupId in (select id from groups where name = 'guest');
+delete from groups where name = 'guest';
+
+alter table users drop column expiryTimestamp;
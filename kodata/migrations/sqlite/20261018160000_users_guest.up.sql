@@ -0,0 +1,4 @@
+-- the time after which the user account is disabled, or 0 for never
+alter table users add column expiryTimestamp int not null default 0;
+
+insert into groups (name, defaultGroup, description, permissions) values ('guest', false, 'Temporary user account, such as for someone subletting a room', '{shoppinglist:read,shoppinglist:write}') on conflict (name) do nothing;
@@ -0,0 +1,54 @@
+-- flattrack.flats definition
+
+create table if not exists flats (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  name text not null,
+  hostname text not null default '',
+  initialized bool not null default false,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+
+  primary key (id),
+  unique (name)
+);
+
+create unique index if not exists flats_hostname_unique on flats (hostname) where hostname <> '';
+
+insert into flats (name, initialized)
+  values ('default', coalesce((select value = 'true' from system where name = 'initialized'), false))
+  on conflict (name) do nothing;
+delete from system where name = 'initialized';
+
+-- the flat which each row belongs to.
+-- SQLite is unable to add a column which both references another table and has a default,
+-- so unlike in Postgres the column has no foreign key
+alter table users add column flatId text not null default '';
+update users set flatId = (select id from flats where name = 'default');
+
+alter table groups add column flatId text not null default '';
+update groups set flatId = (select id from flats where name = 'default');
+
+alter table settings add column flatId text not null default '';
+update settings set flatId = (select id from flats where name = 'default');
+
+alter table shopping_list add column flatId text not null default '';
+update shopping_list set flatId = (select id from flats where name = 'default');
+
+alter table shopping_list_tag add column flatId text not null default '';
+update shopping_list_tag set flatId = (select id from flats where name = 'default');
+
+alter table task add column flatId text not null default '';
+update task set flatId = (select id from flats where name = 'default');
+
+alter table expense add column flatId text not null default '';
+update expense set flatId = (select id from flats where name = 'default');
+
+alter table user_auth_attempt add column flatId text not null default '';
+update user_auth_attempt set flatId = (select id from flats where name = 'default');
+
+drop index if exists groups_name_unique;
+create unique index if not exists groups_flat_name_unique on groups (flatId, name);
+create unique index if not exists settings_flat_name_unique on settings (flatId, name);
+drop index if exists user_auth_attempt_kind_key_key;
+create unique index if not exists user_auth_attempt_flatid_kind_key_key on user_auth_attempt (flatId, kind, key);
@@ -0,0 +1 @@
+delete from system where name = 'backupLastRun';
@@ -0,0 +1,5 @@
+insert into system
+            (name, value)
+values
+    ('backupLastRun', '{}')
+    on conflict do nothing;
@@ -0,0 +1,22 @@
+-- flattrack.shopping_attachment definition
+
+create table if not exists shopping_attachment (
+  id text default (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6)))) not null,
+  listId text not null,
+  -- the item which a photo is of, or empty for a receipt of the list
+  itemId text not null default '',
+  kind text not null,
+  name text not null default '',
+  contentType text not null,
+  size int not null default 0,
+  hasThumbnail bool not null default false,
+  author text not null,
+  creationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  modificationTimestamp int not null default (cast(strftime('%s', 'now') as int)),
+  deletionTimestamp int not null default 0,
+  flatId text not null,
+
+  primary key (id),
+  foreign key (author) references users(id),
+  foreign key (flatId) references flats(id)
+);
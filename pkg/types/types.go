@@ -19,6 +19,7 @@
 package types
 
 import (
+	"encoding/json"
 	"net/http"
 
 	jwt "github.com/golang-jwt/jwt/v5"
@@ -28,8 +29,46 @@ type RequestContextKeyClaim string
 
 const (
 	RequestContextKeyClaimAuth RequestContextKeyClaim = "auth"
+	RequestContextKeyFlat      RequestContextKeyClaim = "flat"
 )
 
+// FlatSpec ...
+// a flat which the FlatTrack instance hosts, selected by it's hostname or by it's name in the path
+type FlatSpec struct {
+	ID                    string `json:"id"`
+	Name                  string `json:"name"`
+	Hostname              string `json:"hostname,omitempty"`
+	Initialized           bool   `json:"initialized"`
+	CreationTimestamp     int64  `json:"creationTimestamp"`
+	ModificationTimestamp int64  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+}
+
+// MigrationSpec ...
+// a migration of the database schema, from the migration sql files
+type MigrationSpec struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Applied bool   `json:"applied"`
+}
+
+// BackupSpec ...
+// the first line of a backup archive, describing what it contains
+type BackupSpec struct {
+	Kind              string `json:"kind"`
+	FormatVersion     int    `json:"formatVersion"`
+	MigrationVersion  uint   `json:"migrationVersion"`
+	BuildVersion      string `json:"buildVersion"`
+	CreationTimestamp int64  `json:"creationTimestamp"`
+}
+
+// BackupRowSpec ...
+// a line of a backup archive, holding a row of a table
+type BackupRowSpec struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
 // Group ...
 // request object for a group
 type Group struct {
@@ -40,13 +79,22 @@ type Group struct {
 // GroupSpec ...
 // standard values for a group
 type GroupSpec struct {
-	ID                    string `json:"id"`
-	Name                  string `json:"name"`
-	DefaultGroup          bool   `json:"defaultGroup"`
-	Description           string `json:"description"`
-	CreationTimestamp     int64  `json:"creationTimestamp"`
-	ModificationTimestamp int64  `json:"modificationTimestamp"`
-	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+	ID                    string   `json:"id"`
+	FlatID                string   `json:"-"`
+	Name                  string   `json:"name"`
+	DefaultGroup          bool     `json:"defaultGroup"`
+	Description           string   `json:"description"`
+	Permissions           []string `json:"permissions"`
+	CreationTimestamp     int64    `json:"creationTimestamp"`
+	ModificationTimestamp int64    `json:"modificationTimestamp"`
+	DeletionTimestamp     int64    `json:"deletionTimestamp"`
+}
+
+// GroupPermissionSpec ...
+// a permission which groups are able to grant
+type GroupPermissionSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
 
 // GroupList ...
@@ -68,6 +116,7 @@ type User struct {
 // swagger:response userSpec
 type UserSpec struct {
 	ID                    string   `json:"id"`
+	FlatID                string   `json:"-"`
 	Names                 string   `json:"names"`
 	Email                 string   `json:"email"`
 	Groups                []string `json:"groups"`
@@ -79,6 +128,7 @@ type UserSpec struct {
 	Registered            bool     `json:"registered"`
 	LastLogin             int      `json:"lastLogin,omitempty"`
 	AuthNonce             string   `json:"-"`
+	ExpiryTimestamp       int64    `json:"expiryTimestamp,omitempty"`
 	CreationTimestamp     int64    `json:"creationTimestamp"`
 	ModificationTimestamp int64    `json:"modificationTimestamp"`
 	DeletionTimestamp     int64    `json:"deletionTimestamp"`
@@ -111,6 +161,7 @@ type UserSelector struct {
 // fields for a shopping list
 type ShoppingListSpec struct {
 	ID                    string   `json:"id"`
+	FlatID                string   `json:"-"`
 	Name                  string   `json:"name"`
 	Notes                 string   `json:"notes,omitempty"`
 	TemplateID            string   `json:"templateId,omitempty"`
@@ -158,6 +209,12 @@ type ShoppingListKeepPolicySpec struct {
 	KeepPolicy ShoppingListKeepPolicy `json:"keepPolicy"`
 }
 
+// AdminTwoFactorPolicySpec ...
+// whether members of the admin group must use two-factor authentication
+type AdminTwoFactorPolicySpec struct {
+	Required bool `json:"required"`
+}
+
 // ShoppingListOptions ...
 // options for lists
 type ShoppingListOptions struct {
@@ -196,6 +253,74 @@ type ShoppingItemSpec struct {
 	DeletionTimestamp     int64   `json:"deletionTimestamp"`
 }
 
+// ShoppingListFileFormat ...
+// a format which shopping lists are exported to and imported from
+type ShoppingListFileFormat string
+
+const (
+	// ShoppingListFileFormatCSV is a spreadsheet of items, with a header row
+	ShoppingListFileFormatCSV ShoppingListFileFormat = "csv"
+	// ShoppingListFileFormatJSON is an array of items
+	ShoppingListFileFormatJSON ShoppingListFileFormat = "json"
+)
+
+// ShoppingItemFileSpec ...
+// an item of a shopping list, as exported and imported
+type ShoppingItemFileSpec struct {
+	Name     string  `json:"name"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+	Tag      string  `json:"tag"`
+	Notes    string  `json:"notes"`
+	Obtained bool    `json:"obtained"`
+}
+
+// ShoppingListImportRowError ...
+// why a row of an imported file is unable to be added to a shopping list
+type ShoppingListImportRowError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ShoppingListImportSpec ...
+// the outcome of importing a file into a shopping list
+type ShoppingListImportSpec struct {
+	List   ShoppingListSpec             `json:"list"`
+	DryRun bool                         `json:"dryRun"`
+	Items  int                          `json:"items"`
+	Errors []ShoppingListImportRowError `json:"errors"`
+}
+
+// ShoppingAttachmentKind ...
+// what a shopping attachment is of
+type ShoppingAttachmentKind string
+
+const (
+	// ShoppingAttachmentKindPhoto is a photo of an item, such as of the brand to buy
+	ShoppingAttachmentKindPhoto ShoppingAttachmentKind = "Photo"
+	// ShoppingAttachmentKindReceipt is a receipt for a completed list
+	ShoppingAttachmentKindReceipt ShoppingAttachmentKind = "Receipt"
+)
+
+// ShoppingAttachmentSpec ...
+// a photo of a shopping item or a receipt of a shopping list, kept in file storage
+type ShoppingAttachmentSpec struct {
+	ID                    string                 `json:"id"`
+	ListID                string                 `json:"listId"`
+	ItemID                string                 `json:"itemId,omitempty"`
+	Kind                  ShoppingAttachmentKind `json:"kind"`
+	Name                  string                 `json:"name"`
+	ContentType           string                 `json:"contentType"`
+	Size                  int64                  `json:"size"`
+	HasThumbnail          bool                   `json:"hasThumbnail"`
+	Author                string                 `json:"author"`
+	CreationTimestamp     int64                  `json:"creationTimestamp"`
+	ModificationTimestamp int64                  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64                  `json:"deletionTimestamp"`
+	FlatID                string                 `json:"-"`
+}
+
 // ShoppingItemSortType ...
 // ways of sorting shopping list items
 type ShoppingItemSortType string
@@ -230,10 +355,30 @@ type ShoppingItemSelector struct {
 	Obtained                 string `json:"obtained"`
 }
 
+// ShoppingItemEventType ...
+// kinds of changes made to shopping list items
+type ShoppingItemEventType string
+
+const (
+	ShoppingItemEventTypeCreated  ShoppingItemEventType = "created"
+	ShoppingItemEventTypeUpdated  ShoppingItemEventType = "updated"
+	ShoppingItemEventTypeObtained ShoppingItemEventType = "obtained"
+	ShoppingItemEventTypeDeleted  ShoppingItemEventType = "deleted"
+)
+
+// ShoppingItemEvent ...
+// a change made to an item in a shopping list
+type ShoppingItemEvent struct {
+	Type   ShoppingItemEventType `json:"type"`
+	ListID string                `json:"listId"`
+	Item   ShoppingItemSpec      `json:"item"`
+}
+
 // ShoppingTag ...
 // selects a tag
 type ShoppingTag struct {
 	ID                    string `json:"id"`
+	FlatID                string `json:"-"`
 	Name                  string `json:"name"`
 	Author                string `json:"author"`
 	AuthorLast            string `json:"authorLast"`
@@ -271,6 +416,149 @@ type FlatNotes struct {
 	Notes string `json:"notes"`
 }
 
+// TaskSpec ...
+// fields for a flat task
+type TaskSpec struct {
+	ID                    string         `json:"id"`
+	FlatID                string         `json:"-"`
+	Name                  string         `json:"name"`
+	Description           string         `json:"description,omitempty"`
+	Assignees             []string       `json:"assignees"`
+	Recurrence            TaskRecurrence `json:"recurrence"`
+	StartTimestamp        int64          `json:"startTimestamp"`
+	Rotate                bool           `json:"rotate"`
+	RotationPosition      int            `json:"rotationPosition"`
+	Author                string         `json:"author"`
+	AuthorLast            string         `json:"authorLast"`
+	CreationTimestamp     int64          `json:"creationTimestamp"`
+	ModificationTimestamp int64          `json:"modificationTimestamp"`
+	DeletionTimestamp     int64          `json:"deletionTimestamp"`
+}
+
+// TaskPatchSpec ...
+// fields for patching a flat task, where rotate is only changed when it is given
+type TaskPatchSpec struct {
+	TaskSpec
+	Rotate *bool `json:"rotate"`
+}
+
+// TaskRecurrence ...
+// how often a task is due
+type TaskRecurrence string
+
+const (
+	TaskRecurrenceNever       = "Never"
+	TaskRecurrenceDaily       = "Daily"
+	TaskRecurrenceWeekly      = "Weekly"
+	TaskRecurrenceFortnightly = "Fortnightly"
+	TaskRecurrenceMonthly     = "Monthly"
+)
+
+// TaskOccurrenceSpec ...
+// fields for a single due instance of a task
+type TaskOccurrenceSpec struct {
+	ID                    string   `json:"id"`
+	TaskID                string   `json:"taskId"`
+	Assignees             []string `json:"assignees"`
+	DueTimestamp          int64    `json:"dueTimestamp"`
+	Completed             bool     `json:"completed"`
+	CompletedBy           string   `json:"completedBy,omitempty"`
+	CompletedTimestamp    int64    `json:"completedTimestamp,omitempty"`
+	CreationTimestamp     int64    `json:"creationTimestamp"`
+	ModificationTimestamp int64    `json:"modificationTimestamp"`
+	DeletionTimestamp     int64    `json:"deletionTimestamp"`
+}
+
+// TaskOccurrenceSelector ...
+// options for selecting task occurrences
+type TaskOccurrenceSelector struct {
+	TaskID             string `json:"taskId"`
+	Assignee           string `json:"assignee"`
+	Completed          string `json:"completed"`
+	DueTimestampAfter  int64  `json:"dueTimestampAfter"`
+	DueTimestampBefore int64  `json:"dueTimestampBefore"`
+}
+
+// TaskHistoryEntry ...
+// who was assigned and who completed a past occurrence of a task
+type TaskHistoryEntry struct {
+	OccurrenceID       string   `json:"occurrenceId"`
+	TaskID             string   `json:"taskId"`
+	TaskName           string   `json:"taskName"`
+	Assignees          []string `json:"assignees"`
+	DueTimestamp       int64    `json:"dueTimestamp"`
+	Completed          bool     `json:"completed"`
+	CompletedBy        string   `json:"completedBy,omitempty"`
+	CompletedTimestamp int64    `json:"completedTimestamp,omitempty"`
+}
+
+// TaskHistorySelector ...
+// options for selecting task history
+type TaskHistorySelector struct {
+	TaskID string `json:"taskId"`
+	UserID string `json:"userId"`
+}
+
+// ExpenseSpec ...
+// fields for a shared household expense
+type ExpenseSpec struct {
+	ID                    string             `json:"id"`
+	FlatID                string             `json:"-"`
+	Name                  string             `json:"name"`
+	Notes                 string             `json:"notes,omitempty"`
+	Amount                float64            `json:"amount"`
+	Payer                 string             `json:"payer"`
+	SplitType             ExpenseSplitType   `json:"splitType"`
+	Shares                []ExpenseShareSpec `json:"shares"`
+	ShoppingListID        string             `json:"shoppingListId,omitempty"`
+	Author                string             `json:"author"`
+	AuthorLast            string             `json:"authorLast"`
+	CreationTimestamp     int64              `json:"creationTimestamp"`
+	ModificationTimestamp int64              `json:"modificationTimestamp"`
+	DeletionTimestamp     int64              `json:"deletionTimestamp"`
+}
+
+// ExpenseSplitType ...
+// ways of splitting an expense between flatmates
+type ExpenseSplitType string
+
+const (
+	ExpenseSplitTypeEqual      = "Equal"
+	ExpenseSplitTypePercentage = "Percentage"
+	ExpenseSplitTypeExact      = "Exact"
+)
+
+// ExpenseShareSpec ...
+// a flatmate's share of an expense.
+// Value is a percentage for percentage splits and an amount for exact splits
+type ExpenseShareSpec struct {
+	ID                    string  `json:"id"`
+	ExpenseID             string  `json:"expenseId"`
+	UserID                string  `json:"userId"`
+	Value                 float64 `json:"value,omitempty"`
+	Amount                float64 `json:"amount"`
+	CreationTimestamp     int64   `json:"creationTimestamp"`
+	ModificationTimestamp int64   `json:"modificationTimestamp"`
+	DeletionTimestamp     int64   `json:"deletionTimestamp"`
+}
+
+// ExpenseBalance ...
+// how much a flatmate has paid and owes across all expenses
+type ExpenseBalance struct {
+	UserID  string  `json:"userId"`
+	Paid    float64 `json:"paid"`
+	Owed    float64 `json:"owed"`
+	Balance float64 `json:"balance"`
+}
+
+// ExpenseTransfer ...
+// a payment from one flatmate to another to settle up
+type ExpenseTransfer struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
 // UserCreationSecretSpec ...
 // values for a user to confirm their account with
 type UserCreationSecretSpec struct {
@@ -289,6 +577,129 @@ type UserCreationSecretSelector struct {
 	UserID string `json:"userId"`
 }
 
+// UserPasswordResetSecretSpec ...
+// a single use secret for resetting the password of a user account
+type UserPasswordResetSecretSpec struct {
+	ID                    string `json:"id"`
+	UserID                string `json:"userId"`
+	Secret                string `json:"-"`
+	ExpiryTimestamp       int64  `json:"expiryTimestamp"`
+	CreationTimestamp     int64  `json:"creationTimestamp"`
+	ModificationTimestamp int64  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+}
+
+// UserSessionSpec ...
+// a device which a user account is logged in on
+type UserSessionSpec struct {
+	ID                    string `json:"id"`
+	UserID                string `json:"userId"`
+	UserAgent             string `json:"userAgent"`
+	IPAddress             string `json:"ipAddress"`
+	LastSeenTimestamp     int64  `json:"lastSeenTimestamp"`
+	ExpiryTimestamp       int64  `json:"expiryTimestamp"`
+	Current               bool   `json:"current,omitempty"`
+	CreationTimestamp     int64  `json:"creationTimestamp"`
+	ModificationTimestamp int64  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+}
+
+// UserTOTPSpec ...
+// a time-based one-time password secret of a user account
+type UserTOTPSpec struct {
+	ID                    string `json:"id"`
+	UserID                string `json:"userId"`
+	Secret                string `json:"secret,omitempty"`
+	URI                   string `json:"uri,omitempty"`
+	Enabled               bool   `json:"enabled"`
+	LastUsedCounter       int64  `json:"-"`
+	CreationTimestamp     int64  `json:"creationTimestamp"`
+	ModificationTimestamp int64  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+}
+
+// UserTOTPCode ...
+// a time-based one-time password or recovery code
+type UserTOTPCode struct {
+	Code string `json:"code"`
+}
+
+// UserAuthChallengeSpec ...
+// a short lived token for completing a login with a second factor
+type UserAuthChallengeSpec struct {
+	ID                    string `json:"-"`
+	UserID                string `json:"-"`
+	Token                 string `json:"token"`
+	Attempts              int    `json:"-"`
+	ExpiryTimestamp       int64  `json:"expiryTimestamp"`
+	CreationTimestamp     int64  `json:"-"`
+	ModificationTimestamp int64  `json:"-"`
+	DeletionTimestamp     int64  `json:"-"`
+}
+
+// UserAuthChallengeAnswer ...
+// the second factor for a login
+type UserAuthChallengeAnswer struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// UserAuthAttemptSpec ...
+// the recent failed logins of an IP address or email
+type UserAuthAttemptSpec struct {
+	ID                    string `json:"id"`
+	FlatID                string `json:"-"`
+	Kind                  string `json:"kind"`
+	Key                   string `json:"key"`
+	Failures              int    `json:"failures"`
+	WindowStartTimestamp  int64  `json:"windowStartTimestamp"`
+	LockedUntilTimestamp  int64  `json:"lockedUntilTimestamp"`
+	CreationTimestamp     int64  `json:"creationTimestamp"`
+	ModificationTimestamp int64  `json:"modificationTimestamp"`
+	DeletionTimestamp     int64  `json:"deletionTimestamp"`
+}
+
+// UserAuthLockoutSpec ...
+// an IP address or email which is locked out from logging in,
+// with the user account of the email if there is one
+type UserAuthLockoutSpec struct {
+	ID                   string `json:"id"`
+	Kind                 string `json:"kind"`
+	Key                  string `json:"key"`
+	UserID               string `json:"userId,omitempty"`
+	Names                string `json:"names,omitempty"`
+	LockedUntilTimestamp int64  `json:"lockedUntilTimestamp"`
+}
+
+// UserAccessTokenSpec ...
+// a long-lived token which a user account created for a script or integration
+type UserAccessTokenSpec struct {
+	ID                    string   `json:"id"`
+	UserID                string   `json:"userId"`
+	Name                  string   `json:"name"`
+	Token                 string   `json:"token,omitempty"`
+	Scopes                []string `json:"scopes"`
+	ExpiryTimestamp       int64    `json:"expiryTimestamp"`
+	LastUsedTimestamp     int64    `json:"lastUsedTimestamp"`
+	CreationTimestamp     int64    `json:"creationTimestamp"`
+	ModificationTimestamp int64    `json:"modificationTimestamp"`
+	DeletionTimestamp     int64    `json:"deletionTimestamp"`
+}
+
+// OIDCAuthStateSpec ...
+// a single use state of an in progress login with an identity provider
+type OIDCAuthStateSpec struct {
+	ID                    string `json:"-"`
+	State                 string `json:"-"`
+	Nonce                 string `json:"-"`
+	CodeVerifier          string `json:"-"`
+	Redirect              string `json:"-"`
+	ExpiryTimestamp       int64  `json:"-"`
+	CreationTimestamp     int64  `json:"-"`
+	ModificationTimestamp int64  `json:"-"`
+	DeletionTimestamp     int64  `json:"-"`
+}
+
 // FlatName ...
 // the name of the flat
 type FlatName struct {
@@ -313,10 +724,12 @@ type SystemVersion struct {
 	Mode             string           `json:"mode"`
 	Date             string           `json:"date"`
 	GolangVersion    string           `json:"golangVersion"`
-	PostgresVersion  string           `json:"postgresVersion"`
+	PostgresVersion  string           `json:"postgresVersion,omitempty"`
+	SQLiteVersion    string           `json:"sqliteVersion,omitempty"`
 	OSType           string           `json:"osType"`
 	OSArch           string           `json:"osArch"`
 	SchedulerLastRun SchedulerLastRun `json:"schedulerLastRun"`
+	BackupLastRun    BackupLastRun    `json:"backupLastRun"`
 }
 
 // JSONResponseMetadata ...
@@ -351,6 +764,11 @@ type Endpoints []struct {
 type JWTclaim struct {
 	ID        string `json:"id"`
 	AuthNonce string `json:"authNonce"`
+	SessionID string `json:"sessionId"`
+	// AccessTokenID is set instead of SessionID when authenticated with a personal access token
+	AccessTokenID string `json:"-"`
+	// Scopes limit what a personal access token is allowed to do
+	Scopes []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
@@ -361,6 +779,8 @@ const (
 	CronTabScheduleOnceHourly          = "0 * * * *"
 	CronTabScheduleOnceDaily           = "0 0 * * *"
 	CronTabScheduleShoppingListCleanup = CronTabScheduleOnceDaily
+	CronTabScheduleTaskOccurrences     = CronTabScheduleOnceHourly
+	CronTabScheduleBackup              = CronTabScheduleOnceDaily
 )
 
 type SchedulerRunState string
@@ -376,3 +796,11 @@ type SchedulerLastRun struct {
 	Time  int64             `json:"time"`
 	State SchedulerRunState `json:"state"`
 }
+
+type BackupLastRun struct {
+	Time  int64             `json:"time"`
+	State SchedulerRunState `json:"state"`
+	// Name is the file in storage which the backup was written to
+	Name string `json:"name,omitempty"`
+	Size int64  `json:"size,omitempty"`
+}
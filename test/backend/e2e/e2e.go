@@ -1,28 +1,45 @@
 package e2e
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
 
+	"gitlab.com/flattrack/flattrack/internal/backups"
 	"gitlab.com/flattrack/flattrack/internal/common"
 	"gitlab.com/flattrack/flattrack/internal/database"
+	"gitlab.com/flattrack/flattrack/internal/files"
+	"gitlab.com/flattrack/flattrack/internal/flats"
+	"gitlab.com/flattrack/flattrack/internal/groups"
 	"gitlab.com/flattrack/flattrack/internal/httpserver"
 	"gitlab.com/flattrack/flattrack/internal/migrations"
 	"gitlab.com/flattrack/flattrack/internal/registration"
 	"gitlab.com/flattrack/flattrack/internal/settings"
+	"gitlab.com/flattrack/flattrack/internal/shoppinglist"
 	"gitlab.com/flattrack/flattrack/internal/system"
+	"gitlab.com/flattrack/flattrack/internal/tasks"
 	"gitlab.com/flattrack/flattrack/internal/users"
 	"gitlab.com/flattrack/flattrack/pkg/types"
+	"gitlab.com/flattrack/flattrack/test/backend/oidcprovider"
 )
 
 var jwtToken string
@@ -51,8 +68,9 @@ var _ = ginkgo.Describe("API e2e tests", func() {
 	var usersManager *users.Manager
 	var migrationsManager *migrations.Manager
 	var settingsManager *settings.Manager
-	var systemManager *system.Manager
+	var flatsManager *flats.Manager
 	var registrationManager *registration.Manager
+	var tasksManager *tasks.Manager
 	// _ = godotenv.Load(".env")
 
 	ginkgo.BeforeSuite(func() {
@@ -66,14 +84,21 @@ var _ = ginkgo.Describe("API e2e tests", func() {
 		usersManager = users.NewManager(db)
 		migrationsManager = migrations.NewManager(db)
 		settingsManager = settings.NewManager(db)
-		systemManager = system.NewManager(db)
-		registrationManager = registration.NewManager(usersManager, systemManager, settingsManager)
+		flatsManager = flats.NewManager(db, groups.NewManager(db), settingsManager)
+		registrationManager = registration.NewManager(usersManager, flatsManager, settingsManager)
+		tasksManager = tasks.NewManager(db, usersManager)
 		err = migrationsManager.Reset()
 		gomega.Expect(err).To(gomega.BeNil(), "failed to reset migrations")
 		err = migrationsManager.Migrate()
 		gomega.Expect(err).To(gomega.BeNil(), "failed to migrate")
 
-		registered, jwt, err := registrationManager.Register(regstrationForm)
+		defaultFlat, err := flatsManager.GetDefault()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the default flat")
+		usersManager = usersManager.ForFlat(defaultFlat.ID)
+		registrationManager = registrationManager.ForFlat(defaultFlat.ID)
+		tasksManager = tasksManager.ForFlat(defaultFlat.ID)
+
+		registered, jwt, err := registrationManager.Register(regstrationForm, types.UserSessionSpec{})
 
 		gomega.Expect(err).To(gomega.BeNil(), "failed to register the instance")
 		gomega.Expect(jwt).ToNot(gomega.Equal(""), "failed to register the instance")
@@ -2869,6 +2894,19 @@ var _ = ginkgo.Describe("API e2e tests", func() {
 
 		gomega.Expect(foundTotal).To(gomega.Equal(len(newShoppingListItems)), "unable to find all items from original list in templated list")
 
+		ginkgo.By("listing shopping lists by how often they are used as a template")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists?sortBy=" + types.ShoppingListSortByTemplated
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api must have return code of http.StatusOK")
+		shoppingListsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingLists []types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListsBytes, &shoppingLists)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(shoppingLists).ToNot(gomega.BeEmpty(), "shopping lists must be listed")
+		gomega.Expect(shoppingLists[0].ID).To(gomega.Equal(shoppingListCreated.ID), "the template must be listed first")
+		gomega.Expect(shoppingLists[0].Name).To(gomega.Equal(shoppingListCreated.Name), "the template must have it's fields")
+
 		ginkgo.By("deleting the shopping list")
 		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID
 		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
@@ -3103,16 +3141,2635 @@ var _ = ginkgo.Describe("API e2e tests", func() {
 			gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Spec.(string)).To(gomega.Equal(""), "notes should be empty")
 		}
 	})
-})
+	ginkgo.It("should allow management of tasks and their occurrences", func() {
+		ginkgo.By("fetching the profile")
+		apiEndpoint := apiServerAPIprefix + "/user/profile"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		profileBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var profile types.UserSpec
+		gomega.Expect(json.Unmarshal(profileBytes, &profile)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("creating a task")
+		task := types.TaskSpec{
+			Name:        "Take out the bins",
+			Description: "Both the recycling and the rubbish",
+			Assignees:   []string{profile.ID},
+			Recurrence:  types.TaskRecurrenceWeekly,
+		}
+		taskBytes, err := json.Marshal(task)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), taskBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		taskBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var taskCreated types.TaskSpec
+		gomega.Expect(json.Unmarshal(taskBytes, &taskCreated)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(taskCreated.ID).ToNot(gomega.Equal(""), "task must have an ID")
+		gomega.Expect(taskCreated.Name).To(gomega.Equal(task.Name), "task name must match")
+		gomega.Expect(taskCreated.Author).To(gomega.Equal(profile.ID), "task author must be the creator")
+		gomega.Expect(taskCreated.Assignees).To(gomega.Equal(task.Assignees), "task assignees must match")
+
+		ginkgo.By("listing the task's occurrences")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID + "/occurrences"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		occurrencesBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var occurrences []types.TaskOccurrenceSpec
+		gomega.Expect(json.Unmarshal(occurrencesBytes, &occurrences)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(occurrences)).To(gomega.Equal(1), "task must have a first occurrence")
+		gomega.Expect(occurrences[0].Completed).To(gomega.Equal(false), "first occurrence must not be completed")
+		gomega.Expect(occurrences[0].Assignees).To(gomega.Equal(task.Assignees), "occurrence assignees must match the task")
+
+		ginkgo.By("completing the occurrence")
+		completedBytes, err := json.Marshal(types.TaskOccurrenceSpec{Completed: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID + "/occurrences/" + occurrences[0].ID + "/completed"
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), completedBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		occurrenceBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var occurrenceCompleted types.TaskOccurrenceSpec
+		gomega.Expect(json.Unmarshal(occurrenceBytes, &occurrenceCompleted)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(occurrenceCompleted.Completed).To(gomega.Equal(true), "occurrence must be completed")
+		gomega.Expect(occurrenceCompleted.CompletedBy).To(gomega.Equal(profile.ID), "occurrence must be completed by the user")
+		gomega.Expect(occurrenceCompleted.CompletedTimestamp).ToNot(gomega.Equal(int64(0)), "occurrence must have a completed timestamp")
+
+		ginkgo.By("listing only completed occurrences for the user")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/occurrences?completed=true&assignee=" + profile.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		occurrencesBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		occurrences = []types.TaskOccurrenceSpec{}
+		gomega.Expect(json.Unmarshal(occurrencesBytes, &occurrences)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(occurrences)).To(gomega.Equal(1), "must find the completed occurrence")
 
-func httpRequestWithHeader(verb string, url string, data []byte, jwt string) (resp *http.Response, err error) {
-	if jwt == "" {
-		jwt = jwtToken
-	}
-	req, err := http.NewRequest(verb, url, bytes.NewBuffer(data))
+		ginkgo.By("patching the task")
+		patchBytes, err := json.Marshal(types.TaskSpec{Name: "Take out all the bins"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), patchBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		taskBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var taskPatched types.TaskSpec
+		gomega.Expect(json.Unmarshal(taskBytes, &taskPatched)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(taskPatched.Name).To(gomega.Equal("Take out all the bins"), "task name must be patched")
+		gomega.Expect(taskPatched.Description).To(gomega.Equal(task.Description), "task description must be retained")
+
+		ginkgo.By("deleting the task")
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+	})
+
+	ginkgo.It("should not allow invalid tasks", func() {
+		tasks := []types.TaskSpec{
+			{
+				Name: "",
+			},
+			{
+				Name: "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx",
+			},
+			{
+				Name:       "Vacuum",
+				Recurrence: "Yearly",
+			},
+			{
+				Name:      "Vacuum",
+				Assignees: []string{"does-not-exist"},
+			},
+		}
+		for _, task := range tasks {
+			ginkgo.By("creating the task " + task.Name)
+			taskBytes, err := json.Marshal(task)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint := apiServerAPIprefix + "/apps/tasks/tasks"
+			resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), taskBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		}
+	})
+
+	ginkgo.It("should rotate tasks between flatmates, skipping disabled accounts", func() {
+		ginkgo.By("fetching the profile")
+		apiEndpoint := apiServerAPIprefix + "/user/profile"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		profileBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var profile types.UserSpec
+		gomega.Expect(json.Unmarshal(profileBytes, &profile)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("creating flatmate accounts")
+		flatmates := []types.UserSpec{}
+		for _, email := range []string{"rotation1@example.com", "rotation2@example.com"} {
+			accountBytes, err := json.Marshal(types.UserSpec{
+				Names:    "Rotation flatmate",
+				Email:    email,
+				Password: "Password123!",
+				Groups:   []string{"flatmember"},
+			})
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/admin/users"
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+			accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			var account types.UserSpec
+			gomega.Expect(json.Unmarshal(accountBytes, &account)).To(gomega.BeNil(), "failed to unmarshal")
+			flatmates = append(flatmates, account)
+		}
+
+		ginkgo.By("creating a rotating task which started in the past")
+		task := types.TaskSpec{
+			Name:           "Clean the bathroom",
+			Assignees:      []string{profile.ID, flatmates[0].ID, flatmates[1].ID},
+			Recurrence:     types.TaskRecurrenceWeekly,
+			Rotate:         true,
+			StartTimestamp: time.Now().AddDate(0, 0, -10).Unix(),
+		}
+		taskBytes, err := json.Marshal(task)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), taskBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		taskBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var taskCreated types.TaskSpec
+		gomega.Expect(json.Unmarshal(taskBytes, &taskCreated)).To(gomega.BeNil(), "failed to unmarshal")
+
+		occurrences, err := tasksManager.Occurrence().List(types.TaskOccurrenceSelector{TaskID: taskCreated.ID})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list occurrences")
+		gomega.Expect(len(occurrences)).To(gomega.Equal(1), "task must have a first occurrence")
+		gomega.Expect(occurrences[0].Assignees).To(gomega.Equal([]string{profile.ID}), "first occurrence must be assigned to the first in the rotation")
+
+		ginkgo.By("disabling the next flatmate in the rotation")
+		disabledBytes, err := json.Marshal(types.UserSpec{Disabled: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmates[0].ID + "/disabled"
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), disabledBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("running the rotation")
+		gomega.Expect(tasksManager.Task().GenerateOccurrences()).To(gomega.BeNil(), "failed to generate occurrences")
+		occurrences, err = tasksManager.Occurrence().List(types.TaskOccurrenceSelector{TaskID: taskCreated.ID})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list occurrences")
+		gomega.Expect(len(occurrences)).To(gomega.Equal(2), "task must have a second occurrence")
+		gomega.Expect(occurrences[0].Assignees).To(gomega.Equal([]string{flatmates[1].ID}), "second occurrence must skip the disabled flatmate")
+		gomega.Expect(occurrences[0].DueTimestamp > time.Now().Unix()).To(gomega.Equal(true), "second occurrence must be upcoming")
+
+		ginkgo.By("fetching the history of the task")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID + "/history"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		historyBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var history []types.TaskHistoryEntry
+		gomega.Expect(json.Unmarshal(historyBytes, &history)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(history)).To(gomega.Equal(1), "history must only contain past occurrences")
+		gomega.Expect(history[0].TaskName).To(gomega.Equal(task.Name), "history must include the task name")
+		gomega.Expect(history[0].Assignees).To(gomega.Equal([]string{profile.ID}), "history must include who was assigned")
+
+		ginkgo.By("patching the task to stop rotating")
+		taskBytes, err = json.Marshal(map[string]any{"rotate": false})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), taskBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		taskBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var taskPatched types.TaskSpec
+		gomega.Expect(json.Unmarshal(taskBytes, &taskPatched)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(taskPatched.Rotate).To(gomega.Equal(false), "task must no longer rotate")
+		gomega.Expect(taskPatched.Name).To(gomega.Equal(task.Name), "task name must be kept")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/apps/tasks/tasks/" + taskCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		for _, flatmate := range flatmates {
+			apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+			resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+	})
+
+	ginkgo.It("should track expenses, balances and settling up", func() {
+		ginkgo.By("fetching the profile")
+		apiEndpoint := apiServerAPIprefix + "/user/profile"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		profileBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var profile types.UserSpec
+		gomega.Expect(json.Unmarshal(profileBytes, &profile)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("creating a flatmate account")
+		accountBytes, err := json.Marshal(types.UserSpec{
+			Names:    "Expenses flatmate",
+			Email:    "expenses@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/users"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("creating an invalid expense")
+		expenseBytes, err := json.Marshal(types.ExpenseSpec{
+			Name:      "Power bill",
+			Amount:    100,
+			Payer:     profile.ID,
+			SplitType: types.ExpenseSplitTypePercentage,
+			Shares: []types.ExpenseShareSpec{
+				{UserID: profile.ID, Value: 40},
+				{UserID: flatmate.ID, Value: 40},
+			},
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/expenses/expenses"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), expenseBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("creating an expense split equally")
+		expenseBytes, err = json.Marshal(types.ExpenseSpec{
+			Name:      "Power bill",
+			Amount:    100,
+			Payer:     profile.ID,
+			SplitType: types.ExpenseSplitTypeEqual,
+			Shares: []types.ExpenseShareSpec{
+				{UserID: profile.ID},
+				{UserID: flatmate.ID},
+			},
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), expenseBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		expenseBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var expense types.ExpenseSpec
+		gomega.Expect(json.Unmarshal(expenseBytes, &expense)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(expense.ID).ToNot(gomega.Equal(""), "expense must have an ID")
+		gomega.Expect(len(expense.Shares)).To(gomega.Equal(2), "expense must have two shares")
+		for _, share := range expense.Shares {
+			gomega.Expect(share.Amount).To(gomega.Equal(float64(50)), "each share must be half of the expense")
+		}
+
+		ginkgo.By("fetching the balances")
+		apiEndpoint = apiServerAPIprefix + "/apps/expenses/balances"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		balancesBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var balances []types.ExpenseBalance
+		gomega.Expect(json.Unmarshal(balancesBytes, &balances)).To(gomega.BeNil(), "failed to unmarshal")
+		for _, balance := range balances {
+			switch balance.UserID {
+			case profile.ID:
+				gomega.Expect(balance.Balance).To(gomega.Equal(float64(50)), "payer must be owed half")
+			case flatmate.ID:
+				gomega.Expect(balance.Balance).To(gomega.Equal(float64(-50)), "flatmate must owe half")
+			}
+		}
+
+		ginkgo.By("fetching the transfers to settle up")
+		apiEndpoint = apiServerAPIprefix + "/apps/expenses/settleup"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		transfersBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var transfers []types.ExpenseTransfer
+		gomega.Expect(json.Unmarshal(transfersBytes, &transfers)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(transfers).To(gomega.Equal([]types.ExpenseTransfer{{From: flatmate.ID, To: profile.ID, Amount: 50}}), "flatmate must pay the payer")
+
+		ginkgo.By("creating a completed shopping list")
+		listBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Groceries"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), listBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		listBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var list types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(listBytes, &list)).To(gomega.BeNil(), "failed to unmarshal")
+		for _, item := range []types.ShoppingItemSpec{
+			{Name: "Bread", Price: 2.5, Quantity: 2, Tag: "Bakery"},
+			{Name: "Soap", Price: 10, Quantity: 1, Tag: "Personal"},
+		} {
+			itemBytes, err := json.Marshal(item)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/items"
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), itemBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		}
+		listBytes, err = json.Marshal(types.ShoppingListSpec{TotalTagExclude: []string{"Personal"}})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), listBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		listBytes, err = json.Marshal(types.ShoppingListSpec{Completed: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/completed"
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), listBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("creating an expense from the shopping list")
+		expenseBytes, err = json.Marshal(types.ExpenseSpec{
+			Payer: flatmate.ID,
+			Shares: []types.ExpenseShareSpec{
+				{UserID: profile.ID},
+				{UserID: flatmate.ID},
+			},
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/expense"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), expenseBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		expenseBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var listExpense types.ExpenseSpec
+		gomega.Expect(json.Unmarshal(expenseBytes, &listExpense)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(listExpense.Name).To(gomega.Equal(list.Name), "expense must be named after the list")
+		gomega.Expect(listExpense.Amount).To(gomega.Equal(float64(5)), "expense must exclude items with tags in the list's total tag exclude")
+		gomega.Expect(listExpense.ShoppingListID).To(gomega.Equal(list.ID), "expense must reference the list")
+
+		ginkgo.By("cleaning up")
+		for _, id := range []string{expense.ID, listExpense.ID} {
+			apiEndpoint = apiServerAPIprefix + "/apps/expenses/expenses/" + id
+			resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should stream changes to shopping list items", func() {
+		ginkgo.By("creating a shopping list")
+		listBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Streamed list"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), listBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		listBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var list types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(listBytes, &list)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("failing to stream events of a list which doesn't exist")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/does-not-exist/events"
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil)
+		gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+		req.Header.Set("Authorization", "bearer "+jwtToken)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err = http.DefaultClient.Do(req)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("streaming the events of the list")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/events"
+		req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil)
+		gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+		req.Header.Set("Authorization", "bearer "+jwtToken)
+		req.Header.Set("Accept", "text/event-stream")
+		stream, err := http.DefaultClient.Do(req)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(stream.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(stream.Header.Get("Content-Type")).To(gomega.Equal("text/event-stream"), "api must respond with an event stream")
+		events := make(chan types.ShoppingItemEvent)
+		go func() {
+			defer close(events)
+			scanner := bufio.NewScanner(stream.Body)
+			for scanner.Scan() {
+				data, found := strings.CutPrefix(scanner.Text(), "data: ")
+				if !found {
+					continue
+				}
+				var event types.ShoppingItemEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					continue
+				}
+				events <- event
+			}
+		}()
+
+		ginkgo.By("adding an item to the list")
+		itemBytes, err := json.Marshal(types.ShoppingItemSpec{Name: "Milk", Quantity: 1})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/items"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), itemBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		itemBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var item types.ShoppingItemSpec
+		gomega.Expect(json.Unmarshal(itemBytes, &item)).To(gomega.BeNil(), "failed to unmarshal")
+		var event types.ShoppingItemEvent
+		gomega.Eventually(events, 5*time.Second).Should(gomega.Receive(&event), "an event must be received")
+		gomega.Expect(event.Type).To(gomega.Equal(types.ShoppingItemEventTypeCreated), "event must be of a created item")
+		gomega.Expect(event.Item.ID).To(gomega.Equal(item.ID), "event must be of the created item")
+
+		ginkgo.By("marking the item as obtained")
+		itemBytes, err = json.Marshal(types.ShoppingItemSpec{Obtained: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/items/" + item.ID + "/obtained"
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), itemBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Eventually(events, 5*time.Second).Should(gomega.Receive(&event), "an event must be received")
+		gomega.Expect(event.Type).To(gomega.Equal(types.ShoppingItemEventTypeObtained), "event must be of an obtained item")
+		gomega.Expect(event.Item.Obtained).To(gomega.Equal(true), "event item must be obtained")
+
+		ginkgo.By("deleting the item")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID + "/items/" + item.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Eventually(events, 5*time.Second).Should(gomega.Receive(&event), "an event must be received")
+		gomega.Expect(event.Type).To(gomega.Equal(types.ShoppingItemEventTypeDeleted), "event must be of a deleted item")
+		gomega.Expect(event.Item.ID).To(gomega.Equal(item.ID), "event must be of the deleted item")
+
+		ginkgo.By("cleaning up")
+		gomega.Expect(stream.Body.Close()).To(gomega.BeNil(), "failed to close the event stream")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + list.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should reset forgotten passwords with single use links", func() {
+		ginkgo.By("requesting password resets for emails with and without accounts")
+		var responses []string
+		for _, email := range []string{regstrationForm.User.Email, "no-account@example.com"} {
+			forgotBytes, err := json.Marshal(types.UserSpec{Email: email})
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint := apiServerAPIprefix + "/user/auth/forgot"
+			resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), forgotBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+			responses = append(responses, httpserver.GetHTTPresponseBodyContents(resp).Metadata.Response)
+		}
+		gomega.Expect(responses[0]).To(gomega.Equal(responses[1]), "responses must not reveal if an account has the email")
+
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Forgetful flatmate",
+			Email:    "forgetful@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("logging in as the flatmate")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("creating a password reset secret")
+		resetSecret, err := usersManager.UserPasswordResetSecrets().Create(flatmate.ID)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to create a password reset secret")
+		gomega.Expect(resetSecret.Secret).ToNot(gomega.Equal(""), "password reset secret must not be empty")
+
+		ginkgo.By("checking the validity of the password reset secret")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/forgot/" + resetSecret.ID + "?secret=" + resetSecret.Secret
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Data.(bool)).To(gomega.Equal(true), "password reset secret must be valid")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/forgot/" + resetSecret.ID + "?secret=incorrect"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("failing to reset the password to an invalid one")
+		passwordBytes, err := json.Marshal(types.UserSpec{Password: "short"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/forgot/" + resetSecret.ID + "?secret=" + resetSecret.Secret
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), passwordBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("resetting the password")
+		newPassword := "NewPassword123!"
+		passwordBytes, err = json.Marshal(types.UserSpec{Password: newPassword})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), passwordBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to use the password reset secret again")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), passwordBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("failing to use the login from before the reset")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("logging in with the new password")
+		loginBytes, err = json.Marshal(types.UserSpec{Email: account.Email, Password: newPassword})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should upgrade legacy password hashes on login", func() {
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Legacy flatmate",
+			Email:    "legacy@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("checking the password is hashed with argon2id")
+		var passwordHash string
+		gomega.Expect(db.QueryRow(`select password from users where id = $1`, flatmate.ID).Scan(&passwordHash)).To(gomega.BeNil(), "failed to get password hash")
+		gomega.Expect(strings.HasPrefix(passwordHash, "$argon2id$")).To(gomega.Equal(true), "password must be hashed with argon2id")
+
+		ginkgo.By("replacing the password hash with a legacy one")
+		_, err = db.Exec(`update users set password = $2 where id = $1`, flatmate.ID, "$sha512$"+common.HashSHA512(account.Password))
+		gomega.Expect(err).To(gomega.BeNil(), "failed to set legacy password hash")
+
+		ginkgo.By("counting the legacy password hashes")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/legacyPasswordHashes"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		legacyCount := httpserver.GetHTTPresponseBodyContents(resp).Data.(float64)
+		gomega.Expect(legacyCount >= 1).To(gomega.Equal(true), "legacy password hash must be counted")
+
+		ginkgo.By("logging in with the legacy password hash")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("checking the password hash was upgraded")
+		gomega.Expect(db.QueryRow(`select password from users where id = $1`, flatmate.ID).Scan(&passwordHash)).To(gomega.BeNil(), "failed to get password hash")
+		gomega.Expect(strings.HasPrefix(passwordHash, "$argon2id$")).To(gomega.Equal(true), "password must be rehashed with argon2id")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/legacyPasswordHashes"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Data.(float64)).To(gomega.Equal(legacyCount-1), "legacy password hash count must decrease")
+
+		ginkgo.By("logging in with the upgraded password hash")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should list and revoke individual sessions", func() {
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Travelling flatmate",
+			Email:    "travelling@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("logging in as the flatmate on two devices")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		flatmateJWTs := []string{}
+		for range 2 {
+			apiEndpoint = apiServerAPIprefix + "/user/auth"
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+			flatmateJWTs = append(flatmateJWTs, httpserver.GetHTTPresponseBodyContents(resp).Data.(string))
+		}
+
+		ginkgo.By("listing the sessions of the flatmate")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/sessions"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWTs[0])
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		sessionsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var sessions []types.UserSessionSpec
+		gomega.Expect(json.Unmarshal(sessionsBytes, &sessions)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(sessions)).To(gomega.Equal(2), "flatmate must have a session for each login")
+		var otherSession types.UserSessionSpec
+		currentSessions := 0
+		for _, session := range sessions {
+			gomega.Expect(session.UserID).To(gomega.Equal(flatmate.ID), "session must belong to the flatmate")
+			gomega.Expect(session.UserAgent).ToNot(gomega.Equal(""), "session must have a user agent")
+			gomega.Expect(session.IPAddress).ToNot(gomega.Equal(""), "session must have an IP address")
+			if session.Current {
+				currentSessions++
+				continue
+			}
+			otherSession = session
+		}
+		gomega.Expect(currentSessions).To(gomega.Equal(1), "only one session must be current")
+
+		ginkgo.By("failing to revoke a session of another user account")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/sessions"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		sessionsBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var adminSessions []types.UserSessionSpec
+		gomega.Expect(json.Unmarshal(sessionsBytes, &adminSessions)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(adminSessions) > 0).To(gomega.Equal(true), "admin must have a session")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/sessions/" + adminSessions[0].ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWTs[0])
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("revoking the other session")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/sessions/" + otherSession.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWTs[0])
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		authStatusCodes := []int{}
+		for _, flatmateJWT := range flatmateJWTs {
+			apiEndpoint = apiServerAPIprefix + "/user/auth"
+			resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			authStatusCodes = append(authStatusCodes, resp.StatusCode)
+		}
+		gomega.Expect(authStatusCodes).To(gomega.ContainElement(http.StatusOK), "the current session must remain valid")
+		gomega.Expect(authStatusCodes).To(gomega.ContainElement(http.StatusUnauthorized), "the revoked session must be invalid")
+
+		ginkgo.By("listing the sessions of the flatmate as an admin")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID + "/sessions"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(len(httpserver.GetHTTPresponseBodyContents(resp).List.([]interface{}))).To(gomega.Equal(1), "flatmate must have one session remaining")
+
+		ginkgo.By("revoking all sessions of the flatmate as an admin")
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWTs[0])
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("checking the admin session remains valid")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should log in with two-factor authentication", func() {
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Careful flatmate",
+			Email:    "careful@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("logging in as the flatmate")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("setting up two-factor authentication")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		totpBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var totp types.UserTOTPSpec
+		gomega.Expect(json.Unmarshal(totpBytes, &totp)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(totp.Secret).ToNot(gomega.Equal(""), "secret must not be empty")
+		gomega.Expect(strings.HasPrefix(totp.URI, "otpauth://totp/")).To(gomega.Equal(true), "uri must be an otpauth uri")
+		gomega.Expect(totp.Enabled).To(gomega.Equal(false), "two-factor authentication must not be enabled before confirming a code")
+
+		ginkgo.By("failing to enable two-factor authentication with an incorrect code")
+		codeBytes, err := json.Marshal(types.UserTOTPCode{Code: "000000"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp/enable"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), codeBytes, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("enabling two-factor authentication")
+		counter := time.Now().Unix() / 30
+		code, err := common.GenerateTOTPCode(totp.Secret, counter)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to generate code")
+		codeBytes, err = json.Marshal(types.UserTOTPCode{Code: code})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), codeBytes, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		recoveryCodes := httpserver.GetHTTPresponseBodyContents(resp).List.([]interface{})
+		gomega.Expect(len(recoveryCodes)).To(gomega.Equal(10), "recovery codes must be returned")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Data.(bool)).To(gomega.Equal(true), "two-factor authentication must be enabled")
+
+		ginkgo.By("logging in and receiving a challenge")
+		login := func() string {
+			apiEndpoint := apiServerAPIprefix + "/user/auth"
+			resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusAccepted), "api have return code of http.StatusAccepted")
+			response := httpserver.GetHTTPresponseBodyContents(resp)
+			gomega.Expect(response.Data).To(gomega.BeNil(), "a JWT must not be returned before completing the challenge")
+			return response.Spec.(map[string]interface{})["token"].(string)
+		}
+		answer := func(token string, code string) *http.Response {
+			answerBytes, err := json.Marshal(types.UserAuthChallengeAnswer{Token: token, Code: code})
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint := apiServerAPIprefix + "/user/auth/challenge"
+			resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), answerBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			return resp
+		}
+		challengeToken := login()
+
+		ginkgo.By("failing to reuse the code which enabled two-factor authentication")
+		resp = answer(challengeToken, code)
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("completing the challenge with a new code")
+		code, err = common.GenerateTOTPCode(totp.Secret, counter+1)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to generate code")
+		resp = answer(challengeToken, code)
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT = httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to reuse the completed challenge")
+		resp = answer(challengeToken, recoveryCodes[0].(string))
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("completing a challenge with a recovery code only once")
+		resp = answer(login(), recoveryCodes[0].(string))
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		resp = answer(login(), recoveryCodes[0].(string))
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("failing to require two-factor authentication for admins without it enabled")
+		policyBytes, err := json.Marshal(types.AdminTwoFactorPolicySpec{Required: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/settings/requireAdminTwoFactor"
+		resp, err = httpRequestWithHeader(http.MethodPut, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), policyBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Spec.(map[string]interface{})["required"].(bool)).To(gomega.Equal(false), "two-factor authentication must not be required for admins")
+
+		ginkgo.By("failing to disable two-factor authentication with an incorrect code")
+		codeBytes, err = json.Marshal(types.UserTOTPCode{Code: "000000"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp"
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), codeBytes, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("removing two-factor authentication as an admin")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID + "/totp"
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should log in with single sign-on", func() {
+		ginkgo.By("checking if single sign-on is enabled")
+		apiEndpoint := apiServerAPIprefix + "/user/auth/oidc"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		if enabled, _ := httpserver.GetHTTPresponseBodyContents(resp).Data.(bool); !enabled ||
+			!common.GetOIDCAutoProvision() || common.GetOIDCAdminGroup() == "" {
+			ginkgo.Skip("single sign-on is not configured with auto provisioning and an admin group")
+		}
+
+		ginkgo.By("starting the stand-in identity provider")
+		provider, err := oidcprovider.NewProvider(common.GetOIDCIssuer(), common.GetOIDCClientID(), common.GetOIDCClientSecret())
+		gomega.Expect(err).To(gomega.BeNil(), "failed to create identity provider")
+		issuerURL, err := url.Parse(common.GetOIDCIssuer())
+		gomega.Expect(err).To(gomega.BeNil(), "failed to parse issuer")
+		providerServer := &http.Server{
+			Addr:              issuerURL.Host,
+			Handler:           provider.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := providerServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Println("failed to serve identity provider", err)
+			}
+		}()
+		defer func() {
+			_ = providerServer.Close()
+		}()
+		gomega.Eventually(func() error {
+			resp, err := http.Get(strings.TrimSuffix(common.GetOIDCIssuer(), "/") + "/.well-known/openid-configuration")
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+			return err
+		}, 5*time.Second).Should(gomega.BeNil(), "identity provider must be reachable")
+
+		newBrowser := func() *http.Client {
+			jar, err := cookiejar.New(nil)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to create cookie jar")
+			return &http.Client{
+				Jar: jar,
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+		}
+		browser := newBrowser()
+		navigateWith := func(browser *http.Client, location string) *http.Response {
+			req, err := http.NewRequest(http.MethodGet, location, nil)
+			gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+			req.Header.Set("Accept", "text/html")
+			resp, err := browser.Do(req)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusFound), "must redirect with http.StatusFound")
+			return resp
+		}
+		navigate := func(location string) *http.Response {
+			return navigateWith(browser, location)
+		}
+		startLogin := func() (callbackURL string) {
+			resp := navigate(fmt.Sprintf("%v/%v/user/auth/oidc/login?redirect=%v", apiServer, apiServerAPIprefix, url.QueryEscape("/apps/shopping-list")))
+			authURL := resp.Header.Get("Location")
+			gomega.Expect(strings.HasPrefix(authURL, strings.TrimSuffix(common.GetOIDCIssuer(), "/"))).To(gomega.Equal(true), "must redirect to the identity provider")
+			gomega.Expect(authURL).To(gomega.ContainSubstring("code_challenge_method=S256"), "must use PKCE")
+			return navigate(authURL).Header.Get("Location")
+		}
+		login := func() (location string, token string) {
+			resp := navigate(startLogin())
+			for _, cookie := range resp.Cookies() {
+				if cookie.Name == "token" {
+					token = cookie.Value
+				}
+			}
+			return resp.Header.Get("Location"), token
+		}
+		getProfile := func(token string) (profile types.UserSpec) {
+			apiEndpoint := apiServerAPIprefix + "/user/profile"
+			resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, token)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+			profileBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			gomega.Expect(json.Unmarshal(profileBytes, &profile)).To(gomega.BeNil(), "failed to unmarshal")
+			return profile
+		}
+
+		ginkgo.By("failing to log in with an unverified email")
+		provider.SetUser(oidcprovider.User{
+			Subject: "unverified",
+			Email:   "unverified@example.com",
+			Name:    "Unverified flatmate",
+		})
+		location, token := login()
+		gomega.Expect(strings.HasPrefix(location, "/login?error=")).To(gomega.Equal(true), "must redirect to login with an error")
+		gomega.Expect(token).To(gomega.Equal(""), "must not set a token")
+
+		ginkgo.By("provisioning an admin account on first log in")
+		provider.SetUser(oidcprovider.User{
+			Subject:       "sso",
+			Email:         "ssoaccount@example.com",
+			EmailVerified: true,
+			Name:          "Single sign-on flatmate",
+			Groups:        []string{common.GetOIDCAdminGroup()},
+		})
+		location, token = login()
+		gomega.Expect(location).To(gomega.Equal("/apps/shopping-list"), "must redirect to the requested page")
+		gomega.Expect(token).ToNot(gomega.Equal(""), "must set a token")
+		profile := getProfile(token)
+		gomega.Expect(profile.Email).To(gomega.Equal("ssoaccount@example.com"), "must be logged in as the provisioned account")
+		gomega.Expect(profile.Names).To(gomega.Equal("Single sign-on flatmate"), "must be named from the ID token")
+		gomega.Expect(profile.Registered).To(gomega.Equal(true), "provisioned account must be registered")
+		gomega.Expect(profile.Groups).To(gomega.ContainElements("flatmember", "admin"), "must be in the default and admin groups")
+
+		ginkgo.By("removing admin when no longer in the identity provider group")
+		provider.SetUser(oidcprovider.User{
+			Subject:       "sso",
+			Email:         "ssoaccount@example.com",
+			EmailVerified: true,
+			Name:          "Single sign-on flatmate",
+		})
+		location, token = login()
+		gomega.Expect(location).To(gomega.Equal("/apps/shopping-list"), "must redirect to the requested page")
+		profile = getProfile(token)
+		gomega.Expect(profile.Groups).To(gomega.ContainElement("flatmember"), "must remain in the default groups")
+		gomega.Expect(profile.Groups).ToNot(gomega.ContainElement("admin"), "must be removed from the admin group")
+
+		ginkgo.By("failing to complete the same login twice")
+		callbackURL := startLogin()
+		resp = navigate(callbackURL)
+		gomega.Expect(resp.Header.Get("Location")).To(gomega.Equal("/apps/shopping-list"), "must redirect to the requested page")
+		resp = navigate(callbackURL)
+		gomega.Expect(strings.HasPrefix(resp.Header.Get("Location"), "/login?error=")).To(gomega.Equal(true), "must redirect to login with an error")
+
+		ginkgo.By("failing to complete a login in a different browser to the one which started it")
+		callbackURL = startLogin()
+		resp = navigateWith(newBrowser(), callbackURL)
+		gomega.Expect(strings.HasPrefix(resp.Header.Get("Location"), "/login?error=")).To(gomega.Equal(true), "must redirect to login with an error")
+		for _, cookie := range resp.Cookies() {
+			gomega.Expect(cookie.Name == "token" && cookie.Value != "").To(gomega.Equal(false), "must not set a token")
+		}
+		resp = navigate(callbackURL)
+		gomega.Expect(resp.Header.Get("Location")).To(gomega.Equal("/apps/shopping-list"), "must still complete in the browser which started it")
+
+		ginkgo.By("asking for the second factor of accounts with two-factor authentication")
+		_, token = login()
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		totpBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var totp types.UserTOTPSpec
+		gomega.Expect(json.Unmarshal(totpBytes, &totp)).To(gomega.BeNil(), "failed to unmarshal")
+		counter := time.Now().Unix() / 30
+		code, err := common.GenerateTOTPCode(totp.Secret, counter)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to generate code")
+		codeBytes, err := json.Marshal(types.UserTOTPCode{Code: code})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp/enable"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), codeBytes, token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		location, token = login()
+		gomega.Expect(strings.HasPrefix(location, "/login?challenge=")).To(gomega.Equal(true), "must redirect to login to ask for the second factor")
+		gomega.Expect(token).To(gomega.Equal(""), "must not set a token before the second factor is given")
+		challengeURL, err := url.Parse(location)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to parse location")
+		gomega.Expect(challengeURL.Query().Get("redirect")).To(gomega.Equal("/apps/shopping-list"), "must keep the requested page")
+		code, err = common.GenerateTOTPCode(totp.Secret, counter+1)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to generate code")
+		answerBytes, err := json.Marshal(types.UserAuthChallengeAnswer{Token: challengeURL.Query().Get("challenge"), Code: code})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/challenge"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), answerBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(getProfile(httpserver.GetHTTPresponseBodyContents(resp).Data.(string)).Email).To(gomega.Equal("ssoaccount@example.com"), "must be logged in once the second factor is given")
+
+		ginkgo.By("logging in to an existing account by it's email")
+		provider.SetUser(oidcprovider.User{
+			Subject:       "admin",
+			Email:         regstrationForm.User.Email,
+			EmailVerified: true,
+			Name:          regstrationForm.User.Names,
+			Groups:        []string{common.GetOIDCAdminGroup()},
+		})
+		_, token = login()
+		gomega.Expect(getProfile(token).Email).To(gomega.Equal(regstrationForm.User.Email), "must be logged in as the existing account")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + profile.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should lock out logins after too many failures", func() {
+		maxEmailFailures := common.GetAuthMaxFailuresPerEmail()
+		maxIPFailures := common.GetAuthMaxFailuresPerIP()
+		if common.GetAppRealIPHeader() == "" || maxEmailFailures == 0 || maxIPFailures == 0 {
+			ginkgo.Skip("login lockouts need a real IP header to test without locking out other tests")
+		}
+
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Forgetful flatmate",
+			Email:    "forgetful@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		login := func(email string, password string, ipAddress string) *http.Response {
+			loginBytes, err := json.Marshal(types.UserSpec{Email: email, Password: password})
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/%v/user/auth", apiServer, apiServerAPIprefix), bytes.NewBuffer(loginBytes))
+			gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set(common.GetAppRealIPHeader(), ipAddress)
+			resp, err := http.DefaultClient.Do(req)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			return resp
+		}
+		getLockout := func(kind string, key string) (lockout types.UserAuthLockoutSpec) {
+			apiEndpoint := apiServerAPIprefix + "/admin/users/lockouts"
+			resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+			lockoutsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			var lockouts []types.UserAuthLockoutSpec
+			gomega.Expect(json.Unmarshal(lockoutsBytes, &lockouts)).To(gomega.BeNil(), "failed to unmarshal")
+			for _, lockout := range lockouts {
+				if lockout.Kind == kind && lockout.Key == key {
+					return lockout
+				}
+			}
+			return types.UserAuthLockoutSpec{}
+		}
+		unlock := func(id string) {
+			apiEndpoint := apiServerAPIprefix + "/admin/users/lockouts/" + id
+			resp, err := httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+
+		ginkgo.By("locking out an email after too many failures")
+		for i := 1; i < maxEmailFailures; i++ {
+			resp = login(account.Email, "WrongPassword123!", fmt.Sprintf("192.0.2.%v", i))
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+		}
+		resp = login(account.Email, "WrongPassword123!", "192.0.2.100")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+		gomega.Expect(resp.Header.Get("Retry-After")).ToNot(gomega.Equal(""), "must say when to retry")
+
+		ginkgo.By("failing to log in with the correct password while locked out")
+		resp = login(account.Email, account.Password, "192.0.2.101")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+
+		ginkgo.By("listing the locked out account")
+		lockout := getLockout("email", account.Email)
+		gomega.Expect(lockout.ID).ToNot(gomega.Equal(""), "the email must be listed as locked out")
+		gomega.Expect(lockout.UserID).To(gomega.Equal(flatmate.ID), "the lockout must be of the account")
+		gomega.Expect(lockout.LockedUntilTimestamp > time.Now().Unix()).To(gomega.Equal(true), "the lockout must not have expired")
+
+		ginkgo.By("logging in once unlocked by an admin")
+		unlock(lockout.ID)
+		resp = login(account.Email, account.Password, "192.0.2.101")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("locking out an IP address after too many failures")
+		lockedOutIP := "198.51.100.1"
+		for i := 1; i < maxIPFailures; i++ {
+			resp = login(fmt.Sprintf("nobody-%v@example.com", i), "WrongPassword123!", lockedOutIP)
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+		}
+		resp = login("nobody@example.com", "WrongPassword123!", lockedOutIP)
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+		resp = login(account.Email, account.Password, lockedOutIP)
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+		resp = login(account.Email, account.Password, "198.51.100.2")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		lockout = getLockout("ip", lockedOutIP)
+		gomega.Expect(lockout.ID).ToNot(gomega.Equal(""), "the IP address must be listed as locked out")
+		unlock(lockout.ID)
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should authenticate with scoped personal access tokens", func() {
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Scripting flatmate",
+			Email:    "scripting@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("logging in as the flatmate")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("failing to create invalid personal access tokens")
+		invalidAccessTokens := []types.UserAccessTokenSpec{
+			{Name: "", Scopes: []string{"shoppinglist:read"}},
+			{Name: "No scopes"},
+			{Name: "Unknown scope", Scopes: []string{"everything"}},
+			{Name: "Expired", Scopes: []string{"shoppinglist:read"}, ExpiryTimestamp: time.Now().Add(-time.Hour).Unix()},
+		}
+		for _, accessToken := range invalidAccessTokens {
+			accessTokenBytes, err := json.Marshal(accessToken)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/user/auth/tokens"
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accessTokenBytes, flatmateJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		}
+
+		ginkgo.By("failing to create a personal access token with the admin scope as a flatmate")
+		accessTokenBytes, err := json.Marshal(types.UserAccessTokenSpec{Name: "Admin", Scopes: []string{"admin"}})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accessTokenBytes, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("creating read and write personal access tokens")
+		accessTokens := map[string]types.UserAccessTokenSpec{}
+		for _, scope := range []string{"shoppinglist:read", "shoppinglist:write"} {
+			accessTokenBytes, err := json.Marshal(types.UserAccessTokenSpec{
+				Name:            "Script with " + scope,
+				Scopes:          []string{scope},
+				ExpiryTimestamp: time.Now().Add(time.Hour).Unix(),
+			})
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accessTokenBytes, flatmateJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+			accessTokenBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			var accessToken types.UserAccessTokenSpec
+			gomega.Expect(json.Unmarshal(accessTokenBytes, &accessToken)).To(gomega.BeNil(), "failed to unmarshal")
+			gomega.Expect(accessToken.Token).To(gomega.HavePrefix("ftpat_"), "token must be returned once created")
+			gomega.Expect(accessToken.Scopes).To(gomega.Equal([]string{scope}), "token must have the scope it was created with")
+			accessTokens[scope] = accessToken
+		}
+
+		ginkgo.By("reading shopping lists with either token")
+		for _, accessToken := range accessTokens {
+			apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+			resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, accessToken.Token)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+
+		ginkgo.By("failing to create a shopping list with the read token")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Scripted list"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, accessTokens["shoppinglist:read"].Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("creating a shopping list with the write token")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, accessTokens["shoppinglist:write"].Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingList types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingList)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("failing to use routes without scopes with a token")
+		for _, endpoint := range []string{"/user/profile", "/user/auth/tokens", "/user/auth/sessions"} {
+			apiEndpoint = apiServerAPIprefix + endpoint
+			resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, accessTokens["shoppinglist:write"].Token)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+		}
+
+		ginkgo.By("listing the personal access tokens of the flatmate")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/tokens"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		accessTokensBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var accessTokensListed []types.UserAccessTokenSpec
+		gomega.Expect(json.Unmarshal(accessTokensBytes, &accessTokensListed)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(accessTokensListed)).To(gomega.Equal(2), "flatmate must have two personal access tokens")
+		for _, accessToken := range accessTokensListed {
+			gomega.Expect(accessToken.Token).To(gomega.Equal(""), "tokens must not be returned after being created")
+			gomega.Expect(accessToken.LastUsedTimestamp).ToNot(gomega.Equal(int64(0)), "tokens must have been used")
+		}
+
+		ginkgo.By("revoking the read token")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/tokens/" + accessTokens["shoppinglist:read"].ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, accessTokens["shoppinglist:read"].Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("failing to revoke a personal access token of another user account")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/tokens/" + accessTokens["shoppinglist:write"].ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("using admin features with an admin token")
+		accessTokenBytes, err = json.Marshal(types.UserAccessTokenSpec{Name: "Admin", Scopes: []string{"admin"}})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/tokens"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accessTokenBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accessTokenBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var adminAccessToken types.UserAccessTokenSpec
+		gomega.Expect(json.Unmarshal(accessTokenBytes, &adminAccessToken)).To(gomega.BeNil(), "failed to unmarshal")
+		apiEndpoint = apiServerAPIprefix + "/admin/users"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, adminAccessToken.Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, adminAccessToken.Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/tokens/" + adminAccessToken.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingList.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiServerAPIprefix+"/apps/shoppinglist/lists"), nil, accessTokens["shoppinglist:write"].Token)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "tokens of deleted accounts must be revoked")
+	})
+	ginkgo.It("should lock out second factor guesses after too many failures", func() {
+		maxEmailFailures := common.GetAuthMaxFailuresPerEmail()
+		if common.GetAppRealIPHeader() == "" || maxEmailFailures == 0 {
+			ginkgo.Skip("login lockouts need a real IP header to test without locking out other tests")
+		}
+
+		ginkgo.By("creating a flatmate account with two-factor authentication")
+		account := types.UserSpec{
+			Names:    "Guessing flatmate",
+			Email:    "guessing@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		post := func(endpoint string, body interface{}, ipAddress string) *http.Response {
+			bodyBytes, err := json.Marshal(body)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%v/%v/%v", apiServer, apiServerAPIprefix, endpoint), bytes.NewBuffer(bodyBytes))
+			gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set(common.GetAppRealIPHeader(), ipAddress)
+			resp, err := http.DefaultClient.Do(req)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			return resp
+		}
+		loginBody := types.UserSpec{Email: account.Email, Password: account.Password}
+		resp = post("user/auth", loginBody, "203.0.113.1")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		totpBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var totp types.UserTOTPSpec
+		gomega.Expect(json.Unmarshal(totpBytes, &totp)).To(gomega.BeNil(), "failed to unmarshal")
+		code, err := common.GenerateTOTPCode(totp.Secret, time.Now().Unix()/30)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to generate code")
+		codeBytes, err := json.Marshal(types.UserTOTPCode{Code: code})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/totp/enable"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), codeBytes, flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		login := func(ipAddress string) string {
+			resp := post("user/auth", loginBody, ipAddress)
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusAccepted), "api have return code of http.StatusAccepted")
+			return httpserver.GetHTTPresponseBodyContents(resp).Spec.(map[string]interface{})["token"].(string)
+		}
+
+		ginkgo.By("guessing a code with a new login each time")
+		for i := 1; i < maxEmailFailures; i++ {
+			ipAddress := fmt.Sprintf("203.0.113.%v", i+1)
+			resp = post("user/auth/challenge", types.UserAuthChallengeAnswer{Token: login(ipAddress), Code: "000000"}, ipAddress)
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+		}
+		challengeToken := login("203.0.113.100")
+		resp = post("user/auth/challenge", types.UserAuthChallengeAnswer{Token: challengeToken, Code: "000000"}, "203.0.113.100")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+		gomega.Expect(resp.Header.Get("Retry-After")).ToNot(gomega.Equal(""), "must say when to retry")
+
+		ginkgo.By("failing to log in or answer a challenge while locked out")
+		resp = post("user/auth", loginBody, "203.0.113.101")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+		resp = post("user/auth/challenge", types.UserAuthChallengeAnswer{Token: challengeToken, Code: "000000"}, "203.0.113.101")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusTooManyRequests), "api have return code of http.StatusTooManyRequests")
+
+		ginkgo.By("unlocking the account as an admin")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/lockouts"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		lockoutsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var lockouts []types.UserAuthLockoutSpec
+		gomega.Expect(json.Unmarshal(lockoutsBytes, &lockouts)).To(gomega.BeNil(), "failed to unmarshal")
+		var lockout types.UserAuthLockoutSpec
+		for _, l := range lockouts {
+			if l.Kind == "email" && l.Key == account.Email {
+				lockout = l
+			}
+		}
+		gomega.Expect(lockout.ID).ToNot(gomega.Equal(""), "the email must be listed as locked out")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/lockouts/" + lockout.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		login("203.0.113.102")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+
+	ginkgo.It("should manage custom groups with permissions", func() {
+		ginkgo.By("listing the permissions which groups are able to grant")
+		apiEndpoint := apiServerAPIprefix + "/admin/groups/permissions"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		permissionsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var permissions []types.GroupPermissionSpec
+		gomega.Expect(json.Unmarshal(permissionsBytes, &permissions)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(permissions)).To(gomega.Equal(len(groups.Permissions)), "all permissions must be listed")
+
+		ginkgo.By("checking the built-in groups have equivalent permissions")
+		apiEndpoint = apiServerAPIprefix + "/admin/groups"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		groupsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var groupList []types.GroupSpec
+		gomega.Expect(json.Unmarshal(groupsBytes, &groupList)).To(gomega.BeNil(), "failed to unmarshal")
+		builtInGroups := map[string]types.GroupSpec{}
+		for _, group := range groupList {
+			builtInGroups[group.Name] = group
+		}
+		gomega.Expect(builtInGroups[groups.GroupFlatmember].Permissions).To(gomega.ContainElements(groups.PermissionShoppingListWrite, groups.PermissionTasksWrite, groups.PermissionExpensesWrite), "flatmember group must be able to use the apps")
+		gomega.Expect(builtInGroups[groups.GroupFlatmember].Permissions).ToNot(gomega.ContainElement(groups.PermissionAdminUsers), "flatmember group must not be able to use admin features")
+		gomega.Expect(builtInGroups[groups.GroupAdmin].Permissions).To(gomega.ContainElements(groups.PermissionAdminSettings, groups.PermissionAdminUsers, groups.PermissionAdminGroups), "admin group must be able to use admin features")
+
+		ginkgo.By("failing to create invalid groups")
+		invalidGroups := []types.GroupSpec{
+			{Name: ""},
+			{Name: "Treasurer"},
+			{Name: "the treasurer"},
+			{Name: "treasurer", Permissions: []string{"everything"}},
+			{Name: groups.GroupFlatmember},
+		}
+		for _, group := range invalidGroups {
+			groupBytes, err := json.Marshal(group)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), groupBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		}
+
+		ginkgo.By("creating a treasurer group")
+		groupBytes, err := json.Marshal(types.GroupSpec{
+			Name:        "treasurer",
+			Description: "Looks after the flat's money",
+			Permissions: []string{groups.PermissionExpensesRead, groups.PermissionExpensesWrite},
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), groupBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		groupBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var treasurer types.GroupSpec
+		gomega.Expect(json.Unmarshal(groupBytes, &treasurer)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(treasurer.ID).ToNot(gomega.Equal(""), "group must have an id")
+
+		ginkgo.By("creating an account only in the treasurer group")
+		account := types.UserSpec{
+			Names:    "Treasurer",
+			Email:    "treasurer@example.com",
+			Password: "Password123!",
+			Groups:   []string{treasurer.Name},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/users"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var treasurerAccount types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &treasurerAccount)).To(gomega.BeNil(), "failed to unmarshal")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		treasurerJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("only being allowed to use what the group grants")
+		expectedStatusCodes := map[string]int{
+			"/apps/expenses/balances":  http.StatusOK,
+			"/apps/shoppinglist/lists": http.StatusForbidden,
+			"/apps/tasks/tasks":        http.StatusForbidden,
+			"/admin/users":             http.StatusForbidden,
+			"/admin/groups":            http.StatusForbidden,
+		}
+		for endpoint, statusCode := range expectedStatusCodes {
+			apiEndpoint = apiServerAPIprefix + endpoint
+			resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, treasurerJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(statusCode), "api have return code of "+http.StatusText(statusCode)+" for "+endpoint)
+		}
+		apiEndpoint = apiServerAPIprefix + "/user/can-i/permission/" + groups.PermissionExpensesWrite
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, treasurerJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Data).To(gomega.Equal(true), "treasurer must have the permission")
+
+		ginkgo.By("granting the treasurer group more permissions")
+		treasurer.Permissions = append(treasurer.Permissions, groups.PermissionShoppingListRead, groups.PermissionAdminUsers)
+		groupBytes, err = json.Marshal(treasurer)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/groups/" + treasurer.ID
+		resp, err = httpRequestWithHeader(http.MethodPut, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), groupBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, treasurerJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to give accounts permissions which the treasurer doesn't have")
+		escalations := []struct {
+			method  string
+			account types.UserSpec
+		}{
+			{method: http.MethodPost, account: types.UserSpec{Names: "Accomplice", Email: "accomplice@example.com", Password: "Password123!", Groups: []string{groups.GroupAdmin}}},
+			{method: http.MethodPut, account: types.UserSpec{ID: treasurerAccount.ID, Names: treasurerAccount.Names, Email: treasurerAccount.Email, Groups: []string{treasurer.Name, groups.GroupAdmin}}},
+			{method: http.MethodPatch, account: types.UserSpec{ID: treasurerAccount.ID, Groups: []string{treasurer.Name, groups.GroupAdmin}}},
+		}
+		for _, escalation := range escalations {
+			accountBytes, err = json.Marshal(escalation.account)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/admin/users"
+			if escalation.account.ID != "" {
+				apiEndpoint += "/" + escalation.account.ID
+			}
+			resp, err = httpRequestWithHeader(escalation.method, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, treasurerJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden for "+escalation.method)
+		}
+		apiEndpoint = apiServerAPIprefix + "/user/can-i/permission/" + groups.PermissionAdminGroups
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, treasurerJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Data).To(gomega.Equal(false), "treasurer must not have become an admin")
+
+		ginkgo.By("creating an account in a group whose permissions the treasurer has")
+		accountBytes, err = json.Marshal(types.UserSpec{Names: "Assistant treasurer", Email: "assistant@example.com", Password: "Password123!", Groups: []string{treasurer.Name}})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/users"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, treasurerJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		assistantID := httpserver.GetHTTPresponseBodyContents(resp).Spec.(map[string]interface{})["id"].(string)
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + assistantID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to rename or delete the built-in groups, or take admin permissions from the admin group")
+		flatmemberGroup := builtInGroups[groups.GroupFlatmember]
+		flatmemberGroup.Name = "flatmate"
+		adminGroup := builtInGroups[groups.GroupAdmin]
+		adminGroup.Permissions = []string{groups.PermissionAdminSettings}
+		for _, group := range []types.GroupSpec{flatmemberGroup, adminGroup} {
+			groupBytes, err = json.Marshal(group)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/admin/groups/" + group.ID
+			resp, err = httpRequestWithHeader(http.MethodPut, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), groupBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+			resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		}
+
+		ginkgo.By("failing to delete a group with members")
+		apiEndpoint = apiServerAPIprefix + "/admin/groups/" + treasurer.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("deleting the group once it has no members")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + treasurerAccount.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/admin/groups/" + treasurer.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+	})
+	ginkgo.It("should create guest accounts which expire", func() {
+		expiry := time.Now().Add(time.Hour * 24 * 7).Unix()
+
+		ginkgo.By("failing to create invalid guest accounts")
+		invalidAccounts := []types.UserSpec{
+			{Names: "Guest", Email: "guest@example.com", Password: "Password123!", Groups: []string{groups.GroupGuest}},
+			{Names: "Guest", Email: "guest@example.com", Password: "Password123!", Groups: []string{groups.GroupGuest, groups.GroupFlatmember}, ExpiryTimestamp: expiry},
+			{Names: "Guest", Email: "guest@example.com", Password: "Password123!", Groups: []string{groups.GroupGuest}, ExpiryTimestamp: time.Now().Add(-time.Hour).Unix()},
+		}
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		for _, account := range invalidAccounts {
+			accountBytes, err := json.Marshal(account)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		}
+
+		ginkgo.By("creating a guest account")
+		account := types.UserSpec{
+			Names:           "Guest",
+			Email:           "guest@example.com",
+			Password:        "Password123!",
+			Groups:          []string{groups.GroupGuest},
+			ExpiryTimestamp: expiry,
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var guestAccount types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &guestAccount)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(guestAccount.ExpiryTimestamp).To(gomega.Equal(expiry), "guest account must have the expiry")
+
+		ginkgo.By("listing the guest account with it's expiry")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + guestAccount.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var guestAccountFetched types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &guestAccountFetched)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(guestAccountFetched.ExpiryTimestamp).To(gomega.Equal(expiry), "guest account must have the expiry")
+
+		ginkgo.By("logging in as the guest")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		guestJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("only being allowed to use shopping lists")
+		expectedStatusCodes := map[string]int{
+			"/apps/shoppinglist/lists": http.StatusOK,
+			"/apps/tasks/tasks":        http.StatusForbidden,
+			"/apps/expenses/balances":  http.StatusForbidden,
+			"/users":                   http.StatusForbidden,
+			"/admin/users":             http.StatusForbidden,
+		}
+		for endpoint, statusCode := range expectedStatusCodes {
+			apiEndpoint = apiServerAPIprefix + endpoint
+			resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, guestJWT)
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(statusCode), "api have return code of "+http.StatusText(statusCode)+" for "+endpoint)
+		}
+
+		ginkgo.By("failing to move the expiry of the guest account into the past")
+		accountBytes, err = json.Marshal(types.UserSpec{ExpiryTimestamp: time.Now().Add(-time.Hour).Unix()})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + guestAccount.ID
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("being refused once the guest account expires, before it is disabled")
+		_, err = db.Exec(`update users set expiryTimestamp = $2 where id = $1`, guestAccount.ID, time.Now().Add(-time.Minute).Unix())
+		gomega.Expect(err).To(gomega.BeNil(), "failed to move the expiry of the guest account")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, guestJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusForbidden), "api have return code of http.StatusForbidden")
+
+		ginkgo.By("deleting the guest account")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + guestAccount.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+	ginkgo.It("should keep the data of each flat hosted by the instance apart", func() {
+		instanceSecret := os.Getenv("APP_INSTANCE_ADMIN_SECRET")
+		if instanceSecret == "" {
+			ginkgo.Skip("APP_INSTANCE_ADMIN_SECRET is not set")
+		}
+		instanceRequest := func(verb string, url string, data []byte) (resp *http.Response, err error) {
+			req, err := http.NewRequest(verb, url, bytes.NewBuffer(data))
+			gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+			req.Header.Set(httpserver.FlatTrackInstanceSecretHeader, instanceSecret)
+			req.Header.Set("Accept", "application/json")
+			return http.DefaultClient.Do(req)
+		}
+
+		ginkgo.By("failing to create a flat without the instance secret")
+		apiEndpoint := apiServerAPIprefix + "/instance/flats"
+		flatBytes, err := json.Marshal(types.FlatSpec{Name: "second-flat"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), flatBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("creating a second flat")
+		resp, err = instanceRequest(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), flatBytes)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		flatBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flat types.FlatSpec
+		gomega.Expect(json.Unmarshal(flatBytes, &flat)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(flat.ID).ToNot(gomega.Equal(""), "flat id must not be empty")
+		gomega.Expect(flat.Initialized).To(gomega.BeFalse(), "flat must not be initialized")
+
+		ginkgo.By("failing to create another flat with the same name")
+		resp, err = instanceRequest(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), flatBytes)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		flatServer := apiServer + flats.PathPrefix + flat.Name
+		ginkgo.By("registering the second flat")
+		secondRegistration := types.Registration{
+			Timezone: "Pacific/Auckland",
+			FlatName: "Second flat",
+			Language: "en_US",
+			User: types.UserSpec{
+				Names:    "Second admin account",
+				Email:    "secondadminaccount@example.com",
+				Password: "Password123!",
+			},
+		}
+		registrationBytes, err := json.Marshal(secondRegistration)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/admin/register"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), registrationBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		secondJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("failing to register the second flat again")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), registrationBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).ToNot(gomega.Equal(http.StatusCreated), "api must not have return code of http.StatusCreated")
+
+		ginkgo.By("having separate settings")
+		apiEndpoint = apiServerAPIprefix + "/system/flatName"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Spec.(string)).To(gomega.Equal(secondRegistration.FlatName), "flatName must be the one of the second flat")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(httpserver.GetHTTPresponseBodyContents(resp).Spec.(string)).To(gomega.Equal(regstrationForm.FlatName), "flatName must be the one of the default flat")
+
+		ginkgo.By("having separate user accounts")
+		apiEndpoint = apiServerAPIprefix + "/users"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		usersBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var secondFlatUsers []types.UserSpec
+		gomega.Expect(json.Unmarshal(usersBytes, &secondFlatUsers)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(secondFlatUsers)).To(gomega.Equal(1), "there must be one user account in the second flat")
+		gomega.Expect(secondFlatUsers[0].Email).To(gomega.Equal(secondRegistration.User.Email), "the user account must be the one of the second flat")
+
+		ginkgo.By("failing to use the account of one flat in another")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("failing to revoke a session of the second flat from the default flat")
+		apiEndpoint = apiServerAPIprefix + "/user/auth/sessions"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		sessionsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var secondFlatSessions []types.UserSessionSpec
+		gomega.Expect(json.Unmarshal(sessionsBytes, &secondFlatSessions)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(len(secondFlatSessions)).To(gomega.BeNumerically(">", 0), "the second flat admin must have a session")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + secondFlatUsers[0].ID + "/sessions/" + secondFlatSessions[0].ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+		apiEndpoint = apiServerAPIprefix + "/user/profile"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "the session of the second flat must not be revoked")
+
+		ginkgo.By("having separate shopping lists")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Second flat list"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), shoppingListBytes, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingList types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingList)).To(gomega.BeNil(), "failed to unmarshal")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingList.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).ToNot(gomega.Equal(http.StatusOK), "the list of the second flat must not be found in the default flat")
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", flatServer, apiEndpoint), nil, secondJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to use a flat which doesn't exist")
+		apiEndpoint = apiServerAPIprefix + "/system/initialized"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v%vdoes-not-exist/%v", apiServer, flats.PathPrefix, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("failing to delete a flat which has user accounts")
+		apiEndpoint = apiServerAPIprefix + "/instance/flats/" + flat.ID
+		resp, err = instanceRequest(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+	})
+	ginkgo.It("should back up and restore the instance", func() {
+		instanceSecret := os.Getenv("APP_INSTANCE_ADMIN_SECRET")
+		if instanceSecret == "" {
+			ginkgo.Skip("APP_INSTANCE_ADMIN_SECRET is not set")
+		}
+		instanceRequest := func(verb string, url string, data []byte) (resp *http.Response, err error) {
+			req, err := http.NewRequest(verb, url, bytes.NewBuffer(data))
+			gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+			req.Header.Set(httpserver.FlatTrackInstanceSecretHeader, instanceSecret)
+			req.Header.Set("Accept", "application/json")
+			return http.DefaultClient.Do(req)
+		}
+
+		ginkgo.By("creating a shopping list to back up")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Backed up list"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingList types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingList)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("backing up the instance")
+		apiEndpoint = apiServerAPIprefix + "/instance/backup"
+		resp, err = instanceRequest(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		archive, err := io.ReadAll(resp.Body)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to read backup")
+		lines := strings.Split(strings.TrimSpace(string(archive)), "\n")
+		var backup types.BackupSpec
+		gomega.Expect(json.Unmarshal([]byte(lines[0]), &backup)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(backup.Kind).To(gomega.Equal(backups.BackupKind), "backup must have it's kind")
+		version, _, err := migrationsManager.Version()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get migration version")
+		gomega.Expect(backup.MigrationVersion).To(gomega.Equal(version), "backup must have the migration version of the database")
+		tables := map[string]int{}
+		for _, line := range lines[1:] {
+			var row types.BackupRowSpec
+			gomega.Expect(json.Unmarshal([]byte(line), &row)).To(gomega.BeNil(), "failed to unmarshal")
+			tables[row.Table]++
+		}
+		for _, table := range []string{"flats", "users", "groups", "user_to_groups", "settings", "shopping_list"} {
+			gomega.Expect(tables[table]).To(gomega.BeNumerically(">", 0), "backup must have rows of "+table)
+		}
+
+		ginkgo.By("deleting the shopping list")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingList.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("failing to restore a backup from a different schema version")
+		mismatched := backup
+		mismatched.MigrationVersion = 1
+		mismatchedBytes, err := json.Marshal(mismatched)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/instance/restore"
+		resp, err = instanceRequest(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), append(mismatchedBytes, []byte("\n"+strings.Join(lines[1:], "\n"))...))
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("failing to restore something which isn't a backup")
+		resp, err = instanceRequest(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), []byte(`{"kind":"Something"}`))
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("restoring the backup")
+		resp, err = instanceRequest(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), archive)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("being signed out by the restore")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnauthorized), "api have return code of http.StatusUnauthorized")
+
+		ginkgo.By("logging in again")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: regstrationForm.User.Email, Password: regstrationForm.User.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		jwtToken = httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("having the shopping list back")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingList.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("deleting the shopping list")
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+	ginkgo.It("should write scheduled backups into file storage and only keep the latest", func() {
+		fileAccess, err := files.OpenFromEnv()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to open file storage")
+		if fileAccess == nil {
+			ginkgo.Skip("file storage is not configured")
+		}
+		backupsManager := backups.NewManager(db, migrationsManager, system.NewManager(db))
+		backupFiles := fileAccess.WithPrefix("backup")
+
+		ginkgo.By("writing a backup")
+		scheduled := backupsManager.Scheduled(fileAccess, 1, 0)
+		gomega.Expect(scheduled()).To(gomega.BeNil(), "failed to write scheduled backup")
+
+		ginkgo.By("reporting the backup with the version")
+		apiEndpoint := apiServerAPIprefix + "/system/version"
+		resp, err := httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		versionBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Data)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var version types.SystemVersion
+		gomega.Expect(json.Unmarshal(versionBytes, &version)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(version.BackupLastRun.State).To(gomega.BeEquivalentTo(types.SchedulerRunStateComplete), "backup must be complete")
+		gomega.Expect(version.BackupLastRun.Size).To(gomega.BeNumerically(">", 0), "backup must not be empty")
+		names, err := backupFiles.List()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list backups")
+		gomega.Expect(names).To(gomega.HaveLen(1), "there must be one backup")
+		gomega.Expect("backup-"+names[0]).To(gomega.Equal(version.BackupLastRun.Name), "the backup must be the one reported")
+
+		ginkgo.By("writing another backup on the same day")
+		time.Sleep(time.Second)
+		gomega.Expect(scheduled()).To(gomega.BeNil(), "failed to write scheduled backup")
+		namesAfter, err := backupFiles.List()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list backups")
+		gomega.Expect(namesAfter).To(gomega.HaveLen(1), "only the latest backup of the day must be kept")
+		gomega.Expect(namesAfter[0]).ToNot(gomega.Equal(names[0]), "the older backup must be removed")
+
+		ginkgo.By("removing the backup")
+		gomega.Expect(backupFiles.Delete(namesAfter[0])).To(gomega.BeNil(), "failed to delete backup")
+	})
+	ginkgo.It("should copy the instance into another database", func() {
+		dir, err := os.MkdirTemp("", "flattrack-copy")
+		gomega.Expect(err).To(gomega.BeNil(), "failed to create temporary directory")
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+		destinationDB, err := database.OpenConnectionString("sqlite://" + path.Join(dir, "flattrack.db"))
+		gomega.Expect(err).To(gomega.BeNil(), "failed to open destination database")
+		defer func() {
+			_ = destinationDB.Close()
+		}()
+		if err := database.Ping(destinationDB); err != nil {
+			ginkgo.Skip("SQLite databases are not able to be opened: " + err.Error())
+		}
+		destinationMigrations := migrations.NewManager(destinationDB)
+		destination := backups.NewManager(destinationDB, destinationMigrations, system.NewManager(destinationDB))
+		source := backups.NewManager(db, migrationsManager, system.NewManager(db))
+
+		ginkgo.By("failing to copy into a database with a different schema version")
+		_, err = source.CopyTo(destination)
+		gomega.Expect(err).To(gomega.MatchError(backups.ErrCopyMigrationVersionMismatch), "copy must fail when the versions differ")
+
+		ginkgo.By("copying the instance")
+		gomega.Expect(destinationMigrations.Migrate()).To(gomega.BeNil(), "failed to migrate destination database")
+		counts, err := source.CopyTo(destination)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to copy database")
+		for _, table := range []string{"system", "flats", "users", "groups", "user_to_groups", "settings"} {
+			gomega.Expect(counts[table]).To(gomega.BeNumerically(">", 0), "copy must have rows of "+table)
+		}
+
+		ginkgo.By("having the same instance and user accounts in the copy")
+		sourceUUID, err := system.NewManager(db).GetInstanceUUID()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get instance uuid")
+		destinationUUID, err := system.NewManager(destinationDB).GetInstanceUUID()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get instance uuid")
+		gomega.Expect(destinationUUID).To(gomega.Equal(sourceUUID), "copy must have the instance uuid")
+		defaultFlat, err := flatsManager.GetDefault()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the default flat")
+		user, err := users.NewManager(destinationDB).ForFlat(defaultFlat.ID).GetByEmail(regstrationForm.User.Email, false)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get user account from copy")
+		gomega.Expect(user.Email).To(gomega.Equal(regstrationForm.User.Email), "copy must have the user account")
+
+		ginkgo.By("failing to copy into a database which already has user accounts")
+		_, err = source.CopyTo(destination)
+		gomega.Expect(err).To(gomega.MatchError(backups.ErrCopyDestinationNotEmpty), "copy must not replace user accounts")
+	})
+	ginkgo.It("should attach photos to shopping items and receipts to completed lists", func() {
+		fileAccess, err := files.OpenFromEnv()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to open file storage")
+		if fileAccess == nil {
+			ginkgo.Skip("file storage is not configured")
+		}
+		defaultFlat, err := flatsManager.GetDefault()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the default flat")
+		shoppinglistManager := shoppinglist.NewManager(db, settingsManager.ForFlat(defaultFlat.ID), fileAccess).ForFlat(defaultFlat.ID)
+
+		var photo bytes.Buffer
+		photoImage := image.NewRGBA(image.Rect(0, 0, 640, 480))
+		for x := range 640 {
+			photoImage.Set(x, x%480, color.RGBA{R: 255, A: 255})
+		}
+		gomega.Expect(png.Encode(&photo, photoImage)).To(gomega.BeNil(), "failed to encode photo")
+
+		ginkgo.By("creating a shopping list")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "My list"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingListCreated types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingListCreated)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("creating a shopping item")
+		shoppingItemBytes, err := json.Marshal(types.ShoppingItemSpec{Name: "Bananas", Quantity: 1})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingItemBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingItemBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingItemCreated types.ShoppingItemSpec
+		gomega.Expect(json.Unmarshal(shoppingItemBytes, &shoppingItemCreated)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("refusing a photo which isn't an image")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items/" + shoppingItemCreated.ID + "/photo"
+		resp, err = httpUploadWithHeader(fmt.Sprintf("%v/%v", apiServer, apiEndpoint), "bananas.txt", []byte("bananas"), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusUnsupportedMediaType), "api have return code of http.StatusUnsupportedMediaType")
+
+		ginkgo.By("uploading a photo of the shopping item")
+		resp, err = httpUploadWithHeader(fmt.Sprintf("%v/%v", apiServer, apiEndpoint), "bananas.png", photo.Bytes(), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		attachmentBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var photoCreated types.ShoppingAttachmentSpec
+		gomega.Expect(json.Unmarshal(attachmentBytes, &photoCreated)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(photoCreated.ContentType).To(gomega.Equal("image/png"), "photo must be a PNG")
+		gomega.Expect(photoCreated.Size).To(gomega.Equal(int64(photo.Len())), "photo size must match the upload")
+		gomega.Expect(photoCreated.HasThumbnail).To(gomega.Equal(true), "photo must have a thumbnail")
+
+		ginkgo.By("downloading the photo")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/attachments/" + photoCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(resp.Header.Get("Content-Type")).To(gomega.Equal("image/png"), "photo must be served as a PNG")
+		downloaded, err := io.ReadAll(resp.Body)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to read photo")
+		gomega.Expect(downloaded).To(gomega.Equal(photo.Bytes()), "photo must match the upload")
+
+		ginkgo.By("downloading the thumbnail of the photo")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/attachments/" + photoCreated.ID + "/thumbnail"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		thumbnail, _, err := image.DecodeConfig(resp.Body)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to decode thumbnail")
+		gomega.Expect(thumbnail.Width).To(gomega.Equal(320), "thumbnail must be scaled to fit")
+		gomega.Expect(thumbnail.Height).To(gomega.Equal(240), "thumbnail must keep the aspect ratio")
+
+		ginkgo.By("refusing a receipt for a list which isn't completed")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/receipts"
+		resp, err = httpUploadWithHeader(fmt.Sprintf("%v/%v", apiServer, apiEndpoint), "receipt.png", photo.Bytes(), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("uploading a receipt for the completed list")
+		completedBytes, err := json.Marshal(types.ShoppingListSpec{Completed: true})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/completed"
+		resp, err = httpRequestWithHeader(http.MethodPatch, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), completedBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/receipts"
+		resp, err = httpUploadWithHeader(fmt.Sprintf("%v/%v", apiServer, apiEndpoint), "receipt.png", photo.Bytes(), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+
+		ginkgo.By("listing the attachments of the list")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/attachments"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		attachmentsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var attachments []types.ShoppingAttachmentSpec
+		gomega.Expect(json.Unmarshal(attachmentsBytes, &attachments)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(attachments).To(gomega.HaveLen(2), "there must be a photo and a receipt")
+
+		ginkgo.By("removing the photo once the item is deleted")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items/" + shoppingItemCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(shoppinglistManager.ShoppingList().DeleteCleanup()).To(gomega.BeNil(), "failed to clean up shopping lists")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/attachments/" + photoCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusNotFound), "api have return code of http.StatusNotFound")
+
+		ginkgo.By("deleting the shopping list and it's receipt")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(shoppinglistManager.ShoppingList().DeleteCleanup()).To(gomega.BeNil(), "failed to clean up shopping lists")
+		remaining, err := shoppinglistManager.ShoppingAttachment().List(shoppingListCreated.ID)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list attachments")
+		gomega.Expect(remaining).To(gomega.BeEmpty(), "the receipt must be removed")
+	})
+	ginkgo.It("should keep deleted user accounts which uploaded attachments when removing unreferenced ones", func() {
+		fileAccess, err := files.OpenFromEnv()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to open file storage")
+		if fileAccess == nil {
+			ginkgo.Skip("file storage is not configured")
+		}
+
+		var photo bytes.Buffer
+		gomega.Expect(png.Encode(&photo, image.NewRGBA(image.Rect(0, 0, 64, 64)))).To(gomega.BeNil(), "failed to encode photo")
+
+		ginkgo.By("creating a flatmate account")
+		account := types.UserSpec{
+			Names:    "Attachment flatmate",
+			Email:    "attachment@example.com",
+			Password: "Password123!",
+			Groups:   []string{"flatmember"},
+		}
+		accountBytes, err := json.Marshal(account)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/admin/users"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), accountBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		accountBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var flatmate types.UserSpec
+		gomega.Expect(json.Unmarshal(accountBytes, &flatmate)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("logging in as the flatmate")
+		loginBytes, err := json.Marshal(types.UserSpec{Email: account.Email, Password: account.Password})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/user/auth"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), loginBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		flatmateJWT := httpserver.GetHTTPresponseBodyContents(resp).Data.(string)
+
+		ginkgo.By("creating a shopping list with an item")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Attachments"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingListCreated types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingListCreated)).To(gomega.BeNil(), "failed to unmarshal")
+		shoppingItemBytes, err := json.Marshal(types.ShoppingItemSpec{Name: "Bananas", Quantity: 1})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingItemBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingItemBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingItemCreated types.ShoppingItemSpec
+		gomega.Expect(json.Unmarshal(shoppingItemBytes, &shoppingItemCreated)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("uploading a photo of the shopping item as the flatmate")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items/" + shoppingItemCreated.ID + "/photo"
+		resp, err = httpUploadWithHeader(fmt.Sprintf("%v/%v", apiServer, apiEndpoint), "bananas.png", photo.Bytes(), flatmateJWT)
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+
+		ginkgo.By("deleting the flatmate account")
+		apiEndpoint = apiServerAPIprefix + "/admin/users/" + flatmate.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+
+		ginkgo.By("removing unreferenced deleted user accounts")
+		gomega.Expect(usersManager.RemoveUnreferencedDeletedUsers()).To(gomega.BeNil(), "failed to remove unreferenced deleted user accounts")
+		userAccount, err := usersManager.GetByID(flatmate.ID, false)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get user account")
+		gomega.Expect(userAccount.ID).To(gomega.Equal(flatmate.ID), "user account which uploaded an attachment must be kept")
+
+		ginkgo.By("cleaning up")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID
+		resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+	})
+	ginkgo.It("should export and import shopping lists as CSV and JSON", func() {
+		ginkgo.By("creating a shopping list with items")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{Name: "Weekly"})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint := apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingListCreated types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingListCreated)).To(gomega.BeNil(), "failed to unmarshal")
+		shoppingItems := []types.ShoppingItemSpec{
+			{Name: "Apples", Quantity: 6, Price: 0.5, Tag: "Fruit", Notes: "Green, please"},
+			{Name: "Milk", Quantity: 2, Price: 3.2, Tag: "Dairy", Obtained: true},
+		}
+		for _, shoppingItem := range shoppingItems {
+			shoppingItemBytes, err := json.Marshal(shoppingItem)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+			apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items"
+			resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingItemBytes, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		}
+
+		ginkgo.By("exporting the shopping list as CSV")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/export?format=csv"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		gomega.Expect(resp.Header.Get("Content-Type")).To(gomega.HavePrefix("text/csv"), "export must be a CSV file")
+		exported, err := io.ReadAll(resp.Body)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to read export")
+		gomega.Expect(string(exported)).To(gomega.Equal("name,quantity,price,tag,notes,obtained\n"+
+			"Milk,2,3.2,Dairy,,true\n"+
+			"Apples,6,0.5,Fruit,\"Green, please\",false\n"), "export must list every item")
+
+		ginkgo.By("exporting the shopping list as JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/export?format=json"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		var exportedItems []types.ShoppingItemFileSpec
+		gomega.Expect(json.NewDecoder(resp.Body).Decode(&exportedItems)).To(gomega.BeNil(), "failed to decode export")
+		gomega.Expect(exportedItems).To(gomega.HaveLen(len(shoppingItems)), "export must list every item")
+
+		ginkgo.By("checking an import with invalid rows, without changing the list")
+		invalidCSV := []byte("Name,Quantity,Price\nBread,1,4\n,2,1\nEggs,a dozen,7\n")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/import?format=csv&dryRun=true"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), invalidCSV, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+		importBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var importChecked types.ShoppingListImportSpec
+		gomega.Expect(json.Unmarshal(importBytes, &importChecked)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(importChecked.Errors).To(gomega.HaveLen(2), "the invalid rows must be reported")
+		gomega.Expect(importChecked.Errors[0].Row).To(gomega.Equal(3), "the row without a name must be reported")
+		gomega.Expect(importChecked.Errors[1].Row).To(gomega.Equal(4), "the row with an invalid quantity must be reported")
+
+		ginkgo.By("importing nothing when a row is invalid")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/import?format=csv"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), invalidCSV, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("importing the exported CSV into a new shopping list")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/import?format=csv&name=Weekly%20copy"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), exported, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		importBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var imported types.ShoppingListImportSpec
+		gomega.Expect(json.Unmarshal(importBytes, &imported)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(imported.List.ID).ToNot(gomega.Equal(""), "the list must be created")
+		gomega.Expect(imported.List.Name).To(gomega.Equal("Weekly copy"), "the list must be named")
+		gomega.Expect(imported.Items).To(gomega.Equal(len(shoppingItems)), "every item must be imported")
+		importedListID := imported.List.ID
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + imported.List.ID + "/export?format=csv"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		reexported, err := io.ReadAll(resp.Body)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to read export")
+		gomega.Expect(reexported).To(gomega.Equal(exported), "the imported list must match the exported list")
+
+		ginkgo.By("importing JSON into the existing shopping list")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/import?format=json"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), []byte(`[{"name": "Bread", "price": 4}]`), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		importBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		gomega.Expect(json.Unmarshal(importBytes, &imported)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(imported.List.Count).To(gomega.Equal(len(shoppingItems)+1), "the item must be added to the list")
+
+		ginkgo.By("deleting the shopping lists")
+		for _, id := range []string{shoppingListCreated.ID, importedListID} {
+			apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + id
+			resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+	})
+	ginkgo.It("should create a shopping list from a template with many items", func() {
+		ginkgo.By("importing a template with many items")
+		var templateCSV strings.Builder
+		templateCSV.WriteString("name,quantity,price,tag\n")
+		for i := range shoppinglist.ShoppingListImportMaxItems {
+			fmt.Fprintf(&templateCSV, "Item %v,%v,%v,Tag %v\n", i, i%5+1, i%7, i%10)
+		}
+		apiEndpoint := apiServerAPIprefix + "/apps/shoppinglist/lists/import?format=csv&name=Big%20template"
+		resp, err := httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), []byte(templateCSV.String()), "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		importBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var imported types.ShoppingListImportSpec
+		gomega.Expect(json.Unmarshal(importBytes, &imported)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(imported.List.Count).To(gomega.Equal(shoppinglist.ShoppingListImportMaxItems), "every item must be imported")
+
+		ginkgo.By("creating a shopping list from the template")
+		shoppingListBytes, err := json.Marshal(types.ShoppingListSpec{
+			Name:       "From big template",
+			TemplateID: imported.List.ID,
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		started := time.Now()
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusCreated), "api have return code of http.StatusCreated")
+		gomega.Expect(time.Since(started)).To(gomega.BeNumerically("<", 5*time.Second), "the list must be created quickly")
+		shoppingListBytes, err = json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).Spec)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var shoppingListCreated types.ShoppingListSpec
+		gomega.Expect(json.Unmarshal(shoppingListBytes, &shoppingListCreated)).To(gomega.BeNil(), "failed to unmarshal")
+
+		ginkgo.By("listing the items copied from the template")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + shoppingListCreated.ID + "/items"
+		resp, err = httpRequestWithHeader(http.MethodGet, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		itemsBytes, err := json.Marshal(httpserver.GetHTTPresponseBodyContents(resp).List)
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		var items []types.ShoppingItemSpec
+		gomega.Expect(json.Unmarshal(itemsBytes, &items)).To(gomega.BeNil(), "failed to unmarshal")
+		gomega.Expect(items).To(gomega.HaveLen(shoppinglist.ShoppingListImportMaxItems), "every item must be copied")
+		for _, item := range items {
+			gomega.Expect(item.TemplateID).To(gomega.Equal(imported.List.ID), "copied items must reference the template")
+			gomega.Expect(item.ListID).To(gomega.Equal(shoppingListCreated.ID), "copied items must be in the new list")
+		}
+
+		ginkgo.By("creating nothing when the template doesn't exist")
+		shoppingListBytes, err = json.Marshal(types.ShoppingListSpec{
+			Name:       "From missing template",
+			TemplateID: "does-not-exist",
+		})
+		gomega.Expect(err).To(gomega.BeNil(), "failed to marshal to JSON")
+		apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists"
+		resp, err = httpRequestWithHeader(http.MethodPost, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), shoppingListBytes, "")
+		gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+		gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusBadRequest), "api have return code of http.StatusBadRequest")
+
+		ginkgo.By("deleting the shopping lists")
+		for _, id := range []string{shoppingListCreated.ID, imported.List.ID} {
+			apiEndpoint = apiServerAPIprefix + "/apps/shoppinglist/lists/" + id
+			resp, err = httpRequestWithHeader(http.MethodDelete, fmt.Sprintf("%v/%v", apiServer, apiEndpoint), nil, "")
+			gomega.Expect(err).To(gomega.BeNil(), "Request should not return an error")
+			gomega.Expect(resp.StatusCode).To(gomega.Equal(http.StatusOK), "api have return code of http.StatusOK")
+		}
+	})
+
+	ginkgo.It("should cancel queries along with their context", func() {
+		ginkgo.By("listing users with a cancelled context")
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := usersManager.WithContext(ctx).List(false, types.UserSelector{})
+		gomega.Expect(err).To(gomega.MatchError(context.Canceled), "queries must not run once their context is cancelled")
+
+		ginkgo.By("listing shopping lists after the deadline of a context")
+		ctx, cancel = context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+		defaultFlat, err := flatsManager.GetDefault()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the default flat")
+		shoppingListManager := shoppinglist.NewManager(db, settingsManager, nil).ForFlat(defaultFlat.ID)
+		_, err = shoppingListManager.WithContext(ctx).ShoppingList().List(types.ShoppingListOptions{})
+		gomega.Expect(err).To(gomega.MatchError(context.DeadlineExceeded), "queries must not run once their context is past it's deadline")
+
+		ginkgo.By("listing tasks and flats with a cancelled context")
+		ctx, cancel = context.WithCancel(context.Background())
+		cancel()
+		_, err = tasksManager.WithContext(ctx).Task().List()
+		gomega.Expect(err).To(gomega.MatchError(context.Canceled), "task queries must not run once their context is cancelled")
+		_, err = flatsManager.WithContext(ctx).List()
+		gomega.Expect(err).To(gomega.MatchError(context.Canceled), "flat queries must not run once their context is cancelled")
+
+		ginkgo.By("listing users without a context")
+		userAccounts, err := usersManager.List(false, types.UserSelector{})
+		gomega.Expect(err).To(gomega.BeNil(), "queries must run without a context")
+		gomega.Expect(userAccounts).ToNot(gomega.BeEmpty(), "users must be listed")
+	})
+
+	ginkgo.It("should scan every column of the tables which are read by name", func() {
+		for table, columns := range map[string][]string{
+			"users":             users.UserColumns.Names(),
+			"groups":            groups.GroupColumns.Names(),
+			"shopping_list":     shoppinglist.ShoppingListColumns.Names(),
+			"shopping_item":     shoppinglist.ShoppingItemColumns.Names(),
+			"shopping_list_tag": shoppinglist.ShoppingTagColumns.Names(),
+		} {
+			ginkgo.By("comparing the columns of " + table)
+			tableColumns, err := database.TableColumns(db, table)
+			gomega.Expect(err).To(gomega.BeNil(), "failed to list the columns of the table")
+			scanned := []string{}
+			for _, name := range columns {
+				scanned = append(scanned, strings.ToLower(name))
+			}
+			gomega.Expect(scanned).To(gomega.ConsistOf(tableColumns), "every column of '%v' must be scanned, including those added by migrations", table)
+		}
+	})
+
+	ginkgo.It("should list, roll back and reapply migrations", func() {
+		ginkgo.By("listing the migrations of a migrated database")
+		version, dirty, err := migrationsManager.Version()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the database version")
+		gomega.Expect(dirty).To(gomega.BeFalse(), "the database must not be dirty")
+		migrationsList, err := migrationsManager.List()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list migrations")
+		gomega.Expect(migrationsList).ToNot(gomega.BeEmpty(), "migrations must be listed")
+		gomega.Expect(migrationsList[len(migrationsList)-1].Version).To(gomega.Equal(version), "the database must be at the latest migration")
+		pending, err := migrationsManager.Pending()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list pending migrations")
+		gomega.Expect(pending).To(gomega.BeEmpty(), "no migrations must be pending")
+
+		ginkgo.By("rolling back the latest migration")
+		gomega.Expect(migrationsManager.Rollback()).To(gomega.BeNil(), "failed to roll back")
+		pending, err = migrationsManager.Pending()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to list pending migrations")
+		gomega.Expect(pending).To(gomega.HaveLen(1), "the rolled back migration must be pending")
+		gomega.Expect(pending[0].Version).To(gomega.Equal(version), "the rolled back migration must be the latest")
+
+		ginkgo.By("migrating to the latest version")
+		gomega.Expect(migrationsManager.MigrateTo(version)).To(gomega.BeNil(), "failed to migrate to the latest version")
+		versionMigrated, _, err := migrationsManager.Version()
+		gomega.Expect(err).To(gomega.BeNil(), "failed to get the database version")
+		gomega.Expect(versionMigrated).To(gomega.Equal(version), "the database must be at the latest migration again")
+
+		ginkgo.By("migrating to a version which doesn't exist")
+		err = migrationsManager.MigrateTo(123)
+		gomega.Expect(err).To(gomega.MatchError(migrations.ErrMigrationVersionNotFound), "unknown versions must not be migrated to")
+
+		ginkgo.By("forcing the version of a clean database")
+		err = migrationsManager.Force(version)
+		gomega.Expect(err).To(gomega.MatchError(migrations.ErrMigrationNotDirty), "only dirty databases must be forced")
+	})
+})
+
+func httpRequestWithHeader(verb string, url string, data []byte, jwt string) (resp *http.Response, err error) {
+	if jwt == "" {
+		jwt = jwtToken
+	}
+	req, err := http.NewRequest(verb, url, bytes.NewBuffer(data))
+	gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
+	req.Header.Set("Authorization", "bearer "+jwt)
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{}
+	resp, err = client.Do(req)
+	return resp, err
+}
+
+func httpUploadWithHeader(url string, name string, data []byte, jwt string) (resp *http.Response, err error) {
+	if jwt == "" {
+		jwt = jwtToken
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	gomega.Expect(err).To(gomega.BeNil(), "multipart form should not have error")
+	_, err = part.Write(data)
+	gomega.Expect(err).To(gomega.BeNil(), "multipart form should not have error")
+	gomega.Expect(writer.Close()).To(gomega.BeNil(), "multipart form should not have error")
+	req, err := http.NewRequest(http.MethodPost, url, &body)
 	gomega.Expect(err).To(gomega.BeNil(), "http request should not have error")
 	req.Header.Set("Authorization", "bearer "+jwt)
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", writer.FormDataContentType())
 	client := &http.Client{}
 	resp, err = client.Do(req)
 	return resp, err
@@ -0,0 +1,259 @@
+/*
+  oidcprovider
+    a stand-in OpenID Connect identity provider for testing single sign-on
+*/
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the Affero GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the Affero GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package oidcprovider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// User ...
+// the identity which the provider logs everyone in as
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// authorization ...
+// a login which is waiting for it's code to be exchanged
+type authorization struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiry        time.Time
+}
+
+// Provider ...
+// an identity provider which approves every login as the current user
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+
+	basePath string
+	key      *rsa.PrivateKey
+	keyID    string
+
+	lock  sync.Mutex
+	user  User
+	codes map[string]authorization
+}
+
+// NewProvider ...
+// creates an identity provider with a new signing key
+func NewProvider(issuer string, clientID string, clientSecret string) (*Provider, error) {
+	issuerURL, err := url.Parse(issuer)
+	if err != nil {
+		return nil, err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	keyHash := sha256.Sum256(key.N.Bytes())
+	return &Provider{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		basePath:     strings.TrimSuffix(issuerURL.Path, "/"),
+		key:          key,
+		keyID:        base64.RawURLEncoding.EncodeToString(keyHash[:8]),
+		codes:        map[string]authorization{},
+	}, nil
+}
+
+// SetUser ...
+// sets who logins are approved as
+func (p *Provider) SetUser(user User) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.user = user
+}
+
+// Handler ...
+// returns the endpoints of the identity provider
+func (p *Provider) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+p.basePath+"/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET "+p.basePath+"/keys", p.keys)
+	mux.HandleFunc("GET "+p.basePath+"/authorize", p.authorize)
+	mux.HandleFunc("POST "+p.basePath+"/token", p.token)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, output any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(output)
+}
+
+func tokenError(w http.ResponseWriter, code int, tokenError string, description string) {
+	writeJSON(w, code, map[string]string{
+		"error":             tokenError,
+		"error_description": description,
+	})
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	base := strings.TrimSuffix(p.Issuer, "/")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                base + "/authorize",
+		"token_endpoint":                        base + "/token",
+		"jwks_uri":                              base + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+func (p *Provider) keys(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": p.keyID,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			},
+		},
+	})
+}
+
+// authorize ...
+// approves a login as the current user, sending the browser back with a code
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if query.Get("client_id") != p.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" ||
+		query.Get("code_challenge") == "" ||
+		!strings.Contains(" "+query.Get("scope")+" ", " openid ") {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := rand.Text()
+	p.lock.Lock()
+	p.codes[code] = authorization{
+		user:          p.user,
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiry:        time.Now().Add(time.Minute),
+	}
+	p.lock.Unlock()
+	redirectQuery := redirectURI.Query()
+	redirectQuery.Set("code", code)
+	redirectQuery.Set("state", query.Get("state"))
+	redirectURI.RawQuery = redirectQuery.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token ...
+// exchanges a code for a signed ID token, checking the client and code verifier
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	if clientID != p.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client", "unknown client or secret")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+	code := r.PostForm.Get("code")
+	p.lock.Lock()
+	auth, found := p.codes[code]
+	delete(p.codes, code)
+	p.lock.Unlock()
+	if !found || time.Now().After(auth.expiry) || r.PostForm.Get("redirect_uri") != auth.redirectURI {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+		return
+	}
+	verifierHash := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifierHash[:]) != auth.codeChallenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant", "code verifier does not match")
+		return
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.Issuer,
+		"sub":            auth.user.Subject,
+		"aud":            p.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          auth.nonce,
+		"email":          auth.user.Email,
+		"email_verified": auth.user.EmailVerified,
+		"name":           auth.user.Name,
+		"groups":         auth.user.Groups,
+	})
+	token.Header["kid"] = p.keyID
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("failed to sign token: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}